@@ -2,20 +2,45 @@ package bootstrap
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	grpcApi "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/grpc"
+	httpApi "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/http"
+	"github.com/adverax/metacrm/apps/backend/iam/services/access"
+	"github.com/adverax/metacrm/apps/backend/iam/services/auth"
+	"github.com/adverax/metacrm/apps/backend/iam/services/cache"
+	"github.com/adverax/metacrm/apps/backend/iam/services/groups"
+	"github.com/adverax/metacrm/apps/backend/iam/services/objects"
+	"github.com/adverax/metacrm/apps/backend/iam/services/outbox"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"github.com/adverax/metacrm/apps/backend/iam/services/principals"
+	"github.com/adverax/metacrm/apps/backend/iam/services/roles"
+	"github.com/adverax/metacrm/apps/backend/iam/services/snapshots"
+	"github.com/adverax/metacrm/apps/backend/iam/services/territories"
+	"github.com/adverax/metacrm/apps/backend/iam/services/users"
+	"github.com/adverax/metacrm/apps/backend/iam/services/webhooks"
+	"github.com/adverax/metacrm/pkg/broker"
+	kafkaBroker "github.com/adverax/metacrm/pkg/broker/kafka"
 	"github.com/adverax/metacrm/pkg/database/sql"
 	"github.com/adverax/metacrm/pkg/di"
+	"github.com/adverax/metacrm/pkg/jwt"
 	"github.com/adverax/metacrm/pkg/log"
 	fileExporter "github.com/adverax/metacrm/pkg/log/exporters/file"
 	jsonFormatter "github.com/adverax/metacrm/pkg/log/formatters/json"
 	templateFormatter "github.com/adverax/metacrm/pkg/log/formatters/template"
 	"github.com/adverax/metacrm/pkg/log/purifiers"
+	"github.com/adverax/metacrm/pkg/webhook"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -170,6 +195,29 @@ var (
 				WithDatabase(cfg.DB.Database).
 				WithErrorBuilder(ComponentDatabaseErrorBuilder(ctx)).
 				WithQueryTracer(ComponentDatabaseQueryLogger(ctx)).
+				WithIdentityRequired(true).
+				Build()
+		},
+		di.WithComponentInit(func(ctx context.Context, instance sql.DB) error {
+			return checkRowLevelSecurity(ctx, instance)
+		}),
+		di.WithComponentDone(func(ctx context.Context, instance sql.DB) {
+			instance.Close()
+		}),
+	)
+
+	// ComponentMigrationDatabase - connection of the schema owner, used only by the migrations
+	ComponentMigrationDatabase = di.NewComponent(
+		"migration-database",
+		func(ctx context.Context) (sql.DB, error) {
+			cfg := ComponentConfig(ctx)
+			return sql.NewBuilder().
+				WithHost(cfg.MigrationDB.Host).
+				WithPort(cfg.MigrationDB.Port).
+				WithUser(cfg.MigrationDB.User).
+				WithPassword(cfg.MigrationDB.Password).
+				WithDatabase(cfg.MigrationDB.Database).
+				WithErrorBuilder(ComponentDatabaseErrorBuilder(ctx)).
 				Build()
 		},
 		di.WithComponentDone(func(ctx context.Context, instance sql.DB) {
@@ -177,10 +225,522 @@ var (
 		}),
 	)
 
+	ComponentDatabaseListener = di.NewComponent(
+		"database-listener",
+		func(ctx context.Context) (*sql.Listener, error) {
+			if !ComponentConfig(ctx).Outbox.Listen {
+				return nil, nil
+			}
+
+			return sql.NewListenerBuilder().
+				WithPool(ComponentDatabase(ctx).Pool()).
+				WithChannels(outbox.Channel, webhooks.Channel).
+				WithLogger(ComponentLogger(ctx)).
+				Build()
+		},
+		di.WithComponentInit(func(ctx context.Context, instance *sql.Listener) error {
+			if instance == nil {
+				return nil
+			}
+			return instance.Start(ctx)
+		}),
+		di.WithComponentDone(func(ctx context.Context, instance *sql.Listener) {
+			if instance != nil {
+				instance.Stop()
+			}
+		}),
+	)
+
+	ComponentUserService = di.NewComponent(
+		"user-service",
+		func(ctx context.Context) (*users.Service, error) {
+			return users.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentRoleService = di.NewComponent(
+		"role-service",
+		func(ctx context.Context) (*roles.Service, error) {
+			return roles.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentTerritoryService = di.NewComponent(
+		"territory-service",
+		func(ctx context.Context) (*territories.Service, error) {
+			return territories.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentGroupService = di.NewComponent(
+		"group-service",
+		func(ctx context.Context) (*groups.Service, error) {
+			return groups.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentPrincipalService = di.NewComponent(
+		"principal-service",
+		func(ctx context.Context) (*principals.Service, error) {
+			return principals.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentObjectService = di.NewComponent(
+		"object-service",
+		func(ctx context.Context) (*objects.Service, error) {
+			return objects.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentPermissionService = di.NewComponent(
+		"permission-service",
+		func(ctx context.Context) (*permissions.Service, error) {
+			return permissions.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentAccessService = di.NewComponent(
+		"access-service",
+		func(ctx context.Context) (*access.Service, error) {
+			return access.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentSnapshotService = di.NewComponent(
+		"snapshot-service",
+		func(ctx context.Context) (*snapshots.Service, error) {
+			return snapshots.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentChangeFeed = di.NewComponent(
+		"change-feed",
+		func(ctx context.Context) (*snapshots.Feed, error) {
+			cfg := ComponentConfig(ctx)
+			return snapshots.NewFeed(
+				ComponentDatabase(ctx),
+				ComponentDatabaseListener(ctx),
+				snapshots.FeedOptions{
+					PollInterval: cfg.Feed.PollInterval,
+					BatchSize:    cfg.Feed.BatchSize,
+				},
+			), nil
+		},
+	)
+
+	ComponentOutboxService = di.NewComponent(
+		"outbox-service",
+		func(ctx context.Context) (*outbox.Service, error) {
+			return outbox.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentOutboxPublisher = di.NewComponent(
+		"outbox-publisher",
+		func(ctx context.Context) (outbox.Publisher, error) {
+			publishers := outbox.Publishers{
+				outbox.NewLogPublisher(ComponentLogger(ctx)),
+			}
+			if len(ComponentConfig(ctx).Broker.Brokers) != 0 {
+				publishers = append(publishers, outbox.NewBrokerPublisher(ComponentBroker(ctx)))
+			}
+			return append(publishers, webhooks.NewPublisher(ComponentWebhookService(ctx))), nil
+		},
+	)
+
+	ComponentBroker = di.NewComponent(
+		"broker",
+		func(ctx context.Context) (broker.Publisher, error) {
+			cfg := ComponentConfig(ctx)
+
+			var acks int16
+			switch cfg.Broker.Acks {
+			case "", "all":
+				acks = kafkaBroker.AcksAll
+			case "leader":
+				acks = kafkaBroker.AcksLeader
+			default:
+				return nil, fmt.Errorf("Unknown broker acks: %s", cfg.Broker.Acks)
+			}
+
+			var tlsConfig *tls.Config
+			if cfg.Broker.TLS.Enabled {
+				var err error
+				if tlsConfig, err = brokerTLSConfig(cfg.Broker.TLS); err != nil {
+					return nil, err
+				}
+			}
+
+			return kafkaBroker.NewBuilder().
+				WithBrokers(cfg.Broker.Brokers...).
+				WithClientId(cfg.Broker.ClientId).
+				WithRequiredAcks(acks).
+				WithIdempotence(cfg.Broker.Idempotence).
+				WithTimeout(cfg.Broker.Timeout).
+				WithRetries(cfg.Broker.Retries, cfg.Broker.Backoff).
+				WithTLS(tlsConfig).
+				WithSASL(cfg.Broker.SASL.Mechanism, cfg.Broker.SASL.Username, cfg.Broker.SASL.Password).
+				Build()
+		},
+		di.WithComponentDone(func(ctx context.Context, instance broker.Publisher) {
+			instance.Close()
+		}),
+	)
+
+	ComponentWorkerId = di.NewComponent(
+		"worker-id",
+		func(ctx context.Context) (string, error) {
+			cfg := ComponentConfig(ctx)
+			if cfg.Outbox.WorkerId != "" {
+				return cfg.Outbox.WorkerId, nil
+			}
+
+			host, err := os.Hostname()
+			if err != nil {
+				return "", fmt.Errorf("resolve worker id: %w", err)
+			}
+			return fmt.Sprintf("%s-%d", host, os.Getpid()), nil
+		},
+	)
+
+	ComponentOutboxRelay = di.NewComponent(
+		"outbox-relay",
+		func(ctx context.Context) (*outbox.Relay, error) {
+			cfg := ComponentConfig(ctx)
+			return outbox.NewRelay(
+				ComponentOutboxService(ctx),
+				ComponentOutboxPublisher(ctx),
+				ComponentDatabaseListener(ctx),
+				outbox.RelayOptions{
+					WorkerId:     ComponentWorkerId(ctx),
+					BatchSize:    cfg.Outbox.BatchSize,
+					Concurrency:  cfg.Outbox.Concurrency,
+					PollInterval: cfg.Outbox.PollInterval,
+					LockTimeout:  cfg.Outbox.LockTimeout,
+					MaxAttempts:  cfg.Outbox.MaxAttempts,
+				},
+				ComponentLogger(ctx),
+			), nil
+		},
+		di.WithComponentInit(func(ctx context.Context, instance *outbox.Relay) error {
+			return instance.Start(ctx)
+		}),
+		di.WithComponentDone(func(ctx context.Context, instance *outbox.Relay) {
+			instance.Stop()
+		}),
+	)
+
+	ComponentWebhookService = di.NewComponent(
+		"webhook-service",
+		func(ctx context.Context) (*webhooks.Service, error) {
+			return webhooks.NewService(
+				ComponentDatabase(ctx),
+				webhooks.Options{
+					Targets: ComponentWebhookTargets(ctx),
+					Secrets: ComponentWebhookSecrets(ctx),
+				},
+			), nil
+		},
+	)
+
+	ComponentWebhookTargets = di.NewComponent(
+		"webhook-targets",
+		func(ctx context.Context) (webhook.TargetPolicy, error) {
+			// Development endpoints run locally without certificates
+			return webhook.TargetPolicy{
+				AllowInsecure: isDevEnv(),
+				AllowPrivate:  isDevEnv(),
+			}, nil
+		},
+	)
+
+	ComponentWebhookSecrets = di.NewComponent(
+		"webhook-secrets",
+		func(ctx context.Context) (*webhooks.SecretBox, error) {
+			cfg := ComponentConfig(ctx)
+			if cfg.Webhook.SecretKey == "" {
+				if !isDevEnv() {
+					return nil, errors.New("webhook secret key is not configured")
+				}
+				return nil, nil
+			}
+
+			data, err := os.ReadFile(cfg.Webhook.SecretKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read webhook secret key: %w", err)
+			}
+			key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode webhook secret key: %w", err)
+			}
+			return webhooks.NewSecretBox(key)
+		},
+	)
+
+	ComponentWebhookDispatcher = di.NewComponent(
+		"webhook-dispatcher",
+		func(ctx context.Context) (*webhooks.Dispatcher, error) {
+			cfg := ComponentConfig(ctx)
+			return webhooks.NewDispatcher(
+				ComponentWebhookService(ctx),
+				webhook.NewClient(ComponentWebhookTargets(ctx), 0),
+				ComponentDatabaseListener(ctx),
+				webhooks.DispatcherOptions{
+					WorkerId:     ComponentWorkerId(ctx),
+					BatchSize:    cfg.Webhook.BatchSize,
+					Concurrency:  cfg.Webhook.Concurrency,
+					PollInterval: cfg.Webhook.PollInterval,
+					Timeout:      cfg.Webhook.Timeout,
+					LockTimeout:  cfg.Webhook.LockTimeout,
+					MaxAttempts:  cfg.Webhook.MaxAttempts,
+					Backoff:      cfg.Webhook.Backoff,
+					MaxBackoff:   cfg.Webhook.MaxBackoff,
+				},
+				ComponentLogger(ctx),
+			), nil
+		},
+		di.WithComponentInit(func(ctx context.Context, instance *webhooks.Dispatcher) error {
+			return instance.Start(ctx)
+		}),
+		di.WithComponentDone(func(ctx context.Context, instance *webhooks.Dispatcher) {
+			instance.Stop()
+		}),
+	)
+
+	ComponentCacheService = di.NewComponent(
+		"cache-service",
+		func(ctx context.Context) (*cache.Service, error) {
+			return cache.NewService(ComponentDatabase(ctx)), nil
+		},
+	)
+
+	ComponentCacheCleaner = di.NewComponent(
+		"cache-cleaner",
+		func(ctx context.Context) (*cache.Cleaner, error) {
+			cfg := ComponentConfig(ctx)
+			return cache.NewCleaner(
+				ComponentCacheService(ctx),
+				cfg.Cache.CleanupInterval,
+				ComponentLogger(ctx),
+			), nil
+		},
+		di.WithComponentInit(func(ctx context.Context, instance *cache.Cleaner) error {
+			return instance.Start(ctx)
+		}),
+		di.WithComponentDone(func(ctx context.Context, instance *cache.Cleaner) {
+			instance.Stop()
+		}),
+	)
+
+	ComponentSigningKeys = di.NewComponent(
+		"signing-keys",
+		func(ctx context.Context) (*jwt.KeySet, error) {
+			cfg := ComponentConfig(ctx)
+			if len(cfg.Auth.SigningKeys) == 0 {
+				if !isDevEnv() {
+					return nil, errors.New("signing keys are not configured")
+				}
+				// Tokens of the ephemeral key do not survive restart
+				key, err := jwt.GenerateKey(fmt.Sprintf("dev-%d", time.Now().Unix()))
+				if err != nil {
+					return nil, err
+				}
+				return jwt.NewKeySet(key), nil
+			}
+
+			keys := make([]*jwt.Key, 0, len(cfg.Auth.SigningKeys))
+			for _, keyCfg := range cfg.Auth.SigningKeys {
+				data, err := os.ReadFile(keyCfg.File)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read signing key %q: %w", keyCfg.Id, err)
+				}
+				key, err := jwt.ParseKey(keyCfg.Id, data)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse signing key %q: %w", keyCfg.Id, err)
+				}
+				keys = append(keys, key)
+			}
+			return jwt.NewKeySet(keys[0], keys[1:]...), nil
+		},
+	)
+
+	ComponentTokenIssuer = di.NewComponent(
+		"token-issuer",
+		func(ctx context.Context) (*jwt.Issuer, error) {
+			cfg := ComponentConfig(ctx)
+			return jwt.NewIssuerBuilder().
+				WithKeys(ComponentSigningKeys(ctx)).
+				WithTTL(cfg.Auth.AccessTokenTTL).
+				Build()
+		},
+	)
+
+	ComponentTokenVerifier = di.NewComponent(
+		"token-verifier",
+		func(ctx context.Context) (*jwt.Verifier, error) {
+			cfg := ComponentConfig(ctx)
+			return jwt.NewVerifierBuilder().
+				WithKeys(ComponentSigningKeys(ctx)).
+				WithLeeway(cfg.Auth.ClockSkew).
+				WithGenerations(auth.NewGenerations(ComponentDatabase(ctx))).
+				Build()
+		},
+	)
+
+	ComponentAuthService = di.NewComponent(
+		"auth-service",
+		func(ctx context.Context) (*auth.Service, error) {
+			cfg := ComponentConfig(ctx)
+			return auth.NewService(
+				ComponentDatabase(ctx),
+				auth.Options{
+					RefreshTokenTTL: cfg.Auth.RefreshTokenTTL,
+					ResetTokenTTL:   cfg.Auth.ResetTokenTTL,
+				},
+				ComponentTokenIssuer(ctx),
+				ComponentTokenVerifier(ctx),
+			), nil
+		},
+	)
+
+	ComponentHttpServer = di.NewComponent(
+		"http-server",
+		func(ctx context.Context) (*httpApi.Server, error) {
+			return httpApi.NewServer(
+				httpApi.NewUserHandler(ComponentUserService(ctx)),
+				httpApi.NewRoleHandler(ComponentRoleService(ctx)),
+				httpApi.NewTerritoryHandler(ComponentTerritoryService(ctx)),
+				httpApi.NewGroupHandler(ComponentGroupService(ctx), ComponentUserService(ctx)),
+				httpApi.NewPrincipalHandler(ComponentPrincipalService(ctx)),
+				httpApi.NewObjectHandler(ComponentObjectService(ctx)),
+				httpApi.NewPermissionSetHandler(ComponentPermissionService(ctx)),
+				httpApi.NewAccessHandler(ComponentAccessService(ctx)),
+				httpApi.NewCacheHandler(ComponentCacheService(ctx)),
+				httpApi.NewAuthHandler(ComponentAuthService(ctx), ComponentUserService(ctx)),
+				httpApi.NewWebhookHandler(ComponentWebhookService(ctx)),
+				httpApi.NewOutboxHandler(ComponentOutboxService(ctx), ComponentConfig(ctx).Outbox.Retention),
+			), nil
+		},
+	)
+
 	ComponentRouter = di.NewComponent(
 		"router",
 		func(ctx context.Context) (*gin.Engine, error) {
-			return gin.Default(), nil
+			router := gin.Default()
+			router.Use(httpApi.ErrorLogger(ComponentLogger(ctx)))
+			router.GET(httpApi.JwksPath, httpApi.NewJwksHandler(ComponentSigningKeys(ctx)))
+			ComponentHttpServer(ctx).Register(router, httpApi.ActorMiddleware(ComponentAuthService(ctx)), httpApi.ClientMiddleware())
+			return router, nil
+		},
+	)
+
+	// ComponentAdminRouter - administration shared by all tenants, served apart from the public API.
+	// The server is registered as a whole, OperatorMiddleware admits only the operations secured by OperatorKey.
+	ComponentAdminRouter = di.NewComponent(
+		"admin-router",
+		func(ctx context.Context) (*gin.Engine, error) {
+			operator, err := httpApi.OperatorMiddleware(ComponentConfig(ctx).Api.OperatorKeys)
+			if err != nil {
+				return nil, err
+			}
+
+			router := gin.Default()
+			router.Use(httpApi.ErrorLogger(ComponentLogger(ctx)))
+			ComponentHttpServer(ctx).Register(router, operator)
+			return router, nil
+		},
+	)
+
+	ComponentGrpcServer = di.NewComponent(
+		"grpc-server",
+		func(ctx context.Context) (*grpc.Server, error) {
+			logger := ComponentLogger(ctx)
+			verifier := ComponentTokenVerifier(ctx)
+			server := grpc.NewServer(
+				grpc.ChainUnaryInterceptor(grpcApi.ErrorLogger(logger), grpcApi.AuthInterceptor(verifier)),
+				grpc.ChainStreamInterceptor(grpcApi.StreamErrorLogger(logger), grpcApi.StreamAuthInterceptor(verifier)),
+			)
+			grpcApi.NewServer(
+				grpcApi.NewPermissionServer(ComponentAccessService(ctx)),
+				grpcApi.NewSyncServer(ComponentSnapshotService(ctx), ComponentChangeFeed(ctx)),
+			).Register(server)
+			return server, nil
 		},
 	)
 )
+
+// DaemonCacheCleaner - removes expired permission cache entries while the server is running
+func DaemonCacheCleaner(ctx context.Context) {
+	ComponentCacheCleaner(ctx)
+}
+
+// DaemonOutboxRelay - publishes outbox events while the application is running
+func DaemonOutboxRelay(ctx context.Context) {
+	ComponentOutboxRelay(ctx)
+}
+
+// DaemonWebhookDispatcher - delivers webhooks while the application is running
+func DaemonWebhookDispatcher(ctx context.Context) {
+	ComponentWebhookDispatcher(ctx)
+}
+
+// brokerTLSConfig - TLS of the broker connections, the client certificate is optional
+func brokerTLSConfig(cfg BrokerTLSConfig) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CAFile != "" {
+		data, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read broker CA certificates: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errors.New("broker CA certificates are not found")
+		}
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load broker client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// checkRowLevelSecurity - tenant isolation is enforced only for the roles subject to the policies:
+// superusers, roles with BYPASSRLS and the owner of the tables see the rows of every tenant.
+// Such role is refused outside of development.
+func checkRowLevelSecurity(ctx context.Context, db sql.DB) error {
+	var role string
+	var bypass bool
+	err := db.QueryRow(
+		ctx,
+		`SELECT r.rolname,
+		        r.rolsuper OR r.rolbypassrls OR EXISTS (
+		            SELECT 1
+		            FROM pg_class c
+		            JOIN pg_namespace n ON n.oid = c.relnamespace
+		            WHERE n.nspname = 'iam' AND c.relowner = r.oid
+		        )
+		 FROM pg_roles r
+		 WHERE r.rolname = current_user`,
+	).Scan(&role, &bypass)
+	if err != nil {
+		return fmt.Errorf("failed to check database role: %w", err)
+	}
+	if !bypass {
+		return nil
+	}
+
+	if !isDevEnv() {
+		return fmt.Errorf("database role %s bypasses row level security, connect as a member of iam_app", role)
+	}
+	ComponentLogger(ctx).
+		WithFields(log.Fields{"role": role}).
+		Warning(ctx, "database role bypasses row level security")
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	envFetcher "github.com/adverax/metacrm/pkg/access/fetchers/maps/env"
 	yamlConfig "github.com/adverax/metacrm/pkg/configs/formats/yaml"
@@ -12,7 +13,10 @@ import (
 )
 
 type ApiConfig struct {
-	Port int
+	Port         int
+	GrpcPort     int      `yaml:"grpc_port" json:"grpc_port"`
+	AdminPort    int      `yaml:"admin_port" json:"admin_port"`       // Port of the administration shared by all tenants, 0 disables it
+	OperatorKeys []string `yaml:"operator_keys" json:"operator_keys"` // Hex encoded SHA-256 digests of the operator API keys accepted by the administration
 }
 
 type DbConfig struct {
@@ -46,11 +50,88 @@ type LogConfig struct {
 	Format string `yaml:"format" json:"format"` // Log format (e.g., "json", "text")
 }
 
+type CacheConfig struct {
+	CleanupInterval time.Duration `yaml:"cleanup_interval" json:"cleanup_interval"` // Period of expired cache entries removal, 0 disables it
+}
+
+type FeedConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" json:"poll_interval"` // Period of the outbox polling by the permission change streams
+	BatchSize    int           `yaml:"batch_size" json:"batch_size"`       // Maximum number of the outbox events read at once
+}
+
+type OutboxConfig struct {
+	WorkerId     string        `yaml:"worker_id" json:"worker_id"`         // Identifier of the relay and the webhook dispatcher, defaults to host name and process id
+	BatchSize    int           `yaml:"batch_size" json:"batch_size"`       // Maximum number of events claimed at once
+	Concurrency  int           `yaml:"concurrency" json:"concurrency"`     // Maximum number of aggregates whose events are published simultaneously
+	PollInterval time.Duration `yaml:"poll_interval" json:"poll_interval"` // Period of the outbox polling, 0 disables the relay
+	LockTimeout  time.Duration `yaml:"lock_timeout" json:"lock_timeout"`   // Period after which events locked by crashed relays are processed again
+	MaxAttempts  int           `yaml:"max_attempts" json:"max_attempts"`   // Number of publishing attempts before the event is dead
+	Retention    time.Duration `yaml:"retention" json:"retention"`         // Period during which delivered events are kept, applied by the purge
+	Listen       bool          `yaml:"listen" json:"listen"`               // Wake up the relay, the webhook dispatcher and the change feeds by LISTEN/NOTIFY, polling remains as a fallback
+}
+
+type WebhookConfig struct {
+	BatchSize    int           `yaml:"batch_size" json:"batch_size"`       // Maximum number of deliveries claimed at once
+	Concurrency  int           `yaml:"concurrency" json:"concurrency"`     // Maximum number of endpoints called simultaneously
+	PollInterval time.Duration `yaml:"poll_interval" json:"poll_interval"` // Period of the deliveries polling, 0 disables the dispatcher
+	Timeout      time.Duration `yaml:"timeout" json:"timeout"`             // Timeout of the endpoint call
+	LockTimeout  time.Duration `yaml:"lock_timeout" json:"lock_timeout"`   // Period after which deliveries locked by crashed dispatchers are processed again
+	MaxAttempts  int           `yaml:"max_attempts" json:"max_attempts"`   // Number of delivery attempts before the delivery is dead
+	Backoff      time.Duration `yaml:"backoff" json:"backoff"`             // Suspension of the endpoint after the first failure, doubled on every next failure
+	MaxBackoff   time.Duration `yaml:"max_backoff" json:"max_backoff"`     // Maximum suspension of the endpoint
+	SecretKey    string        `yaml:"secret_key" json:"secret_key"`       // Path to the base64 encoded 32 byte key encrypting the endpoint secrets, required outside development
+}
+
+type BrokerConfig struct {
+	Brokers     []string         `yaml:"brokers" json:"brokers"`         // Bootstrap brokers of the Kafka compatible cluster in host:port form, empty disables publishing to the broker
+	ClientId    string           `yaml:"client_id" json:"client_id"`     // Client identifier reported to the brokers
+	Acks        string           `yaml:"acks" json:"acks"`               // Required acknowledgements: "all" or "leader"
+	Timeout     time.Duration    `yaml:"timeout" json:"timeout"`         // Time the broker waits for the acknowledgements
+	Retries     int              `yaml:"retries" json:"retries"`         // Number of retries of the failed message within one publishing
+	Backoff     time.Duration    `yaml:"backoff" json:"backoff"`         // Pause between the retries
+	Idempotence bool             `yaml:"idempotence" json:"idempotence"` // Publish by the idempotent producer, requires acks "all" and IDEMPOTENT_WRITE permission
+	TLS         BrokerTLSConfig  `yaml:"tls" json:"tls"`
+	SASL        BrokerSASLConfig `yaml:"sasl" json:"sasl"`
+}
+
+type BrokerTLSConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`     // Connect to the brokers by TLS
+	CAFile   string `yaml:"ca_file" json:"ca_file"`     // Path to PEM encoded CA certificates, the system pool is used without it
+	CertFile string `yaml:"cert_file" json:"cert_file"` // Path to PEM encoded client certificate of the mutual TLS
+	KeyFile  string `yaml:"key_file" json:"key_file"`   // Path to PEM encoded private key of the client certificate
+}
+
+type BrokerSASLConfig struct {
+	Mechanism string `yaml:"mechanism" json:"mechanism"` // "PLAIN", "SCRAM-SHA-256" or "SCRAM-SHA-512", empty disables authentication
+	Username  string `yaml:"username" json:"username"`
+	Password  string `yaml:"password" json:"password"`
+}
+
+type SigningKeyConfig struct {
+	Id   string `yaml:"id" json:"id"`     // Key identifier, published as kid
+	File string `yaml:"file" json:"file"` // Path to PEM encoded RSA private key
+}
+
+type AuthConfig struct {
+	AccessTokenTTL  time.Duration      `yaml:"access_token_ttl" json:"access_token_ttl"`   // Lifetime of the access token
+	RefreshTokenTTL time.Duration      `yaml:"refresh_token_ttl" json:"refresh_token_ttl"` // Lifetime of the refresh token, prolonged on every refresh
+	ResetTokenTTL   time.Duration      `yaml:"reset_token_ttl" json:"reset_token_ttl"`     // Lifetime of the password reset token
+	ClockSkew       time.Duration      `yaml:"clock_skew" json:"clock_skew"`               // Allowed clock skew on access token verification
+	SigningKeys     []SigningKeyConfig `yaml:"signing_keys" json:"signing_keys"`           // The first key signs tokens, the rest are published until retired
+}
+
 type Config struct {
-	Env string    `yaml:"env" json:"env"` // Application environment (e.g., "development", "production", etc.)
-	DB  DbConfig  `yaml:"db" json:"db"`
-	Api ApiConfig `yaml:"api" json:"api"`
-	Log LogConfig `yaml:"log" json:"log"`
+	Env         string        `yaml:"env" json:"env"`                   // Application environment (e.g., "development", "production", etc.)
+	DB          DbConfig      `yaml:"db" json:"db"`                     // Login role of the application, a member of iam_app subject to row level security
+	MigrationDB DbConfig      `yaml:"migration_db" json:"migration_db"` // Owner of the schema applying the migrations
+	Api         ApiConfig     `yaml:"api" json:"api"`
+	Log         LogConfig     `yaml:"log" json:"log"`
+	Cache       CacheConfig   `yaml:"cache" json:"cache"`
+	Feed        FeedConfig    `yaml:"feed" json:"feed"`
+	Outbox      OutboxConfig  `yaml:"outbox" json:"outbox"`
+	Webhook     WebhookConfig `yaml:"webhook" json:"webhook"`
+	Broker      BrokerConfig  `yaml:"broker" json:"broker"`
+	Auth        AuthConfig    `yaml:"auth" json:"auth"`
 }
 
 func (that *Config) IsDevEnv() bool {
@@ -98,6 +179,11 @@ func (that *Config) Init() error {
 		return err
 	}
 
+	err = that.MigrationDB.Init()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -137,9 +223,19 @@ func DefaultConfig() *Config {
 	return &Config{
 		Env: "development",
 		Api: ApiConfig{
-			Port: 8080,
+			Port:     8080,
+			GrpcPort: 9090,
 		},
 		DB: DbConfig{
+			DSN: sql.DSN{
+				Host:     "localhost",
+				Port:     5439,
+				User:     "iam_service",
+				Password: "iam_service",
+				Database: "iam",
+			},
+		},
+		MigrationDB: DbConfig{
 			DSN: sql.DSN{
 				Host:     "localhost",
 				Port:     5439,
@@ -153,5 +249,45 @@ func DefaultConfig() *Config {
 			Output: "stdout",
 			Format: "text",
 		},
+		Cache: CacheConfig{
+			CleanupInterval: 5 * time.Minute,
+		},
+		Feed: FeedConfig{
+			PollInterval: time.Second,
+			BatchSize:    500,
+		},
+		Outbox: OutboxConfig{
+			BatchSize:    100,
+			Concurrency:  4,
+			PollInterval: time.Second,
+			LockTimeout:  5 * time.Minute,
+			MaxAttempts:  5,
+			Retention:    30 * 24 * time.Hour,
+			Listen:       true,
+		},
+		Webhook: WebhookConfig{
+			BatchSize:    100,
+			Concurrency:  8,
+			PollInterval: time.Second,
+			Timeout:      10 * time.Second,
+			LockTimeout:  5 * time.Minute,
+			MaxAttempts:  10,
+			Backoff:      10 * time.Second,
+			MaxBackoff:   time.Hour,
+		},
+		Broker: BrokerConfig{
+			ClientId:    "iam",
+			Acks:        "all",
+			Timeout:     10 * time.Second,
+			Retries:     3,
+			Backoff:     100 * time.Millisecond,
+			Idempotence: true,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  time.Hour,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+			ResetTokenTTL:   time.Hour,
+			ClockSkew:       30 * time.Second,
+		},
 	}
 }
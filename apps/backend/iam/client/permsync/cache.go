@@ -0,0 +1,151 @@
+package permsync
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// cache - permissions of the recently checked users.
+// Entries expire after ttl, the least recently used entry is evicted when the cache is full.
+type cache struct {
+	size int
+	ttl  time.Duration
+
+	mx    sync.Mutex
+	items map[User]*list.Element
+	order *list.List              // the most recently used entry first
+	loads map[*cacheLoad]struct{} // loads in progress
+}
+
+// cacheLoad - load of the user permissions.
+// Invalidation of the user during the load makes the load stale,
+// so permissions of the other users are still cached while their changes keep coming.
+type cacheLoad struct {
+	user  User
+	stale bool
+}
+
+type cacheEntry struct {
+	user        User
+	permissions *Permissions
+	expiresAt   time.Time
+}
+
+func newCache(size int, ttl time.Duration) *cache {
+	return &cache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[User]*list.Element),
+		order: list.New(),
+		loads: make(map[*cacheLoad]struct{}),
+	}
+}
+
+// get - returns live permissions of the user
+func (that *cache) get(user User) (*Permissions, bool) {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	item, ok := that.items[user]
+	if !ok {
+		return nil, false
+	}
+
+	entry := item.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		that.remove(item)
+		return nil, false
+	}
+
+	that.order.MoveToFront(item)
+	return entry.permissions, true
+}
+
+// begin - starts the load of the user permissions, the load must be finished by end
+func (that *cache) begin(user User) *cacheLoad {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	pending := &cacheLoad{user: user}
+	that.loads[pending] = struct{}{}
+	return pending
+}
+
+// end - finishes the load
+func (that *cache) end(pending *cacheLoad) {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+	delete(that.loads, pending)
+}
+
+// put - stores permissions of the load, permissions loaded across invalidation of the user are dropped
+func (that *cache) put(pending *cacheLoad, permissions *Permissions) {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	if pending.stale {
+		return
+	}
+
+	user := pending.user
+	entry := &cacheEntry{user: user, permissions: permissions, expiresAt: time.Now().Add(that.ttl)}
+	if item, ok := that.items[user]; ok {
+		item.Value = entry
+		that.order.MoveToFront(item)
+		return
+	}
+
+	that.items[user] = that.order.PushFront(entry)
+	for that.order.Len() > that.size {
+		that.remove(that.order.Back())
+	}
+}
+
+// invalidate - drops permissions of the user
+func (that *cache) invalidate(user User) {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	that.discard(func(u User) bool { return u == user })
+	if item, ok := that.items[user]; ok {
+		that.remove(item)
+	}
+}
+
+// invalidateTenant - drops permissions of every user of the tenant
+func (that *cache) invalidateTenant(tenantId string) {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	that.discard(func(u User) bool { return u.TenantId == tenantId })
+	for user, item := range that.items {
+		if user.TenantId == tenantId {
+			that.remove(item)
+		}
+	}
+}
+
+// invalidateAll - drops every cached permissions
+func (that *cache) invalidateAll() {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	that.discard(func(User) bool { return true })
+	clear(that.items)
+	that.order.Init()
+}
+
+func (that *cache) remove(item *list.Element) {
+	entry := that.order.Remove(item).(*cacheEntry)
+	delete(that.items, entry.user)
+}
+
+// discard - makes loads of the matched users stale
+func (that *cache) discard(match func(user User) bool) {
+	for pending := range that.loads {
+		if match(pending.user) {
+			pending.stale = true
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package permsync
+
+import (
+	"testing"
+	"time"
+)
+
+func cached(c *cache, user User) bool {
+	_, ok := c.get(user)
+	return ok
+}
+
+func store(c *cache, user User) {
+	pending := c.begin(user)
+	defer c.end(pending)
+	c.put(pending, &Permissions{User: user})
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCache(2, time.Hour)
+	first := User{TenantId: "t1", UserId: "u1"}
+	second := User{TenantId: "t1", UserId: "u2"}
+	third := User{TenantId: "t1", UserId: "u3"}
+
+	store(c, first)
+	store(c, second)
+	if !cached(c, first) {
+		t.Fatal("first user is not cached")
+	}
+	store(c, third)
+
+	if cached(c, second) {
+		t.Error("least recently used user is not evicted")
+	}
+	if !cached(c, first) || !cached(c, third) {
+		t.Error("recently used users are evicted")
+	}
+	if c.order.Len() != 2 || len(c.items) != 2 {
+		t.Errorf("cache holds %d entries, want 2", c.order.Len())
+	}
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	c := newCache(10, 20*time.Millisecond)
+	user := User{TenantId: "t1", UserId: "u1"}
+
+	store(c, user)
+	if !cached(c, user) {
+		t.Fatal("user is not cached")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if cached(c, user) {
+		t.Error("expired permissions are returned")
+	}
+	if len(c.items) != 0 {
+		t.Error("expired entry is kept")
+	}
+}
+
+func TestCacheDropsLoadsAcrossInvalidation(t *testing.T) {
+	user := User{TenantId: "t1", UserId: "u1"}
+	neighbour := User{TenantId: "t1", UserId: "u2"}
+	stranger := User{TenantId: "t2", UserId: "u1"}
+
+	tests := []struct {
+		name       string
+		invalidate func(c *cache)
+		stale      bool
+	}{
+		{name: "user", invalidate: func(c *cache) { c.invalidate(user) }, stale: true},
+		{name: "tenant", invalidate: func(c *cache) { c.invalidateTenant(user.TenantId) }, stale: true},
+		{name: "all", invalidate: func(c *cache) { c.invalidateAll() }, stale: true},
+		{name: "other user", invalidate: func(c *cache) { c.invalidate(neighbour) }},
+		{name: "other tenant", invalidate: func(c *cache) { c.invalidateTenant(stranger.TenantId) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCache(10, time.Hour)
+
+			pending := c.begin(user)
+			tt.invalidate(c)
+			c.put(pending, &Permissions{User: user})
+			c.end(pending)
+
+			if got := cached(c, user); got == tt.stale {
+				t.Errorf("cached = %v, want %v", got, !tt.stale)
+			}
+			if len(c.loads) != 0 {
+				t.Error("finished load is kept")
+			}
+		})
+	}
+}
+
+func TestCacheStoresLoadStartedAfterInvalidation(t *testing.T) {
+	c := newCache(10, time.Hour)
+	user := User{TenantId: "t1", UserId: "u1"}
+
+	c.invalidate(user)
+	store(c, user)
+
+	if !cached(c, user) {
+		t.Error("permissions loaded after invalidation are not cached")
+	}
+}
@@ -0,0 +1,254 @@
+// Package permsync - permission checks of the external services against the local cache
+// filled from PermissionSyncService of IAM.
+//
+// Permissions of the user are loaded by GetUserPermissionsSnapshot on the first check
+// and kept until they expire, are evicted or are invalidated by the change feed.
+package permsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/grpc/sync"
+	"github.com/adverax/metacrm/pkg/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Options - local cache of the client
+type Options struct {
+	Size        int           // Maximum number of the cached users
+	TTL         time.Duration // Lifetime of the cached permissions
+	ObjectNames []string      // Objects checked by the service, empty means every object
+	Timeout     time.Duration // Timeout of the snapshot request
+	Backoff     time.Duration // Pause before resubscription to the change feed, doubled up to MaxBackoff
+	MaxBackoff  time.Duration
+}
+
+// DefaultOptions - options of the typical service
+var DefaultOptions = Options{
+	Size:       10000,
+	TTL:        5 * time.Minute,
+	Timeout:    10 * time.Second,
+	Backoff:    time.Second,
+	MaxBackoff: time.Minute,
+}
+
+// withDefaults - replaces missing and invalid options by DefaultOptions
+func (that Options) withDefaults() Options {
+	if that.Size <= 0 {
+		that.Size = DefaultOptions.Size
+	}
+	if that.TTL <= 0 {
+		that.TTL = DefaultOptions.TTL
+	}
+	if that.Timeout <= 0 {
+		that.Timeout = DefaultOptions.Timeout
+	}
+	if that.Backoff <= 0 {
+		that.Backoff = DefaultOptions.Backoff
+	}
+	if that.MaxBackoff < that.Backoff {
+		that.MaxBackoff = max(that.Backoff, DefaultOptions.MaxBackoff)
+	}
+	return that
+}
+
+// Client - checks permissions of the users against the local cache
+type Client struct {
+	sync    pb.PermissionSyncServiceClient
+	cache   *cache
+	options Options
+	logger  log.Logger
+
+	mx      sync.Mutex
+	loading map[User]*load
+}
+
+// load - snapshot request shared by concurrent checks of the user
+type load struct {
+	done        chan struct{}
+	permissions *Permissions
+	err         error
+}
+
+// New - creates client of the PermissionSyncService available by the connection.
+// IAM authenticates the calls by the bearer access token, so the connection must carry
+// per RPC credentials of the service (grpc.WithPerRPCCredentials).
+// Missing and invalid options are replaced by DefaultOptions.
+func New(conn grpc.ClientConnInterface, options Options, logger log.Logger) *Client {
+	options = options.withDefaults()
+	return &Client{
+		sync:    pb.NewPermissionSyncServiceClient(conn),
+		cache:   newCache(options.Size, options.TTL),
+		options: options,
+		logger:  logger,
+		loading: make(map[User]*load),
+	}
+}
+
+// Can - reports whether the user is granted every permission of the action on the object
+func (that *Client) Can(ctx context.Context, user User, object string, action Action) (bool, error) {
+	permissions, err := that.Permissions(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Can(object, action), nil
+}
+
+// CanField - reports whether the user is granted every permission of the action on the field of the object
+func (that *Client) CanField(ctx context.Context, user User, object, field string, action FieldAction) (bool, error) {
+	permissions, err := that.Permissions(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return permissions.CanField(object, field, action), nil
+}
+
+// Permissions - returns cached permissions of the user, missing ones are loaded from IAM
+func (that *Client) Permissions(ctx context.Context, user User) (*Permissions, error) {
+	if permissions, ok := that.cache.get(user); ok {
+		return permissions, nil
+	}
+
+	that.mx.Lock()
+	current, ok := that.loading[user]
+	if !ok {
+		current = &load{done: make(chan struct{})}
+		that.loading[user] = current
+		go that.load(user, current)
+	}
+	that.mx.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-current.done:
+		return current.permissions, current.err
+	}
+}
+
+// Invalidate - drops cached permissions of the user
+func (that *Client) Invalidate(user User) {
+	that.cache.invalidate(user)
+}
+
+// InvalidateTenant - drops cached permissions of every user of the tenant
+func (that *Client) InvalidateTenant(tenantId string) {
+	that.cache.invalidateTenant(tenantId)
+}
+
+// InvalidateAll - drops every cached permissions
+func (that *Client) InvalidateAll() {
+	that.cache.invalidateAll()
+}
+
+// Watch - invalidates cached permissions of the tenant by the change feed until the context is done.
+// Feed is resumed from the last received change after failures, so the changes are not lost;
+// permissions of the whole tenant are dropped when the feed can not be resumed.
+func (that *Client) Watch(ctx context.Context, tenantId string) error {
+	var cursor *string
+	backoff := that.options.Backoff
+	for {
+		received, err := that.watch(ctx, tenantId, &cursor)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			backoff = that.options.Backoff
+		}
+
+		if status.Code(err) == codes.InvalidArgument && cursor != nil {
+			// Cursor is outdated, changes since it are unknown
+			cursor = nil
+			that.InvalidateTenant(tenantId)
+		}
+
+		that.logger.
+			WithError(err).
+			WithFields(log.Fields{"tenant_id": tenantId, "backoff": backoff.String()}).
+			Warning(ctx, "permission change feed disconnected")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, that.options.MaxBackoff)
+	}
+}
+
+// watch - streams changes until the stream fails, reports whether any change was received
+func (that *Client) watch(ctx context.Context, tenantId string, cursor **string) (bool, error) {
+	req := &pb.StreamPermissionChangesRequest{TenantId: tenantId, Cursor: *cursor}
+	if *cursor == nil {
+		// Permissions cached before the subscription may miss earlier changes
+		req.Since = timestamppb.Now()
+		that.InvalidateTenant(tenantId)
+	}
+
+	stream, err := that.sync.StreamPermissionChanges(ctx, req)
+	if err != nil {
+		return false, fmt.Errorf("subscribe to permission changes: %w", err)
+	}
+
+	received := false
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return received, fmt.Errorf("receive permission change: %w", err)
+		}
+		received = true
+
+		if userId := event.GetChange().GetUserId(); userId != "" {
+			that.Invalidate(User{TenantId: tenantId, UserId: userId})
+		} else {
+			// Changes of the permission sets affect unknown set of users
+			that.InvalidateTenant(tenantId)
+		}
+		*cursor = &event.Cursor
+	}
+}
+
+// load - requests snapshot of the user and caches it unless it was invalidated meanwhile
+func (that *Client) load(user User, current *load) {
+	defer func() {
+		that.mx.Lock()
+		delete(that.loading, user)
+		that.mx.Unlock()
+		close(current.done)
+	}()
+
+	// The load is shared, so it is not bound to the context of any check
+	ctx, cancel := context.WithTimeout(context.Background(), that.options.Timeout)
+	defer cancel()
+
+	pending := that.cache.begin(user)
+	defer that.cache.end(pending)
+
+	resp, err := that.sync.GetUserPermissionsSnapshot(ctx, &pb.GetUserPermissionsSnapshotRequest{
+		TenantId:       user.TenantId,
+		UserId:         user.UserId,
+		ObjectApiNames: that.options.ObjectNames,
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			current.err = ErrUserNotFound
+			return
+		}
+		current.err = fmt.Errorf("get permissions snapshot: %w", err)
+		return
+	}
+	if resp.Snapshot == nil {
+		current.err = errors.New("get permissions snapshot: empty response")
+		return
+	}
+
+	current.permissions = newPermissions(user, resp.Snapshot)
+	that.cache.put(pending, current.permissions)
+}
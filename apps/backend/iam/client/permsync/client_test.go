@@ -0,0 +1,20 @@
+package permsync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOptionsWithDefaults(t *testing.T) {
+	options := Options{Size: 5, Backoff: 2 * time.Hour}.withDefaults()
+
+	if options.Size != 5 || options.Backoff != 2*time.Hour {
+		t.Errorf("configured options are replaced: %+v", options)
+	}
+	if options.TTL != DefaultOptions.TTL || options.Timeout != DefaultOptions.Timeout {
+		t.Errorf("missing options are not defaulted: %+v", options)
+	}
+	if options.MaxBackoff != options.Backoff {
+		t.Errorf("max backoff %s is less than backoff %s", options.MaxBackoff, options.Backoff)
+	}
+}
@@ -0,0 +1,83 @@
+package permsync
+
+import (
+	"errors"
+	"time"
+
+	pb "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/grpc/sync"
+)
+
+// Action - bitmask of object level permissions
+type Action int32
+
+const (
+	ActionRead Action = 1 << iota
+	ActionUpdate
+	ActionCreate
+	ActionDelete
+)
+
+// FieldAction - bitmask of field level permissions
+type FieldAction int32
+
+const (
+	FieldRead FieldAction = 1 << iota
+	FieldWrite
+)
+
+// User - user of the tenant whose permissions are checked
+type User struct {
+	TenantId string
+	UserId   string // record_id of the user
+}
+
+// Permissions - snapshot of the user permissions.
+// Objects and fields missing in the snapshot are not accessible.
+type Permissions struct {
+	User       User
+	Version    string
+	SnapshotAt time.Time
+	objects    map[string]Action
+	fields     map[string]map[string]FieldAction
+}
+
+// Can - reports whether every permission of the action is granted on the object
+func (that *Permissions) Can(object string, action Action) bool {
+	granted, ok := that.objects[object]
+	return ok && granted&action == action
+}
+
+// CanField - reports whether every permission of the action is granted on the field of the object
+func (that *Permissions) CanField(object, field string, action FieldAction) bool {
+	granted, ok := that.fields[object][field]
+	return ok && granted&action == action
+}
+
+func newPermissions(user User, snapshot *pb.UserPermissionsSnapshot) *Permissions {
+	permissions := &Permissions{
+		User:       user,
+		Version:    snapshot.SnapshotVersion,
+		SnapshotAt: snapshot.SnapshotAt.AsTime(),
+		objects:    make(map[string]Action, len(snapshot.Permissions)),
+		fields:     make(map[string]map[string]FieldAction),
+	}
+
+	for _, grant := range snapshot.Permissions {
+		permissions.objects[grant.ObjectApiName] = Action(grant.Permissions.GetValue())
+	}
+
+	for _, grant := range snapshot.FieldPermissions {
+		fields, ok := permissions.fields[grant.ObjectApiName]
+		if !ok {
+			fields = make(map[string]FieldAction)
+			permissions.fields[grant.ObjectApiName] = fields
+		}
+		fields[grant.FieldApiName] = FieldAction(grant.Permissions.GetValue())
+	}
+
+	return permissions
+}
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+)
@@ -0,0 +1,82 @@
+package grpcApi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/auth"
+	"github.com/adverax/metacrm/pkg/jwt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizationKey - metadata of the bearer token, "authorization: Bearer <token>"
+const authorizationKey = "authorization"
+
+// Verifier - verifies the access token of the call
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*jwt.Claims, error)
+}
+
+// AuthInterceptor - binds the principal authenticated by the bearer token to the context of the call,
+// calls without valid token are rejected
+func AuthInterceptor(verifier Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, verifier)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor - AuthInterceptor of the streaming calls
+func StreamAuthInterceptor(verifier Verifier) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), verifier)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (that *authenticatedStream) Context() context.Context {
+	return that.ctx
+}
+
+func authenticate(ctx context.Context, verifier Verifier) (context.Context, error) {
+	var token string
+	if values := metadata.ValueFromIncomingContext(ctx, authorizationKey); len(values) != 0 {
+		scheme, value, ok := strings.Cut(values[0], " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(value)
+		}
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "bearer token is required")
+	}
+
+	claims, err := verifier.Verify(ctx, token)
+	if err != nil {
+		if errors.Is(err, jwt.ErrGenerationUnavailable) {
+			return nil, err
+		}
+		return nil, status.Error(codes.Unauthenticated, auth.ErrInvalidToken.Error())
+	}
+
+	actor, err := auth.ActorOf(claims)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, auth.ErrInvalidToken.Error())
+	}
+	return services.WithActor(ctx, actor), nil
+}
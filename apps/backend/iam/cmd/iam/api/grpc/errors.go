@@ -0,0 +1,109 @@
+package grpcApi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/access"
+	"github.com/adverax/metacrm/apps/backend/iam/services/snapshots"
+	"github.com/adverax/metacrm/pkg/log"
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type errorMapping struct {
+	err  error
+	code codes.Code
+}
+
+// errorMappings - translation of domain errors into gRPC statuses.
+// The first matching entry wins, so specific errors must precede generic ones.
+var errorMappings = []errorMapping{
+	{context.Canceled, codes.Canceled},
+	{context.DeadlineExceeded, codes.DeadlineExceeded},
+
+	{services.ErrTenantRequired, codes.InvalidArgument},
+
+	{access.ErrUserNotFound, codes.NotFound},
+	{access.ErrObjectNotFound, codes.NotFound},
+	{access.ErrFieldNotFound, codes.NotFound},
+
+	{snapshots.ErrUserNotFound, codes.NotFound},
+	{snapshots.ErrGroupNotFound, codes.NotFound},
+	{snapshots.ErrTooManyUsers, codes.InvalidArgument},
+	{snapshots.ErrInvalidCursor, codes.InvalidArgument},
+}
+
+// toStatus - translates error into gRPC status error
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	if validation.IsValidationError(err) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return status.Error(m.code, m.err.Error())
+		}
+	}
+
+	return status.Error(codes.Internal, "an internal error occurred")
+}
+
+// withTenant - checks that tenant of the request is the tenant of the authenticated principal.
+// The context is bound to the principal by AuthInterceptor, tenant_id of the request is not trusted.
+func withTenant(ctx context.Context, tenantId string) (context.Context, error) {
+	id, err := uuid.Parse(tenantId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid tenant_id: %v", err)
+	}
+
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if actor.TenantId != id {
+		return nil, status.Error(codes.PermissionDenied, "tenant_id is not the tenant of the authenticated principal")
+	}
+	return ctx, nil
+}
+
+// ErrorLogger - translates errors of the handlers into statuses and logs unexpected ones
+func ErrorLogger(logger log.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		return nil, logStatus(ctx, logger, info.FullMethod, err)
+	}
+}
+
+// StreamErrorLogger - ErrorLogger of the streaming handlers
+func StreamErrorLogger(logger log.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, stream)
+		if err == nil {
+			return nil
+		}
+		return logStatus(stream.Context(), logger, info.FullMethod, err)
+	}
+}
+
+func logStatus(ctx context.Context, logger log.Logger, method string, err error) error {
+	st := toStatus(err)
+	if status.Code(st) == codes.Internal {
+		logger.
+			WithError(err).
+			WithFields(log.Fields{"grpc.method": method}).
+			Error(ctx, "grpc_request_failed")
+	}
+	return st
+}
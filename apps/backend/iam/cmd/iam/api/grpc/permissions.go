@@ -0,0 +1,526 @@
+package grpcApi
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	pb "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/grpc/permissions"
+	"github.com/adverax/metacrm/apps/backend/iam/services/access"
+	"github.com/adverax/metacrm/apps/backend/iam/services/groups"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PermissionServer - implementation of PermissionServiceServer on top of the permission cache
+type PermissionServer struct {
+	pb.UnimplementedPermissionServiceServer
+	access *access.Service
+}
+
+func NewPermissionServer(access *access.Service) *PermissionServer {
+	return &PermissionServer{access: access}
+}
+
+func (that *PermissionServer) GetUserObjectPermissions(ctx context.Context, req *pb.GetUserObjectPermissionsRequest) (*pb.GetUserObjectPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := that.objectGrant(ctx, req.UserId, req.ObjectApiName, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetUserObjectPermissionsResponse{
+		Permission: newObjectPermission(grant),
+		CacheInfo:  newCacheInfo(ttl, grant.Cached, grant.CachedAt, grant.ExpiresAt),
+	}, nil
+}
+
+func (that *PermissionServer) GetUserFieldPermissions(ctx context.Context, req *pb.GetUserFieldPermissionsRequest) (*pb.GetUserFieldPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := that.fieldGrant(ctx, req.UserId, req.ObjectApiName, req.FieldApiName, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetUserFieldPermissionsResponse{
+		Permission: newFieldPermission(grant),
+		CacheInfo:  newCacheInfo(ttl, grant.Cached, grant.CachedAt, grant.ExpiresAt),
+	}, nil
+}
+
+func (that *PermissionServer) GetUserGroupMemberships(ctx context.Context, req *pb.GetUserGroupMembershipsRequest) (*pb.GetUserGroupMembershipsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nested groups, roles and territories are all expanded by the group closure,
+	// so indirect memberships are returned only when both kinds are requested
+	indirect := (req.IncludeInherited == nil || *req.IncludeInherited) &&
+		(req.IncludeNested == nil || *req.IncludeNested)
+
+	memberships, err := that.access.Memberships(ctx, req.UserId, indirect)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.GetUserGroupMembershipsResponse{
+		Memberships: make([]*pb.GroupMembership, 0, len(memberships)),
+		TotalCount:  int32(len(memberships)),
+	}
+	for _, membership := range memberships {
+		resp.Memberships = append(resp.Memberships, newGroupMembership(membership))
+	}
+	return resp, nil
+}
+
+func (that *PermissionServer) GetAllUserObjectPermissions(ctx context.Context, req *pb.GetAllUserObjectPermissionsRequest) (*pb.GetAllUserObjectPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := that.access.ObjectGrants(ctx, req.UserId, nil, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	items, info := newObjectPermissions(grants, ttl)
+	return &pb.GetAllUserObjectPermissionsResponse{
+		Permissions: items,
+		TotalCount:  int32(len(items)),
+		CacheInfo:   info,
+	}, nil
+}
+
+func (that *PermissionServer) GetAllUserFieldPermissions(ctx context.Context, req *pb.GetAllUserFieldPermissionsRequest) (*pb.GetAllUserFieldPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := that.access.FieldGrants(ctx, req.UserId, req.ObjectApiName, nil, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	items, info := newFieldPermissions(grants, ttl)
+	return &pb.GetAllUserFieldPermissionsResponse{
+		Permissions: items,
+		TotalCount:  int32(len(items)),
+		CacheInfo:   info,
+	}, nil
+}
+
+func (that *PermissionServer) CheckUserObjectPermission(ctx context.Context, req *pb.CheckUserObjectPermissionRequest) (*pb.CheckUserObjectPermissionResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	required, ok := objectAccessOf(req.Permission)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported object permission %s", req.Permission)
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := that.objectGrant(ctx, req.UserId, req.ObjectApiName, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CheckUserObjectPermissionResponse{
+		HasPermission:      grant.Permissions.Has(required),
+		RequiredPermission: req.Permission,
+		ActualPermission:   objectPermissionType(grant.Permissions),
+		CacheInfo:          newCacheInfo(ttl, grant.Cached, grant.CachedAt, grant.ExpiresAt),
+	}, nil
+}
+
+func (that *PermissionServer) CheckUserFieldPermission(ctx context.Context, req *pb.CheckUserFieldPermissionRequest) (*pb.CheckUserFieldPermissionResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	required, ok := fieldAccessOf(req.Permission)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported field permission %s", req.Permission)
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := that.fieldGrant(ctx, req.UserId, req.ObjectApiName, req.FieldApiName, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CheckUserFieldPermissionResponse{
+		HasPermission:      grant.Permissions.Has(required),
+		RequiredPermission: req.Permission,
+		ActualPermission:   fieldPermissionType(grant.Permissions),
+		CacheInfo:          newCacheInfo(ttl, grant.Cached, grant.CachedAt, grant.ExpiresAt),
+	}, nil
+}
+
+func (that *PermissionServer) GetBulkObjectPermissions(ctx context.Context, req *pb.GetBulkObjectPermissionsRequest) (*pb.GetBulkObjectPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.ObjectApiNames) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "object_api_names are required")
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := that.access.ObjectGrants(ctx, req.UserId, req.ObjectApiNames, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	items, info := newObjectPermissions(grants, ttl)
+	return &pb.GetBulkObjectPermissionsResponse{
+		Permissions: items,
+		TotalCount:  int32(len(items)),
+		CacheInfo:   info,
+	}, nil
+}
+
+func (that *PermissionServer) GetBulkFieldPermissions(ctx context.Context, req *pb.GetBulkFieldPermissionsRequest) (*pb.GetBulkFieldPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.FieldApiNames) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "field_api_names are required")
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := that.access.FieldGrants(ctx, req.UserId, req.ObjectApiName, req.FieldApiNames, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	items, info := newFieldPermissions(grants, ttl)
+	return &pb.GetBulkFieldPermissionsResponse{
+		Permissions: items,
+		TotalCount:  int32(len(items)),
+		CacheInfo:   info,
+	}, nil
+}
+
+// objectGrant - returns permissions of the user on the single object
+func (that *PermissionServer) objectGrant(ctx context.Context, userId, objectName string, ttl time.Duration) (*access.ObjectGrant, error) {
+	grants, err := that.access.ObjectGrants(ctx, userId, []string{objectName}, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if len(grants) == 0 {
+		return nil, access.ErrObjectNotFound
+	}
+	return grants[0], nil
+}
+
+// fieldGrant - returns permissions of the user on the single field
+func (that *PermissionServer) fieldGrant(ctx context.Context, userId, objectName, fieldName string, ttl time.Duration) (*access.FieldGrant, error) {
+	grants, err := that.access.FieldGrants(ctx, userId, objectName, []string{fieldName}, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if len(grants) == 0 {
+		return nil, access.ErrFieldNotFound
+	}
+	return grants[0], nil
+}
+
+// ttlOf - returns cache ttl requested by the client
+func ttlOf(seconds *int32) (time.Duration, error) {
+	if seconds == nil {
+		return access.DefaultCacheTTL, nil
+	}
+	if *seconds <= 0 {
+		return 0, status.Error(codes.InvalidArgument, "ttl_seconds must be positive")
+	}
+	return time.Duration(*seconds) * time.Second, nil
+}
+
+// objectAccessOf - translates requested permission into the object bitmask.
+// PermissionType numbers CREATE and UPDATE differently from the stored bitmask.
+func objectAccessOf(permission pb.PermissionType) (permissions.ObjectAccess, bool) {
+	switch permission {
+	case pb.PermissionType_PERMISSION_TYPE_READ:
+		return permissions.ObjectRead, true
+	case pb.PermissionType_PERMISSION_TYPE_CREATE:
+		return permissions.ObjectCreate, true
+	case pb.PermissionType_PERMISSION_TYPE_UPDATE:
+		return permissions.ObjectUpdate, true
+	case pb.PermissionType_PERMISSION_TYPE_DELETE:
+		return permissions.ObjectDelete, true
+	default:
+		return 0, false
+	}
+}
+
+// fieldAccessOf - translates requested permission into the field bitmask, fields are only read or written
+func fieldAccessOf(permission pb.PermissionType) (permissions.FieldAccess, bool) {
+	switch permission {
+	case pb.PermissionType_PERMISSION_TYPE_READ:
+		return permissions.FieldRead, true
+	case pb.PermissionType_PERMISSION_TYPE_CREATE, pb.PermissionType_PERMISSION_TYPE_UPDATE:
+		return permissions.FieldWrite, true
+	default:
+		return 0, false
+	}
+}
+
+// objectPermissionType - combines granted permissions using PermissionType numbering
+func objectPermissionType(access permissions.ObjectAccess) pb.PermissionType {
+	var res pb.PermissionType
+	for _, permission := range []pb.PermissionType{
+		pb.PermissionType_PERMISSION_TYPE_READ,
+		pb.PermissionType_PERMISSION_TYPE_CREATE,
+		pb.PermissionType_PERMISSION_TYPE_UPDATE,
+		pb.PermissionType_PERMISSION_TYPE_DELETE,
+	} {
+		if flag, _ := objectAccessOf(permission); access.Has(flag) {
+			res |= permission
+		}
+	}
+	return res
+}
+
+// fieldPermissionType - combines granted permissions using PermissionType numbering, WRITE stands for UPDATE
+func fieldPermissionType(access permissions.FieldAccess) pb.PermissionType {
+	var res pb.PermissionType
+	if access.Has(permissions.FieldRead) {
+		res |= pb.PermissionType_PERMISSION_TYPE_READ
+	}
+	if access.Has(permissions.FieldWrite) {
+		res |= pb.PermissionType_PERMISSION_TYPE_UPDATE
+	}
+	return res
+}
+
+func newObjectPermissions(grants []*access.ObjectGrant, ttl time.Duration) ([]*pb.ObjectPermission, *pb.CacheInfo) {
+	items := make([]*pb.ObjectPermission, 0, len(grants))
+	var info cacheState
+	for _, grant := range grants {
+		items = append(items, newObjectPermission(grant))
+		info.add(grant.Cached, grant.CachedAt, grant.ExpiresAt)
+	}
+	return items, info.build(ttl)
+}
+
+func newObjectPermission(grant *access.ObjectGrant) *pb.ObjectPermission {
+	sources := make([]*pb.PermissionSource, 0, len(grant.Sources))
+	for _, source := range grant.Sources {
+		sources = append(sources, newPermissionSource(source.GroupId, source.GroupLabel, source.GroupType, newObjectBitmask(source.Permissions)))
+	}
+
+	return &pb.ObjectPermission{
+		ObjectApiName: grant.ObjectName,
+		ObjectId:      grant.ObjectId,
+		Permissions:   newObjectBitmask(grant.Permissions),
+		Sources:       sources,
+		ComputedAt:    computedAt(grant.CachedAt),
+	}
+}
+
+func newFieldPermissions(grants []*access.FieldGrant, ttl time.Duration) ([]*pb.FieldPermission, *pb.CacheInfo) {
+	items := make([]*pb.FieldPermission, 0, len(grants))
+	var info cacheState
+	for _, grant := range grants {
+		items = append(items, newFieldPermission(grant))
+		info.add(grant.Cached, grant.CachedAt, grant.ExpiresAt)
+	}
+	return items, info.build(ttl)
+}
+
+func newFieldPermission(grant *access.FieldGrant) *pb.FieldPermission {
+	sources := make([]*pb.PermissionSource, 0, len(grant.Sources))
+	for _, source := range grant.Sources {
+		sources = append(sources, newPermissionSource(source.GroupId, source.GroupLabel, source.GroupType, newFieldBitmask(source.Permissions)))
+	}
+
+	return &pb.FieldPermission{
+		ObjectApiName: grant.ObjectName,
+		FieldApiName:  grant.FieldName,
+		ObjectId:      grant.ObjectId,
+		FieldId:       grant.FieldId,
+		Permissions:   newFieldBitmask(grant.Permissions),
+		Sources:       sources,
+		ComputedAt:    computedAt(grant.CachedAt),
+	}
+}
+
+// newObjectBitmask - the stored object bitmask matches PermissionBitmask layout
+func newObjectBitmask(access permissions.ObjectAccess) *pb.PermissionBitmask {
+	return &pb.PermissionBitmask{
+		Value:           int32(access),
+		CanRead:         access.Has(permissions.ObjectRead),
+		CanUpdate:       access.Has(permissions.ObjectUpdate),
+		CanCreate:       access.Has(permissions.ObjectCreate),
+		CanDelete:       access.Has(permissions.ObjectDelete),
+		PermissionNames: access.Names(),
+	}
+}
+
+// newFieldBitmask - field WRITE permission allows both update and create of the field value
+func newFieldBitmask(access permissions.FieldAccess) *pb.PermissionBitmask {
+	return &pb.PermissionBitmask{
+		Value:           int32(access),
+		CanRead:         access.Has(permissions.FieldRead),
+		CanUpdate:       access.Has(permissions.FieldWrite),
+		CanCreate:       access.Has(permissions.FieldWrite),
+		PermissionNames: access.Names(),
+	}
+}
+
+// newPermissionSource - permissions are granted through permission sets of the groups only
+func newPermissionSource(groupId int64, label string, groupType groups.GroupType, bitmask *pb.PermissionBitmask) *pb.PermissionSource {
+	sourceType := pb.SourceType_SOURCE_TYPE_GROUP
+	switch {
+	case groupType.IsRoleBased():
+		sourceType = pb.SourceType_SOURCE_TYPE_ROLE
+	case groupType.IsTerritoryBased():
+		sourceType = pb.SourceType_SOURCE_TYPE_TERRITORY
+	}
+
+	return &pb.PermissionSource{
+		SourceType:  sourceType,
+		SourceId:    strconv.FormatInt(groupId, 10),
+		SourceName:  label,
+		Permissions: bitmask,
+	}
+}
+
+// newGroupMembership - groups have no record id, so the group id is used instead
+func newGroupMembership(membership *access.Membership) *pb.GroupMembership {
+	res := &pb.GroupMembership{
+		GroupRecordId:  strconv.FormatInt(membership.GroupId, 10),
+		GroupLabel:     membership.GroupLabel,
+		GroupApiName:   membership.GroupApiName,
+		GroupType:      groupTypeOf(membership.GroupType),
+		MembershipType: pb.MembershipType_MEMBERSHIP_TYPE_INHERITED,
+	}
+
+	if membership.Direct {
+		res.MembershipType = pb.MembershipType_MEMBERSHIP_TYPE_DIRECT
+	}
+	if membership.JoinedAt != nil {
+		res.JoinedAt = timestamppb.New(*membership.JoinedAt)
+	}
+	if membership.RelatedRoleId != nil {
+		res.RelatedEntityId = strconv.FormatInt(*membership.RelatedRoleId, 10)
+	}
+	if membership.RelatedTerritoryId != nil {
+		res.RelatedEntityId = strconv.FormatInt(*membership.RelatedTerritoryId, 10)
+	}
+	return res
+}
+
+func groupTypeOf(groupType groups.GroupType) pb.GroupType {
+	switch {
+	case groupType.IsRoleBased():
+		return pb.GroupType_GROUP_TYPE_ROLE_BASED
+	case groupType.IsTerritoryBased():
+		return pb.GroupType_GROUP_TYPE_TERRITORY_BASED
+	default:
+		return pb.GroupType_GROUP_TYPE_MANUAL
+	}
+}
+
+func newCacheInfo(ttl time.Duration, cached bool, cachedAt, expiresAt *time.Time) *pb.CacheInfo {
+	var info cacheState
+	info.add(cached, cachedAt, expiresAt)
+	return info.build(ttl)
+}
+
+// cacheState - cache state of several grants: served from cache only when every grant was,
+// the oldest entry defines when the data was cached and the first expiring one when it expires
+type cacheState struct {
+	count     int
+	cached    int
+	cachedAt  *time.Time
+	expiresAt *time.Time
+}
+
+func (that *cacheState) add(cached bool, cachedAt, expiresAt *time.Time) {
+	that.count++
+	if cached {
+		that.cached++
+	}
+	if cachedAt != nil && (that.cachedAt == nil || cachedAt.Before(*that.cachedAt)) {
+		that.cachedAt = cachedAt
+	}
+	if expiresAt != nil && (that.expiresAt == nil || expiresAt.Before(*that.expiresAt)) {
+		that.expiresAt = expiresAt
+	}
+}
+
+func (that *cacheState) build(ttl time.Duration) *pb.CacheInfo {
+	info := &pb.CacheInfo{
+		FromCache:  that.count > 0 && that.cached == that.count,
+		TtlSeconds: int32(ttl.Seconds()),
+	}
+	if that.cachedAt != nil {
+		info.CachedAt = timestamppb.New(*that.cachedAt)
+	}
+	if that.expiresAt != nil {
+		info.ExpiresAt = timestamppb.New(*that.expiresAt)
+	}
+	return info
+}
+
+// computedAt - permissions are computed when they are cached, empty permissions are computed right now
+func computedAt(cachedAt *time.Time) *timestamppb.Timestamp {
+	if cachedAt != nil {
+		return timestamppb.New(*cachedAt)
+	}
+	return timestamppb.Now()
+}
@@ -0,0 +1,23 @@
+package grpcApi
+
+import (
+	permissionsGrpc "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/grpc/permissions"
+	syncGrpc "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/grpc/sync"
+	"google.golang.org/grpc"
+)
+
+// Server - gRPC services of IAM assembled from domain servers
+type Server struct {
+	permissions *PermissionServer
+	sync        *SyncServer
+}
+
+func NewServer(permissions *PermissionServer, sync *SyncServer) *Server {
+	return &Server{permissions: permissions, sync: sync}
+}
+
+// Register - registers all services on the gRPC server
+func (that *Server) Register(registrar grpc.ServiceRegistrar) {
+	permissionsGrpc.RegisterPermissionServiceServer(registrar, that.permissions)
+	syncGrpc.RegisterPermissionSyncServiceServer(registrar, that.sync)
+}
@@ -0,0 +1,514 @@
+package grpcApi
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	pb "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/grpc/sync"
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/groups"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"github.com/adverax/metacrm/apps/backend/iam/services/snapshots"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultSyncStatsPeriod - period of the sync statistics when the start is not requested
+const DefaultSyncStatsPeriod = 24 * time.Hour
+
+// SyncServer - implementation of PermissionSyncServiceServer for services that cache permissions locally
+type SyncServer struct {
+	pb.UnimplementedPermissionSyncServiceServer
+	snapshots *snapshots.Service
+	feed      *snapshots.Feed
+}
+
+func NewSyncServer(snapshots *snapshots.Service, feed *snapshots.Feed) *SyncServer {
+	return &SyncServer{snapshots: snapshots, feed: feed}
+}
+
+func (that *SyncServer) GetUserPermissionsSnapshot(ctx context.Context, req *pb.GetUserPermissionsSnapshotRequest) (*pb.GetUserPermissionsSnapshotResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := that.snapshots.Snapshot(ctx, req.UserId, snapshots.Query{
+		ObjectNames:        namesOf(req.ObjectApiNames),
+		IncludeMemberships: req.IncludeGroupMemberships,
+		IncludeSources:     req.IncludePermissionSources,
+		TTL:                ttl,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetUserPermissionsSnapshotResponse{
+		Snapshot:    newSnapshot(snapshot),
+		CacheInfo:   newSyncCacheInfo(ttl, snapshot),
+		GeneratedAt: timestamppb.New(snapshot.SnapshotAt),
+	}, nil
+}
+
+func (that *SyncServer) GetUserPermissionsChanges(ctx context.Context, req *pb.GetUserPermissionsChangesRequest) (*pb.GetUserPermissionsChangesResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Since == nil {
+		return nil, status.Error(codes.InvalidArgument, "since is required")
+	}
+
+	changes, err := that.snapshots.Changes(ctx, req.UserId, req.Since.AsTime(), namesOf(req.ObjectApiNames), req.IncludeGroupMemberships)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.GetUserPermissionsChangesResponse{
+		Changes:      make([]*pb.PermissionChange, 0, len(changes.Changes)),
+		TotalChanges: int32(changes.TotalChanges),
+		LastChange:   timestamppb.New(changes.LastChange),
+		// Changes are read from the outbox, they are never cached
+		CacheInfo: &pb.CacheInfo{},
+	}
+	for _, change := range changes.Changes {
+		resp.Changes = append(resp.Changes, newPermissionChange(change))
+	}
+	return resp, nil
+}
+
+func (that *SyncServer) GetBulkUserPermissions(ctx context.Context, req *pb.GetBulkUserPermissionsRequest) (*pb.GetBulkUserPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	bulk, err := that.snapshots.BulkSnapshots(ctx, snapshots.BulkQuery{
+		Query: snapshots.Query{
+			ObjectNames:        namesOf(req.ObjectApiNames),
+			IncludeMemberships: req.IncludeGroupMemberships,
+			IncludeSources:     req.IncludePermissionSources,
+			TTL:                ttl,
+		},
+		UserIds: req.UserIds,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetBulkUserPermissionsResponse{
+		Snapshots:     newSnapshots(bulk.Snapshots),
+		TotalUsers:    int32(bulk.TotalUsers),
+		FailedUsers:   int32(len(bulk.FailedUserIds)),
+		FailedUserIds: bulk.FailedUserIds,
+		CacheInfo:     newSyncCacheInfo(ttl, bulk.Snapshots...),
+	}, nil
+}
+
+func (that *SyncServer) GetGroupMembershipPermissions(ctx context.Context, req *pb.GetGroupMembershipPermissionsRequest) (*pb.GetGroupMembershipPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := ttlOf(req.TtlSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	groupId, err := groupIdOf(req.GroupRecordId)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := that.snapshots.GroupSnapshots(ctx, snapshots.GroupQuery{
+		Query: snapshots.Query{
+			ObjectNames:    namesOf(req.ObjectApiNames),
+			IncludeSources: req.IncludePermissionSources,
+			TTL:            ttl,
+		},
+		GroupId:        groupId,
+		IncludeMembers: req.IncludeAllMembers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetGroupMembershipPermissionsResponse{
+		MemberPermissions: newSnapshots(group.Members),
+		GroupInfo:         newGroupInfo(group.Group),
+		TotalMembers:      int32(group.TotalMembers),
+		CacheInfo:         newSyncCacheInfo(ttl, group.Members...),
+	}, nil
+}
+
+func (that *SyncServer) CheckUserPermissionsChanged(ctx context.Context, req *pb.CheckUserPermissionsChangedRequest) (*pb.CheckUserPermissionsChangedResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Since == nil {
+		return nil, status.Error(codes.InvalidArgument, "since is required")
+	}
+
+	summary, err := that.snapshots.Changed(ctx, req.UserId, req.Since.AsTime(), namesOf(req.ObjectApiNames))
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CheckUserPermissionsChangedResponse{
+		HasChanges:     summary.HasChanges,
+		LastChange:     timestampOf(summary.LastChange),
+		ChangedObjects: summary.ChangedObjects,
+		TotalChanges:   int32(summary.TotalChanges),
+	}, nil
+}
+
+func (that *SyncServer) SyncGroupPermissions(ctx context.Context, req *pb.SyncGroupPermissionsRequest) (*pb.SyncGroupPermissionsResponse, error) {
+	ctx, err := withTenant(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	groupId, err := groupIdOf(req.GroupRecordId)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := that.snapshots.SyncGroup(ctx, snapshots.SyncQuery{
+		GroupId:      groupId,
+		UserIds:      req.UserIds,
+		ObjectNames:  namesOf(req.ObjectApiNames),
+		ForceRefresh: req.ForceRefresh,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.SyncGroupPermissionsResponse{
+		UsersSynced:        int32(result.UsersSynced),
+		PermissionsUpdated: int32(result.PermissionsUpdated),
+		SyncedAt:           timestamppb.New(result.SyncedAt),
+		FailedUserIds:      result.FailedUserIds,
+	}, nil
+}
+
+// GetPermissionSyncStats - statistics of the tenant of the authenticated principal,
+// the requested tenant must be the same
+func (that *SyncServer) GetPermissionSyncStats(ctx context.Context, req *pb.GetPermissionSyncStatsRequest) (*pb.GetPermissionSyncStatsResponse, error) {
+	if req.TenantId != nil {
+		var err error
+		ctx, err = withTenant(ctx, *req.TenantId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	tenantId := &actor.TenantId
+
+	since := time.Now().Add(-DefaultSyncStatsPeriod)
+	if req.Since != nil {
+		since = req.Since.AsTime()
+	}
+
+	stats, err := that.snapshots.Stats(ctx, tenantId, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetPermissionSyncStatsResponse{
+		Stats: &pb.PermissionSyncStats{
+			TotalEvents:       int32(stats.TotalEvents),
+			SyncEvents:        int32(stats.SyncEvents),
+			FailedEvents:      int32(stats.FailedEvents),
+			AvgProcessingTime: durationpb.New(time.Duration(stats.AvgProcessingTime * float64(time.Second))),
+			PeriodStart:       timestamppb.New(stats.PeriodStart),
+			PeriodEnd:         timestamppb.New(stats.PeriodEnd),
+		},
+	}, nil
+}
+
+// StreamPermissionChanges - streams changes of the tenant until the client cancels the stream
+func (that *SyncServer) StreamPermissionChanges(req *pb.StreamPermissionChangesRequest, stream pb.PermissionSyncService_StreamPermissionChangesServer) error {
+	ctx, err := withTenant(stream.Context(), req.TenantId)
+	if err != nil {
+		return err
+	}
+
+	query := snapshots.FeedQuery{
+		ChangeTypes: changeTypesOf(req.ChangeTypes),
+	}
+	if req.Cursor != nil {
+		cursor, err := snapshots.ParseCursor(*req.Cursor)
+		if err != nil {
+			return err
+		}
+		query.Cursor = &cursor
+	}
+	if req.Since != nil {
+		since := req.Since.AsTime()
+		query.Since = &since
+	}
+
+	return that.feed.Subscribe(ctx, query, func(event *snapshots.FeedEvent) error {
+		return stream.Send(&pb.PermissionChangeEvent{
+			Cursor: event.Cursor.String(),
+			Change: newPermissionChange(event.Change),
+		})
+	})
+}
+
+// namesOf - empty list of api names selects every object
+func namesOf(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
+
+// groupIdOf - groups have no record id, the numeric group id is used instead
+func groupIdOf(groupRecordId string) (int64, error) {
+	id, err := strconv.ParseInt(groupRecordId, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, snapshots.ErrGroupNotFound
+	}
+	return id, nil
+}
+
+func timestampOf(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func newSnapshots(items []*snapshots.Snapshot) []*pb.UserPermissionsSnapshot {
+	res := make([]*pb.UserPermissionsSnapshot, 0, len(items))
+	for _, snapshot := range items {
+		res = append(res, newSnapshot(snapshot))
+	}
+	return res
+}
+
+func newSnapshot(snapshot *snapshots.Snapshot) *pb.UserPermissionsSnapshot {
+	res := &pb.UserPermissionsSnapshot{
+		UserId:            snapshot.UserId,
+		TenantId:          snapshot.TenantId,
+		Permissions:       make([]*pb.ObjectPermission, 0, len(snapshot.Permissions)),
+		FieldPermissions:  make([]*pb.FieldPermission, 0, len(snapshot.FieldPermissions)),
+		GroupMemberships:  make([]*pb.GroupMembership, 0, len(snapshot.Memberships)),
+		PermissionSources: make([]*pb.PermissionSource, 0, len(snapshot.Sources)),
+		SnapshotAt:        timestamppb.New(snapshot.SnapshotAt),
+		SnapshotVersion:   snapshot.SnapshotVersion,
+	}
+
+	for _, grant := range snapshot.Permissions {
+		res.Permissions = append(res.Permissions, &pb.ObjectPermission{
+			ObjectApiName: grant.ObjectName,
+			ObjectId:      grant.ObjectId,
+			Permissions:   newSyncObjectBitmask(grant.Permissions),
+			ComputedAt:    timestamppb.New(grant.ComputedAt),
+		})
+	}
+	for _, grant := range snapshot.FieldPermissions {
+		res.FieldPermissions = append(res.FieldPermissions, &pb.FieldPermission{
+			ObjectApiName: grant.ObjectName,
+			FieldApiName:  grant.FieldName,
+			ObjectId:      grant.ObjectId,
+			FieldId:       grant.FieldId,
+			Permissions:   newSyncFieldBitmask(grant.Permissions),
+			ComputedAt:    timestamppb.New(grant.ComputedAt),
+		})
+	}
+	for _, membership := range snapshot.Memberships {
+		res.GroupMemberships = append(res.GroupMemberships, newSyncGroupMembership(membership))
+	}
+	for _, source := range snapshot.Sources {
+		res.PermissionSources = append(res.PermissionSources, newSyncPermissionSource(source))
+	}
+	return res
+}
+
+func newSyncGroupMembership(membership *snapshots.Membership) *pb.GroupMembership {
+	res := &pb.GroupMembership{
+		GroupRecordId:  membership.GroupId,
+		GroupLabel:     membership.GroupLabel,
+		GroupApiName:   membership.GroupApiName,
+		GroupType:      syncGroupTypeOf(membership.GroupType),
+		MembershipType: pb.MembershipType_MEMBERSHIP_TYPE_INHERITED,
+		JoinedAt:       timestampOf(membership.JoinedAt),
+	}
+	if membership.MembershipType == snapshots.MembershipTypeDirect {
+		res.MembershipType = pb.MembershipType_MEMBERSHIP_TYPE_DIRECT
+	}
+	if membership.RelatedEntityId != nil {
+		res.RelatedEntityId = *membership.RelatedEntityId
+	}
+	return res
+}
+
+func newSyncPermissionSource(source *snapshots.Source) *pb.PermissionSource {
+	sourceType := pb.SourceType_SOURCE_TYPE_GROUP
+	switch source.SourceType {
+	case snapshots.SourceTypeRole:
+		sourceType = pb.SourceType_SOURCE_TYPE_ROLE
+	case snapshots.SourceTypeTerritory:
+		sourceType = pb.SourceType_SOURCE_TYPE_TERRITORY
+	}
+
+	return &pb.PermissionSource{
+		SourceType:  sourceType,
+		SourceId:    source.SourceId,
+		SourceName:  source.SourceName,
+		Permissions: newSyncObjectBitmask(source.Permissions),
+		Priority:    int32(source.Priority),
+		GrantedAt:   timestampOf(source.GrantedAt),
+	}
+}
+
+func newGroupInfo(group *snapshots.GroupInfo) *pb.GroupInfo {
+	return &pb.GroupInfo{
+		GroupRecordId: group.GroupId,
+		GroupLabel:    group.GroupLabel,
+		GroupApiName:  group.GroupApiName,
+		GroupType:     syncGroupTypeOf(group.GroupType),
+		MemberCount:   int32(group.MemberCount),
+		LastModified:  timestamppb.New(group.LastModified),
+	}
+}
+
+func newPermissionChange(change *snapshots.Change) *pb.PermissionChange {
+	res := &pb.PermissionChange{
+		ChangeId:   change.ChangeId,
+		UserId:     change.UserId,
+		ChangeType: changeTypeOf(change.ChangeType),
+		ChangedAt:  timestamppb.New(change.ChangedAt),
+	}
+	if change.GroupId != nil {
+		res.GroupId = *change.GroupId
+	}
+	if change.ObjectName != nil {
+		res.ObjectApiName = *change.ObjectName
+	}
+	if change.ChangeReason != nil {
+		res.ChangeReason = *change.ChangeReason
+	}
+	if change.EventId != nil {
+		res.EventId = *change.EventId
+	}
+	return res
+}
+
+func changeTypeOf(changeType snapshots.ChangeType) pb.ChangeType {
+	switch changeType {
+	case snapshots.ChangeTypeGroupJoined:
+		return pb.ChangeType_CHANGE_TYPE_GROUP_JOINED
+	case snapshots.ChangeTypeGroupLeft:
+		return pb.ChangeType_CHANGE_TYPE_GROUP_LEFT
+	case snapshots.ChangeTypePermissionGranted:
+		return pb.ChangeType_CHANGE_TYPE_PERMISSION_GRANTED
+	case snapshots.ChangeTypePermissionRevoked:
+		return pb.ChangeType_CHANGE_TYPE_PERMISSION_REVOKED
+	case snapshots.ChangeTypePermissionChanged:
+		return pb.ChangeType_CHANGE_TYPE_PERMISSION_CHANGED
+	default:
+		return pb.ChangeType_CHANGE_TYPE_UNSPECIFIED
+	}
+}
+
+// changeTypesOf - change types without counterpart in the feed select nothing
+func changeTypesOf(changeTypes []pb.ChangeType) []snapshots.ChangeType {
+	if len(changeTypes) == 0 {
+		return nil
+	}
+
+	res := make([]snapshots.ChangeType, 0, len(changeTypes))
+	for _, changeType := range changeTypes {
+		switch changeType {
+		case pb.ChangeType_CHANGE_TYPE_GROUP_JOINED:
+			res = append(res, snapshots.ChangeTypeGroupJoined)
+		case pb.ChangeType_CHANGE_TYPE_GROUP_LEFT:
+			res = append(res, snapshots.ChangeTypeGroupLeft)
+		case pb.ChangeType_CHANGE_TYPE_PERMISSION_GRANTED:
+			res = append(res, snapshots.ChangeTypePermissionGranted)
+		case pb.ChangeType_CHANGE_TYPE_PERMISSION_REVOKED:
+			res = append(res, snapshots.ChangeTypePermissionRevoked)
+		case pb.ChangeType_CHANGE_TYPE_PERMISSION_CHANGED:
+			res = append(res, snapshots.ChangeTypePermissionChanged)
+		}
+	}
+	return res
+}
+
+func syncGroupTypeOf(groupType groups.GroupType) pb.GroupType {
+	switch {
+	case groupType.IsRoleBased():
+		return pb.GroupType_GROUP_TYPE_ROLE_BASED
+	case groupType.IsTerritoryBased():
+		return pb.GroupType_GROUP_TYPE_TERRITORY_BASED
+	default:
+		return pb.GroupType_GROUP_TYPE_MANUAL
+	}
+}
+
+// newSyncObjectBitmask - the stored object bitmask matches PermissionBitmask layout
+func newSyncObjectBitmask(access permissions.ObjectAccess) *pb.PermissionBitmask {
+	return &pb.PermissionBitmask{
+		Value:           int32(access),
+		CanRead:         access.Has(permissions.ObjectRead),
+		CanUpdate:       access.Has(permissions.ObjectUpdate),
+		CanCreate:       access.Has(permissions.ObjectCreate),
+		CanDelete:       access.Has(permissions.ObjectDelete),
+		PermissionNames: access.Names(),
+	}
+}
+
+// newSyncFieldBitmask - field WRITE permission allows both update and create of the field value
+func newSyncFieldBitmask(access permissions.FieldAccess) *pb.PermissionBitmask {
+	return &pb.PermissionBitmask{
+		Value:           int32(access),
+		CanRead:         access.Has(permissions.FieldRead),
+		CanUpdate:       access.Has(permissions.FieldWrite),
+		CanCreate:       access.Has(permissions.FieldWrite),
+		PermissionNames: access.Names(),
+	}
+}
+
+// newSyncCacheInfo - combines cache state of the snapshots
+func newSyncCacheInfo(ttl time.Duration, items ...*snapshots.Snapshot) *pb.CacheInfo {
+	var state cacheState
+	for _, snapshot := range items {
+		if info := snapshot.CacheInfo; info != nil {
+			state.add(info.FromCache, info.CachedAt, info.ExpiresAt)
+		}
+	}
+
+	info := &pb.CacheInfo{
+		FromCache:  state.count > 0 && state.cached == state.count,
+		TtlSeconds: int32(ttl.Seconds()),
+	}
+	if state.cachedAt != nil {
+		info.CachedAt = timestamppb.New(*state.cachedAt)
+	}
+	if state.expiresAt != nil {
+		info.ExpiresAt = timestamppb.New(*state.expiresAt)
+	}
+	return info
+}
@@ -26,6 +26,7 @@ const (
 	PermissionSyncService_CheckUserPermissionsChanged_FullMethodName   = "/iam.permissions.sync.v1.PermissionSyncService/CheckUserPermissionsChanged"
 	PermissionSyncService_SyncGroupPermissions_FullMethodName          = "/iam.permissions.sync.v1.PermissionSyncService/SyncGroupPermissions"
 	PermissionSyncService_GetPermissionSyncStats_FullMethodName        = "/iam.permissions.sync.v1.PermissionSyncService/GetPermissionSyncStats"
+	PermissionSyncService_StreamPermissionChanges_FullMethodName       = "/iam.permissions.sync.v1.PermissionSyncService/StreamPermissionChanges"
 )
 
 // PermissionSyncServiceClient is the client API for PermissionSyncService service.
@@ -46,6 +47,8 @@ type PermissionSyncServiceClient interface {
 	SyncGroupPermissions(ctx context.Context, in *SyncGroupPermissionsRequest, opts ...grpc.CallOption) (*SyncGroupPermissionsResponse, error)
 	// Get permission sync statistics for monitoring
 	GetPermissionSyncStats(ctx context.Context, in *GetPermissionSyncStatsRequest, opts ...grpc.CallOption) (*GetPermissionSyncStatsResponse, error)
+	// Stream permission changes of the tenant as they happen
+	StreamPermissionChanges(ctx context.Context, in *StreamPermissionChangesRequest, opts ...grpc.CallOption) (PermissionSyncService_StreamPermissionChangesClient, error)
 }
 
 type permissionSyncServiceClient struct {
@@ -119,6 +122,38 @@ func (c *permissionSyncServiceClient) GetPermissionSyncStats(ctx context.Context
 	return out, nil
 }
 
+func (c *permissionSyncServiceClient) StreamPermissionChanges(ctx context.Context, in *StreamPermissionChangesRequest, opts ...grpc.CallOption) (PermissionSyncService_StreamPermissionChangesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PermissionSyncService_ServiceDesc.Streams[0], PermissionSyncService_StreamPermissionChanges_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &permissionSyncServiceStreamPermissionChangesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PermissionSyncService_StreamPermissionChangesClient interface {
+	Recv() (*PermissionChangeEvent, error)
+	grpc.ClientStream
+}
+
+type permissionSyncServiceStreamPermissionChangesClient struct {
+	grpc.ClientStream
+}
+
+func (x *permissionSyncServiceStreamPermissionChangesClient) Recv() (*PermissionChangeEvent, error) {
+	m := new(PermissionChangeEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // PermissionSyncServiceServer is the server API for PermissionSyncService service.
 // All implementations must embed UnimplementedPermissionSyncServiceServer
 // for forward compatibility
@@ -137,6 +172,8 @@ type PermissionSyncServiceServer interface {
 	SyncGroupPermissions(context.Context, *SyncGroupPermissionsRequest) (*SyncGroupPermissionsResponse, error)
 	// Get permission sync statistics for monitoring
 	GetPermissionSyncStats(context.Context, *GetPermissionSyncStatsRequest) (*GetPermissionSyncStatsResponse, error)
+	// Stream permission changes of the tenant as they happen
+	StreamPermissionChanges(*StreamPermissionChangesRequest, PermissionSyncService_StreamPermissionChangesServer) error
 	mustEmbedUnimplementedPermissionSyncServiceServer()
 }
 
@@ -165,6 +202,9 @@ func (UnimplementedPermissionSyncServiceServer) SyncGroupPermissions(context.Con
 func (UnimplementedPermissionSyncServiceServer) GetPermissionSyncStats(context.Context, *GetPermissionSyncStatsRequest) (*GetPermissionSyncStatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetPermissionSyncStats not implemented")
 }
+func (UnimplementedPermissionSyncServiceServer) StreamPermissionChanges(*StreamPermissionChangesRequest, PermissionSyncService_StreamPermissionChangesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPermissionChanges not implemented")
+}
 func (UnimplementedPermissionSyncServiceServer) mustEmbedUnimplementedPermissionSyncServiceServer() {}
 
 // UnsafePermissionSyncServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -304,6 +344,27 @@ func _PermissionSyncService_GetPermissionSyncStats_Handler(srv interface{}, ctx
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PermissionSyncService_StreamPermissionChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamPermissionChangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PermissionSyncServiceServer).StreamPermissionChanges(m, &permissionSyncServiceStreamPermissionChangesServer{stream})
+}
+
+type PermissionSyncService_StreamPermissionChangesServer interface {
+	Send(*PermissionChangeEvent) error
+	grpc.ServerStream
+}
+
+type permissionSyncServiceStreamPermissionChangesServer struct {
+	grpc.ServerStream
+}
+
+func (x *permissionSyncServiceStreamPermissionChangesServer) Send(m *PermissionChangeEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // PermissionSyncService_ServiceDesc is the grpc.ServiceDesc for PermissionSyncService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -340,6 +401,12 @@ var PermissionSyncService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _PermissionSyncService_GetPermissionSyncStats_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPermissionChanges",
+			Handler:       _PermissionSyncService_StreamPermissionChanges_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "iam-permissions-sync.proto",
 }
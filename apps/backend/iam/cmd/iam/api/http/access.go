@@ -0,0 +1,161 @@
+package httpApi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/access"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"github.com/gin-gonic/gin"
+)
+
+type AccessHandler struct {
+	access *access.Service
+}
+
+func NewAccessHandler(access *access.Service) *AccessHandler {
+	return &AccessHandler{access: access}
+}
+
+func (that *AccessHandler) GetPermissionsCheck(c *gin.Context, params GetPermissionsCheckParams) {
+	decision, err := that.access.Check(c.Request.Context(), access.Check{
+		UserId:     params.UserId,
+		ObjectId:   int64(params.ObjectId),
+		FieldId:    toInt64Ptr(params.FieldId),
+		RowId:      toInt64Ptr(params.RowId),
+		Permission: permissions.ObjectAccess(params.Permission),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PermissionCheckResult{
+		HasPermission:      decision.Allowed,
+		UserId:             decision.UserId,
+		ObjectId:           int(decision.ObjectId),
+		FieldId:            toIntPtr(decision.FieldId),
+		RowId:              toIntPtr(decision.RowId),
+		RequiredPermission: int(decision.Permission),
+		ActualPermissions:  decision.Actual,
+		Cached:             decision.Cached,
+	})
+}
+
+func (that *AccessHandler) GetPermissionsUserUserIdObjects(c *gin.Context, userId string, params GetPermissionsUserUserIdObjectsParams) {
+	list, err := that.access.Objects(c.Request.Context(), userId, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newUserObjectPermission))
+}
+
+func (that *AccessHandler) GetPermissionsUserUserIdFields(c *gin.Context, userId string, params GetPermissionsUserUserIdFieldsParams) {
+	filter := access.Filter{
+		ObjectId: toInt64Ptr(params.ObjectId),
+	}
+
+	list, err := that.access.Fields(c.Request.Context(), userId, filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newUserFieldPermission))
+}
+
+func (that *AccessHandler) GetPermissionsUserUserIdRows(c *gin.Context, userId string, params GetPermissionsUserUserIdRowsParams) {
+	filter := access.Filter{
+		ObjectId: toInt64Ptr(params.ObjectId),
+	}
+
+	list, err := that.access.Rows(c.Request.Context(), userId, filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newUserRowPermission))
+}
+
+// PermissionCheckResult - response of the permission check, the contract declares it inline
+type PermissionCheckResult struct {
+	HasPermission      bool   `json:"has_permission"`
+	UserId             string `json:"user_id"`
+	ObjectId           int    `json:"object_id"`
+	FieldId            *int   `json:"field_id"`
+	RowId              *int   `json:"row_id"`
+	RequiredPermission int    `json:"required_permission"`
+	ActualPermissions  int    `json:"actual_permissions"`
+	Cached             bool   `json:"cached"`
+}
+
+// UserObjectPermission - item of the user object permissions list, the contract declares it inline
+type UserObjectPermission struct {
+	ObjectId        int      `json:"object_id"`
+	ObjectName      string   `json:"object_name"`
+	Permissions     int      `json:"permissions"`
+	PermissionNames []string `json:"permission_names"`
+	Cached          bool     `json:"cached"`
+}
+
+// UserFieldPermission - item of the user field permissions list, the contract declares it inline
+type UserFieldPermission struct {
+	ObjectId        int      `json:"object_id"`
+	ObjectName      string   `json:"object_name"`
+	FieldId         int      `json:"field_id"`
+	FieldName       string   `json:"field_name"`
+	Permissions     int      `json:"permissions"`
+	PermissionNames []string `json:"permission_names"`
+	Restrictions    int      `json:"restrictions"`
+	Cached          bool     `json:"cached"`
+}
+
+// UserRowPermission - item of the user row permissions list, the contract declares it inline
+type UserRowPermission struct {
+	ObjectId        int       `json:"object_id"`
+	ObjectName      string    `json:"object_name"`
+	RowId           int       `json:"row_id"`
+	Permissions     int       `json:"permissions"`
+	PermissionNames []string  `json:"permission_names"`
+	CachedAt        time.Time `json:"cached_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+func newUserObjectPermission(grant *access.ObjectGrant) UserObjectPermission {
+	return UserObjectPermission{
+		ObjectId:        int(grant.ObjectId),
+		ObjectName:      grant.ObjectName,
+		Permissions:     int(grant.Permissions),
+		PermissionNames: grant.Permissions.Names(),
+		Cached:          grant.Cached,
+	}
+}
+
+func newUserFieldPermission(grant *access.FieldGrant) UserFieldPermission {
+	return UserFieldPermission{
+		ObjectId:        int(grant.ObjectId),
+		ObjectName:      grant.ObjectName,
+		FieldId:         int(grant.FieldId),
+		FieldName:       grant.FieldName,
+		Permissions:     int(grant.Permissions),
+		PermissionNames: grant.Permissions.Names(),
+		Restrictions:    int(grant.Restriction),
+		Cached:          grant.Cached,
+	}
+}
+
+func newUserRowPermission(grant *access.RowGrant) UserRowPermission {
+	return UserRowPermission{
+		ObjectId:        int(grant.ObjectId),
+		ObjectName:      grant.ObjectName,
+		RowId:           int(grant.RowId),
+		Permissions:     int(grant.Permissions),
+		PermissionNames: grant.Permissions.Names(),
+		CachedAt:        grant.CachedAt,
+		ExpiresAt:       grant.ExpiresAt,
+	}
+}
@@ -0,0 +1,171 @@
+package httpApi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services/auth"
+	"github.com/adverax/metacrm/apps/backend/iam/services/users"
+	"github.com/gin-gonic/gin"
+)
+
+const TokenTypeBearer = "Bearer"
+
+type AuthHandler struct {
+	auth  *auth.Service
+	users *users.Service
+}
+
+func NewAuthHandler(auth *auth.Service, users *users.Service) *AuthHandler {
+	return &AuthHandler{auth: auth, users: users}
+}
+
+func (that *AuthHandler) PostAuthLogin(c *gin.Context) {
+	var body PostAuthLoginJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	tokens, err := that.auth.Login(c.Request.Context(), auth.Credentials{
+		Email:    string(body.Email),
+		Password: body.Password,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	that.respondTokens(c, tokens)
+}
+
+// PostAuthRefresh - the operation is anonymous, so the expired access token does not prevent the refresh,
+// the tenant of the session is bound by ActorMiddleware from X-Tenant-Id header
+func (that *AuthHandler) PostAuthRefresh(c *gin.Context) {
+	var body PostAuthRefreshJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	tokens, err := that.auth.Refresh(c.Request.Context(), body.RefreshToken)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	that.respondTokens(c, tokens)
+}
+
+func (that *AuthHandler) PostAuthLogout(c *gin.Context) {
+	if err := that.auth.Logout(c.Request.Context(), bearerToken(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *AuthHandler) GetAuthMe(c *gin.Context) {
+	user, err := that.users.Get(c.Request.Context(), authenticationOf(c).UserId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUser(user))
+}
+
+func (that *AuthHandler) PostAuthRegister(c *gin.Context) {
+	var body PostAuthRegisterJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	userId, err := that.auth.Register(c.Request.Context(), auth.Registration{
+		Name:     body.Name,
+		Email:    string(body.Email),
+		Password: body.Password,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	user, err := that.users.Get(c.Request.Context(), userId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newUser(user))
+}
+
+func (that *AuthHandler) PostAuthForgotPassword(c *gin.Context) {
+	var body PostAuthForgotPasswordJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	err := that.auth.ForgotPassword(c.Request.Context(), auth.PasswordResetRequest{
+		Email: string(body.Email),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthMessage{Message: "Password reset email sent"})
+}
+
+func (that *AuthHandler) PostAuthResetPassword(c *gin.Context) {
+	var body PostAuthResetPasswordJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	err := that.auth.ResetPassword(c.Request.Context(), auth.PasswordReset{
+		Token:    body.Token,
+		Password: body.Password,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthMessage{Message: "Password reset successfully"})
+}
+
+// respondTokens - writes issued tokens together with the authenticated user
+func (that *AuthHandler) respondTokens(c *gin.Context, tokens *auth.Tokens) {
+	user, err := that.users.Get(c.Request.Context(), tokens.UserId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    TokenTypeBearer,
+		ExpiresIn:    int(tokens.ExpiresIn.Seconds()),
+		User:         newUser(user),
+	})
+}
+
+// AuthMessage - response of the password reset operations, the contract declares it inline
+type AuthMessage struct {
+	Message string `json:"message"`
+}
+
+// bearerToken - extracts token from the Authorization header
+func bearerToken(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, TokenTypeBearer) {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
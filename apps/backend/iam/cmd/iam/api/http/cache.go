@@ -0,0 +1,157 @@
+package httpApi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services/cache"
+	"github.com/gin-gonic/gin"
+)
+
+type CacheHandler struct {
+	cache *cache.Service
+}
+
+func NewCacheHandler(cache *cache.Service) *CacheHandler {
+	return &CacheHandler{cache: cache}
+}
+
+func (that *CacheHandler) PostCacheInvalidateUserUserId(c *gin.Context, userId string) {
+	invalidation, err := that.cache.InvalidateUser(c.Request.Context(), userId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, UserCacheInvalidation{
+		Message:       "User cache invalidated successfully",
+		UserId:        userId,
+		InvalidatedAt: invalidation.InvalidatedAt,
+	})
+}
+
+func (that *CacheHandler) PostCacheInvalidateGroupGroupId(c *gin.Context, groupId int) {
+	invalidation, err := that.cache.InvalidateGroup(c.Request.Context(), int64(groupId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, GroupCacheInvalidation{
+		Message:         "Group cache invalidated successfully",
+		GroupId:         groupId,
+		MembersAffected: invalidation.MembersAffected,
+		InvalidatedAt:   invalidation.InvalidatedAt,
+	})
+}
+
+func (that *CacheHandler) PostCacheInvalidateObjectObjectId(c *gin.Context, objectId int) {
+	invalidation, err := that.cache.InvalidateObject(c.Request.Context(), int64(objectId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ObjectCacheInvalidation{
+		Message:       "Object cache invalidated successfully",
+		ObjectId:      objectId,
+		InvalidatedAt: invalidation.InvalidatedAt,
+	})
+}
+
+func (that *CacheHandler) PostCacheCleanup(c *gin.Context) {
+	cleanup, err := that.cache.Cleanup(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, CacheCleanup{
+		Message:           "Cache cleanup completed successfully",
+		EntriesRemoved:    int(cleanup.EntriesRemoved),
+		CleanupDurationMs: int(cleanup.Duration.Milliseconds()),
+		CleanedAt:         cleanup.CleanedAt,
+	})
+}
+
+func (that *CacheHandler) GetCacheStats(c *gin.Context, params GetCacheStatsParams) {
+	stats, err := that.cache.Stats(c.Request.Context(), params.TenantId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	tables := make([]CacheTableStats, 0, len(stats.Tables))
+	for _, table := range stats.Tables {
+		tables = append(tables, CacheTableStats{
+			TableName:      table.TableName,
+			TotalRecords:   int(table.TotalRecords),
+			ExpiredRecords: int(table.ExpiredRecords),
+			ActiveRecords:  int(table.ActiveRecords),
+			CacheHitRatio:  float32(table.HitRatio),
+		})
+	}
+
+	c.JSON(http.StatusOK, CacheStats{
+		CacheTables: tables,
+		Summary: CacheStatsSummary{
+			TotalRecords:    int(stats.TotalRecords),
+			TotalExpired:    int(stats.TotalExpired),
+			TotalActive:     int(stats.TotalActive),
+			OverallHitRatio: float32(stats.HitRatio),
+		},
+		GeneratedAt: stats.GeneratedAt,
+	})
+}
+
+// UserCacheInvalidation - response of the user cache invalidation, the contract declares it inline
+type UserCacheInvalidation struct {
+	Message       string    `json:"message"`
+	UserId        string    `json:"user_id"`
+	InvalidatedAt time.Time `json:"invalidated_at"`
+}
+
+// GroupCacheInvalidation - response of the group cache invalidation, the contract declares it inline
+type GroupCacheInvalidation struct {
+	Message         string    `json:"message"`
+	GroupId         int       `json:"group_id"`
+	MembersAffected int       `json:"members_affected"`
+	InvalidatedAt   time.Time `json:"invalidated_at"`
+}
+
+// ObjectCacheInvalidation - response of the object cache invalidation, the contract declares it inline
+type ObjectCacheInvalidation struct {
+	Message       string    `json:"message"`
+	ObjectId      int       `json:"object_id"`
+	InvalidatedAt time.Time `json:"invalidated_at"`
+}
+
+// CacheCleanup - response of the cache cleanup, the contract declares it inline
+type CacheCleanup struct {
+	Message           string    `json:"message"`
+	EntriesRemoved    int       `json:"entries_removed"`
+	CleanupDurationMs int       `json:"cleanup_duration_ms"`
+	CleanedAt         time.Time `json:"cleaned_at"`
+}
+
+// CacheStats - response of the cache statistics, the contract declares it inline
+type CacheStats struct {
+	CacheTables []CacheTableStats `json:"cache_tables"`
+	Summary     CacheStatsSummary `json:"summary"`
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+type CacheTableStats struct {
+	TableName      string  `json:"table_name"`
+	TotalRecords   int     `json:"total_records"`
+	ExpiredRecords int     `json:"expired_records"`
+	ActiveRecords  int     `json:"active_records"`
+	CacheHitRatio  float32 `json:"cache_hit_ratio"`
+}
+
+type CacheStatsSummary struct {
+	TotalRecords    int     `json:"total_records"`
+	TotalExpired    int     `json:"total_expired"`
+	TotalActive     int     `json:"total_active"`
+	OverallHitRatio float32 `json:"overall_hit_ratio"`
+}
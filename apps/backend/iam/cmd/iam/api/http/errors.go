@@ -0,0 +1,155 @@
+package httpApi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/access"
+	"github.com/adverax/metacrm/apps/backend/iam/services/auth"
+	"github.com/adverax/metacrm/apps/backend/iam/services/cache"
+	"github.com/adverax/metacrm/apps/backend/iam/services/groups"
+	"github.com/adverax/metacrm/apps/backend/iam/services/hierarchy"
+	"github.com/adverax/metacrm/apps/backend/iam/services/objects"
+	"github.com/adverax/metacrm/apps/backend/iam/services/outbox"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"github.com/adverax/metacrm/apps/backend/iam/services/principals"
+	"github.com/adverax/metacrm/apps/backend/iam/services/roles"
+	"github.com/adverax/metacrm/apps/backend/iam/services/territories"
+	"github.com/adverax/metacrm/apps/backend/iam/services/users"
+	"github.com/adverax/metacrm/apps/backend/iam/services/webhooks"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIdHeader = "X-Request-Id"
+
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings - translation of domain errors into HTTP responses.
+// The first matching entry wins, so specific errors must precede generic ones.
+var errorMappings = []errorMapping{
+	{services.ErrTenantRequired, http.StatusUnauthorized, "UNAUTHORIZED"},
+
+	{users.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{users.ErrEmailExists, http.StatusConflict, "EMAIL_EXISTS"},
+	{users.ErrManagerNotFound, http.StatusBadRequest, "MANAGER_NOT_FOUND"},
+	{users.ErrManagerCycle, http.StatusConflict, "MANAGER_CYCLE"},
+
+	{roles.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{roles.ErrApiNameExists, http.StatusConflict, "API_NAME_EXISTS"},
+	{roles.ErrParentNotFound, http.StatusBadRequest, "PARENT_NOT_FOUND"},
+	{roles.ErrHasChildren, http.StatusConflict, "HAS_CHILDREN"},
+	{roles.ErrInUse, http.StatusConflict, "IN_USE"},
+
+	{territories.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{territories.ErrApiNameExists, http.StatusConflict, "API_NAME_EXISTS"},
+	{territories.ErrParentNotFound, http.StatusBadRequest, "PARENT_NOT_FOUND"},
+	{territories.ErrHasChildren, http.StatusConflict, "HAS_CHILDREN"},
+	{territories.ErrInUse, http.StatusConflict, "IN_USE"},
+
+	{groups.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{groups.ErrApiNameExists, http.StatusConflict, "API_NAME_EXISTS"},
+	{groups.ErrRoleNotFound, http.StatusBadRequest, "ROLE_NOT_FOUND"},
+	{groups.ErrTerritoryNotFound, http.StatusBadRequest, "TERRITORY_NOT_FOUND"},
+	{groups.ErrMemberNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{groups.ErrMemberUserNotFound, http.StatusBadRequest, "MEMBER_USER_NOT_FOUND"},
+	{groups.ErrMemberGroupNotFound, http.StatusBadRequest, "MEMBER_GROUP_NOT_FOUND"},
+	{groups.ErrMemberExists, http.StatusConflict, "MEMBER_EXISTS"},
+	{groups.ErrMembershipCycle, http.StatusConflict, "MEMBERSHIP_CYCLE"},
+
+	{principals.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{principals.ErrLoginExists, http.StatusConflict, "LOGIN_EXISTS"},
+	{principals.ErrSubjectNotFound, http.StatusBadRequest, "SUBJECT_NOT_FOUND"},
+	{principals.ErrIdentityNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{principals.ErrIdentityExists, http.StatusConflict, "IDENTITY_EXISTS"},
+	{principals.ErrPrincipalNotFound, http.StatusBadRequest, "PRINCIPAL_NOT_FOUND"},
+	{principals.ErrPrincipalInactive, http.StatusConflict, "PRINCIPAL_INACTIVE"},
+
+	{objects.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{objects.ErrApiNameExists, http.StatusConflict, "API_NAME_EXISTS"},
+	{objects.ErrFieldNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{objects.ErrFieldApiNameExists, http.StatusConflict, "API_NAME_EXISTS"},
+
+	{permissions.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{permissions.ErrApiNameExists, http.StatusConflict, "API_NAME_EXISTS"},
+	{permissions.ErrObjectNotFound, http.StatusBadRequest, "OBJECT_NOT_FOUND"},
+	{permissions.ErrFieldNotFound, http.StatusBadRequest, "FIELD_NOT_FOUND"},
+	{permissions.ErrObjectPermissionExists, http.StatusConflict, "PERMISSION_EXISTS"},
+	{permissions.ErrObjectPermissionNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{permissions.ErrFieldPermissionExists, http.StatusConflict, "PERMISSION_EXISTS"},
+	{permissions.ErrFieldPermissionNotFound, http.StatusNotFound, "NOT_FOUND"},
+
+	{access.ErrUserNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{access.ErrObjectNotFound, http.StatusBadRequest, "OBJECT_NOT_FOUND"},
+	{access.ErrFieldNotFound, http.StatusBadRequest, "FIELD_NOT_FOUND"},
+
+	{cache.ErrUserNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{cache.ErrGroupNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{cache.ErrObjectNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{cache.ErrForeignTenant, http.StatusForbidden, "FORBIDDEN"},
+
+	{auth.ErrEmailExists, http.StatusConflict, "EMAIL_EXISTS"},
+	{auth.ErrInvalidCredentials, http.StatusUnauthorized, "INVALID_CREDENTIALS"},
+	{auth.ErrInvalidToken, http.StatusUnauthorized, "UNAUTHORIZED"},
+	{auth.ErrTokenReused, http.StatusUnauthorized, "TOKEN_REUSED"},
+	{auth.ErrInvalidResetToken, http.StatusUnauthorized, "INVALID_RESET_TOKEN"},
+
+	{webhooks.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+
+	{outbox.ErrNotFound, http.StatusNotFound, "NOT_FOUND"},
+	{outbox.ErrInvalidRetention, http.StatusBadRequest, "VALIDATION_ERROR"},
+
+	{hierarchy.ErrCycle, http.StatusConflict, "HIERARCHY_CYCLE"},
+
+	{sql.ErrAlreadyExists, http.StatusConflict, "CONFLICT"},
+	{sql.ErrInvalid, http.StatusBadRequest, "VALIDATION_ERROR"},
+}
+
+// respondError - writes error response for the given error
+func respondError(c *gin.Context, err error) {
+	if validation.IsValidationError(err) {
+		abortWithError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid input data", map[string]interface{}{
+			"fields": err,
+		})
+		return
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			abortWithError(c, m.status, m.code, m.err.Error(), nil)
+			return
+		}
+	}
+
+	_ = c.Error(err)
+	abortWithError(c, http.StatusInternalServerError, "INTERNAL_ERROR", "An internal error occurred", nil)
+}
+
+// respondBadRequest - writes error response for malformed request
+func respondBadRequest(c *gin.Context, err error) {
+	abortWithError(c, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid input data", map[string]interface{}{
+		"reason": err.Error(),
+	})
+}
+
+func abortWithError(c *gin.Context, status int, code, message string, details map[string]interface{}) {
+	resp := Error{
+		Error:     code,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	}
+	if len(details) != 0 {
+		resp.Details = &details
+	}
+	if id := c.GetHeader(RequestIdHeader); id != "" {
+		resp.RequestId = &id
+	}
+	c.AbortWithStatusJSON(status, resp)
+}
@@ -0,0 +1,305 @@
+package httpApi
+
+import (
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/groups"
+	"github.com/adverax/metacrm/apps/backend/iam/services/users"
+	"github.com/gin-gonic/gin"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+type GroupHandler struct {
+	groups *groups.Service
+	users  *users.Service
+}
+
+func NewGroupHandler(groups *groups.Service, users *users.Service) *GroupHandler {
+	return &GroupHandler{groups: groups, users: users}
+}
+
+func (that *GroupHandler) GetGroups(c *gin.Context, params GetGroupsParams) {
+	filter := groups.Filter{}
+	if params.Search != nil {
+		filter.Search = *params.Search
+	}
+	if params.Type != nil {
+		filter.Type = groups.GroupType(*params.Type)
+	}
+
+	list, err := that.groups.List(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newGroup))
+}
+
+func (that *GroupHandler) PostGroups(c *gin.Context) {
+	var body PostGroupsJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	group, err := that.groups.Create(c.Request.Context(), groups.CreateGroup{
+		Label:              body.Label,
+		ApiName:            body.ApiName,
+		Type:               groups.GroupType(body.Type),
+		Email:              fromEmailPtr(body.Email),
+		RelatedRoleId:      toInt64Ptr(body.RelatedRoleId),
+		RelatedTerritoryId: toInt64Ptr(body.RelatedTerritoryId),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newGroup(group))
+}
+
+func (that *GroupHandler) DeleteGroupsGroupId(c *gin.Context, groupId int) {
+	if err := that.groups.Delete(c.Request.Context(), int64(groupId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *GroupHandler) GetGroupsGroupId(c *gin.Context, groupId int) {
+	group, err := that.groups.Get(c.Request.Context(), int64(groupId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newGroup(group))
+}
+
+func (that *GroupHandler) PutGroupsGroupId(c *gin.Context, groupId int) {
+	var body PutGroupsGroupIdJSONRequestBody
+	fields, err := bindPartialJSON(c, &body)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := groups.UpdateGroup{
+		Label:   body.Label,
+		ApiName: body.ApiName,
+	}
+	if body.Type != nil {
+		groupType := groups.GroupType(*body.Type)
+		request.Type = &groupType
+	}
+	if fields.Has("email") {
+		email := fromEmailPtr(body.Email)
+		request.Email = &email
+	}
+	if fields.Has("related_role_id") {
+		roleId := toInt64Ptr(body.RelatedRoleId)
+		request.RelatedRoleId = &roleId
+	}
+	if fields.Has("related_territory_id") {
+		territoryId := toInt64Ptr(body.RelatedTerritoryId)
+		request.RelatedTerritoryId = &territoryId
+	}
+
+	group, err := that.groups.Update(c.Request.Context(), int64(groupId), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newGroup(group))
+}
+
+func (that *GroupHandler) GetGroupsGroupIdMembers(c *gin.Context, groupId int, params GetGroupsGroupIdMembersParams) {
+	ctx := c.Request.Context()
+	page := services.NewPage(params.Page, params.Limit)
+
+	if params.Expand != nil && *params.Expand {
+		group, err := that.groups.Get(ctx, int64(groupId))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		list, err := that.users.InGroup(ctx, group.Id, page)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, newUserList(list))
+		return
+	}
+
+	list, err := that.groups.Members(ctx, int64(groupId), page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newGroupMember))
+}
+
+func (that *GroupHandler) PostGroupsGroupIdMembers(c *gin.Context, groupId int) {
+	var body PostGroupsGroupIdMembersJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	member, err := that.groups.AddMember(c.Request.Context(), groups.AddMember{
+		GroupId:       int64(groupId),
+		MemberUserId:  body.MemberUserId,
+		MemberGroupId: toInt64Ptr(body.MemberGroupId),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newGroupMember(member))
+}
+
+func (that *GroupHandler) DeleteGroupsGroupIdMembersMemberId(c *gin.Context, groupId int, memberId string) {
+	id := int64(groupId)
+	if err := that.groups.RemoveMember(c.Request.Context(), memberId, &id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *GroupHandler) GetGroupMembers(c *gin.Context, params GetGroupMembersParams) {
+	filter := groups.MemberFilter{
+		GroupId:       toInt64Ptr(params.GroupId),
+		MemberGroupId: toInt64Ptr(params.MemberGroupId),
+	}
+	if params.MemberUserId != nil {
+		filter.MemberUserId = *params.MemberUserId
+	}
+
+	list, err := that.groups.ListMembers(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newGroupMember))
+}
+
+func (that *GroupHandler) PostGroupMembers(c *gin.Context) {
+	var body PostGroupMembersJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := groups.AddMember{
+		MemberUserId:  body.MemberUserId,
+		MemberGroupId: toInt64Ptr(body.MemberGroupId),
+	}
+	if body.GroupId != nil {
+		request.GroupId = int64(*body.GroupId)
+	}
+
+	member, err := that.groups.AddMember(c.Request.Context(), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newGroupMember(member))
+}
+
+func (that *GroupHandler) DeleteGroupMembersMemberId(c *gin.Context, memberId string) {
+	if err := that.groups.RemoveMember(c.Request.Context(), memberId, nil); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *GroupHandler) GetGroupMembersMemberId(c *gin.Context, memberId string) {
+	member, err := that.groups.GetMember(c.Request.Context(), memberId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newGroupMember(member))
+}
+
+func (that *GroupHandler) PutGroupMembersMemberId(c *gin.Context, memberId string) {
+	var body PutGroupMembersMemberIdJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	member, err := that.groups.UpdateMember(c.Request.Context(), memberId, groups.UpdateMember{
+		GroupId:       toInt64Ptr(body.GroupId),
+		MemberUserId:  body.MemberUserId,
+		MemberGroupId: toInt64Ptr(body.MemberGroupId),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newGroupMember(member))
+}
+
+func newGroup(group *groups.Group) Group {
+	return Group{
+		Id:                 int(group.Id),
+		TenantId:           group.TenantId,
+		Label:              group.Label,
+		ApiName:            group.ApiName,
+		Type:               GroupType(group.Type),
+		Email:              toEmailPtr(group.Email),
+		RelatedRoleId:      toIntPtr(group.RelatedRoleId),
+		RelatedTerritoryId: toIntPtr(group.RelatedTerritoryId),
+		CreatedAt:          group.CreatedAt,
+		UpdatedAt:          group.UpdatedAt,
+		DeletedAt:          group.DeletedAt,
+	}
+}
+
+func newGroupMember(member *groups.Member) GroupMember {
+	return GroupMember{
+		Id:            int(member.Id),
+		RecordId:      member.RecordId,
+		TenantId:      member.TenantId,
+		GroupId:       int(member.GroupId),
+		MemberUserId:  member.MemberUserId,
+		MemberGroupId: toIntPtr(member.MemberGroupId),
+		CreatedAt:     member.CreatedAt,
+		UpdatedAt:     member.UpdatedAt,
+		DeletedAt:     member.DeletedAt,
+	}
+}
+
+func fromEmailPtr(v *openapi_types.Email) *string {
+	if v == nil {
+		return nil
+	}
+	r := string(*v)
+	return &r
+}
+
+func toEmailPtr(v *string) *openapi_types.Email {
+	if v == nil {
+		return nil
+	}
+	r := openapi_types.Email(*v)
+	return &r
+}
@@ -0,0 +1,25 @@
+package httpApi
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// JwksPath - well-known location of the signing keys, it is not a part of the versioned API
+const JwksPath = "/.well-known/jwks.json"
+
+// jwksMaxAge - how long verifiers may cache the key set.
+// Retired keys must stay published at least that long after rotation.
+const jwksMaxAge = 5 * time.Minute
+
+// NewJwksHandler - publishes public keys, so services verify access tokens offline
+func NewJwksHandler(keys *jwt.KeySet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+		c.JSON(http.StatusOK, keys.JWKS())
+	}
+}
@@ -0,0 +1,157 @@
+package httpApi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/auth"
+	"github.com/adverax/metacrm/pkg/log"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	TenantIdHeader    = "X-Tenant-Id"
+	OperatorKeyHeader = "X-Api-Key"
+)
+
+// authenticationKey - key of the authentication within gin context
+const authenticationKey = "iam.authentication"
+
+// Authenticator - verifies the access token of the request
+type Authenticator interface {
+	Authenticate(ctx context.Context, accessToken string) (*auth.Authentication, error)
+}
+
+// ActorMiddleware - binds tenant and principal of the request to the request context.
+// Operations of the contract requiring security are authenticated by AuthMiddleware.
+// Operations declared without security (login, registration, refresh and password reset)
+// are anonymous, their tenant is taken from X-Tenant-Id header.
+// Operations of the operators are served by the administration listener, so they are not found here.
+func ActorMiddleware(authenticator Authenticator) MiddlewareFunc {
+	authenticate := AuthMiddleware(authenticator)
+	return func(c *gin.Context) {
+		if _, operator := c.Get(OperatorKeyScopes); operator {
+			abortWithError(c, http.StatusNotFound, "NOT_FOUND", "operation is not found", nil)
+			return
+		}
+
+		if _, secured := c.Get(BearerAuthScopes); secured {
+			authenticate(c)
+			return
+		}
+
+		tenant := c.GetHeader(TenantIdHeader)
+		if tenant == "" {
+			return
+		}
+
+		tenantId, err := uuid.Parse(tenant)
+		if err != nil {
+			respondBadRequest(c, fmt.Errorf("invalid %s header: %w", TenantIdHeader, err))
+			return
+		}
+
+		c.Request = c.Request.WithContext(services.WithActor(c.Request.Context(), services.Actor{TenantId: tenantId}))
+	}
+}
+
+// AuthMiddleware - authenticates the request by the bearer token and binds its principal
+// to the request context, requests without valid token are rejected
+func AuthMiddleware(authenticator Authenticator) MiddlewareFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			abortWithError(c, http.StatusUnauthorized, "UNAUTHORIZED", "bearer token is required", nil)
+			return
+		}
+
+		authentication, err := authenticator.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		actor := services.Actor{TenantId: authentication.TenantId, PrincipalId: authentication.PrincipalId}
+		c.Request = c.Request.WithContext(services.WithActor(c.Request.Context(), actor))
+		c.Set(authenticationKey, authentication)
+	}
+}
+
+// authenticationOf - returns authentication of the request passed AuthMiddleware
+func authenticationOf(c *gin.Context) *auth.Authentication {
+	return c.MustGet(authenticationKey).(*auth.Authentication)
+}
+
+// OperatorMiddleware - admits operators of the installation by the API key of X-Api-Key header
+// to the operations of the contract secured by OperatorKey, other operations are not found.
+// Keys are configured by their hex encoded SHA-256 digests, so the configuration does not disclose them.
+// Without configured keys every request is rejected.
+func OperatorMiddleware(digests []string) (MiddlewareFunc, error) {
+	keys := make([][]byte, len(digests))
+	for i, digest := range digests {
+		key, err := hex.DecodeString(digest)
+		if err != nil || len(key) != sha256.Size {
+			return nil, fmt.Errorf("operator key %d is not a hex encoded SHA-256 digest", i)
+		}
+		keys[i] = key
+	}
+
+	return func(c *gin.Context) {
+		if _, operator := c.Get(OperatorKeyScopes); !operator {
+			abortWithError(c, http.StatusNotFound, "NOT_FOUND", "operation is not found", nil)
+			return
+		}
+
+		key := c.GetHeader(OperatorKeyHeader)
+		if key == "" {
+			abortWithError(c, http.StatusUnauthorized, "UNAUTHORIZED", "operator key is required", nil)
+			return
+		}
+
+		digest := sha256.Sum256([]byte(key))
+		matched := 0
+		for _, k := range keys {
+			matched |= subtle.ConstantTimeCompare(digest[:], k)
+		}
+		if matched == 0 {
+			abortWithError(c, http.StatusForbidden, "FORBIDDEN", "operator key is not accepted", nil)
+			return
+		}
+	}, nil
+}
+
+// ClientMiddleware - binds address and user agent of the client to the request context
+func ClientMiddleware() MiddlewareFunc {
+	return func(c *gin.Context) {
+		client := services.Client{IpAddress: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+		c.Request = c.Request.WithContext(services.WithClient(c.Request.Context(), client))
+	}
+}
+
+// ErrorLogger - logs unexpected errors collected while handling the request
+func ErrorLogger(logger log.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Status() < http.StatusInternalServerError {
+			return
+		}
+
+		for _, err := range c.Errors {
+			logger.
+				WithError(err.Err).
+				WithFields(log.Fields{
+					"http.method": c.Request.Method,
+					"http.path":   c.FullPath(),
+					"http.status": c.Writer.Status(),
+				}).
+				Error(c.Request.Context(), "http_request_failed")
+		}
+	}
+}
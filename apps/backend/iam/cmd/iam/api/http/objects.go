@@ -0,0 +1,174 @@
+package httpApi
+
+import (
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/objects"
+	"github.com/gin-gonic/gin"
+)
+
+type ObjectHandler struct {
+	objects *objects.Service
+}
+
+func NewObjectHandler(objects *objects.Service) *ObjectHandler {
+	return &ObjectHandler{objects: objects}
+}
+
+func (that *ObjectHandler) GetSecurityObjects(c *gin.Context, params GetSecurityObjectsParams) {
+	filter := objects.Filter{}
+	if params.Search != nil {
+		filter.Search = *params.Search
+	}
+
+	list, err := that.objects.List(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newSecurityObject))
+}
+
+func (that *ObjectHandler) PostSecurityObjects(c *gin.Context) {
+	var body PostSecurityObjectsJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	object, err := that.objects.Create(c.Request.Context(), objects.CreateObject{
+		ApiName: body.ApiName,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newSecurityObject(object))
+}
+
+func (that *ObjectHandler) DeleteSecurityObjectsObjectId(c *gin.Context, objectId int) {
+	if err := that.objects.Delete(c.Request.Context(), int64(objectId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *ObjectHandler) GetSecurityObjectsObjectId(c *gin.Context, objectId int) {
+	object, err := that.objects.Get(c.Request.Context(), int64(objectId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newSecurityObject(object))
+}
+
+func (that *ObjectHandler) PutSecurityObjectsObjectId(c *gin.Context, objectId int) {
+	var body PutSecurityObjectsObjectIdJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	object, err := that.objects.Update(c.Request.Context(), int64(objectId), objects.UpdateObject{
+		ApiName: body.ApiName,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newSecurityObject(object))
+}
+
+func (that *ObjectHandler) GetSecurityObjectsObjectIdFields(c *gin.Context, objectId int, params GetSecurityObjectsObjectIdFieldsParams) {
+	list, err := that.objects.Fields(c.Request.Context(), int64(objectId), services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newSecurityField))
+}
+
+func (that *ObjectHandler) PostSecurityObjectsObjectIdFields(c *gin.Context, objectId int) {
+	var body PostSecurityObjectsObjectIdFieldsJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	field, err := that.objects.CreateField(c.Request.Context(), objects.CreateField{
+		ObjectId: int64(objectId),
+		ApiName:  body.ApiName,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newSecurityField(field))
+}
+
+func (that *ObjectHandler) DeleteSecurityFieldsFieldId(c *gin.Context, fieldId int) {
+	if err := that.objects.DeleteField(c.Request.Context(), int64(fieldId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *ObjectHandler) GetSecurityFieldsFieldId(c *gin.Context, fieldId int) {
+	field, err := that.objects.GetField(c.Request.Context(), int64(fieldId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newSecurityField(field))
+}
+
+func (that *ObjectHandler) PutSecurityFieldsFieldId(c *gin.Context, fieldId int) {
+	var body PutSecurityFieldsFieldIdJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	field, err := that.objects.UpdateField(c.Request.Context(), int64(fieldId), objects.UpdateField{
+		ApiName: body.ApiName,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newSecurityField(field))
+}
+
+func newSecurityObject(object *objects.Object) SecurityObject {
+	return SecurityObject{
+		Id:        int(object.Id),
+		TenantId:  object.TenantId,
+		ApiName:   object.ApiName,
+		CreatedAt: object.CreatedAt,
+		UpdatedAt: object.UpdatedAt,
+	}
+}
+
+func newSecurityField(field *objects.Field) SecurityField {
+	return SecurityField{
+		Id:        int(field.Id),
+		TenantId:  field.TenantId,
+		ObjectId:  int(field.ObjectId),
+		ApiName:   field.ApiName,
+		CreatedAt: field.CreatedAt,
+		UpdatedAt: field.UpdatedAt,
+	}
+}
@@ -0,0 +1,126 @@
+package httpApi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/outbox"
+	"github.com/gin-gonic/gin"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+// OutboxHandler - administration of the outbox shared by all tenants: dead events and purge of delivered events.
+// Operations are secured by OperatorKey, so they are served by the administration listener only.
+type OutboxHandler struct {
+	outbox    *outbox.Service
+	retention time.Duration
+}
+
+// NewOutboxHandler - retention is applied by the purge without explicit older_than
+func NewOutboxHandler(outbox *outbox.Service, retention time.Duration) *OutboxHandler {
+	return &OutboxHandler{outbox: outbox, retention: retention}
+}
+
+func (that *OutboxHandler) GetAdminOutboxDead(c *gin.Context, params GetAdminOutboxDeadParams) {
+	filter := newDeadFilter(params.Id, params.EventType, params.TenantId, params.From, params.To)
+
+	list, err := that.outbox.ListDead(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newDeadEvent))
+}
+
+func (that *OutboxHandler) GetAdminOutboxDeadEventId(c *gin.Context, eventId int64) {
+	event, err := that.outbox.GetDead(c.Request.Context(), eventId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newDeadEvent(event))
+}
+
+func (that *OutboxHandler) PostAdminOutboxDeadEventIdRequeue(c *gin.Context, eventId int64) {
+	if err := that.outbox.Requeue(c.Request.Context(), eventId); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxResult{Count: 1})
+}
+
+// PostAdminOutboxDeadRequeue - requeues dead events selected by the body, "all" is required to requeue every dead event
+func (that *OutboxHandler) PostAdminOutboxDeadRequeue(c *gin.Context) {
+	var body PostAdminOutboxDeadRequeueJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	filter := newDeadFilter(body.Ids, body.EventType, body.TenantId, body.From, body.To)
+	if filter.IsEmpty() && (body.All == nil || !*body.All) {
+		respondBadRequest(c, errors.New("ids, filters or all are required"))
+		return
+	}
+
+	count, err := that.outbox.RequeueDead(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxResult{Count: count})
+}
+
+// PostAdminOutboxPurge - deletes delivered events older than older_than (Go duration, e.g. 720h) or the configured retention
+func (that *OutboxHandler) PostAdminOutboxPurge(c *gin.Context, params PostAdminOutboxPurgeParams) {
+	retention := that.retention
+	if params.OlderThan != nil {
+		var err error
+		retention, err = time.ParseDuration(*params.OlderThan)
+		if err != nil {
+			respondBadRequest(c, fmt.Errorf("invalid older_than: %w", err))
+			return
+		}
+	}
+
+	count, err := that.outbox.Purge(c.Request.Context(), retention)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, OutboxResult{Count: count})
+}
+
+// newDeadFilter - selection of the dead events by the query or the body
+func newDeadFilter(ids *[]int64, eventType *string, tenantId *openapi_types.UUID, from, to *time.Time) outbox.DeadFilter {
+	filter := outbox.DeadFilter{TenantId: tenantId, From: from, To: to}
+	if ids != nil {
+		filter.Ids = *ids
+	}
+	if eventType != nil {
+		filter.EventType = *eventType
+	}
+	return filter
+}
+
+func newDeadEvent(event *outbox.DeadEvent) DeadEvent {
+	return DeadEvent{
+		Id:            event.Id,
+		AggregateType: event.AggregateType,
+		AggregateId:   event.AggregateId,
+		EventType:     event.EventType,
+		Payload:       event.Payload,
+		Headers:       event.Headers,
+		Attempt:       event.Attempt,
+		CreatedAt:     event.CreatedAt,
+		DeadAt:        event.DeadAt,
+	}
+}
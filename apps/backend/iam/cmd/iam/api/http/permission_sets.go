@@ -0,0 +1,316 @@
+package httpApi
+
+import (
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"github.com/gin-gonic/gin"
+)
+
+type PermissionSetHandler struct {
+	permissions *permissions.Service
+}
+
+func NewPermissionSetHandler(permissions *permissions.Service) *PermissionSetHandler {
+	return &PermissionSetHandler{permissions: permissions}
+}
+
+func (that *PermissionSetHandler) GetSecurityPermissionSets(c *gin.Context, params GetSecurityPermissionSetsParams) {
+	filter := permissions.Filter{
+		GroupId: toInt64Ptr(params.GroupId),
+	}
+	if params.Search != nil {
+		filter.Search = *params.Search
+	}
+
+	list, err := that.permissions.List(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newPermissionSet))
+}
+
+func (that *PermissionSetHandler) PostSecurityPermissionSets(c *gin.Context) {
+	var body PostSecurityPermissionSetsJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	set, err := that.permissions.Create(c.Request.Context(), permissions.CreatePermissionSet{
+		ApiName:     body.ApiName,
+		Label:       body.Label,
+		Description: body.Description,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newPermissionSet(set))
+}
+
+func (that *PermissionSetHandler) DeleteSecurityPermissionSetsPermissionSetId(c *gin.Context, permissionSetId int) {
+	if err := that.permissions.Delete(c.Request.Context(), int64(permissionSetId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *PermissionSetHandler) GetSecurityPermissionSetsPermissionSetId(c *gin.Context, permissionSetId int) {
+	set, err := that.permissions.Get(c.Request.Context(), int64(permissionSetId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPermissionSet(set))
+}
+
+func (that *PermissionSetHandler) PutSecurityPermissionSetsPermissionSetId(c *gin.Context, permissionSetId int) {
+	var body PutSecurityPermissionSetsPermissionSetIdJSONRequestBody
+	fields, err := bindPartialJSON(c, &body)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := permissions.UpdatePermissionSet{
+		ApiName: body.ApiName,
+		Label:   body.Label,
+	}
+	if fields.Has("description") {
+		request.Description = &body.Description
+	}
+
+	set, err := that.permissions.Update(c.Request.Context(), int64(permissionSetId), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPermissionSet(set))
+}
+
+func (that *PermissionSetHandler) GetSecurityPermissionSetsPermissionSetIdObjectPermissions(c *gin.Context, permissionSetId int, params GetSecurityPermissionSetsPermissionSetIdObjectPermissionsParams) {
+	list, err := that.permissions.ObjectPermissions(c.Request.Context(), int64(permissionSetId), services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newObjectPermission))
+}
+
+func (that *PermissionSetHandler) PostSecurityPermissionSetsPermissionSetIdObjectPermissions(c *gin.Context, permissionSetId int) {
+	var body PostSecurityPermissionSetsPermissionSetIdObjectPermissionsJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	permission, err := that.permissions.GrantObject(c.Request.Context(), int64(permissionSetId), permissions.GrantObject{
+		ObjectId:    int64(body.ObjectId),
+		Permissions: toObjectAccess(body.Permissions),
+		Flags:       toObjectFlags(body.PermissionsDetail),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newObjectPermission(permission))
+}
+
+func (that *PermissionSetHandler) DeleteSecurityObjectPermissionsPermissionId(c *gin.Context, permissionId int) {
+	if err := that.permissions.RevokeObjectPermission(c.Request.Context(), int64(permissionId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *PermissionSetHandler) PutSecurityObjectPermissionsPermissionId(c *gin.Context, permissionId int) {
+	var body PutSecurityObjectPermissionsPermissionIdJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	permission, err := that.permissions.UpdateObjectPermission(c.Request.Context(), int64(permissionId), permissions.UpdateObjectPermission{
+		Permissions: toObjectAccess(body.Permissions),
+		Flags:       toObjectFlags(body.PermissionsDetail),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newObjectPermission(permission))
+}
+
+func (that *PermissionSetHandler) GetSecurityPermissionSetsPermissionSetIdFieldPermissions(c *gin.Context, permissionSetId int, params GetSecurityPermissionSetsPermissionSetIdFieldPermissionsParams) {
+	list, err := that.permissions.FieldPermissions(c.Request.Context(), int64(permissionSetId), services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newFieldPermission))
+}
+
+func (that *PermissionSetHandler) PostSecurityPermissionSetsPermissionSetIdFieldPermissions(c *gin.Context, permissionSetId int) {
+	var body PostSecurityPermissionSetsPermissionSetIdFieldPermissionsJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	permission, err := that.permissions.GrantField(c.Request.Context(), int64(permissionSetId), permissions.GrantField{
+		FieldId:     int64(body.FieldId),
+		Permissions: toFieldAccess(body.Permissions),
+		Flags:       toFieldFlags(body.PermissionsDetail),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newFieldPermission(permission))
+}
+
+func (that *PermissionSetHandler) DeleteSecurityFieldPermissionsPermissionId(c *gin.Context, permissionId int) {
+	if err := that.permissions.RevokeFieldPermission(c.Request.Context(), int64(permissionId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *PermissionSetHandler) PutSecurityFieldPermissionsPermissionId(c *gin.Context, permissionId int) {
+	var body PutSecurityFieldPermissionsPermissionIdJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	permission, err := that.permissions.UpdateFieldPermission(c.Request.Context(), int64(permissionId), permissions.UpdateFieldPermission{
+		Permissions: toFieldAccess(body.Permissions),
+		Flags:       toFieldFlags(body.PermissionsDetail),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newFieldPermission(permission))
+}
+
+func newPermissionSet(set *permissions.PermissionSet) PermissionSet {
+	return PermissionSet{
+		Id:          int(set.Id),
+		TenantId:    set.TenantId,
+		ApiName:     set.ApiName,
+		Label:       set.Label,
+		Description: set.Description,
+		IsDeleted:   set.DeletedAt != nil,
+		DeletedAt:   set.DeletedAt,
+		CreatedAt:   set.CreatedAt,
+		UpdatedAt:   set.UpdatedAt,
+	}
+}
+
+// newObjectPermission - returns both the raw bitmask and its named flags
+func newObjectPermission(permission *permissions.ObjectPermission) ObjectPermission {
+	return ObjectPermission{
+		Id:              int(permission.Id),
+		TenantId:        permission.TenantId,
+		PermissionSetId: int(permission.PermissionSetId),
+		ObjectId:        int(permission.ObjectId),
+		Permissions:     int(permission.Permissions),
+		PermissionsDetail: &objectPermissionsDetail{
+			Read:   toBoolPtr(permission.Permissions.Has(permissions.ObjectRead)),
+			Update: toBoolPtr(permission.Permissions.Has(permissions.ObjectUpdate)),
+			Create: toBoolPtr(permission.Permissions.Has(permissions.ObjectCreate)),
+			Delete: toBoolPtr(permission.Permissions.Has(permissions.ObjectDelete)),
+		},
+		CreatedAt: permission.CreatedAt,
+		UpdatedAt: permission.UpdatedAt,
+	}
+}
+
+// newFieldPermission - returns both the raw bitmask and its named flags
+func newFieldPermission(permission *permissions.FieldPermission) FieldPermission {
+	return FieldPermission{
+		Id:              int(permission.Id),
+		TenantId:        permission.TenantId,
+		PermissionSetId: int(permission.PermissionSetId),
+		FieldId:         int(permission.FieldId),
+		Permissions:     int(permission.Permissions),
+		PermissionsDetail: &fieldPermissionsDetail{
+			Read:  toBoolPtr(permission.Permissions.Has(permissions.FieldRead)),
+			Write: toBoolPtr(permission.Permissions.Has(permissions.FieldWrite)),
+		},
+		CreatedAt: permission.CreatedAt,
+		UpdatedAt: permission.UpdatedAt,
+	}
+}
+
+// objectPermissionsDetail - named flags of the object permission bitmask as declared by the contract
+type objectPermissionsDetail = struct {
+	Create *bool `json:"create,omitempty"`
+	Delete *bool `json:"delete,omitempty"`
+	Read   *bool `json:"read,omitempty"`
+	Update *bool `json:"update,omitempty"`
+}
+
+// fieldPermissionsDetail - named flags of the field permission bitmask as declared by the contract
+type fieldPermissionsDetail = struct {
+	Read  *bool `json:"read,omitempty"`
+	Write *bool `json:"write,omitempty"`
+}
+
+func toObjectAccess(v *int) *permissions.ObjectAccess {
+	if v == nil {
+		return nil
+	}
+	r := permissions.ObjectAccess(*v)
+	return &r
+}
+
+func toObjectFlags(detail *objectPermissionsDetail) *permissions.ObjectFlags {
+	if detail == nil {
+		return nil
+	}
+	return &permissions.ObjectFlags{
+		Read:   detail.Read,
+		Update: detail.Update,
+		Create: detail.Create,
+		Delete: detail.Delete,
+	}
+}
+
+func toFieldAccess(v *int) *permissions.FieldAccess {
+	if v == nil {
+		return nil
+	}
+	r := permissions.FieldAccess(*v)
+	return &r
+}
+
+func toFieldFlags(detail *fieldPermissionsDetail) *permissions.FieldFlags {
+	if detail == nil {
+		return nil
+	}
+	return &permissions.FieldFlags{
+		Read:  detail.Read,
+		Write: detail.Write,
+	}
+}
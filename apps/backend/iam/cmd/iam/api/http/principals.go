@@ -0,0 +1,240 @@
+package httpApi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/principals"
+	"github.com/gin-gonic/gin"
+)
+
+type PrincipalHandler struct {
+	principals *principals.Service
+}
+
+func NewPrincipalHandler(principals *principals.Service) *PrincipalHandler {
+	return &PrincipalHandler{principals: principals}
+}
+
+func (that *PrincipalHandler) GetPrincipals(c *gin.Context, params GetPrincipalsParams) {
+	filter := principals.Filter{
+		IsActive:  params.IsActive,
+		SubjectId: toInt64Ptr(params.SubjectId),
+	}
+	if params.Kind != nil {
+		filter.Kind = principals.Kind(*params.Kind)
+	}
+
+	list, err := that.principals.List(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newPrincipal))
+}
+
+func (that *PrincipalHandler) PostPrincipals(c *gin.Context) {
+	var body PostPrincipalsJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	principal, err := that.principals.Create(c.Request.Context(), principals.CreatePrincipal{
+		Kind:      principals.Kind(body.Kind),
+		Login:     body.Login,
+		SubjectId: toInt64Ptr(body.SubjectId),
+		IsActive:  body.IsActive,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newPrincipal(principal))
+}
+
+func (that *PrincipalHandler) GetPrincipalsPrincipalId(c *gin.Context, principalId int) {
+	principal, err := that.principals.Get(c.Request.Context(), int64(principalId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPrincipal(principal))
+}
+
+func (that *PrincipalHandler) PutPrincipalsPrincipalId(c *gin.Context, principalId int) {
+	var body PutPrincipalsPrincipalIdJSONRequestBody
+	fields, err := bindPartialJSON(c, &body)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := principals.UpdatePrincipal{
+		Login:    body.Login,
+		IsActive: body.IsActive,
+	}
+	if body.Kind != nil {
+		kind := principals.Kind(*body.Kind)
+		request.Kind = &kind
+	}
+	if fields.Has("subject_id") {
+		subjectId := toInt64Ptr(body.SubjectId)
+		request.SubjectId = &subjectId
+	}
+
+	principal, err := that.principals.Update(c.Request.Context(), int64(principalId), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPrincipal(principal))
+}
+
+func (that *PrincipalHandler) PutPrincipalsPrincipalIdStatus(c *gin.Context, principalId int) {
+	var body PutPrincipalsPrincipalIdStatusJSONRequestBody
+	fields, err := bindPartialJSON(c, &body)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+	if !fields.Has("is_active") {
+		respondBadRequest(c, errors.New("is_active is required"))
+		return
+	}
+
+	principal, err := that.principals.SetActive(c.Request.Context(), int64(principalId), body.IsActive)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPrincipal(principal))
+}
+
+func (that *PrincipalHandler) GetPrincipalsPrincipalIdIdentities(c *gin.Context, principalId int, params GetPrincipalsPrincipalIdIdentitiesParams) {
+	list, err := that.principals.Identities(c.Request.Context(), int64(principalId), services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newIdentity))
+}
+
+func (that *PrincipalHandler) GetIdentities(c *gin.Context, params GetIdentitiesParams) {
+	filter := principals.IdentityFilter{
+		PrincipalId: toInt64Ptr(params.PrincipalId),
+	}
+	if params.Kind != nil {
+		filter.Kind = principals.IdentityKind(*params.Kind)
+	}
+	if params.Idp != nil {
+		filter.Idp = *params.Idp
+	}
+
+	list, err := that.principals.ListIdentities(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newIdentity))
+}
+
+func (that *PrincipalHandler) PostIdentities(c *gin.Context) {
+	var body PostIdentitiesJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	identity, err := that.principals.CreateIdentity(c.Request.Context(), principals.CreateIdentity{
+		PrincipalId: int64(body.PrincipalId),
+		Kind:        principals.IdentityKind(body.Kind),
+		Idp:         body.Idp,
+		Subject:     body.Subject,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newIdentity(identity))
+}
+
+func (that *PrincipalHandler) DeleteIdentitiesIdentityId(c *gin.Context, identityId int) {
+	if err := that.principals.DeleteIdentity(c.Request.Context(), int64(identityId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *PrincipalHandler) GetIdentitiesIdentityId(c *gin.Context, identityId int) {
+	identity, err := that.principals.GetIdentity(c.Request.Context(), int64(identityId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newIdentity(identity))
+}
+
+func (that *PrincipalHandler) PutIdentitiesIdentityId(c *gin.Context, identityId int) {
+	var body PutIdentitiesIdentityIdJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := principals.UpdateIdentity{
+		PrincipalId: toInt64Ptr(body.PrincipalId),
+		Idp:         body.Idp,
+		Subject:     body.Subject,
+	}
+	if body.Kind != nil {
+		kind := principals.IdentityKind(*body.Kind)
+		request.Kind = &kind
+	}
+
+	identity, err := that.principals.UpdateIdentity(c.Request.Context(), int64(identityId), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newIdentity(identity))
+}
+
+func newPrincipal(principal *principals.Principal) Principal {
+	return Principal{
+		Id:        int(principal.Id),
+		TenantId:  principal.TenantId,
+		Kind:      PrincipalKind(principal.Kind),
+		Login:     principal.Login,
+		SubjectId: toIntPtr(principal.SubjectId),
+		IsActive:  principal.IsActive,
+		CreatedAt: principal.CreatedAt,
+		UpdatedAt: principal.UpdatedAt,
+	}
+}
+
+func newIdentity(identity *principals.Identity) Identity {
+	return Identity{
+		Id:          int(identity.Id),
+		TenantId:    identity.TenantId,
+		PrincipalId: int(identity.PrincipalId),
+		Kind:        IdentityKind(identity.Kind),
+		Idp:         identity.Idp,
+		Subject:     identity.Subject,
+		CreatedAt:   identity.CreatedAt,
+		UpdatedAt:   identity.UpdatedAt,
+	}
+}
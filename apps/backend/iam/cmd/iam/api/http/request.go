@@ -0,0 +1,48 @@
+package httpApi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonFields - set of top level fields present in the request body
+type jsonFields map[string]json.RawMessage
+
+func (that jsonFields) Has(name string) bool {
+	_, ok := that[name]
+	return ok
+}
+
+// bindPartialJSON - decodes request body into dst and reports which fields were sent.
+// It allows to distinguish between omitted field and field explicitly set to null.
+func bindPartialJSON(c *gin.Context, dst any) (jsonFields, error) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("request body is empty")
+	}
+
+	var fields jsonFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+func toInt64Ptr(v *int) *int64 {
+	if v == nil {
+		return nil
+	}
+	r := int64(*v)
+	return &r
+}
@@ -0,0 +1,36 @@
+package httpApi
+
+import (
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/gin-gonic/gin"
+)
+
+// newList - builds paginated list response
+func newList[S, D any](list *services.List[S], convert func(S) D) gin.H {
+	data := make([]D, 0, len(list.Items))
+	for _, item := range list.Items {
+		data = append(data, convert(item))
+	}
+
+	return gin.H{
+		"data": data,
+		"pagination": Pagination{
+			Page:  list.Page.Number,
+			Limit: list.Page.Limit,
+			Pages: list.Pages(),
+			Total: list.Total,
+		},
+	}
+}
+
+func toIntPtr(v *int64) *int {
+	if v == nil {
+		return nil
+	}
+	r := int(*v)
+	return &r
+}
+
+func toBoolPtr(v bool) *bool {
+	return &v
+}
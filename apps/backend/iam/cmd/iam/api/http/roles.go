@@ -0,0 +1,148 @@
+package httpApi
+
+import (
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/roles"
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	roles *roles.Service
+}
+
+func NewRoleHandler(roles *roles.Service) *RoleHandler {
+	return &RoleHandler{roles: roles}
+}
+
+func (that *RoleHandler) GetRoles(c *gin.Context, params GetRolesParams) {
+	filter := roles.Filter{
+		ParentId: toInt64Ptr(params.ParentId),
+	}
+	if params.Search != nil {
+		filter.Search = *params.Search
+	}
+
+	list, err := that.roles.List(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newRole))
+}
+
+func (that *RoleHandler) PostRoles(c *gin.Context) {
+	var body PostRolesJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	role, err := that.roles.Create(c.Request.Context(), roles.CreateRole{
+		Label:      body.Label,
+		ApiName:    body.ApiName,
+		ExternalId: body.ExternalId,
+		ParentId:   toInt64Ptr(body.ParentId),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newRole(role))
+}
+
+func (that *RoleHandler) DeleteRolesRoleId(c *gin.Context, roleId int) {
+	if err := that.roles.Delete(c.Request.Context(), int64(roleId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *RoleHandler) GetRolesRoleId(c *gin.Context, roleId int) {
+	role, err := that.roles.Get(c.Request.Context(), int64(roleId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newRole(role))
+}
+
+func (that *RoleHandler) PutRolesRoleId(c *gin.Context, roleId int) {
+	var body PutRolesRoleIdJSONRequestBody
+	fields, err := bindPartialJSON(c, &body)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := roles.UpdateRole{
+		Label:   body.Label,
+		ApiName: body.ApiName,
+	}
+	if fields.Has("external_id") {
+		request.ExternalId = &body.ExternalId
+	}
+	if fields.Has("parent_id") {
+		parentId := toInt64Ptr(body.ParentId)
+		request.ParentId = &parentId
+	}
+
+	role, err := that.roles.Update(c.Request.Context(), int64(roleId), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newRole(role))
+}
+
+func (that *RoleHandler) GetRolesRoleIdChildren(c *gin.Context, roleId int, params GetRolesRoleIdChildrenParams) {
+	list, err := that.roles.Children(
+		c.Request.Context(),
+		int64(roleId),
+		params.Recursive != nil && *params.Recursive,
+		services.NewPage(params.Page, params.Limit),
+	)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newRole))
+}
+
+func (that *RoleHandler) PutRolesRoleIdParent(c *gin.Context, roleId int) {
+	var body PutRolesRoleIdParentJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	role, err := that.roles.SetParent(c.Request.Context(), int64(roleId), toInt64Ptr(body.ParentId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newRole(role))
+}
+
+func newRole(role *roles.Role) Role {
+	return Role{
+		Id:         int(role.Id),
+		TenantId:   role.TenantId,
+		ExternalId: role.ExternalId,
+		Label:      role.Label,
+		ApiName:    role.ApiName,
+		ParentId:   toIntPtr(role.ParentId),
+		CreatedAt:  role.CreatedAt,
+		UpdatedAt:  role.UpdatedAt,
+		DeletedAt:  role.DeletedAt,
+	}
+}
@@ -13,6 +13,21 @@ import (
 
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// Get list of dead events
+	// (GET /admin/outbox/dead)
+	GetAdminOutboxDead(c *gin.Context, params GetAdminOutboxDeadParams)
+	// Requeue dead events
+	// (POST /admin/outbox/dead/requeue)
+	PostAdminOutboxDeadRequeue(c *gin.Context)
+	// Get dead event by ID
+	// (GET /admin/outbox/dead/{event_id})
+	GetAdminOutboxDeadEventId(c *gin.Context, eventId int64)
+	// Requeue dead event
+	// (POST /admin/outbox/dead/{event_id}/requeue)
+	PostAdminOutboxDeadEventIdRequeue(c *gin.Context, eventId int64)
+	// Purge delivered events
+	// (POST /admin/outbox/purge)
+	PostAdminOutboxPurge(c *gin.Context, params PostAdminOutboxPurgeParams)
 	// Request password reset
 	// (POST /auth/forgot-password)
 	PostAuthForgotPassword(c *gin.Context)
@@ -268,6 +283,24 @@ type ServerInterface interface {
 	// Get user's subordinates
 	// (GET /users/{user_id}/subordinates)
 	GetUsersUserIdSubordinates(c *gin.Context, userId string, params GetUsersUserIdSubordinatesParams)
+	// Get list of webhook endpoints
+	// (GET /webhooks)
+	GetWebhooks(c *gin.Context, params GetWebhooksParams)
+	// Register webhook endpoint
+	// (POST /webhooks)
+	PostWebhooks(c *gin.Context)
+	// Delete webhook endpoint
+	// (DELETE /webhooks/{webhook_id})
+	DeleteWebhooksWebhookId(c *gin.Context, webhookId int)
+	// Get webhook endpoint by ID
+	// (GET /webhooks/{webhook_id})
+	GetWebhooksWebhookId(c *gin.Context, webhookId int)
+	// Update webhook endpoint
+	// (PATCH /webhooks/{webhook_id})
+	PatchWebhooksWebhookId(c *gin.Context, webhookId int)
+	// Rotate webhook secret
+	// (POST /webhooks/{webhook_id}/rotate-secret)
+	PostWebhooksWebhookIdRotateSecret(c *gin.Context, webhookId int)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -279,6 +312,177 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(c *gin.Context)
 
+// GetAdminOutboxDead operation middleware
+func (siw *ServerInterfaceWrapper) GetAdminOutboxDead(c *gin.Context) {
+
+	var err error
+
+	c.Set(OperatorKeyScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetAdminOutboxDeadParams
+
+	// ------------- Optional query parameter "id" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "id", c.Request.URL.Query(), &params.Id)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "event_type" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "event_type", c.Request.URL.Query(), &params.EventType)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter event_type: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "tenant_id" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "tenant_id", c.Request.URL.Query(), &params.TenantId)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter tenant_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "from" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "from", c.Request.URL.Query(), &params.From)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter from: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "to" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "to", c.Request.URL.Query(), &params.To)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter to: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "page" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "page", c.Request.URL.Query(), &params.Page)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter page: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", c.Request.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter limit: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetAdminOutboxDead(c, params)
+}
+
+// PostAdminOutboxDeadRequeue operation middleware
+func (siw *ServerInterfaceWrapper) PostAdminOutboxDeadRequeue(c *gin.Context) {
+
+	c.Set(OperatorKeyScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostAdminOutboxDeadRequeue(c)
+}
+
+// GetAdminOutboxDeadEventId operation middleware
+func (siw *ServerInterfaceWrapper) GetAdminOutboxDeadEventId(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "event_id" -------------
+	var eventId int64
+
+	err = runtime.BindStyledParameterWithOptions("simple", "event_id", c.Param("event_id"), &eventId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter event_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(OperatorKeyScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetAdminOutboxDeadEventId(c, eventId)
+}
+
+// PostAdminOutboxDeadEventIdRequeue operation middleware
+func (siw *ServerInterfaceWrapper) PostAdminOutboxDeadEventIdRequeue(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "event_id" -------------
+	var eventId int64
+
+	err = runtime.BindStyledParameterWithOptions("simple", "event_id", c.Param("event_id"), &eventId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter event_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(OperatorKeyScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostAdminOutboxDeadEventIdRequeue(c, eventId)
+}
+
+// PostAdminOutboxPurge operation middleware
+func (siw *ServerInterfaceWrapper) PostAdminOutboxPurge(c *gin.Context) {
+
+	var err error
+
+	c.Set(OperatorKeyScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PostAdminOutboxPurgeParams
+
+	// ------------- Optional query parameter "older_than" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "older_than", c.Request.URL.Query(), &params.OlderThan)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter older_than: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostAdminOutboxPurge(c, params)
+}
+
 // PostAuthForgotPassword operation middleware
 func (siw *ServerInterfaceWrapper) PostAuthForgotPassword(c *gin.Context) {
 
@@ -342,10 +546,6 @@ func (siw *ServerInterfaceWrapper) GetAuthMe(c *gin.Context) {
 // PostAuthRefresh operation middleware
 func (siw *ServerInterfaceWrapper) PostAuthRefresh(c *gin.Context) {
 
-	c.Set(BearerAuthScopes, []string{})
-
-	c.Set(ApiKeyAuthScopes, []string{})
-
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -868,6 +1068,14 @@ func (siw *ServerInterfaceWrapper) GetGroupsGroupIdMembers(c *gin.Context) {
 		return
 	}
 
+	// ------------- Optional query parameter "expand" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "expand", c.Request.URL.Query(), &params.Expand)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter expand: %w", err), http.StatusBadRequest)
+		return
+	}
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -1748,6 +1956,14 @@ func (siw *ServerInterfaceWrapper) GetRolesRoleIdChildren(c *gin.Context) {
 		return
 	}
 
+	// ------------- Optional query parameter "recursive" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "recursive", c.Request.URL.Query(), &params.Recursive)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter recursive: %w", err), http.StatusBadRequest)
+		return
+	}
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -2701,6 +2917,14 @@ func (siw *ServerInterfaceWrapper) GetTerritoriesTerritoryIdChildren(c *gin.Cont
 		return
 	}
 
+	// ------------- Optional query parameter "recursive" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "recursive", c.Request.URL.Query(), &params.Recursive)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter recursive: %w", err), http.StatusBadRequest)
+		return
+	}
+
 	for _, middleware := range siw.HandlerMiddlewares {
 		middleware(c)
 		if c.IsAborted() {
@@ -2997,6 +3221,173 @@ func (siw *ServerInterfaceWrapper) GetUsersUserIdSubordinates(c *gin.Context) {
 	siw.Handler.GetUsersUserIdSubordinates(c, userId, params)
 }
 
+// GetWebhooks operation middleware
+func (siw *ServerInterfaceWrapper) GetWebhooks(c *gin.Context) {
+
+	var err error
+
+	c.Set(BearerAuthScopes, []string{})
+
+	c.Set(ApiKeyAuthScopes, []string{})
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetWebhooksParams
+
+	// ------------- Optional query parameter "page" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "page", c.Request.URL.Query(), &params.Page)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter page: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", c.Request.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter limit: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetWebhooks(c, params)
+}
+
+// PostWebhooks operation middleware
+func (siw *ServerInterfaceWrapper) PostWebhooks(c *gin.Context) {
+
+	c.Set(BearerAuthScopes, []string{})
+
+	c.Set(ApiKeyAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostWebhooks(c)
+}
+
+// DeleteWebhooksWebhookId operation middleware
+func (siw *ServerInterfaceWrapper) DeleteWebhooksWebhookId(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "webhook_id" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhook_id", c.Param("webhook_id"), &webhookId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter webhook_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	c.Set(ApiKeyAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeleteWebhooksWebhookId(c, webhookId)
+}
+
+// GetWebhooksWebhookId operation middleware
+func (siw *ServerInterfaceWrapper) GetWebhooksWebhookId(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "webhook_id" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhook_id", c.Param("webhook_id"), &webhookId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter webhook_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	c.Set(ApiKeyAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetWebhooksWebhookId(c, webhookId)
+}
+
+// PatchWebhooksWebhookId operation middleware
+func (siw *ServerInterfaceWrapper) PatchWebhooksWebhookId(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "webhook_id" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhook_id", c.Param("webhook_id"), &webhookId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter webhook_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	c.Set(ApiKeyAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PatchWebhooksWebhookId(c, webhookId)
+}
+
+// PostWebhooksWebhookIdRotateSecret operation middleware
+func (siw *ServerInterfaceWrapper) PostWebhooksWebhookIdRotateSecret(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "webhook_id" -------------
+	var webhookId int
+
+	err = runtime.BindStyledParameterWithOptions("simple", "webhook_id", c.Param("webhook_id"), &webhookId, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter webhook_id: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	c.Set(BearerAuthScopes, []string{})
+
+	c.Set(ApiKeyAuthScopes, []string{})
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostWebhooksWebhookIdRotateSecret(c, webhookId)
+}
+
 // GinServerOptions provides options for the Gin server.
 type GinServerOptions struct {
 	BaseURL      string
@@ -3024,6 +3415,11 @@ func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options
 		ErrorHandler:       errorHandler,
 	}
 
+	router.GET(options.BaseURL+"/admin/outbox/dead", wrapper.GetAdminOutboxDead)
+	router.POST(options.BaseURL+"/admin/outbox/dead/requeue", wrapper.PostAdminOutboxDeadRequeue)
+	router.GET(options.BaseURL+"/admin/outbox/dead/:event_id", wrapper.GetAdminOutboxDeadEventId)
+	router.POST(options.BaseURL+"/admin/outbox/dead/:event_id/requeue", wrapper.PostAdminOutboxDeadEventIdRequeue)
+	router.POST(options.BaseURL+"/admin/outbox/purge", wrapper.PostAdminOutboxPurge)
 	router.POST(options.BaseURL+"/auth/forgot-password", wrapper.PostAuthForgotPassword)
 	router.POST(options.BaseURL+"/auth/login", wrapper.PostAuthLogin)
 	router.POST(options.BaseURL+"/auth/logout", wrapper.PostAuthLogout)
@@ -3109,4 +3505,10 @@ func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options
 	router.GET(options.BaseURL+"/users/:user_id/manager", wrapper.GetUsersUserIdManager)
 	router.PUT(options.BaseURL+"/users/:user_id/manager", wrapper.PutUsersUserIdManager)
 	router.GET(options.BaseURL+"/users/:user_id/subordinates", wrapper.GetUsersUserIdSubordinates)
+	router.GET(options.BaseURL+"/webhooks", wrapper.GetWebhooks)
+	router.POST(options.BaseURL+"/webhooks", wrapper.PostWebhooks)
+	router.DELETE(options.BaseURL+"/webhooks/:webhook_id", wrapper.DeleteWebhooksWebhookId)
+	router.GET(options.BaseURL+"/webhooks/:webhook_id", wrapper.GetWebhooksWebhookId)
+	router.PATCH(options.BaseURL+"/webhooks/:webhook_id", wrapper.PatchWebhooksWebhookId)
+	router.POST(options.BaseURL+"/webhooks/:webhook_id/rotate-secret", wrapper.PostWebhooksWebhookIdRotateSecret)
 }
@@ -0,0 +1,67 @@
+package httpApi
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const BaseURL = "/api/v1"
+
+// Server - implementation of ServerInterface assembled from domain handlers.
+// Every operation must be provided by a handler, otherwise the server does not compile.
+type Server struct {
+	*UserHandler
+	*RoleHandler
+	*TerritoryHandler
+	*GroupHandler
+	*PrincipalHandler
+	*ObjectHandler
+	*PermissionSetHandler
+	*AccessHandler
+	*CacheHandler
+	*AuthHandler
+	*WebhookHandler
+	*OutboxHandler
+}
+
+var _ ServerInterface = (*Server)(nil)
+
+func NewServer(
+	users *UserHandler,
+	roles *RoleHandler,
+	territories *TerritoryHandler,
+	groups *GroupHandler,
+	principals *PrincipalHandler,
+	objects *ObjectHandler,
+	permissionSets *PermissionSetHandler,
+	access *AccessHandler,
+	cache *CacheHandler,
+	auth *AuthHandler,
+	webhooks *WebhookHandler,
+	outbox *OutboxHandler,
+) *Server {
+	return &Server{
+		UserHandler:          users,
+		RoleHandler:          roles,
+		TerritoryHandler:     territories,
+		GroupHandler:         groups,
+		PrincipalHandler:     principals,
+		ObjectHandler:        objects,
+		PermissionSetHandler: permissionSets,
+		AccessHandler:        access,
+		CacheHandler:         cache,
+		AuthHandler:          auth,
+		WebhookHandler:       webhooks,
+		OutboxHandler:        outbox,
+	}
+}
+
+// Register - registers all operations of the server on the router
+func (that *Server) Register(router gin.IRouter, middlewares ...MiddlewareFunc) {
+	RegisterHandlersWithOptions(router, that, GinServerOptions{
+		BaseURL:     BaseURL,
+		Middlewares: middlewares,
+		ErrorHandler: func(c *gin.Context, err error, status int) {
+			abortWithError(c, status, "VALIDATION_ERROR", err.Error(), nil)
+		},
+	})
+}
@@ -0,0 +1,143 @@
+package httpApi
+
+import (
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/territories"
+	"github.com/gin-gonic/gin"
+)
+
+type TerritoryHandler struct {
+	territories *territories.Service
+}
+
+func NewTerritoryHandler(territories *territories.Service) *TerritoryHandler {
+	return &TerritoryHandler{territories: territories}
+}
+
+func (that *TerritoryHandler) GetTerritories(c *gin.Context, params GetTerritoriesParams) {
+	filter := territories.Filter{
+		ParentId: toInt64Ptr(params.ParentId),
+	}
+	if params.Search != nil {
+		filter.Search = *params.Search
+	}
+
+	list, err := that.territories.List(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newTerritory))
+}
+
+func (that *TerritoryHandler) PostTerritories(c *gin.Context) {
+	var body PostTerritoriesJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	territory, err := that.territories.Create(c.Request.Context(), territories.CreateTerritory{
+		Label:    body.Label,
+		ApiName:  body.ApiName,
+		ParentId: toInt64Ptr(body.ParentId),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newTerritory(territory))
+}
+
+func (that *TerritoryHandler) DeleteTerritoriesTerritoryId(c *gin.Context, territoryId int) {
+	if err := that.territories.Delete(c.Request.Context(), int64(territoryId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *TerritoryHandler) GetTerritoriesTerritoryId(c *gin.Context, territoryId int) {
+	territory, err := that.territories.Get(c.Request.Context(), int64(territoryId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTerritory(territory))
+}
+
+func (that *TerritoryHandler) PutTerritoriesTerritoryId(c *gin.Context, territoryId int) {
+	var body PutTerritoriesTerritoryIdJSONRequestBody
+	fields, err := bindPartialJSON(c, &body)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := territories.UpdateTerritory{
+		Label:   body.Label,
+		ApiName: body.ApiName,
+	}
+	if fields.Has("parent_id") {
+		parentId := toInt64Ptr(body.ParentId)
+		request.ParentId = &parentId
+	}
+
+	territory, err := that.territories.Update(c.Request.Context(), int64(territoryId), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTerritory(territory))
+}
+
+func (that *TerritoryHandler) GetTerritoriesTerritoryIdChildren(c *gin.Context, territoryId int, params GetTerritoriesTerritoryIdChildrenParams) {
+	list, err := that.territories.Children(
+		c.Request.Context(),
+		int64(territoryId),
+		params.Recursive != nil && *params.Recursive,
+		services.NewPage(params.Page, params.Limit),
+	)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newTerritory))
+}
+
+func (that *TerritoryHandler) PutTerritoriesTerritoryIdParent(c *gin.Context, territoryId int) {
+	var body PutTerritoriesTerritoryIdParentJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	territory, err := that.territories.SetParent(c.Request.Context(), int64(territoryId), toInt64Ptr(body.ParentId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTerritory(territory))
+}
+
+func newTerritory(territory *territories.Territory) Territory {
+	return Territory{
+		Id:        int(territory.Id),
+		TenantId:  territory.TenantId,
+		Label:     territory.Label,
+		ApiName:   territory.ApiName,
+		ParentId:  toIntPtr(territory.ParentId),
+		CreatedAt: territory.CreatedAt,
+		UpdatedAt: territory.UpdatedAt,
+		DeletedAt: territory.DeletedAt,
+	}
+}
@@ -13,8 +13,9 @@ import (
 )
 
 const (
-	ApiKeyAuthScopes = "ApiKeyAuth.Scopes"
-	BearerAuthScopes = "BearerAuth.Scopes"
+	ApiKeyAuthScopes  = "ApiKeyAuth.Scopes"
+	BearerAuthScopes  = "BearerAuth.Scopes"
+	OperatorKeyScopes = "OperatorKey.Scopes"
 )
 
 // Defines values for CreateGroupRequestType.
@@ -297,6 +298,51 @@ type CreateUserRequest struct {
 	Name string `json:"name"`
 }
 
+// CreateWebhookEndpointRequest defines model for CreateWebhookEndpointRequest.
+type CreateWebhookEndpointRequest struct {
+	// Description Human-readable description of the subscriber
+	Description *string `json:"description"`
+
+	// EventTypes Exact event types or prefixes ending with '*', empty or omitted list subscribes to every event
+	EventTypes *[]string `json:"event_types,omitempty"`
+
+	// IsActive Inactive endpoint receives no new deliveries
+	IsActive *bool `json:"is_active,omitempty"`
+
+	// Url URL receiving POST requests with events, https outside of development
+	Url string `json:"url"`
+}
+
+// DeadEvent defines model for DeadEvent.
+type DeadEvent struct {
+	// AggregateId Identifier of the aggregate
+	AggregateId string `json:"aggregate_id"`
+
+	// AggregateType Type of the aggregate emitting the event
+	AggregateType string `json:"aggregate_type"`
+
+	// Attempt Number of the failed publishing attempts
+	Attempt int `json:"attempt"`
+
+	// CreatedAt Creation timestamp
+	CreatedAt time.Time `json:"created_at"`
+
+	// DeadAt Moment the event was marked dead, null for events that died before it was recorded
+	DeadAt *time.Time `json:"dead_at"`
+
+	// EventType Type of the event
+	EventType string `json:"event_type"`
+
+	// Headers Headers of the event as stored in the outbox
+	Headers json.RawMessage `json:"headers"`
+
+	// Id Outbox event ID
+	Id int64 `json:"id"`
+
+	// Payload Payload of the event as stored in the outbox
+	Payload json.RawMessage `json:"payload"`
+}
+
 // Error defines model for Error.
 type Error struct {
 	// Details Additional error details
@@ -492,6 +538,12 @@ type ObjectPermission struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// OutboxResult defines model for OutboxResult.
+type OutboxResult struct {
+	// Count Number of the events affected by the operation
+	Count int `json:"count"`
+}
+
 // Pagination defines model for Pagination.
 type Pagination struct {
 	// Limit Number of items per page
@@ -567,6 +619,27 @@ type Principal struct {
 // PrincipalKind Type of principal
 type PrincipalKind string
 
+// RequeueDeadEventsRequest defines model for RequeueDeadEventsRequest.
+type RequeueDeadEventsRequest struct {
+	// All Requeue every dead event, required when no filter is given
+	All *bool `json:"all,omitempty"`
+
+	// EventType Event type
+	EventType *string `json:"event_type,omitempty"`
+
+	// From Events created at or after
+	From *time.Time `json:"from,omitempty"`
+
+	// Ids Event IDs
+	Ids *[]int64 `json:"ids,omitempty"`
+
+	// TenantId Tenant of the event headers
+	TenantId *openapi_types.UUID `json:"tenant_id,omitempty"`
+
+	// To Events created before
+	To *time.Time `json:"to,omitempty"`
+}
+
 // Role defines model for Role.
 type Role struct {
 	// ApiName API-friendly role identifier
@@ -829,6 +902,21 @@ type UpdateUserRequest struct {
 	Name *string `json:"name,omitempty"`
 }
 
+// UpdateWebhookEndpointRequest defines model for UpdateWebhookEndpointRequest.
+type UpdateWebhookEndpointRequest struct {
+	// Description Human-readable description of the subscriber, null clears it
+	Description *string `json:"description"`
+
+	// EventTypes Exact event types or prefixes ending with '*', empty list subscribes to every event
+	EventTypes *[]string `json:"event_types,omitempty"`
+
+	// IsActive Inactive endpoint receives no new deliveries
+	IsActive *bool `json:"is_active,omitempty"`
+
+	// Url URL receiving POST requests with events, https outside of development
+	Url *string `json:"url,omitempty"`
+}
+
 // User defines model for User.
 type User struct {
 	// CreatedAt Creation timestamp
@@ -856,6 +944,42 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// WebhookEndpoint defines model for WebhookEndpoint.
+type WebhookEndpoint struct {
+	// CreatedAt Creation timestamp
+	CreatedAt time.Time `json:"created_at"`
+
+	// Description Human-readable description of the subscriber
+	Description *string `json:"description"`
+
+	// EventTypes Exact event types or prefixes ending with '*', empty list subscribes to every event
+	EventTypes []string `json:"event_types"`
+
+	// Failures Number of consecutive failed deliveries
+	Failures int `json:"failures"`
+
+	// Id Internal webhook endpoint ID
+	Id int `json:"id"`
+
+	// IsActive Inactive endpoint receives no new deliveries
+	IsActive bool `json:"is_active"`
+
+	// RetryAt Deliveries are suspended until this time after failure
+	RetryAt *time.Time `json:"retry_at"`
+
+	// Secret Secret signing the deliveries, returned only on registration and rotation
+	Secret *string `json:"secret,omitempty"`
+
+	// TenantId Tenant identifier
+	TenantId openapi_types.UUID `json:"tenant_id"`
+
+	// UpdatedAt Last update timestamp
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Url URL receiving POST requests with events
+	Url string `json:"url"`
+}
+
 // BadRequest defines model for BadRequest.
 type BadRequest = Error
 
@@ -871,6 +995,36 @@ type NotFound = Error
 // Unauthorized defines model for Unauthorized.
 type Unauthorized = Error
 
+// GetAdminOutboxDeadParams defines parameters for GetAdminOutboxDead.
+type GetAdminOutboxDeadParams struct {
+	// Id Filter by event IDs
+	Id *[]int64 `form:"id,omitempty" json:"id,omitempty"`
+
+	// EventType Filter by event type
+	EventType *string `form:"event_type,omitempty" json:"event_type,omitempty"`
+
+	// TenantId Filter by tenant of the event headers
+	TenantId *openapi_types.UUID `form:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+
+	// From Filter by events created at or after
+	From *time.Time `form:"from,omitempty" json:"from,omitempty"`
+
+	// To Filter by events created before
+	To *time.Time `form:"to,omitempty" json:"to,omitempty"`
+
+	// Page Page number for pagination
+	Page *int `form:"page,omitempty" json:"page,omitempty"`
+
+	// Limit Number of items per page
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// PostAdminOutboxPurgeParams defines parameters for PostAdminOutboxPurge.
+type PostAdminOutboxPurgeParams struct {
+	// OlderThan Retention as Go duration, the configured outbox retention when omitted
+	OlderThan *string `form:"older_than,omitempty" json:"older_than,omitempty"`
+}
+
 // PostAuthForgotPasswordJSONBody defines parameters for PostAuthForgotPassword.
 type PostAuthForgotPasswordJSONBody struct {
 	// Email User email address
@@ -1000,6 +1154,9 @@ type GetGroupsGroupIdMembersParams struct {
 
 	// Limit Number of items per page
 	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Expand Return the transitively expanded set of users instead of direct memberships
+	Expand *bool `form:"expand,omitempty" json:"expand,omitempty"`
 }
 
 // PostGroupsGroupIdMembersJSONBody defines parameters for PostGroupsGroupIdMembers.
@@ -1053,7 +1210,7 @@ type GetPermissionsCheckParams struct {
 	// RowId Row ID for row-level permission check
 	RowId *int `form:"row_id,omitempty" json:"row_id,omitempty"`
 
-	// Permission Required permission (1=READ, 2=CREATE, 4=UPDATE, 8=DELETE)
+	// Permission Required permission (1=READ, 2=UPDATE, 4=CREATE, 8=DELETE)
 	Permission GetPermissionsCheckParamsPermission `form:"permission" json:"permission"`
 }
 
@@ -1151,6 +1308,9 @@ type GetRolesRoleIdChildrenParams struct {
 
 	// Limit Number of items per page
 	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Recursive Return the whole subtree instead of direct children only
+	Recursive *bool `form:"recursive,omitempty" json:"recursive,omitempty"`
 }
 
 // PutRolesRoleIdParentJSONBody defines parameters for PutRolesRoleIdParent.
@@ -1235,6 +1395,9 @@ type GetTerritoriesTerritoryIdChildrenParams struct {
 
 	// Limit Number of items per page
 	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Recursive Return the whole subtree instead of direct children only
+	Recursive *bool `form:"recursive,omitempty" json:"recursive,omitempty"`
 }
 
 // PutTerritoriesTerritoryIdParentJSONBody defines parameters for PutTerritoriesTerritoryIdParent.
@@ -1273,6 +1436,18 @@ type GetUsersUserIdSubordinatesParams struct {
 	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
 }
 
+// GetWebhooksParams defines parameters for GetWebhooks.
+type GetWebhooksParams struct {
+	// Page Page number for pagination
+	Page *int `form:"page,omitempty" json:"page,omitempty"`
+
+	// Limit Number of items per page
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// PostAdminOutboxDeadRequeueJSONRequestBody defines body for PostAdminOutboxDeadRequeue for application/json ContentType.
+type PostAdminOutboxDeadRequeueJSONRequestBody = RequeueDeadEventsRequest
+
 // PostAuthForgotPasswordJSONRequestBody defines body for PostAuthForgotPassword for application/json ContentType.
 type PostAuthForgotPasswordJSONRequestBody PostAuthForgotPasswordJSONBody
 
@@ -1375,6 +1550,12 @@ type PutUsersUserIdJSONRequestBody = UpdateUserRequest
 // PutUsersUserIdManagerJSONRequestBody defines body for PutUsersUserIdManager for application/json ContentType.
 type PutUsersUserIdManagerJSONRequestBody PutUsersUserIdManagerJSONBody
 
+// PostWebhooksJSONRequestBody defines body for PostWebhooks for application/json ContentType.
+type PostWebhooksJSONRequestBody = CreateWebhookEndpointRequest
+
+// PatchWebhooksWebhookIdJSONRequestBody defines body for PatchWebhooksWebhookId for application/json ContentType.
+type PatchWebhooksWebhookIdJSONRequestBody = UpdateWebhookEndpointRequest
+
 // AsCreateFieldPermissionRequest0 returns the union data inside the CreateFieldPermissionRequest as a CreateFieldPermissionRequest0
 func (t CreateFieldPermissionRequest) AsCreateFieldPermissionRequest0() (CreateFieldPermissionRequest0, error) {
 	var body CreateFieldPermissionRequest0
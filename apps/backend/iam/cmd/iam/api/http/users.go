@@ -0,0 +1,164 @@
+package httpApi
+
+import (
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/users"
+	"github.com/gin-gonic/gin"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+type UserHandler struct {
+	users *users.Service
+}
+
+func NewUserHandler(users *users.Service) *UserHandler {
+	return &UserHandler{users: users}
+}
+
+func (that *UserHandler) GetUsers(c *gin.Context, params GetUsersParams) {
+	filter := users.Filter{}
+	if params.Search != nil {
+		filter.Search = *params.Search
+	}
+	if params.ManagerId != nil {
+		filter.ManagerId = *params.ManagerId
+	}
+
+	list, err := that.users.List(c.Request.Context(), filter, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserList(list))
+}
+
+func (that *UserHandler) PostUsers(c *gin.Context) {
+	var body PostUsersJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	user, err := that.users.Create(c.Request.Context(), users.CreateUser{
+		Name:       body.Name,
+		Email:      string(body.Email),
+		ExternalId: body.ExternalId,
+		ManagerId:  body.ManagerId,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newUser(user))
+}
+
+func (that *UserHandler) DeleteUsersUserId(c *gin.Context, userId string) {
+	if err := that.users.Delete(c.Request.Context(), userId); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *UserHandler) GetUsersUserId(c *gin.Context, userId string) {
+	user, err := that.users.Get(c.Request.Context(), userId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUser(user))
+}
+
+func (that *UserHandler) PutUsersUserId(c *gin.Context, userId string) {
+	var body PutUsersUserIdJSONRequestBody
+	fields, err := bindPartialJSON(c, &body)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := users.UpdateUser{
+		Name: body.Name,
+	}
+	if body.Email != nil {
+		email := string(*body.Email)
+		request.Email = &email
+	}
+	if fields.Has("external_id") {
+		request.ExternalId = &body.ExternalId
+	}
+	if fields.Has("manager_id") {
+		request.ManagerId = &body.ManagerId
+	}
+
+	user, err := that.users.Update(c.Request.Context(), userId, request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUser(user))
+}
+
+func (that *UserHandler) GetUsersUserIdManager(c *gin.Context, userId string) {
+	manager, err := that.users.GetManager(c.Request.Context(), userId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if manager == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUser(manager))
+}
+
+func (that *UserHandler) PutUsersUserIdManager(c *gin.Context, userId string) {
+	var body PutUsersUserIdManagerJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	user, err := that.users.SetManager(c.Request.Context(), userId, body.ManagerId)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUser(user))
+}
+
+func (that *UserHandler) GetUsersUserIdSubordinates(c *gin.Context, userId string, params GetUsersUserIdSubordinatesParams) {
+	list, err := that.users.Subordinates(c.Request.Context(), userId, services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserList(list))
+}
+
+func newUser(user *users.User) User {
+	return User{
+		Id:         user.RecordId,
+		TenantId:   user.TenantId,
+		Name:       user.Name,
+		Email:      openapi_types.Email(user.Email),
+		ExternalId: user.ExternalId,
+		ManagerId:  user.ManagerId,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+	}
+}
+
+func newUserList(list *services.List[*users.User]) gin.H {
+	return newList(list, newUser)
+}
@@ -0,0 +1,128 @@
+package httpApi
+
+import (
+	"net/http"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler - management of the tenant webhook endpoints
+type WebhookHandler struct {
+	webhooks *webhooks.Service
+}
+
+func NewWebhookHandler(webhooks *webhooks.Service) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks}
+}
+
+func (that *WebhookHandler) GetWebhooks(c *gin.Context, params GetWebhooksParams) {
+	list, err := that.webhooks.List(c.Request.Context(), services.NewPage(params.Page, params.Limit))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newList(list, newWebhookEndpoint))
+}
+
+func (that *WebhookHandler) PostWebhooks(c *gin.Context) {
+	var body PostWebhooksJSONRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := webhooks.CreateEndpoint{
+		Url:         body.Url,
+		Description: body.Description,
+		IsActive:    body.IsActive,
+	}
+	if body.EventTypes != nil {
+		request.EventTypes = *body.EventTypes
+	}
+
+	endpoint, err := that.webhooks.Create(c.Request.Context(), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newWebhookEndpoint(endpoint))
+}
+
+func (that *WebhookHandler) GetWebhooksWebhookId(c *gin.Context, webhookId int) {
+	endpoint, err := that.webhooks.Get(c.Request.Context(), int64(webhookId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newWebhookEndpoint(endpoint))
+}
+
+func (that *WebhookHandler) PatchWebhooksWebhookId(c *gin.Context, webhookId int) {
+	var body PatchWebhooksWebhookIdJSONRequestBody
+	fields, err := bindPartialJSON(c, &body)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+
+	request := webhooks.UpdateEndpoint{
+		Url:        body.Url,
+		EventTypes: body.EventTypes,
+		IsActive:   body.IsActive,
+	}
+	if fields.Has("description") {
+		request.Description = &body.Description
+	}
+
+	endpoint, err := that.webhooks.Update(c.Request.Context(), int64(webhookId), request)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newWebhookEndpoint(endpoint))
+}
+
+func (that *WebhookHandler) DeleteWebhooksWebhookId(c *gin.Context, webhookId int) {
+	if err := that.webhooks.Delete(c.Request.Context(), int64(webhookId)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (that *WebhookHandler) PostWebhooksWebhookIdRotateSecret(c *gin.Context, webhookId int) {
+	endpoint, err := that.webhooks.RotateSecret(c.Request.Context(), int64(webhookId))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newWebhookEndpoint(endpoint))
+}
+
+// newWebhookEndpoint - the secret is returned only on creation and rotation
+func newWebhookEndpoint(endpoint *webhooks.Endpoint) WebhookEndpoint {
+	result := WebhookEndpoint{
+		Id:          int(endpoint.Id),
+		TenantId:    endpoint.TenantId,
+		Url:         endpoint.Url,
+		Description: endpoint.Description,
+		EventTypes:  endpoint.EventTypes,
+		IsActive:    endpoint.IsActive,
+		Failures:    endpoint.Failures,
+		RetryAt:     endpoint.RetryAt,
+		CreatedAt:   endpoint.CreatedAt,
+		UpdatedAt:   endpoint.UpdatedAt,
+	}
+	if endpoint.Secret != "" {
+		result.Secret = &endpoint.Secret
+	}
+	return result
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os/signal"
 	"syscall"
@@ -16,6 +17,7 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/stdlib"
+	"google.golang.org/grpc"
 )
 
 type App struct {
@@ -47,7 +49,17 @@ func (that *App) StartServer() error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	return di.Execute(ctx, di.NewUsecase(that.config, that.execServe))
+	return di.Execute(
+		ctx,
+		di.NewUsecase(that.config, that.execServe, bootstrap.DaemonCacheCleaner, bootstrap.DaemonOutboxRelay, bootstrap.DaemonWebhookDispatcher),
+	)
+}
+
+func (that *App) StartOutboxWorker() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return di.Execute(ctx, di.NewUsecase(that.config, that.execOutboxWorker, bootstrap.DaemonOutboxRelay, bootstrap.DaemonWebhookDispatcher))
 }
 
 func (that *App) RunMigrations() error {
@@ -63,39 +75,109 @@ func (that *App) execServe(ctx context.Context) error {
 	}
 
 	port := that.config.Api.Port
+	grpcPort := that.config.Api.GrpcPort
 
-	server := &http.Server{
+	httpServer := &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
 		Handler: bootstrap.ComponentRouter(ctx),
 	}
+	grpcServer := bootstrap.ComponentGrpcServer(ctx)
 
-	serverErrCh := make(chan error)
+	grpcListener, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error starting grpc server: %v", err))
+	}
+
+	// Administration is served on its own port, so it can be kept off the public network
+	var adminServer *http.Server
+	if adminPort := that.config.Api.AdminPort; adminPort != 0 {
+		adminServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", adminPort),
+			Handler: bootstrap.ComponentAdminRouter(ctx),
+		}
+	}
+
+	serverErrCh := make(chan error, 3)
 
 	go func() {
 		log.Printf("server is running... port=%d", port)
 		defer log.Print("server gracefully stopped")
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			serverErrCh <- err
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErrCh <- errors.New(fmt.Sprintf("error starting server: %v", err))
+		}
+	}()
+
+	go func() {
+		log.Printf("grpc server is running... port=%d", grpcPort)
+		defer log.Print("grpc server gracefully stopped")
+		if err := grpcServer.Serve(grpcListener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			serverErrCh <- errors.New(fmt.Sprintf("error starting grpc server: %v", err))
 		}
 	}()
 
+	if adminServer != nil {
+		go func() {
+			log.Printf("admin server is running... port=%d", that.config.Api.AdminPort)
+			defer log.Print("admin server gracefully stopped")
+			if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErrCh <- errors.New(fmt.Sprintf("error starting admin server: %v", err))
+			}
+		}()
+	}
+
 	select {
-	case err := <-serverErrCh:
-		return errors.New(fmt.Sprintf("error starting server: %v", err))
+	case err = <-serverErrCh:
 	case <-ctx.Done():
-		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		defer cancel()
-		log.Print("server is shutting down...")
-		err := server.Shutdown(shutdownCtx)
-		if err != nil {
-			err = errors.New(fmt.Sprintf("failed to shutdown server: %v", err))
+	}
+
+	// Context of the usecase is already cancelled here, shutdown gets its own deadline
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	log.Print("server is shutting down...")
+
+	if shutdownErr := shutdownGrpc(shutdownCtx, grpcServer); shutdownErr != nil && err == nil {
+		err = errors.New(fmt.Sprintf("failed to shutdown grpc server: %v", shutdownErr))
+	}
+	if shutdownErr := httpServer.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = errors.New(fmt.Sprintf("failed to shutdown server: %v", shutdownErr))
+	}
+	if adminServer != nil {
+		if shutdownErr := adminServer.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+			err = errors.New(fmt.Sprintf("failed to shutdown admin server: %v", shutdownErr))
 		}
-		return err
 	}
+	return err
+}
+
+// shutdownGrpc - waits for pending RPCs to complete, closes remaining connections when ctx expires
+func shutdownGrpc(ctx context.Context, server *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// execOutboxWorker - the relay and the webhook dispatcher run as daemons, the usecase only waits for the shutdown
+func (that *App) execOutboxWorker(ctx context.Context) error {
+	log.Print("outbox worker is running...")
+	<-ctx.Done()
+	log.Print("outbox worker is shutting down...")
+	return nil
 }
 
+// execMigrations - migrations are applied by the owner of the schema, the application role can not change it
 func (that *App) execMigrations(ctx context.Context) error {
-	db := bootstrap.ComponentDatabase(ctx)
+	db := bootstrap.ComponentMigrationDatabase(ctx)
 	sqlDB := stdlib.OpenDBFromPool(db.Pool())
 
 	driver, err := pgx.WithInstance(sqlDB, &pgx.Config{})
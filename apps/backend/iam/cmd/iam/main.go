@@ -29,6 +29,22 @@ var serveCmd = &cobra.Command{
 	},
 }
 
+var outboxWorkerCmd = &cobra.Command{
+	Use:   "outbox-worker",
+	Short: "Start the outbox relay and webhook dispatcher without the API server",
+	Run: func(cmd *cobra.Command, args []string) {
+		// Create and start application
+		application, err := New()
+		if err != nil {
+			log.Fatalf("error creating application: %v", err)
+		}
+		if err = application.StartOutboxWorker(); err != nil {
+			log.Fatalf("error starting outbox worker: %v", err)
+		}
+		log.Println("outbox worker stopped")
+	},
+}
+
 var migrateCmd = &cobra.Command{
 	Use:   "migrate",
 	Short: "Run database migrations",
@@ -47,7 +63,9 @@ var migrateCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(outboxWorkerCmd)
 	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(outboxCmd)
 }
 
 func main() {
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/bootstrap"
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/outbox"
+	"github.com/adverax/metacrm/pkg/di"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// deadFilterFlags - selection of the dead events by command line flags
+type deadFilterFlags struct {
+	eventType string
+	tenantId  string
+	from      string
+	to        string
+}
+
+func (that *deadFilterFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&that.eventType, "event-type", "", "event type, e.g. iam.user.created")
+	cmd.Flags().StringVar(&that.tenantId, "tenant", "", "tenant id from the event headers")
+	cmd.Flags().StringVar(&that.from, "from", "", "events created at or after the time (RFC 3339)")
+	cmd.Flags().StringVar(&that.to, "to", "", "events created before the time (RFC 3339)")
+}
+
+func (that *deadFilterFlags) isEmpty() bool {
+	return that.eventType == "" && that.tenantId == "" && that.from == "" && that.to == ""
+}
+
+func (that *deadFilterFlags) filter() (outbox.DeadFilter, error) {
+	filter := outbox.DeadFilter{EventType: that.eventType}
+
+	if that.tenantId != "" {
+		tenantId, err := uuid.Parse(that.tenantId)
+		if err != nil {
+			return filter, fmt.Errorf("invalid tenant: %w", err)
+		}
+		filter.TenantId = &tenantId
+	}
+
+	if that.from != "" {
+		from, err := time.Parse(time.RFC3339, that.from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from: %w", err)
+		}
+		filter.From = &from
+	}
+
+	if that.to != "" {
+		to, err := time.Parse(time.RFC3339, that.to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to: %w", err)
+		}
+		filter.To = &to
+	}
+
+	return filter, nil
+}
+
+var outboxCmd = &cobra.Command{
+	Use:   "outbox",
+	Short: "Manage the outbox events",
+}
+
+var outboxDeadCmd = &cobra.Command{
+	Use:   "dead",
+	Short: "Manage events that were not published within the maximum number of attempts",
+}
+
+var (
+	deadListFilter deadFilterFlags
+	deadListPage   int
+	deadListLimit  int
+)
+
+var outboxDeadListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List dead events, the oldest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, err := deadListFilter.filter()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		runOutbox(func(ctx context.Context, service *outbox.Service) error {
+			list, err := service.ListDead(ctx, filter, services.NewPage(&deadListPage, &deadListLimit))
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "ID\tEVENT TYPE\tAGGREGATE\tATTEMPT\tCREATED AT\tDEAD AT")
+			for _, event := range list.Items {
+				deadAt := "-"
+				if event.DeadAt != nil {
+					deadAt = event.DeadAt.Format(time.RFC3339)
+				}
+				_, _ = fmt.Fprintf(
+					w, "%d\t%s\t%s/%s\t%d\t%s\t%s\n",
+					event.Id, event.EventType, event.AggregateType, event.AggregateId,
+					event.Attempt, event.CreatedAt.Format(time.RFC3339), deadAt,
+				)
+			}
+			_ = w.Flush()
+
+			fmt.Printf("page %d of %d, %d events\n", list.Page.Number, list.Pages(), list.Total)
+			return nil
+		})
+	},
+}
+
+var outboxDeadShowCmd = &cobra.Command{
+	Use:   "show <event-id>",
+	Short: "Show the dead event with its payload and headers",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := parseEventId(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		runOutbox(func(ctx context.Context, service *outbox.Service) error {
+			event, err := service.GetDead(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(map[string]any{
+				"id":             event.Id,
+				"aggregate_type": event.AggregateType,
+				"aggregate_id":   event.AggregateId,
+				"event_type":     event.EventType,
+				"payload":        event.Payload,
+				"headers":        event.Headers,
+				"attempt":        event.Attempt,
+				"created_at":     event.CreatedAt,
+				"dead_at":        event.DeadAt,
+			})
+		})
+	},
+}
+
+var (
+	deadRequeueFilter deadFilterFlags
+	deadRequeueAll    bool
+)
+
+var outboxDeadRequeueCmd = &cobra.Command{
+	Use:   "requeue [event-id...]",
+	Short: "Return dead events to publishing with fresh attempts",
+	Long: `Return dead events to publishing with fresh attempts.
+Events are selected by ids or by filters, --all is required to requeue every dead event.
+Later events of the aggregate may be already published, so requeued events arrive out of order.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, err := deadRequeueFilter.filter()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, arg := range args {
+			id, err := parseEventId(arg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			filter.Ids = append(filter.Ids, id)
+		}
+		if len(filter.Ids) == 0 && deadRequeueFilter.isEmpty() && !deadRequeueAll {
+			log.Fatal("event ids, filters or --all are required")
+		}
+
+		runOutbox(func(ctx context.Context, service *outbox.Service) error {
+			count, err := service.RequeueDead(ctx, filter)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d events requeued\n", count)
+			return nil
+		})
+	},
+}
+
+var outboxPurgeOlderThan time.Duration
+
+var outboxPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Delete published events older than the retention period",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runOutbox(func(ctx context.Context, service *outbox.Service) error {
+			retention := outboxPurgeOlderThan
+			if retention == 0 {
+				retention = bootstrap.ComponentConfig(ctx).Outbox.Retention
+			}
+
+			count, err := service.Purge(ctx, retention)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d events purged\n", count)
+			return nil
+		})
+	},
+}
+
+func init() {
+	deadListFilter.bind(outboxDeadListCmd)
+	outboxDeadListCmd.Flags().IntVar(&deadListPage, "page", 1, "page number")
+	outboxDeadListCmd.Flags().IntVar(&deadListLimit, "limit", services.DefaultPageLimit, "events per page")
+
+	deadRequeueFilter.bind(outboxDeadRequeueCmd)
+	outboxDeadRequeueCmd.Flags().BoolVar(&deadRequeueAll, "all", false, "requeue every dead event")
+
+	outboxPurgeCmd.Flags().DurationVar(&outboxPurgeOlderThan, "older-than", 0, "retention period, defaults to outbox.retention of the config")
+
+	outboxDeadCmd.AddCommand(outboxDeadListCmd, outboxDeadShowCmd, outboxDeadRequeueCmd)
+	outboxCmd.AddCommand(outboxDeadCmd, outboxPurgeCmd)
+}
+
+// runOutbox - executes the action against the outbox of the configured database
+func runOutbox(action func(ctx context.Context, service *outbox.Service) error) {
+	application, err := New()
+	if err != nil {
+		log.Fatalf("error creating application: %v", err)
+	}
+	if err = application.RunOutbox(action); err != nil {
+		log.Fatalf("error executing outbox command: %v", err)
+	}
+}
+
+func (that *App) RunOutbox(action func(ctx context.Context, service *outbox.Service) error) error {
+	return di.Execute(context.Background(), di.NewUsecase(that.config, func(ctx context.Context) error {
+		return action(ctx, bootstrap.ComponentOutboxService(ctx))
+	}))
+}
+
+func parseEventId(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid event id: " + s)
+	}
+	return id, nil
+}
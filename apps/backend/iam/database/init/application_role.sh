@@ -0,0 +1,18 @@
+#!/bin/bash
+set -e
+set -u
+
+# Login role of the application, subject to the row level security policies.
+# Privileges are granted to iam_app by the migrations, iam_app is created here as well,
+# so the login role can join it before the migrations are applied.
+app_user="${DB_APP_USER:-iam_service}"
+app_password="${DB_APP_PASSWORD:-iam_service}"
+
+echo "Creating application role $app_user"
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "${POSTGRES_DB:-postgres}" \
+  -v app_user="$app_user" -v app_password="$app_password" <<-'EOSQL'
+	SELECT 'CREATE ROLE iam_app NOLOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE NOBYPASSRLS'
+	WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'iam_app') \gexec
+	SELECT format('CREATE ROLE %I LOGIN PASSWORD %L NOSUPERUSER NOBYPASSRLS IN ROLE iam_app', :'app_user', :'app_password')
+	WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = :'app_user') \gexec
+EOSQL
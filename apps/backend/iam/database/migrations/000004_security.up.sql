@@ -183,7 +183,7 @@ CREATE TABLE security.permission_set
     deleted_by_principal_id BIGINT,
     
     -- Constraint: api_name must start with letter or underscore and contain only alphanumeric characters and underscores
-    CONSTRAINT security_permission_set_api_name_check CHECK (api_name ~ '^[_a-zA-Z][a-zA-Z0-9_]{0,62}$')б
+    CONSTRAINT security_permission_set_api_name_check CHECK (api_name ~ '^[_a-zA-Z][a-zA-Z0-9_]{0,62}$'),
 
     -- Foreign key to the group this permission set belongs to
     -- CASCADE DELETE ensures permission sets are removed when group is deleted
@@ -199,7 +199,7 @@ CREATE TABLE security.permission_set
 
     -- Foreign key to the principal who deleted this permission set
     -- CASCADE DELETE ensures permission sets are removed when principal is deleted
-    CONSTRAINT security_permission_set_deleted_by_principal_fk FOREIGN KEY (tenant_id, deleted_by_principal_id) REFERENCES iam.principal (tenant_id, id) ON DELETE RESTRICT,
+    CONSTRAINT security_permission_set_deleted_by_principal_fk FOREIGN KEY (tenant_id, deleted_by_principal_id) REFERENCES iam.principal (tenant_id, id) ON DELETE RESTRICT
 ) PARTITION BY HASH (tenant_id);
 
 SELECT bootstrap.make_partitions('security', 'permission_set', 16);
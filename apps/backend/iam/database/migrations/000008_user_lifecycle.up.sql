@@ -0,0 +1,30 @@
+-- ========================================
+-- IAM USER LIFECYCLE MIGRATION
+-- ========================================
+-- This migration brings iam."user" in line with the other IAM tables:
+-- audit columns for the principal who created/updated the user and
+-- soft delete support. The user event triggers from 000006 already
+-- reference these columns.
+
+-- Audit and soft delete columns
+--
+-- created_by_principal_id / updated_by_principal_id are nullable because
+-- users may be provisioned before any principal exists (self registration,
+-- initial tenant bootstrap).
+ALTER TABLE iam."user"
+    ADD COLUMN IF NOT EXISTS created_by_principal_id BIGINT NULL DEFAULT bootstrap.current_principal_id(),
+    ADD COLUMN IF NOT EXISTS updated_by_principal_id BIGINT NULL DEFAULT bootstrap.current_principal_id(),
+    ADD COLUMN IF NOT EXISTS deleted_at              timestamptz,
+    ADD COLUMN IF NOT EXISTS deleted_by_principal_id BIGINT NULL;
+
+-- Re-attach audit triggers so that updated_deleted_setter is used
+SELECT bootstrap.attach_audit_triggers('iam', 'user');
+
+-- Email must be unique among active users only
+-- Allows the same email to be reused after the previous user was soft deleted
+DROP INDEX IF EXISTS iam.user_tenant_id_email_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS user_email_alive ON iam."user" (tenant_id, email) WHERE deleted_at IS NULL;
+
+-- Index for manager hierarchy queries
+-- Used to find direct subordinates of a user
+CREATE INDEX IF NOT EXISTS ix_user_manager ON iam."user" (tenant_id, manager_id) WHERE deleted_at IS NULL;
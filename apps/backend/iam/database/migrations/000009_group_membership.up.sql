@@ -0,0 +1,276 @@
+-- ========================================
+-- CLUSTER GROUP MEMBERSHIP MIGRATION
+-- ========================================
+-- This migration adds functions for nested group expansion and fixes
+-- membership uniqueness so that soft deleted memberships can be re-created.
+
+-- Unique indexes for memberships
+-- The original indexes covered soft deleted rows as well, so a member that was
+-- removed from a group could never be added back
+DROP INDEX IF EXISTS cluster.ux_group_member_user;
+DROP INDEX IF EXISTS cluster.ux_group_member_group;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_group_member_user ON cluster.group_member (tenant_id, group_id, member_user_id) WHERE member_user_id IS NOT NULL AND deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS ux_group_member_group ON cluster.group_member (tenant_id, group_id, member_group_id) WHERE member_group_id IS NOT NULL AND deleted_at IS NULL;
+
+-- Index for reverse membership lookups
+-- Used to find groups containing a given group
+CREATE INDEX IF NOT EXISTS ix_group_member_member_group ON cluster.group_member (tenant_id, member_group_id) WHERE member_group_id IS NOT NULL AND deleted_at IS NULL;
+
+-- Get group closure
+-- Returns the group itself and all groups it transitively includes
+--
+-- A group includes:
+-- - groups added as members (member_group_id)
+-- - for 'role_and_subordinates': 'role' groups of the related role and all its subordinate roles
+-- - for 'territory_and_subordinates': 'territory' groups of the related territory and all its subordinate territories
+--
+-- Cycles are tolerated: every group is visited once.
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_group_id: Group ID to expand
+--
+-- Returns: TABLE (group_id BIGINT)
+--
+-- Examples:
+--   SELECT * FROM cluster.get_group_closure('uuid', 123);
+CREATE OR REPLACE FUNCTION cluster.get_group_closure(p_tenant_id UUID, p_group_id BIGINT)
+RETURNS TABLE (group_id BIGINT)
+LANGUAGE plpgsql
+STABLE
+AS $$
+DECLARE
+    v_visited  BIGINT[] := ARRAY [p_group_id];
+    v_frontier BIGINT[] := ARRAY [p_group_id];
+    v_next     BIGINT[];
+BEGIN
+    WHILE cardinality(v_frontier) > 0 LOOP
+        WITH RECURSIVE role_tree AS (
+            SELECT g.related_role_id AS role_id
+            FROM cluster."group" g
+            WHERE g.tenant_id = p_tenant_id
+              AND g.id = ANY (v_frontier)
+              AND g.type = 'role_and_subordinates'
+            UNION
+            SELECT r.id
+            FROM iam.role r
+            JOIN role_tree t ON r.parent_id = t.role_id
+            WHERE r.tenant_id = p_tenant_id
+              AND r.deleted_at IS NULL
+        ), territory_tree AS (
+            SELECT g.related_territory_id AS territory_id
+            FROM cluster."group" g
+            WHERE g.tenant_id = p_tenant_id
+              AND g.id = ANY (v_frontier)
+              AND g.type = 'territory_and_subordinates'
+            UNION
+            SELECT tr.id
+            FROM iam.territory tr
+            JOIN territory_tree t ON tr.parent_id = t.territory_id
+            WHERE tr.tenant_id = p_tenant_id
+              AND tr.deleted_at IS NULL
+        )
+        SELECT coalesce(array_agg(DISTINCT c.id), '{}')
+        INTO v_next
+        FROM (
+            SELECT m.member_group_id AS id
+            FROM cluster.group_member m
+            WHERE m.tenant_id = p_tenant_id
+              AND m.group_id = ANY (v_frontier)
+              AND m.member_group_id IS NOT NULL
+              AND m.deleted_at IS NULL
+            UNION
+            SELECT g.id
+            FROM role_tree t
+            JOIN cluster."group" g ON g.tenant_id = p_tenant_id AND g.type = 'role' AND g.related_role_id = t.role_id
+            UNION
+            SELECT g.id
+            FROM territory_tree t
+            JOIN cluster."group" g ON g.tenant_id = p_tenant_id AND g.type = 'territory' AND g.related_territory_id = t.territory_id
+        ) c
+        JOIN cluster."group" g ON g.tenant_id = p_tenant_id AND g.id = c.id AND g.deleted_at IS NULL
+        WHERE c.id <> ALL (v_visited);
+
+        v_frontier := v_next;
+        v_visited := v_visited || v_next;
+    END LOOP;
+
+    RETURN QUERY SELECT unnest(v_visited);
+END;
+$$;
+
+-- Get group users
+-- Returns the transitively expanded set of active users belonging to the group
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_group_id: Group ID to expand
+--
+-- Returns: TABLE (user_id BIGINT)
+--
+-- Examples:
+--   SELECT * FROM cluster.get_group_users('uuid', 123);
+CREATE OR REPLACE FUNCTION cluster.get_group_users(p_tenant_id UUID, p_group_id BIGINT)
+RETURNS TABLE (user_id BIGINT)
+LANGUAGE sql
+STABLE
+AS $$
+    SELECT DISTINCT m.member_user_id
+    FROM cluster.get_group_closure(p_tenant_id, p_group_id) c
+    JOIN cluster.group_member m ON m.tenant_id = p_tenant_id AND m.group_id = c.group_id
+    JOIN iam."user" u ON u.tenant_id = p_tenant_id AND u.id = m.member_user_id
+    WHERE m.member_user_id IS NOT NULL
+      AND m.deleted_at IS NULL
+      AND u.deleted_at IS NULL;
+$$;
+
+-- Check membership cycle
+-- Adding p_member_group_id into p_group_id creates a cycle if p_group_id
+-- is already included (directly or transitively) into p_member_group_id
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_group_id: Group the member is added to
+--   p_member_group_id: Group added as member
+--
+-- Returns: BOOLEAN
+--
+-- Examples:
+--   SELECT cluster.is_membership_cycle('uuid', 123, 456);
+CREATE OR REPLACE FUNCTION cluster.is_membership_cycle(p_tenant_id UUID, p_group_id BIGINT, p_member_group_id BIGINT)
+RETURNS BOOLEAN
+LANGUAGE sql
+STABLE
+AS $$
+    SELECT EXISTS (
+        SELECT 1
+        FROM cluster.get_group_closure(p_tenant_id, p_member_group_id) c
+        WHERE c.group_id = p_group_id
+    );
+$$;
+
+-- ========================================
+-- GROUP EVENT FUNCTIONS
+-- ========================================
+-- Event functions from the event migration reference columns that cluster.group
+-- and cluster.group_member do not have (group record_id, group_type,
+-- related_entity_id, expires_at), so every membership insert failed.
+-- Groups are addressed by their numeric id.
+
+-- Generate group_member.added event
+CREATE OR REPLACE FUNCTION cluster.generate_group_member_added_event()
+    RETURNS TRIGGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+    v_member_record_id TEXT;
+BEGIN
+    -- Get member identifier (user record_id or group id)
+    IF NEW.member_user_id IS NOT NULL THEN
+        SELECT record_id INTO v_member_record_id
+        FROM iam."user"
+        WHERE tenant_id = NEW.tenant_id AND id = NEW.member_user_id;
+    ELSE
+        v_member_record_id := NEW.member_group_id::text;
+    END IF;
+
+    v_payload := jsonb_build_object(
+            'tenant_id', NEW.tenant_id::text,
+            'group_id', NEW.group_id::text,
+            'member_type', CASE WHEN NEW.member_user_id IS NOT NULL THEN 'user' ELSE 'group' END,
+            'member_id', v_member_record_id,
+            'added_by', CASE WHEN NEW.created_by_principal_id IS NOT NULL THEN
+                                 NEW.created_by_principal_id::text
+                             ELSE NULL END
+                 );
+
+    PERFORM bootstrap.create_outbox_event(
+            'group_member',
+            NEW.record_id,
+            'iam.group_member.added',
+            v_payload
+            );
+
+    RETURN NEW;
+END;
+$$;
+
+-- Generate group_member.removed event
+CREATE OR REPLACE FUNCTION cluster.generate_group_member_removed_event()
+    RETURNS TRIGGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+    v_member_record_id TEXT;
+BEGIN
+    -- Only trigger on soft delete
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        -- Get member identifier (user record_id or group id)
+        IF OLD.member_user_id IS NOT NULL THEN
+            SELECT record_id INTO v_member_record_id
+            FROM iam."user"
+            WHERE tenant_id = OLD.tenant_id AND id = OLD.member_user_id;
+        ELSE
+            v_member_record_id := OLD.member_group_id::text;
+        END IF;
+
+        v_payload := jsonb_build_object(
+                'tenant_id', OLD.tenant_id::text,
+                'group_id', OLD.group_id::text,
+                'member_type', CASE WHEN OLD.member_user_id IS NOT NULL THEN 'user' ELSE 'group' END,
+                'member_id', v_member_record_id,
+                'removed_by', CASE WHEN NEW.deleted_by_principal_id IS NOT NULL THEN
+                                       NEW.deleted_by_principal_id::text
+                                   ELSE NULL END,
+                'reason', 'Member removed from group'
+                     );
+
+        PERFORM bootstrap.create_outbox_event(
+                'group_member',
+                OLD.record_id,
+                'iam.group_member.removed',
+                v_payload
+                );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+-- Generate group.deleted event
+CREATE OR REPLACE FUNCTION cluster.generate_group_deleted_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+BEGIN
+    -- Only trigger on soft delete
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        v_payload := jsonb_build_object(
+            'tenant_id', NEW.tenant_id::text,
+            'group_id', NEW.id::text,
+            'label', NEW.label,
+            'api_name', NEW.api_name,
+            'group_type', NEW.type,
+            'related_entity_id', coalesce(NEW.related_role_id, NEW.related_territory_id)::text,
+            'deleted_by', CASE WHEN NEW.deleted_by_principal_id IS NOT NULL THEN
+                NEW.deleted_by_principal_id::text
+            ELSE NULL END,
+            'reason', 'Group deactivated'
+        );
+
+        PERFORM bootstrap.create_outbox_event(
+            'group',
+            NEW.id::text,
+            'iam.group.deleted',
+            v_payload
+        );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
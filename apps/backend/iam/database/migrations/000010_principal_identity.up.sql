@@ -0,0 +1,117 @@
+-- ========================================
+-- IAM PRINCIPAL AND IDENTITY LIFECYCLE MIGRATION
+-- ========================================
+-- This migration makes identities addressable and revocable and adds
+-- events for principal activation/deactivation.
+--
+-- iam.identity had neither a surrogate key nor audit columns, while the
+-- API and the identity.deleted event from 000006 expect both.
+
+-- Login must be unique within tenant (case insensitive)
+CREATE UNIQUE INDEX IF NOT EXISTS ux_principal_login ON iam.principal (tenant_id, lower(login));
+
+-- Identity surrogate key, audit and soft delete columns
+--
+-- A soft deleted identity is revoked: it can not be used for authentication
+-- anymore and its idp + subject pair may be registered again.
+ALTER TABLE iam.identity
+    ADD COLUMN IF NOT EXISTS id                      BIGSERIAL   NOT NULL,
+    ADD COLUMN IF NOT EXISTS created_at              timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at              timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_by_principal_id BIGINT      NULL DEFAULT bootstrap.current_principal_id(),
+    ADD COLUMN IF NOT EXISTS deleted_at              timestamptz,
+    ADD COLUMN IF NOT EXISTS deleted_by_principal_id BIGINT      NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_identity_id ON iam.identity (tenant_id, id);
+
+-- Same IdP + subject combination can only be active once per tenant
+ALTER TABLE iam.identity DROP CONSTRAINT IF EXISTS identity_tenant_id_idp_subject_key;
+CREATE UNIQUE INDEX IF NOT EXISTS ux_identity_idp_subject ON iam.identity (tenant_id, idp, subject) WHERE deleted_at IS NULL;
+
+-- Index for principal identities lookups
+CREATE INDEX IF NOT EXISTS ix_identity_principal ON iam.identity (tenant_id, principal_id) WHERE deleted_at IS NULL;
+
+-- Re-attach audit triggers so that updated_deleted_setter is used
+SELECT bootstrap.attach_audit_triggers('iam', 'identity');
+
+-- ========================================
+-- PRINCIPAL EVENT GENERATION FUNCTIONS
+-- ========================================
+
+-- Generate principal.activated / principal.deactivated event
+CREATE OR REPLACE FUNCTION iam.generate_principal_status_changed_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+BEGIN
+    IF OLD.is_active IS DISTINCT FROM NEW.is_active THEN
+        v_payload := jsonb_build_object(
+            'tenant_id', NEW.tenant_id::text,
+            'principal_id', NEW.id::text,
+            'kind', NEW.kind,
+            'login', NEW.login,
+            'subject_id', CASE WHEN NEW.subject_id IS NOT NULL THEN NEW.subject_id::text ELSE NULL END,
+            'is_active', NEW.is_active,
+            'changed_by', CASE WHEN bootstrap.current_principal_id() IS NOT NULL THEN
+                bootstrap.current_principal_id()::text
+            ELSE NULL END
+        );
+
+        PERFORM bootstrap.create_outbox_event(
+            'principal',
+            NEW.id::text,
+            CASE WHEN NEW.is_active THEN 'iam.principal.activated' ELSE 'iam.principal.deactivated' END,
+            v_payload
+        );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER trg_principal_status_changed_event
+    AFTER UPDATE ON iam.principal
+    FOR EACH ROW
+EXECUTE FUNCTION iam.generate_principal_status_changed_event();
+
+-- ========================================
+-- IDENTITY EVENT GENERATION FUNCTIONS
+-- ========================================
+
+-- Generate identity.deleted event
+-- Replaces the function from 000006 which referenced the nonexistent provider column
+CREATE OR REPLACE FUNCTION iam.generate_identity_deleted_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+BEGIN
+    -- Only trigger on soft delete
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        v_payload := jsonb_build_object(
+            'tenant_id', NEW.tenant_id::text,
+            'identity_id', NEW.id::text,
+            'principal_id', NEW.principal_id::text,
+            'kind', NEW.kind,
+            'idp', NEW.idp,
+            'subject', NEW.subject,
+            'deleted_by', CASE WHEN NEW.deleted_by_principal_id IS NOT NULL THEN
+                NEW.deleted_by_principal_id::text
+            ELSE NULL END,
+            'reason', 'Identity deactivated'
+        );
+
+        PERFORM bootstrap.create_outbox_event(
+            'identity',
+            NEW.id::text,
+            'iam.identity.deleted',
+            v_payload
+        );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
@@ -0,0 +1,306 @@
+-- ========================================
+-- SECURITY METADATA MIGRATION
+-- ========================================
+-- This migration prepares the security schema for the metadata API:
+-- modification timestamps for objects, fields and permission grants,
+-- tenant scoped permission set names and event/cache functions that
+-- match the actual table structure.
+--
+-- Security objects and fields are hard deleted, their fields and
+-- permission grants are removed by ON DELETE CASCADE.
+
+-- Modification timestamps
+ALTER TABLE security.object
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+ALTER TABLE security.field
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+ALTER TABLE security.object_permissions
+    ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+ALTER TABLE security.field_permissions
+    ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+
+SELECT bootstrap.attach_audit_triggers('security', 'object');
+SELECT bootstrap.attach_audit_triggers('security', 'field');
+SELECT bootstrap.attach_audit_triggers('security', 'object_permissions');
+SELECT bootstrap.attach_audit_triggers('security', 'field_permissions');
+
+-- Permission set api_name must be unique within tenant for active permission sets
+-- The original index was not tenant scoped
+DROP INDEX IF EXISTS security.ux_permission_set_api_name_alive;
+CREATE UNIQUE INDEX IF NOT EXISTS ux_permission_set_api_name_alive ON security.permission_set (tenant_id, api_name) WHERE deleted_at IS NULL;
+
+-- Indexes for permission grants lookups
+CREATE INDEX IF NOT EXISTS ix_object_permissions_object ON security.object_permissions (tenant_id, object_id);
+CREATE INDEX IF NOT EXISTS ix_field_permissions_field ON security.field_permissions (tenant_id, field_id);
+
+-- ========================================
+-- SECURITY OBJECT AND FIELD EVENT FUNCTIONS
+-- ========================================
+-- Objects and fields have no soft delete, so deletion events are
+-- generated from DELETE instead of UPDATE
+
+DROP TRIGGER IF EXISTS trg_security_object_deleted_event ON security.object;
+DROP TRIGGER IF EXISTS trg_security_field_deleted_event ON security.field;
+
+-- Generate security.object.deleted event
+CREATE OR REPLACE FUNCTION security.generate_object_deleted_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+BEGIN
+    v_payload := jsonb_build_object(
+        'tenant_id', OLD.tenant_id::text,
+        'object_id', OLD.id::text,
+        'api_name', OLD.api_name,
+        'deleted_by', CASE WHEN bootstrap.current_principal_id() IS NOT NULL THEN
+            bootstrap.current_principal_id()::text
+        ELSE NULL END,
+        'reason', 'Security object deleted'
+    );
+
+    PERFORM bootstrap.create_outbox_event(
+        'security_object',
+        OLD.id::text,
+        'iam.security.object.deleted',
+        v_payload
+    );
+
+    RETURN OLD;
+END;
+$$;
+
+-- Generate security.field.deleted event
+CREATE OR REPLACE FUNCTION security.generate_field_deleted_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+    v_object_api_name TEXT;
+BEGIN
+    -- Get object API name (NULL when the object itself is being deleted)
+    SELECT api_name INTO v_object_api_name
+    FROM security.object
+    WHERE tenant_id = OLD.tenant_id AND id = OLD.object_id;
+
+    v_payload := jsonb_build_object(
+        'tenant_id', OLD.tenant_id::text,
+        'field_id', OLD.id::text,
+        'object_id', OLD.object_id::text,
+        'object_api_name', v_object_api_name,
+        'api_name', OLD.api_name,
+        'deleted_by', CASE WHEN bootstrap.current_principal_id() IS NOT NULL THEN
+            bootstrap.current_principal_id()::text
+        ELSE NULL END,
+        'reason', 'Security field deleted'
+    );
+
+    PERFORM bootstrap.create_outbox_event(
+        'security_field',
+        OLD.id::text,
+        'iam.security.field.deleted',
+        v_payload
+    );
+
+    RETURN OLD;
+END;
+$$;
+
+CREATE TRIGGER trg_security_object_deleted_event
+    AFTER DELETE ON security.object
+    FOR EACH ROW
+EXECUTE FUNCTION security.generate_object_deleted_event();
+
+CREATE TRIGGER trg_security_field_deleted_event
+    AFTER DELETE ON security.field
+    FOR EACH ROW
+EXECUTE FUNCTION security.generate_field_deleted_event();
+
+-- ========================================
+-- PERMISSION SET EVENT FUNCTIONS
+-- ========================================
+-- Groups have no record_id, they are addressed by their numeric id
+
+-- Generate permission_set.assigned_to_group event
+CREATE OR REPLACE FUNCTION security.generate_permission_set_assigned_event()
+    RETURNS TRIGGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+BEGIN
+    -- Only trigger when group_id is set (assigned to group)
+    IF NEW.group_id IS NOT NULL THEN
+        v_payload := jsonb_build_object(
+                'tenant_id', NEW.tenant_id::text,
+                'permission_set_id', NEW.id::text,
+                'api_name', NEW.api_name,
+                'group_id', NEW.group_id::text,
+                'assigned_by', CASE WHEN NEW.created_by_principal_id IS NOT NULL THEN
+                                        NEW.created_by_principal_id::text
+                                    ELSE NULL END
+                     );
+
+        PERFORM bootstrap.create_outbox_event(
+                'permission_set',
+                NEW.id::text,
+                'iam.permission_set.assigned_to_group',
+                v_payload
+                );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+-- Generate permission_set.unassigned_from_group event
+CREATE OR REPLACE FUNCTION security.generate_permission_set_unassigned_event()
+    RETURNS TRIGGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+BEGIN
+    -- Only trigger when group_id is removed (unassigned from group)
+    IF OLD.group_id IS NOT NULL AND NEW.group_id IS NULL THEN
+        v_payload := jsonb_build_object(
+                'tenant_id', OLD.tenant_id::text,
+                'permission_set_id', OLD.id::text,
+                'api_name', OLD.api_name,
+                'group_id', OLD.group_id::text,
+                'unassigned_by', CASE WHEN NEW.updated_by_principal_id IS NOT NULL THEN
+                                          NEW.updated_by_principal_id::text
+                                      ELSE NULL END
+                     );
+
+        PERFORM bootstrap.create_outbox_event(
+                'permission_set',
+                OLD.id::text,
+                'iam.permission_set.unassigned_from_group',
+                v_payload
+                );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+-- Generate permission_set.deleted event
+CREATE OR REPLACE FUNCTION security.generate_permission_set_deleted_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+BEGIN
+    -- Only trigger on soft delete
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        v_payload := jsonb_build_object(
+            'tenant_id', NEW.tenant_id::text,
+            'permission_set_id', NEW.id::text,
+            'api_name', NEW.api_name,
+            'group_id', CASE WHEN NEW.group_id IS NOT NULL THEN NEW.group_id::text ELSE NULL END,
+            'deleted_by', CASE WHEN NEW.deleted_by_principal_id IS NOT NULL THEN
+                NEW.deleted_by_principal_id::text
+            ELSE NULL END,
+            'reason', 'Permission set deactivated'
+        );
+
+        PERFORM bootstrap.create_outbox_event(
+            'permission_set',
+            NEW.id::text,
+            'iam.permission_set.deleted',
+            v_payload
+        );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+-- ========================================
+-- PERMISSION GRANT CACHE INVALIDATION FUNCTIONS
+-- ========================================
+-- The original functions used NEW only, which is NULL on DELETE,
+-- and field permissions have no object_id column
+
+-- Trigger for cache update when object permissions change
+CREATE OR REPLACE FUNCTION cache.trigger_permissions_cache_invalidation()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_tenant_id UUID;
+    v_object_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        v_tenant_id := OLD.tenant_id;
+        v_object_id := OLD.object_id;
+    ELSE
+        v_tenant_id := NEW.tenant_id;
+        v_object_id := NEW.object_id;
+    END IF;
+
+    -- Invalidate object cache
+    PERFORM cache.invalidate_object_permissions_cache(v_tenant_id, v_object_id);
+
+    -- Send event to outbox
+    PERFORM cache.send_cache_invalidation_event(
+        v_tenant_id,
+        'object',
+        v_object_id::text,
+        'iam.object_permissions_changed'
+    );
+
+    RETURN NULL;
+END;
+$$;
+
+-- Trigger for cache update when field permissions change
+CREATE OR REPLACE FUNCTION cache.trigger_field_permissions_cache_invalidation()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_tenant_id UUID;
+    v_field_id  BIGINT;
+    v_object_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        v_tenant_id := OLD.tenant_id;
+        v_field_id := OLD.field_id;
+    ELSE
+        v_tenant_id := NEW.tenant_id;
+        v_field_id := NEW.field_id;
+    END IF;
+
+    SELECT object_id INTO v_object_id
+    FROM security.field
+    WHERE tenant_id = v_tenant_id AND id = v_field_id;
+
+    -- The field is being deleted together with its object
+    IF v_object_id IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    -- Invalidate object cache (since field permissions changed)
+    PERFORM cache.invalidate_object_permissions_cache(v_tenant_id, v_object_id);
+
+    -- Send event to outbox
+    PERFORM cache.send_cache_invalidation_event(
+        v_tenant_id,
+        'object',
+        v_object_id::text,
+        'iam.field_permissions_changed'
+    );
+
+    RETURN NULL;
+END;
+$$;
@@ -0,0 +1,243 @@
+-- ========================================
+-- PERMISSION CHECK MIGRATION
+-- ========================================
+-- This migration makes the permission cache functions usable by the
+-- permission check API:
+-- - functions that populate the cache are declared VOLATILE, since
+--   STABLE functions are not allowed to modify tables
+-- - object permissions are computed from permission sets of the groups
+--   that include the user (directly or transitively) on cache miss,
+--   group_object_permissions is never populated by anything
+-- - field permissions are computed from field permission grants, the
+--   restriction bitmask now holds denied bits as documented
+--
+-- Bitmask values follow security.object_permissions and security.field_permissions:
+--   object: 1=READ, 2=UPDATE, 4=CREATE, 8=DELETE
+--   field:  1=READ, 2=WRITE
+
+-- Get user permission groups
+-- Returns groups with active permission sets that include the user directly or transitively
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_user_id: User ID
+--
+-- Returns: TABLE (group_id BIGINT)
+--
+-- Examples:
+--   SELECT * FROM cluster.get_user_permission_groups('uuid', 123);
+CREATE OR REPLACE FUNCTION cluster.get_user_permission_groups(p_tenant_id UUID, p_user_id BIGINT)
+RETURNS TABLE (group_id BIGINT)
+LANGUAGE sql
+STABLE
+AS $$
+    SELECT DISTINCT ps.group_id
+    FROM security.permission_set ps
+    JOIN cluster."group" g ON g.tenant_id = ps.tenant_id AND g.id = ps.group_id
+    WHERE ps.tenant_id = p_tenant_id
+      AND ps.group_id IS NOT NULL
+      AND ps.deleted_at IS NULL
+      AND g.deleted_at IS NULL
+      AND EXISTS (
+          SELECT 1
+          FROM cluster.get_group_users(p_tenant_id, ps.group_id) u
+          WHERE u.user_id = p_user_id
+      );
+$$;
+
+-- Compute object permissions
+-- Computes user permissions for the object from source tables, bypassing cache
+--
+-- Returns: INTEGER - Permission bitmask (1=READ, 2=UPDATE, 4=CREATE, 8=DELETE)
+--
+-- Examples:
+--   SELECT cache.compute_object_permissions('uuid', 123, 456);
+CREATE OR REPLACE FUNCTION cache.compute_object_permissions(
+    p_tenant_id UUID,
+    p_user_id BIGINT,
+    p_object_id BIGINT
+)
+RETURNS INTEGER
+LANGUAGE sql
+STABLE
+AS $$
+    SELECT COALESCE(bit_or(op.permissions), 0)
+    FROM cluster.get_user_permission_groups(p_tenant_id, p_user_id) ug
+    JOIN security.permission_set ps ON ps.tenant_id = p_tenant_id AND ps.group_id = ug.group_id AND ps.deleted_at IS NULL
+    JOIN security.object_permissions op ON op.tenant_id = p_tenant_id AND op.permission_set_id = ps.id
+    WHERE op.object_id = p_object_id;
+$$;
+
+-- Compute field restriction
+-- Computes denied field operations from source tables, bypassing cache.
+-- A field without any field permission grants is not restricted.
+--
+-- Returns: INTEGER - Restriction bitmask (1=READ restriction, 2=WRITE restriction)
+--
+-- Examples:
+--   SELECT cache.compute_field_restriction('uuid', 123, 789);
+CREATE OR REPLACE FUNCTION cache.compute_field_restriction(
+    p_tenant_id UUID,
+    p_user_id BIGINT,
+    p_field_id BIGINT
+)
+RETURNS INTEGER
+LANGUAGE plpgsql
+STABLE
+AS $$
+DECLARE
+    v_granted INTEGER;
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM security.field_permissions fp
+        JOIN security.permission_set ps ON ps.tenant_id = fp.tenant_id AND ps.id = fp.permission_set_id AND ps.deleted_at IS NULL
+        WHERE fp.tenant_id = p_tenant_id AND fp.field_id = p_field_id
+    ) THEN
+        RETURN 0;
+    END IF;
+
+    SELECT COALESCE(bit_or(fp.permissions), 0) INTO v_granted
+    FROM cluster.get_user_permission_groups(p_tenant_id, p_user_id) ug
+    JOIN security.permission_set ps ON ps.tenant_id = p_tenant_id AND ps.group_id = ug.group_id AND ps.deleted_at IS NULL
+    JOIN security.field_permissions fp ON fp.tenant_id = p_tenant_id AND fp.permission_set_id = ps.id
+    WHERE fp.field_id = p_field_id;
+
+    RETURN 3 & ~v_granted;
+END;
+$$;
+
+-- Get object permissions with automatic caching
+-- Same contract as before, computes permissions from permission sets on cache miss
+CREATE OR REPLACE FUNCTION cache.get_object_permissions(
+    p_tenant_id UUID,
+    p_user_id BIGINT,
+    p_object_id BIGINT,
+    p_ttl_seconds INTEGER DEFAULT 3600
+)
+RETURNS INTEGER
+LANGUAGE plpgsql
+VOLATILE
+AS $$
+DECLARE
+    cached_permissions INTEGER;
+    computed_permissions INTEGER;
+BEGIN
+    -- Try to get from cache
+    SELECT base_permissions INTO cached_permissions
+    FROM cache.user_object_permissions
+    WHERE tenant_id = p_tenant_id
+      AND user_id = p_user_id
+      AND object_id = p_object_id
+      AND expires_at > now();
+
+    -- If not in cache, compute and cache
+    IF cached_permissions IS NULL THEN
+        computed_permissions := cache.compute_object_permissions(p_tenant_id, p_user_id, p_object_id);
+
+        -- No permissions
+        IF computed_permissions = 0 THEN
+            RETURN 0; -- without caching
+        END IF;
+
+        -- Cache the result
+        INSERT INTO cache.user_object_permissions (tenant_id, user_id, object_id, base_permissions, expires_at)
+        VALUES (p_tenant_id, p_user_id, p_object_id, computed_permissions, now() + (p_ttl_seconds || ' seconds')::interval)
+        ON CONFLICT (tenant_id, user_id, object_id)
+        DO UPDATE SET
+            base_permissions = EXCLUDED.base_permissions,
+            cached_at = now(),
+            expires_at = EXCLUDED.expires_at;
+
+        cached_permissions := computed_permissions;
+    END IF;
+
+    RETURN cached_permissions;
+END;
+$$;
+
+-- Get field restriction with automatic caching
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_user_id: User ID to check restriction for
+--   p_object_id: Object ID the field belongs to
+--   p_field_id: Field ID to check restriction for
+--   p_ttl_seconds: Cache TTL in seconds (default: 3600 = 1 hour)
+--
+-- Returns: INTEGER - Restriction bitmask (1=READ restriction, 2=WRITE restriction)
+--
+-- Examples:
+--   SELECT cache.get_field_restriction('uuid', 123, 456, 789);
+CREATE OR REPLACE FUNCTION cache.get_field_restriction(
+    p_tenant_id UUID,
+    p_user_id BIGINT,
+    p_object_id BIGINT,
+    p_field_id BIGINT,
+    p_ttl_seconds INTEGER DEFAULT 3600
+)
+RETURNS INTEGER
+LANGUAGE plpgsql
+VOLATILE
+AS $$
+DECLARE
+    cached_restriction INTEGER;
+BEGIN
+    SELECT restriction INTO cached_restriction
+    FROM cache.user_field_restrictions
+    WHERE tenant_id = p_tenant_id
+      AND user_id = p_user_id
+      AND object_id = p_object_id
+      AND field_id = p_field_id
+      AND expires_at > now();
+
+    IF cached_restriction IS NULL THEN
+        cached_restriction := cache.compute_field_restriction(p_tenant_id, p_user_id, p_field_id);
+
+        INSERT INTO cache.user_field_restrictions (tenant_id, user_id, object_id, field_id, restriction, expires_at)
+        VALUES (p_tenant_id, p_user_id, p_object_id, p_field_id, cached_restriction, now() + (p_ttl_seconds || ' seconds')::interval)
+        ON CONFLICT (tenant_id, user_id, object_id, field_id)
+        DO UPDATE SET
+            restriction = EXCLUDED.restriction,
+            cached_at = now(),
+            expires_at = EXCLUDED.expires_at;
+    END IF;
+
+    RETURN cached_restriction;
+END;
+$$;
+
+-- Get field permissions with restrictions (FLS - Field Level Security)
+-- READ and UPDATE object permissions map to READ and WRITE field permissions,
+-- restricted operations are removed
+--
+-- Returns: INTEGER - Field permission bitmask (1=READ, 2=WRITE)
+CREATE OR REPLACE FUNCTION cache.get_field_permissions(
+    p_tenant_id UUID,
+    p_user_id BIGINT,
+    p_object_id BIGINT,
+    p_field_id BIGINT,
+    p_ttl_seconds INTEGER DEFAULT 3600
+)
+RETURNS INTEGER
+LANGUAGE plpgsql
+VOLATILE
+AS $$
+DECLARE
+    base_permissions INTEGER;
+    field_restriction INTEGER;
+BEGIN
+    base_permissions := cache.get_object_permissions(p_tenant_id, p_user_id, p_object_id, p_ttl_seconds) & 3;
+    IF base_permissions = 0 THEN
+        RETURN 0;
+    END IF;
+
+    field_restriction := cache.get_field_restriction(p_tenant_id, p_user_id, p_object_id, p_field_id, p_ttl_seconds);
+
+    RETURN base_permissions & ~field_restriction;
+END;
+$$;
+
+-- Functions below are unchanged except that they populate the cache
+ALTER FUNCTION cache.get_row_permissions(UUID, BIGINT, BIGINT, BIGINT, INTEGER) VOLATILE;
+ALTER FUNCTION cache.has_permission(UUID, BIGINT, BIGINT, BIGINT, BIGINT, INTEGER, INTEGER) VOLATILE;
@@ -0,0 +1,81 @@
+-- ========================================
+-- CACHE ADMINISTRATION MIGRATION
+-- ========================================
+-- This migration makes the cache maintenance functions usable by the
+-- cache administration API and the scheduled cleanup:
+-- - cleanup no longer touches cache.user_cache, cache.role_cache and
+--   cache.group_cache, which do not exist, and reports removed entries
+-- - group invalidation covers every cache table of the users included
+--   into the group directly or transitively
+
+-- Cleanup expired permissions cache
+-- Removes all expired cache entries from all cache tables to free up storage space
+--
+-- Parameters: None
+--
+-- Returns: BIGINT - Number of removed cache entries
+--
+-- Examples:
+--   SELECT cache.cleanup_expired_permissions_cache();
+DROP FUNCTION IF EXISTS cache.cleanup_expired_permissions_cache();
+
+CREATE FUNCTION cache.cleanup_expired_permissions_cache()
+RETURNS BIGINT
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_removed BIGINT := 0;
+    v_count BIGINT;
+BEGIN
+    DELETE FROM cache.user_object_permissions WHERE expires_at < now();
+    GET DIAGNOSTICS v_count = ROW_COUNT;
+    v_removed := v_removed + v_count;
+
+    DELETE FROM cache.user_field_restrictions WHERE expires_at < now();
+    GET DIAGNOSTICS v_count = ROW_COUNT;
+    v_removed := v_removed + v_count;
+
+    DELETE FROM cache.user_row_permissions WHERE expires_at < now();
+    GET DIAGNOSTICS v_count = ROW_COUNT;
+    v_removed := v_removed + v_count;
+
+    DELETE FROM cache.group_object_permissions WHERE expires_at < now();
+    GET DIAGNOSTICS v_count = ROW_COUNT;
+    v_removed := v_removed + v_count;
+
+    RETURN v_removed;
+END;
+$$;
+
+-- Invalidate group permissions cache
+-- Removes all cached permissions for a specific group and all its members,
+-- including members of nested groups
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_group_id: Group ID to invalidate cache for
+--
+-- Returns: void
+--
+-- Examples:
+--   SELECT cache.invalidate_group_permissions_cache('uuid', 789);
+CREATE OR REPLACE FUNCTION cache.invalidate_group_permissions_cache(p_tenant_id UUID, p_group_id BIGINT)
+RETURNS void
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    DELETE FROM cache.group_object_permissions WHERE tenant_id = p_tenant_id AND group_id = p_group_id;
+
+    DELETE FROM cache.user_object_permissions
+    WHERE tenant_id = p_tenant_id
+      AND user_id IN (SELECT user_id FROM cluster.get_group_users(p_tenant_id, p_group_id));
+
+    DELETE FROM cache.user_field_restrictions
+    WHERE tenant_id = p_tenant_id
+      AND user_id IN (SELECT user_id FROM cluster.get_group_users(p_tenant_id, p_group_id));
+
+    DELETE FROM cache.user_row_permissions
+    WHERE tenant_id = p_tenant_id
+      AND user_id IN (SELECT user_id FROM cluster.get_group_users(p_tenant_id, p_group_id));
+END;
+$$;
@@ -0,0 +1,207 @@
+-- ========================================
+-- PASSWORD AUTHENTICATION MIGRATION
+-- ========================================
+-- This migration adds storage required by the password authentication flow:
+-- - password hash of the 'password' identities
+-- - sessions holding access token digests
+-- - refresh tokens chained by rotation, so reuse of a rotated token is detected
+-- - one-time password reset tokens
+--
+-- Tokens are never stored in plain text, only SHA-256 digests are kept.
+
+-- Password hash of the identity
+-- Holds argon2id hash in PHC string format for 'password' identities,
+-- NULL for other kinds. A 'password' identity without hash can not be used
+-- for login until the password is set by the reset flow.
+ALTER TABLE iam.identity
+    ADD COLUMN IF NOT EXISTS password_hash       text        NULL,
+    ADD COLUMN IF NOT EXISTS password_changed_at timestamptz NULL;
+
+-- Index for password login lookups (case insensitive subject)
+CREATE INDEX IF NOT EXISTS ix_identity_password_subject
+    ON iam.identity (tenant_id, idp, lower(subject))
+    WHERE kind = 'password' AND deleted_at IS NULL;
+
+-- ========================================
+-- IAM SESSION TABLE
+-- ========================================
+
+-- Session table for issued access tokens
+-- Every successful login opens a session, refresh rotates its access token
+--
+-- Key Features:
+-- - Multi-tenant architecture with tenant_id partitioning
+-- - Revocation on logout, refresh token reuse, password reset and identity revocation
+--
+-- Partitioning: HASH partitioning by tenant_id for performance and isolation
+--
+-- Example usage:
+--   SELECT * FROM iam.session
+--   WHERE tenant_id = 'uuid' AND access_token_hash = digest('token', 'sha256') AND revoked_at IS NULL;
+CREATE TABLE IF NOT EXISTS iam.session
+(
+    -- Tenant identifier for multi-tenant isolation
+    -- Must be explicitly set (no default)
+    tenant_id         uuid        NOT NULL,
+
+    -- Internal sequential ID for database operations
+    id                bigserial   NOT NULL,
+
+    -- Authenticated principal
+    -- References iam.principal.id
+    principal_id      bigint      NOT NULL,
+
+    -- Identity used for authentication
+    -- References iam.identity.id
+    identity_id       bigint      NOT NULL,
+
+    -- SHA-256 digest of the current access token
+    access_token_hash bytea       NOT NULL,
+
+    -- Expiration of the current access token
+    access_expires_at timestamptz NOT NULL,
+
+    -- Record creation timestamp
+    created_at        timestamptz NOT NULL DEFAULT now(),
+
+    -- Last modification timestamp (access token rotation)
+    updated_at        timestamptz NOT NULL DEFAULT now(),
+
+    -- Revocation timestamp, NULL for live sessions
+    revoked_at        timestamptz NULL,
+
+    -- Revocation reason: 'logout', 'refresh_token_reuse', 'password_reset', 'identity_revoked'
+    revoke_reason     text        NULL,
+
+    PRIMARY KEY (tenant_id, id),
+
+    -- Foreign key to principal table
+    -- CASCADE DELETE ensures sessions are removed when principal is deleted
+    FOREIGN KEY (tenant_id, principal_id) REFERENCES iam.principal (tenant_id, id) ON DELETE CASCADE
+) PARTITION BY HASH (tenant_id);
+
+SELECT bootstrap.make_partitions('iam', 'session', 16);
+
+-- Index for access token lookups
+CREATE UNIQUE INDEX IF NOT EXISTS ux_session_access_token ON iam.session (tenant_id, access_token_hash);
+
+-- Index for revocation of the identity and principal sessions
+CREATE INDEX IF NOT EXISTS ix_session_identity ON iam.session (tenant_id, identity_id) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS ix_session_principal ON iam.session (tenant_id, principal_id) WHERE revoked_at IS NULL;
+
+-- ========================================
+-- IAM REFRESH TOKEN TABLE
+-- ========================================
+
+-- Refresh token table
+-- Every refresh consumes the presented token and issues its successor.
+-- Presenting a consumed token again means that the token was stolen,
+-- so the whole session is revoked.
+--
+-- Partitioning: HASH partitioning by tenant_id for performance and isolation
+CREATE TABLE IF NOT EXISTS iam.refresh_token
+(
+    -- Tenant identifier for multi-tenant isolation
+    tenant_id   uuid        NOT NULL,
+
+    -- Internal sequential ID for database operations
+    id          bigserial   NOT NULL,
+
+    -- Session the token belongs to
+    -- References iam.session.id
+    session_id  bigint      NOT NULL,
+
+    -- Token rotated into this one, NULL for the token issued on login
+    parent_id   bigint      NULL,
+
+    -- SHA-256 digest of the token
+    token_hash  bytea       NOT NULL,
+
+    -- Expiration of the token
+    expires_at  timestamptz NOT NULL,
+
+    -- Record creation timestamp
+    created_at  timestamptz NOT NULL DEFAULT now(),
+
+    -- Consumption timestamp, set when the token is rotated
+    used_at     timestamptz NULL,
+
+    PRIMARY KEY (tenant_id, id),
+
+    -- Foreign key to session table
+    FOREIGN KEY (tenant_id, session_id) REFERENCES iam.session (tenant_id, id) ON DELETE CASCADE
+) PARTITION BY HASH (tenant_id);
+
+SELECT bootstrap.make_partitions('iam', 'refresh_token', 16);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_refresh_token_hash ON iam.refresh_token (tenant_id, token_hash);
+CREATE INDEX IF NOT EXISTS ix_refresh_token_session ON iam.refresh_token (tenant_id, session_id);
+
+-- ========================================
+-- IAM PASSWORD RESET TOKEN TABLE
+-- ========================================
+
+-- Password reset token table
+-- Tokens are single use: used_at is set on reset, and requesting a new
+-- token consumes the previous unused ones of the identity.
+--
+-- Partitioning: HASH partitioning by tenant_id for performance and isolation
+CREATE TABLE IF NOT EXISTS iam.password_reset_token
+(
+    -- Tenant identifier for multi-tenant isolation
+    tenant_id   uuid        NOT NULL,
+
+    -- Internal sequential ID for database operations
+    id          bigserial   NOT NULL,
+
+    -- Identity whose password is reset
+    -- References iam.identity.id
+    identity_id bigint      NOT NULL,
+
+    -- SHA-256 digest of the token
+    token_hash  bytea       NOT NULL,
+
+    -- Expiration of the token
+    expires_at  timestamptz NOT NULL,
+
+    -- Record creation timestamp
+    created_at  timestamptz NOT NULL DEFAULT now(),
+
+    -- Consumption timestamp, NULL for unused tokens
+    used_at     timestamptz NULL,
+
+    PRIMARY KEY (tenant_id, id)
+) PARTITION BY HASH (tenant_id);
+
+SELECT bootstrap.make_partitions('iam', 'password_reset_token', 16);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_password_reset_token_hash ON iam.password_reset_token (tenant_id, token_hash);
+CREATE INDEX IF NOT EXISTS ix_password_reset_token_identity ON iam.password_reset_token (tenant_id, identity_id) WHERE used_at IS NULL;
+
+-- ========================================
+-- SESSION REVOCATION
+-- ========================================
+
+-- Revoke sessions opened with the revoked identity
+-- Principal deactivation revokes its identities, so it revokes sessions as well
+CREATE OR REPLACE FUNCTION iam.revoke_identity_sessions()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        UPDATE iam.session
+        SET revoked_at = now(), revoke_reason = 'identity_revoked'
+        WHERE tenant_id = NEW.tenant_id
+          AND identity_id = NEW.id
+          AND revoked_at IS NULL;
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER trg_identity_revoke_sessions
+    AFTER UPDATE ON iam.identity
+    FOR EACH ROW
+EXECUTE FUNCTION iam.revoke_identity_sessions();
@@ -0,0 +1,183 @@
+-- ========================================
+-- RIGHTS GENERATION MIGRATION
+-- ========================================
+-- This migration adds versioning of the principal rights.
+--
+-- Access tokens carry the rights generation (`gen` claim) they were issued for.
+-- Every change invalidating the permissions cache also bumps the generation,
+-- so verifiers reject tokens issued before the rights were changed and
+-- the client has to refresh them.
+--
+-- The generation of the principal is the sum of the tenant wide counter
+-- (principal_id = 0, bumped by object permission changes) and the counter
+-- of the principal itself. Both counters only grow, so does the sum.
+
+-- ========================================
+-- IAM RIGHTS GENERATION TABLE
+-- ========================================
+
+-- Rights generation counters
+--
+-- Partitioning: HASH partitioning by tenant_id for performance and isolation
+--
+-- Example usage:
+--   SELECT iam.get_rights_generation('uuid', 123);
+CREATE TABLE IF NOT EXISTS iam.rights_generation
+(
+    -- Tenant identifier for multi-tenant isolation
+    tenant_id    uuid        NOT NULL,
+
+    -- Principal the counter belongs to, 0 for the tenant wide counter
+    principal_id bigint      NOT NULL,
+
+    -- Number of rights changes
+    generation   bigint      NOT NULL DEFAULT 0,
+
+    -- Last bump timestamp
+    updated_at   timestamptz NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (tenant_id, principal_id)
+) PARTITION BY HASH (tenant_id);
+
+SELECT bootstrap.make_partitions('iam', 'rights_generation', 16);
+
+-- ========================================
+-- RIGHTS GENERATION FUNCTIONS
+-- ========================================
+
+-- Get rights generation of the principal
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_principal_id: Principal ID
+--
+-- Returns: BIGINT
+--
+-- Examples:
+--   SELECT iam.get_rights_generation('uuid', 123);
+CREATE OR REPLACE FUNCTION iam.get_rights_generation(p_tenant_id UUID, p_principal_id BIGINT)
+RETURNS BIGINT
+LANGUAGE sql
+STABLE
+AS $$
+    SELECT COALESCE(SUM(generation), 0)::BIGINT
+    FROM iam.rights_generation
+    WHERE tenant_id = p_tenant_id AND principal_id IN (0, p_principal_id);
+$$;
+
+-- Bump rights generation of the principal
+-- Pass 0 as p_principal_id to bump generation of every principal of the tenant
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_principal_id: Principal ID or 0
+--
+-- Returns: void
+--
+-- Examples:
+--   SELECT iam.bump_rights_generation('uuid', 123);
+CREATE OR REPLACE FUNCTION iam.bump_rights_generation(p_tenant_id UUID, p_principal_id BIGINT)
+RETURNS void
+LANGUAGE sql
+AS $$
+    INSERT INTO iam.rights_generation (tenant_id, principal_id, generation)
+    VALUES (p_tenant_id, p_principal_id, 1)
+    ON CONFLICT (tenant_id, principal_id)
+    DO UPDATE SET generation = iam.rights_generation.generation + 1, updated_at = now();
+$$;
+
+-- Bump rights generation of the user principals
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_user_id: User ID
+--
+-- Returns: void
+--
+-- Examples:
+--   SELECT iam.bump_user_rights_generation('uuid', 123);
+CREATE OR REPLACE FUNCTION iam.bump_user_rights_generation(p_tenant_id UUID, p_user_id BIGINT)
+RETURNS void
+LANGUAGE sql
+AS $$
+    SELECT iam.bump_rights_generation(p_tenant_id, p.id)
+    FROM iam.principal p
+    WHERE p.tenant_id = p_tenant_id AND p.kind = 'user' AND p.subject_id = p_user_id;
+$$;
+
+-- ========================================
+-- CACHE INVALIDATION FUNCTIONS
+-- ========================================
+-- Cache invalidation is the single place where rights changes are noticed,
+-- so the invalidation functions bump the generation too
+
+-- Invalidate user permissions cache
+CREATE OR REPLACE FUNCTION cache.invalidate_user_permissions_cache(p_tenant_id UUID, p_user_id BIGINT)
+RETURNS void
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    DELETE FROM cache.user_object_permissions WHERE tenant_id = p_tenant_id AND user_id = p_user_id;
+    DELETE FROM cache.user_field_restrictions WHERE tenant_id = p_tenant_id AND user_id = p_user_id;
+    DELETE FROM cache.user_row_permissions WHERE tenant_id = p_tenant_id AND user_id = p_user_id;
+
+    PERFORM iam.bump_user_rights_generation(p_tenant_id, p_user_id);
+END;
+$$;
+
+-- Invalidate group permissions cache
+CREATE OR REPLACE FUNCTION cache.invalidate_group_permissions_cache(p_tenant_id UUID, p_group_id BIGINT)
+RETURNS void
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    DELETE FROM cache.group_object_permissions WHERE tenant_id = p_tenant_id AND group_id = p_group_id;
+
+    DELETE FROM cache.user_object_permissions
+    WHERE tenant_id = p_tenant_id
+      AND user_id IN (SELECT user_id FROM cluster.get_group_users(p_tenant_id, p_group_id));
+
+    DELETE FROM cache.user_field_restrictions
+    WHERE tenant_id = p_tenant_id
+      AND user_id IN (SELECT user_id FROM cluster.get_group_users(p_tenant_id, p_group_id));
+
+    DELETE FROM cache.user_row_permissions
+    WHERE tenant_id = p_tenant_id
+      AND user_id IN (SELECT user_id FROM cluster.get_group_users(p_tenant_id, p_group_id));
+
+    PERFORM iam.bump_user_rights_generation(p_tenant_id, g.user_id)
+    FROM cluster.get_group_users(p_tenant_id, p_group_id) g;
+END;
+$$;
+
+-- Invalidate object permissions cache
+-- Object permissions are granted through permission sets shared by many
+-- principals, so the tenant wide generation is bumped
+CREATE OR REPLACE FUNCTION cache.invalidate_object_permissions_cache(p_tenant_id UUID, p_object_id BIGINT)
+RETURNS void
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    DELETE FROM cache.user_object_permissions WHERE tenant_id = p_tenant_id AND object_id = p_object_id;
+    DELETE FROM cache.user_field_restrictions WHERE tenant_id = p_tenant_id AND object_id = p_object_id;
+    DELETE FROM cache.user_row_permissions WHERE tenant_id = p_tenant_id AND object_id = p_object_id;
+    DELETE FROM cache.group_object_permissions WHERE tenant_id = p_tenant_id AND object_id = p_object_id;
+
+    PERFORM iam.bump_rights_generation(p_tenant_id, 0);
+END;
+$$;
+
+-- Invalidate entire tenant cache
+CREATE OR REPLACE FUNCTION cache.invalidate_tenant_cache(p_tenant_id UUID)
+RETURNS void
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    DELETE FROM cache.user_object_permissions WHERE tenant_id = p_tenant_id;
+    DELETE FROM cache.user_field_restrictions WHERE tenant_id = p_tenant_id;
+    DELETE FROM cache.user_row_permissions WHERE tenant_id = p_tenant_id;
+    DELETE FROM cache.group_object_permissions WHERE tenant_id = p_tenant_id;
+
+    PERFORM iam.bump_rights_generation(p_tenant_id, 0);
+END;
+$$;
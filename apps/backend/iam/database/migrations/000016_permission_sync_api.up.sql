@@ -0,0 +1,611 @@
+-- ========================================
+-- PERMISSION SYNC API MIGRATION
+-- ========================================
+-- This migration makes the permission sync functions usable by the
+-- permission sync API:
+-- - functions no longer reference group record_id, group_type,
+--   related_entity_id and membership expires_at, which do not exist;
+--   groups are addressed by their numeric id passed as text
+-- - outbox events are matched by the tenant in the payload, the outbox
+--   has no tenant_id column
+-- - unknown users and groups yield NULL instead of an exception, so bulk
+--   operations report them as failed without aborting the transaction
+-- - snapshots include indirect memberships, permission sources of the groups
+--   and the state of the permission cache
+-- - functions that populate the cache are declared VOLATILE
+
+-- Get complete user permissions snapshot for caching
+-- Object permissions are read back from the cache after it is populated, so
+-- computed_at reflects when the cached entry was computed.
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_user_id: User record ID
+--   p_object_api_names: Objects to include (NULL = all objects)
+--   p_include_group_memberships: Include direct and inherited group memberships
+--   p_include_permission_sources: Include groups granting object permissions
+--   p_ttl_seconds: Cache TTL in seconds (default: 3600 = 1 hour)
+--
+-- Returns: JSONB - Snapshot, NULL if the user does not exist
+--
+-- Examples:
+--   SELECT iam.get_user_permissions_snapshot('uuid', 'usr_a1b2c3d4e5f67890', ARRAY['order']);
+CREATE OR REPLACE FUNCTION iam.get_user_permissions_snapshot(
+    p_tenant_id UUID,
+    p_user_id TEXT,
+    p_object_api_names TEXT[] DEFAULT NULL,
+    p_include_group_memberships BOOLEAN DEFAULT true,
+    p_include_permission_sources BOOLEAN DEFAULT false,
+    p_ttl_seconds INTEGER DEFAULT 3600
+)
+RETURNS JSONB
+LANGUAGE plpgsql
+VOLATILE
+AS $$
+DECLARE
+    v_user_internal_id BIGINT;
+    v_object_ids BIGINT[];
+    v_cached_ids BIGINT[];
+    v_object_permissions JSONB;
+    v_field_permissions JSONB;
+    v_group_memberships JSONB;
+    v_permission_sources JSONB;
+    v_computed INTEGER;
+    v_cached_at TIMESTAMPTZ;
+    v_expires_at TIMESTAMPTZ;
+BEGIN
+    SELECT id INTO v_user_internal_id
+    FROM iam."user"
+    WHERE tenant_id = p_tenant_id AND record_id = p_user_id AND deleted_at IS NULL;
+
+    IF v_user_internal_id IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT coalesce(array_agg(so.id), '{}') INTO v_object_ids
+    FROM security.object so
+    WHERE so.tenant_id = p_tenant_id
+      AND (p_object_api_names IS NULL OR so.api_name = ANY (p_object_api_names));
+
+    -- Cache state before the cache functions populate it
+    SELECT coalesce(array_agg(c.object_id), '{}') INTO v_cached_ids
+    FROM cache.user_object_permissions c
+    WHERE c.tenant_id = p_tenant_id
+      AND c.user_id = v_user_internal_id
+      AND c.object_id = ANY (v_object_ids)
+      AND c.expires_at > now();
+
+    PERFORM cache.get_object_permissions(p_tenant_id, v_user_internal_id, so.id, p_ttl_seconds)
+    FROM security.object so
+    WHERE so.tenant_id = p_tenant_id AND so.id = ANY (v_object_ids);
+
+    -- Objects without permissions are not cached
+    SELECT
+        jsonb_agg(
+            jsonb_build_object(
+                'object_api_name', so.api_name,
+                'object_id', so.id,
+                'permissions', coalesce(c.base_permissions, 0),
+                'computed_at', coalesce(c.cached_at, now())
+            )
+            ORDER BY so.api_name, so.id
+        ),
+        count(c.object_id) FILTER (WHERE c.object_id <> ALL (v_cached_ids)),
+        min(c.cached_at),
+        min(c.expires_at)
+    INTO v_object_permissions, v_computed, v_cached_at, v_expires_at
+    FROM security.object so
+    LEFT JOIN cache.user_object_permissions c
+        ON c.tenant_id = p_tenant_id AND c.user_id = v_user_internal_id AND c.object_id = so.id AND c.expires_at > now()
+    WHERE so.tenant_id = p_tenant_id AND so.id = ANY (v_object_ids);
+
+    SELECT jsonb_agg(
+        jsonb_build_object(
+            'object_api_name', so.api_name,
+            'field_api_name', sf.api_name,
+            'object_id', so.id,
+            'field_id', sf.id,
+            'permissions', cache.get_field_permissions(p_tenant_id, v_user_internal_id, so.id, sf.id, p_ttl_seconds),
+            'computed_at', now()
+        )
+        ORDER BY so.api_name, sf.api_name, sf.id
+    ) INTO v_field_permissions
+    FROM security.object so
+    JOIN security.field sf ON sf.tenant_id = so.tenant_id AND sf.object_id = so.id
+    WHERE so.tenant_id = p_tenant_id AND so.id = ANY (v_object_ids);
+
+    IF p_include_group_memberships THEN
+        SELECT jsonb_agg(
+            jsonb_build_object(
+                'group_record_id', cg.id::text,
+                'group_label', cg.label,
+                'group_api_name', cg.api_name,
+                'group_type', cg.type,
+                'membership_type', CASE WHEN cgm.id IS NOT NULL THEN 'direct' ELSE 'inherited' END,
+                'related_entity_id', coalesce(cg.related_role_id, cg.related_territory_id)::text,
+                'joined_at', cgm.created_at
+            )
+            ORDER BY cg.api_name, cg.id
+        ) INTO v_group_memberships
+        FROM cluster."group" cg
+        LEFT JOIN cluster.group_member cgm
+            ON cgm.tenant_id = cg.tenant_id
+           AND cgm.group_id = cg.id
+           AND cgm.member_user_id = v_user_internal_id
+           AND cgm.deleted_at IS NULL
+        WHERE cg.tenant_id = p_tenant_id
+          AND cg.deleted_at IS NULL
+          AND (
+              cgm.id IS NOT NULL
+              OR EXISTS (SELECT 1 FROM cluster.get_group_users(p_tenant_id, cg.id) u WHERE u.user_id = v_user_internal_id)
+          );
+    END IF;
+
+    IF p_include_permission_sources THEN
+        SELECT jsonb_agg(
+            jsonb_build_object(
+                'source_type', CASE
+                    WHEN s.type IN ('role', 'role_and_subordinates') THEN 'role'
+                    WHEN s.type IN ('territory', 'territory_and_subordinates') THEN 'territory'
+                    ELSE 'group'
+                END,
+                'source_id', s.id::text,
+                'source_name', s.label,
+                'permissions', s.permissions,
+                'priority', 0,
+                'granted_at', s.granted_at
+            )
+            ORDER BY s.label, s.id
+        ) INTO v_permission_sources
+        FROM (
+            SELECT cg.id, cg.label, cg.type, bit_or(sop.permissions) AS permissions, min(sps.created_at) AS granted_at
+            FROM cluster.get_user_permission_groups(p_tenant_id, v_user_internal_id) ug
+            JOIN cluster."group" cg ON cg.tenant_id = p_tenant_id AND cg.id = ug.group_id
+            JOIN security.permission_set sps ON sps.tenant_id = p_tenant_id AND sps.group_id = cg.id AND sps.deleted_at IS NULL
+            JOIN security.object_permissions sop ON sop.tenant_id = p_tenant_id AND sop.permission_set_id = sps.id
+            WHERE sop.object_id = ANY (v_object_ids)
+            GROUP BY cg.id, cg.label, cg.type
+            HAVING bit_or(sop.permissions) <> 0
+        ) s;
+    END IF;
+
+    RETURN jsonb_build_object(
+        'user_id', p_user_id,
+        'tenant_id', p_tenant_id::text,
+        'permissions', coalesce(v_object_permissions, '[]'::jsonb),
+        'field_permissions', coalesce(v_field_permissions, '[]'::jsonb),
+        'group_memberships', coalesce(v_group_memberships, '[]'::jsonb),
+        'permission_sources', coalesce(v_permission_sources, '[]'::jsonb),
+        'cache_info', jsonb_build_object(
+            'from_cache', cardinality(v_cached_ids) > 0 AND v_computed = 0,
+            'cached_at', v_cached_at,
+            'expires_at', v_expires_at,
+            'ttl_seconds', p_ttl_seconds
+        ),
+        'snapshot_at', now(),
+        'snapshot_version', '1.1'
+    );
+END;
+$$;
+
+-- Get user permissions changes since timestamp
+-- Changes are derived from outbox events: memberships of the user and permission
+-- sets assigned to or unassigned from groups that include the user.
+-- Events do not reference objects, so the object filter does not narrow the result.
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_user_id: User record ID
+--   p_since: Return changes after this time
+--   p_object_api_names: Accepted for compatibility
+--   p_include_group_memberships: Include membership changes
+--
+-- Returns: JSONB - Changes, NULL if the user does not exist
+--
+-- Examples:
+--   SELECT iam.get_user_permissions_changes('uuid', 'usr_a1b2c3d4e5f67890', now() - interval '1 hour');
+CREATE OR REPLACE FUNCTION iam.get_user_permissions_changes(
+    p_tenant_id UUID,
+    p_user_id TEXT,
+    p_since TIMESTAMPTZ,
+    p_object_api_names TEXT[] DEFAULT NULL,
+    p_include_group_memberships BOOLEAN DEFAULT true
+)
+RETURNS JSONB
+LANGUAGE plpgsql
+STABLE
+AS $$
+DECLARE
+    v_user_internal_id BIGINT;
+    v_group_ids TEXT[];
+    v_changes JSONB;
+    v_last_change TIMESTAMPTZ;
+BEGIN
+    SELECT id INTO v_user_internal_id
+    FROM iam."user"
+    WHERE tenant_id = p_tenant_id AND record_id = p_user_id AND deleted_at IS NULL;
+
+    IF v_user_internal_id IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT coalesce(array_agg(cg.id::text), '{}') INTO v_group_ids
+    FROM cluster."group" cg
+    WHERE cg.tenant_id = p_tenant_id
+      AND cg.deleted_at IS NULL
+      AND EXISTS (SELECT 1 FROM cluster.get_group_users(p_tenant_id, cg.id) u WHERE u.user_id = v_user_internal_id);
+
+    SELECT
+        jsonb_agg(
+            jsonb_build_object(
+                'change_id', o.id::text,
+                'user_id', p_user_id,
+                'change_type', CASE o.event_type
+                    WHEN 'iam.group_member.added' THEN 'group_joined'
+                    WHEN 'iam.group_member.removed' THEN 'group_left'
+                    WHEN 'iam.permission_set.assigned_to_group' THEN 'permission_granted'
+                    WHEN 'iam.permission_set.unassigned_from_group' THEN 'permission_revoked'
+                END,
+                'group_id', o.payload->>'group_id',
+                'changed_at', o.created_at,
+                'change_reason', o.payload->>'reason',
+                'event_id', o.headers->>'event_id'
+            )
+            ORDER BY o.created_at, o.id
+        ),
+        max(o.created_at)
+    INTO v_changes, v_last_change
+    FROM bootstrap.outbox o
+    WHERE o.payload->>'tenant_id' = p_tenant_id::text
+      AND o.created_at > p_since
+      AND (
+          (
+              p_include_group_memberships
+              AND o.event_type IN ('iam.group_member.added', 'iam.group_member.removed')
+              AND o.payload->>'member_type' = 'user'
+              AND o.payload->>'member_id' = p_user_id
+          )
+          OR (
+              o.event_type IN ('iam.permission_set.assigned_to_group', 'iam.permission_set.unassigned_from_group')
+              AND o.payload->>'group_id' = ANY (v_group_ids)
+          )
+      );
+
+    RETURN jsonb_build_object(
+        'user_id', p_user_id,
+        'tenant_id', p_tenant_id::text,
+        'changes', coalesce(v_changes, '[]'::jsonb),
+        'total_changes', jsonb_array_length(coalesce(v_changes, '[]'::jsonb)),
+        'last_change', coalesce(v_last_change, p_since)
+    );
+END;
+$$;
+
+-- Bulk get permissions for multiple users
+-- Unknown users are reported as failed
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_user_ids: User record IDs (max 100)
+--   p_object_api_names: Objects to include (NULL = all objects)
+--   p_include_group_memberships: Include group memberships
+--   p_include_permission_sources: Include permission sources
+--   p_ttl_seconds: Cache TTL in seconds (default: 3600 = 1 hour)
+--
+-- Returns: JSONB - Snapshots of the found users
+--
+-- Examples:
+--   SELECT iam.get_bulk_user_permissions('uuid', ARRAY['usr_a1b2c3d4e5f67890']);
+CREATE OR REPLACE FUNCTION iam.get_bulk_user_permissions(
+    p_tenant_id UUID,
+    p_user_ids TEXT[],
+    p_object_api_names TEXT[] DEFAULT NULL,
+    p_include_group_memberships BOOLEAN DEFAULT true,
+    p_include_permission_sources BOOLEAN DEFAULT false,
+    p_ttl_seconds INTEGER DEFAULT 3600
+)
+RETURNS JSONB
+LANGUAGE plpgsql
+VOLATILE
+AS $$
+DECLARE
+    v_snapshots JSONB := '[]'::jsonb;
+    v_user_id TEXT;
+    v_snapshot JSONB;
+    v_failed_users TEXT[] := '{}';
+BEGIN
+    IF cardinality(p_user_ids) > 100 THEN
+        RAISE EXCEPTION 'Maximum 100 users allowed in bulk request';
+    END IF;
+
+    FOREACH v_user_id IN ARRAY coalesce(p_user_ids, '{}')
+    LOOP
+        v_snapshot := iam.get_user_permissions_snapshot(
+            p_tenant_id,
+            v_user_id,
+            p_object_api_names,
+            p_include_group_memberships,
+            p_include_permission_sources,
+            p_ttl_seconds
+        );
+
+        IF v_snapshot IS NULL THEN
+            v_failed_users := v_failed_users || v_user_id;
+        ELSE
+            v_snapshots := v_snapshots || jsonb_build_array(v_snapshot);
+        END IF;
+    END LOOP;
+
+    RETURN jsonb_build_object(
+        'snapshots', v_snapshots,
+        'total_users', coalesce(cardinality(p_user_ids), 0),
+        'failed_users', cardinality(v_failed_users),
+        'failed_user_ids', to_jsonb(v_failed_users),
+        'generated_at', now()
+    );
+END;
+$$;
+
+-- Get permissions for users of the group
+-- Members of nested groups, roles and territories are included
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_group_record_id: Group ID
+--   p_object_api_names: Objects to include (NULL = all objects)
+--   p_include_all_members: Include snapshots of the group users
+--   p_include_permission_sources: Include permission sources
+--   p_ttl_seconds: Cache TTL in seconds (default: 3600 = 1 hour)
+--
+-- Returns: JSONB - Group info and snapshots, NULL if the group does not exist
+--
+-- Examples:
+--   SELECT iam.get_group_membership_permissions('uuid', '123', NULL, true);
+CREATE OR REPLACE FUNCTION iam.get_group_membership_permissions(
+    p_tenant_id UUID,
+    p_group_record_id TEXT,
+    p_object_api_names TEXT[] DEFAULT NULL,
+    p_include_all_members BOOLEAN DEFAULT false,
+    p_include_permission_sources BOOLEAN DEFAULT false,
+    p_ttl_seconds INTEGER DEFAULT 3600
+)
+RETURNS JSONB
+LANGUAGE plpgsql
+VOLATILE
+AS $$
+DECLARE
+    v_group_internal_id BIGINT;
+    v_group_info JSONB;
+    v_member_count INTEGER;
+    v_member_permissions JSONB := '[]'::jsonb;
+    v_user_id TEXT;
+    v_snapshot JSONB;
+BEGIN
+    IF p_group_record_id !~ '^[0-9]{1,18}$' THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT id INTO v_group_internal_id
+    FROM cluster."group"
+    WHERE tenant_id = p_tenant_id AND id = p_group_record_id::bigint AND deleted_at IS NULL;
+
+    IF v_group_internal_id IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT count(*) INTO v_member_count
+    FROM cluster.get_group_users(p_tenant_id, v_group_internal_id);
+
+    SELECT jsonb_build_object(
+        'group_record_id', id::text,
+        'group_label', label,
+        'group_api_name', api_name,
+        'group_type', type,
+        'member_count', v_member_count,
+        'last_modified', updated_at
+    ) INTO v_group_info
+    FROM cluster."group"
+    WHERE tenant_id = p_tenant_id AND id = v_group_internal_id;
+
+    IF p_include_all_members THEN
+        FOR v_user_id IN
+            SELECT u.record_id
+            FROM cluster.get_group_users(p_tenant_id, v_group_internal_id) gu
+            JOIN iam."user" u ON u.tenant_id = p_tenant_id AND u.id = gu.user_id
+            ORDER BY u.record_id
+        LOOP
+            v_snapshot := iam.get_user_permissions_snapshot(
+                p_tenant_id,
+                v_user_id,
+                p_object_api_names,
+                false, -- Don't include group memberships to avoid recursion
+                p_include_permission_sources,
+                p_ttl_seconds
+            );
+
+            IF v_snapshot IS NOT NULL THEN
+                v_member_permissions := v_member_permissions || jsonb_build_array(v_snapshot);
+            END IF;
+        END LOOP;
+    END IF;
+
+    RETURN jsonb_build_object(
+        'group_info', v_group_info,
+        'member_permissions', v_member_permissions,
+        'total_members', v_member_count,
+        'generated_at', now()
+    );
+END;
+$$;
+
+-- Check if user permissions have changed since timestamp
+-- Changed objects hold the groups the changes relate to, events do not reference objects
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_user_id: User record ID
+--   p_since: Check changes after this time
+--   p_object_api_names: Accepted for compatibility
+--
+-- Returns: JSONB - Change summary, NULL if the user does not exist
+--
+-- Examples:
+--   SELECT iam.check_user_permissions_changed('uuid', 'usr_a1b2c3d4e5f67890', now() - interval '1 hour');
+CREATE OR REPLACE FUNCTION iam.check_user_permissions_changed(
+    p_tenant_id UUID,
+    p_user_id TEXT,
+    p_since TIMESTAMPTZ,
+    p_object_api_names TEXT[] DEFAULT NULL
+)
+RETURNS JSONB
+LANGUAGE plpgsql
+STABLE
+AS $$
+DECLARE
+    v_changes JSONB;
+    v_changed_objects JSONB;
+BEGIN
+    v_changes := iam.get_user_permissions_changes(p_tenant_id, p_user_id, p_since, p_object_api_names, true);
+
+    IF v_changes IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT coalesce(jsonb_agg(DISTINCT c->>'group_id') FILTER (WHERE c->>'group_id' IS NOT NULL), '[]'::jsonb)
+    INTO v_changed_objects
+    FROM jsonb_array_elements(v_changes->'changes') c;
+
+    RETURN jsonb_build_object(
+        'has_changes', (v_changes->>'total_changes')::int > 0,
+        'last_change', CASE WHEN (v_changes->>'total_changes')::int > 0 THEN v_changes->'last_change' END,
+        'changed_objects', v_changed_objects,
+        'total_changes', v_changes->'total_changes'
+    );
+END;
+$$;
+
+-- Sync permissions for users of the group
+-- Recomputes cached permissions of the users, all group users are synced when
+-- no users are given
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier
+--   p_group_record_id: Group ID
+--   p_user_ids: User record IDs (NULL or empty = all group users)
+--   p_object_api_names: Objects to sync (NULL = all objects)
+--   p_force_refresh: Invalidate cached permissions of the users first
+--
+-- Returns: JSONB - Sync result, NULL if the group does not exist
+--
+-- Examples:
+--   SELECT iam.sync_group_permissions('uuid', '123', NULL, NULL, true);
+CREATE OR REPLACE FUNCTION iam.sync_group_permissions(
+    p_tenant_id UUID,
+    p_group_record_id TEXT,
+    p_user_ids TEXT[] DEFAULT NULL,
+    p_object_api_names TEXT[] DEFAULT NULL,
+    p_force_refresh BOOLEAN DEFAULT false
+)
+RETURNS JSONB
+LANGUAGE plpgsql
+VOLATILE
+AS $$
+DECLARE
+    v_group_internal_id BIGINT;
+    v_user_internal_id BIGINT;
+    v_users_synced INTEGER := 0;
+    v_permissions_updated INTEGER := 0;
+    v_failed_user_ids TEXT[] := '{}';
+    v_user_id TEXT;
+    v_snapshot JSONB;
+BEGIN
+    IF p_group_record_id !~ '^[0-9]{1,18}$' THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT id INTO v_group_internal_id
+    FROM cluster."group"
+    WHERE tenant_id = p_tenant_id AND id = p_group_record_id::bigint AND deleted_at IS NULL;
+
+    IF v_group_internal_id IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    IF coalesce(cardinality(p_user_ids), 0) = 0 THEN
+        SELECT coalesce(array_agg(u.record_id ORDER BY u.record_id), '{}') INTO p_user_ids
+        FROM cluster.get_group_users(p_tenant_id, v_group_internal_id) gu
+        JOIN iam."user" u ON u.tenant_id = p_tenant_id AND u.id = gu.user_id;
+    END IF;
+
+    FOREACH v_user_id IN ARRAY p_user_ids
+    LOOP
+        SELECT id INTO v_user_internal_id
+        FROM iam."user"
+        WHERE tenant_id = p_tenant_id AND record_id = v_user_id AND deleted_at IS NULL;
+
+        IF v_user_internal_id IS NULL THEN
+            v_failed_user_ids := v_failed_user_ids || v_user_id;
+            CONTINUE;
+        END IF;
+
+        IF p_force_refresh THEN
+            PERFORM cache.invalidate_user_permissions_cache(p_tenant_id, v_user_internal_id);
+        END IF;
+
+        -- Rebuilds cache entries that are missing or expired
+        v_snapshot := iam.get_user_permissions_snapshot(
+            p_tenant_id,
+            v_user_id,
+            p_object_api_names,
+            false, -- Don't include group memberships
+            false, -- Don't include permission sources
+            3600   -- 1 hour TTL
+        );
+
+        v_users_synced := v_users_synced + 1;
+        v_permissions_updated := v_permissions_updated + jsonb_array_length(v_snapshot->'permissions');
+    END LOOP;
+
+    RETURN jsonb_build_object(
+        'users_synced', v_users_synced,
+        'permissions_updated', v_permissions_updated,
+        'failed_user_ids', to_jsonb(v_failed_user_ids),
+        'synced_at', now()
+    );
+END;
+$$;
+
+-- Get permission sync statistics
+-- Average processing time is reported in seconds
+--
+-- Parameters:
+--   p_tenant_id: Tenant identifier (NULL = all tenants)
+--   p_since: Period start (default: 1 day ago)
+--
+-- Returns: JSONB - Outbox statistics for the period
+--
+-- Examples:
+--   SELECT iam.get_permission_sync_stats('uuid');
+CREATE OR REPLACE FUNCTION iam.get_permission_sync_stats(
+    p_tenant_id UUID DEFAULT NULL,
+    p_since TIMESTAMPTZ DEFAULT now() - interval '1 day'
+)
+RETURNS JSONB
+LANGUAGE sql
+STABLE
+AS $$
+    SELECT jsonb_build_object(
+        'total_events', count(*),
+        'sync_events', count(*) FILTER (WHERE o.event_type LIKE 'iam.permissions.%'),
+        'failed_events', count(*) FILTER (WHERE o.status = 'dead'),
+        'avg_processing_time', coalesce(
+            extract(epoch FROM avg(o.published_at - o.created_at) FILTER (WHERE o.status = 'done' AND o.published_at IS NOT NULL)),
+            0
+        ),
+        'period_start', p_since,
+        'period_end', now()
+    )
+    FROM bootstrap.outbox o
+    WHERE (p_tenant_id IS NULL OR o.headers->>'tenant_id' = p_tenant_id::text)
+      AND o.created_at > p_since;
+$$;
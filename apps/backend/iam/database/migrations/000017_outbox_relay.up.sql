@@ -0,0 +1,161 @@
+-- ========================================
+-- OUTBOX RELAY MIGRATION
+-- ========================================
+-- This migration prepares the outbox processing functions for the relay worker:
+-- - events are claimed in one statement with FOR UPDATE SKIP LOCKED, so
+--   concurrent workers never claim the same event
+-- - events left in 'processing' by crashed workers are returned to 'pending'
+-- - completion and failure are applied only by the worker holding the lock,
+--   so a worker whose lock was recovered cannot override the new owner
+-- - failure no longer assigns text to the status column
+
+-- Index for stuck lock recovery
+CREATE INDEX IF NOT EXISTS outbox_processing_locked_at_idx
+    ON bootstrap.outbox (locked_at)
+    WHERE status = 'processing';
+
+-- Claim events for processing
+-- Locks the batch of pending events that are due, skipping events locked by other workers
+--
+-- Parameters:
+--   p_worker_id: Identifier of the claiming worker
+--   p_limit: Maximum number of claimed events
+--
+-- Returns: TABLE - Claimed events, attempt includes the current one
+--
+-- Examples:
+--   SELECT * FROM bootstrap.claim_events_for_processing('worker-1', 100);
+CREATE OR REPLACE FUNCTION bootstrap.claim_events_for_processing(p_worker_id TEXT, p_limit INTEGER DEFAULT 100)
+    RETURNS TABLE(
+                     id BIGINT,
+                     aggregate_type TEXT,
+                     aggregate_id TEXT,
+                     event_type TEXT,
+                     payload JSONB,
+                     headers JSONB,
+                     attempt INTEGER,
+                     created_at TIMESTAMPTZ
+                 )
+    LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        WITH claimed AS (
+            SELECT o.id
+            FROM bootstrap.outbox o
+            WHERE o.status = 'pending'
+              AND o.next_attempt_at <= now()
+            ORDER BY o.id
+            LIMIT p_limit
+            FOR UPDATE SKIP LOCKED
+        )
+        UPDATE bootstrap.outbox o
+        SET status = 'processing',
+            locked_by = p_worker_id,
+            locked_at = now(),
+            attempt = o.attempt + 1
+        FROM claimed c
+        WHERE o.id = c.id
+        RETURNING o.id, o.aggregate_type, o.aggregate_id, o.event_type, o.payload, o.headers, o.attempt, o.created_at;
+END;
+$$;
+
+-- Release stuck events
+-- Returns events locked longer than the timeout to 'pending', their attempts are kept
+--
+-- Parameters:
+--   p_lock_timeout: Maximum duration of the event processing
+--
+-- Returns: INTEGER - Number of released events
+--
+-- Examples:
+--   SELECT bootstrap.release_stuck_events(interval '5 minutes');
+CREATE OR REPLACE FUNCTION bootstrap.release_stuck_events(p_lock_timeout INTERVAL)
+    RETURNS INTEGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_released INTEGER;
+BEGIN
+    UPDATE bootstrap.outbox
+    SET status = 'pending',
+        next_attempt_at = now(),
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE status = 'processing'
+      AND locked_at < now() - p_lock_timeout;
+
+    GET DIAGNOSTICS v_released = ROW_COUNT;
+    RETURN v_released;
+END;
+$$;
+
+-- Mark event as completed
+-- Used by outbox workers after successful processing
+--
+-- Parameters:
+--   p_id: Event identifier
+--   p_worker_id: Worker holding the lock, NULL completes the event regardless of the lock
+--
+-- Returns: BOOLEAN - TRUE when the event was completed
+--
+-- Examples:
+--   SELECT bootstrap.mark_event_completed(123, 'worker-1');
+DROP FUNCTION IF EXISTS bootstrap.mark_event_completed(BIGINT);
+
+CREATE FUNCTION bootstrap.mark_event_completed(p_id BIGINT, p_worker_id TEXT DEFAULT NULL)
+    RETURNS BOOLEAN
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_updated_rows INTEGER;
+BEGIN
+    UPDATE bootstrap.outbox
+    SET status = 'done',
+        published_at = now(),
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE id = p_id
+      AND (p_worker_id IS NULL OR (status = 'processing' AND locked_by = p_worker_id));
+
+    GET DIAGNOSTICS v_updated_rows = ROW_COUNT;
+    RETURN v_updated_rows > 0;
+END;
+$$;
+
+-- Mark event as failed (with retry logic)
+-- Used by outbox workers after failed processing, the event is dead after the maximum attempts
+--
+-- Parameters:
+--   p_id: Event identifier
+--   p_max_attempts: Maximum number of attempts
+--   p_worker_id: Worker holding the lock, NULL fails the event regardless of the lock
+--
+-- Returns: BOOLEAN - TRUE when the event was failed
+--
+-- Examples:
+--   SELECT bootstrap.mark_event_failed(123, 5, 'worker-1');
+DROP FUNCTION IF EXISTS bootstrap.mark_event_failed(BIGINT, INTEGER);
+
+CREATE FUNCTION bootstrap.mark_event_failed(p_id BIGINT, p_max_attempts INTEGER DEFAULT 5, p_worker_id TEXT DEFAULT NULL)
+    RETURNS BOOLEAN
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_updated_rows INTEGER;
+BEGIN
+    UPDATE bootstrap.outbox
+    SET status = CASE
+                     WHEN attempt >= p_max_attempts THEN 'dead'
+                     ELSE 'pending'
+        END::bootstrap.outbox_status,
+        next_attempt_at = now() + (attempt * 2 || ' minutes')::interval,
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE id = p_id
+      AND (p_worker_id IS NULL OR (status = 'processing' AND locked_by = p_worker_id));
+
+    GET DIAGNOSTICS v_updated_rows = ROW_COUNT;
+    RETURN v_updated_rows > 0;
+END;
+$$;
@@ -0,0 +1,160 @@
+-- ========================================
+-- OUTBOX ORDERING MIGRATION
+-- ========================================
+-- This migration makes delivery of the outbox events ordered per aggregate
+-- (aggregate_type, aggregate_id):
+-- - the event is claimed only together with every earlier pending event of its
+--   aggregate, so the claiming worker publishes the events of the aggregate in order
+-- - events of the aggregate are held back while an earlier event is processed
+--   or waits for retry; dead events no longer hold the aggregate
+-- - events of different aggregates are still claimed and published in parallel
+-- - events claimed after a failed event of the same aggregate are released
+--   without spending their attempts
+
+-- Index for lookup of the earlier undelivered events of the aggregate
+CREATE INDEX IF NOT EXISTS outbox_aggregate_undelivered_idx
+    ON bootstrap.outbox (aggregate_type, aggregate_id, id)
+    WHERE status IN ('pending', 'processing');
+
+-- Get events ready for processing
+-- Returns events that can be published now without breaking the order of their aggregates
+--
+-- Parameters:
+--   p_limit: Maximum number of events
+--
+-- Returns: TABLE - Events ordered by id
+--
+-- Examples:
+--   SELECT * FROM bootstrap.get_events_ready_for_processing(100);
+CREATE OR REPLACE FUNCTION bootstrap.get_events_ready_for_processing(p_limit INTEGER DEFAULT 100)
+    RETURNS TABLE(
+                     id BIGINT,
+                     aggregate_type TEXT,
+                     aggregate_id TEXT,
+                     event_type TEXT,
+                     payload JSONB,
+                     headers JSONB,
+                     attempt INTEGER
+                 )
+    LANGUAGE plpgsql
+    STABLE
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT
+            o.id,
+            o.aggregate_type,
+            o.aggregate_id,
+            o.event_type,
+            o.payload,
+            o.headers,
+            o.attempt
+        FROM bootstrap.outbox o
+        WHERE o.status = 'pending'
+          AND o.next_attempt_at <= now()
+          AND NOT EXISTS (
+              SELECT 1
+              FROM bootstrap.outbox p
+              WHERE p.aggregate_type = o.aggregate_type
+                AND p.aggregate_id = o.aggregate_id
+                AND p.id < o.id
+                AND (p.status = 'processing' OR (p.status = 'pending' AND p.next_attempt_at > now()))
+          )
+        ORDER BY o.id
+        LIMIT p_limit;
+END;
+$$;
+
+-- Claim events for processing
+-- Locks the batch of pending events that are due, skipping events locked by other workers.
+-- The event is claimed only when every earlier pending or processing event of its aggregate
+-- is claimed too, the worker must publish events of the aggregate in order of their ids.
+--
+-- Parameters:
+--   p_worker_id: Identifier of the claiming worker
+--   p_limit: Maximum number of claimed events
+--
+-- Returns: TABLE - Claimed events, attempt includes the current one
+--
+-- Examples:
+--   SELECT * FROM bootstrap.claim_events_for_processing('worker-1', 100);
+CREATE OR REPLACE FUNCTION bootstrap.claim_events_for_processing(p_worker_id TEXT, p_limit INTEGER DEFAULT 100)
+    RETURNS TABLE(
+                     id BIGINT,
+                     aggregate_type TEXT,
+                     aggregate_id TEXT,
+                     event_type TEXT,
+                     payload JSONB,
+                     headers JSONB,
+                     attempt INTEGER,
+                     created_at TIMESTAMPTZ
+                 )
+    LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        WITH candidates AS (
+            SELECT o.id, o.aggregate_type, o.aggregate_id
+            FROM bootstrap.outbox o
+            WHERE o.status = 'pending'
+              AND o.next_attempt_at <= now()
+            ORDER BY o.id
+            LIMIT p_limit
+            FOR UPDATE SKIP LOCKED
+        ),
+        claimed AS (
+            SELECT c.id
+            FROM candidates c
+            WHERE NOT EXISTS (
+                SELECT 1
+                FROM bootstrap.outbox p
+                WHERE p.aggregate_type = c.aggregate_type
+                  AND p.aggregate_id = c.aggregate_id
+                  AND p.id < c.id
+                  AND p.status IN ('pending', 'processing')
+                  AND p.id NOT IN (SELECT cc.id FROM candidates cc)
+            )
+        )
+        UPDATE bootstrap.outbox o
+        SET status = 'processing',
+            locked_by = p_worker_id,
+            locked_at = now(),
+            attempt = o.attempt + 1
+        FROM claimed c
+        WHERE o.id = c.id
+        RETURNING o.id, o.aggregate_type, o.aggregate_id, o.event_type, o.payload, o.headers, o.attempt, o.created_at;
+END;
+$$;
+
+-- Release event
+-- Returns the claimed event to 'pending' without spending its attempt,
+-- used for events held back by the failure of an earlier event of the aggregate
+--
+-- Parameters:
+--   p_id: Event identifier
+--   p_worker_id: Worker holding the lock
+--
+-- Returns: BOOLEAN - TRUE when the event was released
+--
+-- Examples:
+--   SELECT bootstrap.release_event(123, 'worker-1');
+CREATE OR REPLACE FUNCTION bootstrap.release_event(p_id BIGINT, p_worker_id TEXT)
+    RETURNS BOOLEAN
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_updated_rows INTEGER;
+BEGIN
+    UPDATE bootstrap.outbox
+    SET status = 'pending',
+        attempt = greatest(attempt - 1, 0),
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE id = p_id
+      AND status = 'processing'
+      AND locked_by = p_worker_id;
+
+    GET DIAGNOSTICS v_updated_rows = ROW_COUNT;
+    RETURN v_updated_rows > 0;
+END;
+$$;
@@ -0,0 +1,396 @@
+-- ========================================
+-- WEBHOOKS MIGRATION
+-- ========================================
+-- This migration adds delivery of the outbox events as signed HTTP webhooks:
+-- - tenants register subscriber endpoints with the events they are interested in
+-- - the outbox relay turns every event into one delivery per subscribed endpoint
+-- - deliveries of the endpoint are sent one at a time in order of events
+-- - failed delivery puts the whole endpoint into exponential backoff,
+--   a successful one resets it
+--
+-- Endpoint secrets are kept in plain text, they are required to sign the deliveries.
+
+-- ========================================
+-- IAM WEBHOOK ENDPOINT TABLE
+-- ========================================
+
+-- Webhook endpoint table
+-- Subscriber endpoint of the tenant receiving outbox events
+--
+-- Key Features:
+-- - Multi-tenant architecture with tenant_id partitioning
+-- - Subscription to exact event types or prefixes ending with '*'
+-- - Soft delete with audit trail
+--
+-- Partitioning: HASH partitioning by tenant_id for performance and isolation
+--
+-- Example usage:
+--   INSERT INTO iam.webhook_endpoint (url, event_types, secret)
+--   VALUES ('https://partner.example.com/hooks', '{iam.user.*}', 'whsec_...');
+CREATE TABLE IF NOT EXISTS iam.webhook_endpoint
+(
+    -- Tenant identifier for multi-tenant isolation
+    -- Automatically set from session context
+    tenant_id               uuid        NOT NULL DEFAULT bootstrap.current_tenant_id(),
+
+    -- Internal sequential ID for database operations
+    id                      bigserial   NOT NULL,
+
+    -- URL receiving POST requests with events
+    url                     text        NOT NULL,
+
+    -- Human-readable description of the subscriber
+    description             text        NULL,
+
+    -- Subscribed event types, empty array subscribes to every event
+    -- Example: '{iam.user.created, iam.group.*}'
+    event_types             text[]      NOT NULL DEFAULT '{}',
+
+    -- Secret signing the deliveries with HMAC-SHA256
+    secret                  text        NOT NULL,
+
+    -- Inactive endpoints receive no new deliveries, pending ones wait for activation
+    is_active               boolean     NOT NULL DEFAULT true,
+
+    -- Number of consecutive failed deliveries
+    failures                integer     NOT NULL DEFAULT 0,
+
+    -- Deliveries to the endpoint are suspended until this time after failure
+    retry_at                timestamptz NULL,
+
+    -- Record creation timestamp
+    created_at              timestamptz NOT NULL DEFAULT now(),
+
+    -- Last modification timestamp
+    -- Automatically updated by audit triggers
+    updated_at              timestamptz NOT NULL DEFAULT now(),
+
+    -- Soft delete timestamp
+    deleted_at              timestamptz NULL,
+
+    -- Principal who created this endpoint
+    created_by_principal_id bigint      NOT NULL DEFAULT bootstrap.current_principal_id(),
+
+    -- Principal who last updated this endpoint
+    updated_by_principal_id bigint      NOT NULL DEFAULT bootstrap.current_principal_id(),
+
+    -- Principal who deleted this endpoint
+    deleted_by_principal_id bigint      NULL,
+
+    PRIMARY KEY (tenant_id, id),
+
+    CONSTRAINT iam_webhook_endpoint_url_check CHECK (url ~ '^https?://'),
+
+    CONSTRAINT iam_webhook_endpoint_deleted_by CHECK ((deleted_at IS NULL) = (deleted_by_principal_id IS NULL))
+) PARTITION BY HASH (tenant_id);
+
+SELECT bootstrap.make_partitions('iam', 'webhook_endpoint', 16);
+
+-- Index for lookup of the endpoints subscribed to the tenant events
+CREATE INDEX IF NOT EXISTS ix_webhook_endpoint_active
+    ON iam.webhook_endpoint (tenant_id)
+    WHERE is_active AND deleted_at IS NULL;
+
+SELECT bootstrap.attach_audit_triggers('iam', 'webhook_endpoint');
+
+-- ========================================
+-- WEBHOOK DELIVERY TABLE
+-- ========================================
+
+-- Webhook delivery table
+-- Event waiting for delivery to the endpoint. Deliveries are shared by all tenants
+-- and processed by the dispatcher outside of the tenant context, like the outbox.
+CREATE TABLE IF NOT EXISTS bootstrap.webhook_delivery
+(
+    -- Unique identifier of the delivery
+    id           bigserial               PRIMARY KEY,
+
+    -- Tenant of the endpoint
+    tenant_id    uuid                    NOT NULL,
+
+    -- Receiving endpoint
+    endpoint_id  bigint                  NOT NULL,
+
+    -- Delivered outbox event, the event is delivered to the endpoint once
+    event_id     bigint                  NOT NULL,
+
+    -- Type of the event, sent in X-Webhook-Event header
+    event_type   text                    NOT NULL,
+
+    -- Request body
+    body         jsonb                   NOT NULL,
+
+    -- Current processing status of the delivery
+    status       bootstrap.outbox_status NOT NULL DEFAULT 'pending'::bootstrap.outbox_status,
+
+    -- Number of delivery attempts made
+    attempt      integer                 NOT NULL DEFAULT 0,
+
+    -- Worker currently delivering and time of the lock
+    locked_by    text                    NULL,
+    locked_at    timestamptz             NULL,
+
+    -- Error of the last failed attempt
+    last_error   text                    NULL,
+
+    -- When the delivery was created and acknowledged by the endpoint
+    created_at   timestamptz             NOT NULL DEFAULT now(),
+    delivered_at timestamptz             NULL,
+
+    FOREIGN KEY (tenant_id, endpoint_id) REFERENCES iam.webhook_endpoint (tenant_id, id) ON DELETE CASCADE
+);
+
+-- Every event is delivered to the endpoint once
+CREATE UNIQUE INDEX IF NOT EXISTS ux_webhook_delivery_event
+    ON bootstrap.webhook_delivery (tenant_id, endpoint_id, event_id);
+
+-- Index for lookup of the oldest undelivered delivery of the endpoint
+CREATE INDEX IF NOT EXISTS ix_webhook_delivery_undelivered
+    ON bootstrap.webhook_delivery (tenant_id, endpoint_id, id)
+    WHERE status IN ('pending', 'processing');
+
+-- Index for stuck lock recovery
+CREATE INDEX IF NOT EXISTS ix_webhook_delivery_locked_at
+    ON bootstrap.webhook_delivery (locked_at)
+    WHERE status = 'processing';
+
+-- Enqueue webhook deliveries
+-- Creates delivery of the event for every active endpoint of the tenant subscribed to the event type
+--
+-- Parameters:
+--   p_tenant_id: Tenant of the event
+--   p_event_id: Outbox event identifier
+--   p_event_type: Type of the event
+--   p_body: Request body
+--
+-- Returns: INTEGER - Number of created deliveries, repeated calls create nothing
+--
+-- Examples:
+--   SELECT bootstrap.enqueue_webhook_deliveries('uuid', 123, 'iam.user.created', '{}');
+CREATE OR REPLACE FUNCTION bootstrap.enqueue_webhook_deliveries(
+    p_tenant_id UUID,
+    p_event_id BIGINT,
+    p_event_type TEXT,
+    p_body JSONB
+)
+    RETURNS INTEGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_created INTEGER;
+BEGIN
+    INSERT INTO bootstrap.webhook_delivery (tenant_id, endpoint_id, event_id, event_type, body)
+    SELECT e.tenant_id, e.id, p_event_id, p_event_type, p_body
+    FROM iam.webhook_endpoint e
+    WHERE e.tenant_id = p_tenant_id
+      AND e.is_active
+      AND e.deleted_at IS NULL
+      AND (
+          cardinality(e.event_types) = 0
+          OR EXISTS (
+              SELECT 1
+              FROM unnest(e.event_types) t(pattern)
+              WHERE t.pattern = p_event_type
+                 OR (right(t.pattern, 1) = '*' AND starts_with(p_event_type, left(t.pattern, -1)))
+          )
+      )
+    ORDER BY e.id
+    ON CONFLICT (tenant_id, endpoint_id, event_id) DO NOTHING;
+
+    GET DIAGNOSTICS v_created = ROW_COUNT;
+    RETURN v_created;
+END;
+$$;
+
+-- Claim webhook deliveries
+-- Locks the oldest pending delivery of every endpoint that is not in backoff.
+-- Endpoint with a delivery in processing gets nothing, so deliveries of the endpoint are sent in order.
+--
+-- Parameters:
+--   p_worker_id: Identifier of the claiming worker
+--   p_limit: Maximum number of claimed deliveries
+--
+-- Returns: TABLE - Claimed deliveries with their endpoints, attempt includes the current one
+--
+-- Examples:
+--   SELECT * FROM bootstrap.claim_webhook_deliveries('worker-1', 100);
+CREATE OR REPLACE FUNCTION bootstrap.claim_webhook_deliveries(p_worker_id TEXT, p_limit INTEGER DEFAULT 100)
+    RETURNS TABLE(
+                     id BIGINT,
+                     tenant_id UUID,
+                     endpoint_id BIGINT,
+                     event_id BIGINT,
+                     event_type TEXT,
+                     body JSONB,
+                     attempt INTEGER,
+                     url TEXT,
+                     secret TEXT
+                 )
+    LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        WITH heads AS (
+            SELECT DISTINCT ON (d.tenant_id, d.endpoint_id) d.id, d.status
+            FROM bootstrap.webhook_delivery d
+            WHERE d.status IN ('pending', 'processing')
+            ORDER BY d.tenant_id, d.endpoint_id, d.id
+        ),
+        claimed AS (
+            SELECT d.id
+            FROM heads h
+            JOIN bootstrap.webhook_delivery d ON d.id = h.id
+            JOIN iam.webhook_endpoint e ON e.tenant_id = d.tenant_id AND e.id = d.endpoint_id
+            WHERE h.status = 'pending'
+              AND e.is_active
+              AND e.deleted_at IS NULL
+              AND (e.retry_at IS NULL OR e.retry_at <= now())
+            ORDER BY d.id
+            LIMIT p_limit
+            FOR UPDATE OF d SKIP LOCKED
+        )
+        UPDATE bootstrap.webhook_delivery d
+        SET status = 'processing',
+            locked_by = p_worker_id,
+            locked_at = now(),
+            attempt = d.attempt + 1
+        FROM claimed c, iam.webhook_endpoint e
+        WHERE d.id = c.id
+          AND d.status = 'pending'
+          AND e.tenant_id = d.tenant_id
+          AND e.id = d.endpoint_id
+        RETURNING d.id, d.tenant_id, d.endpoint_id, d.event_id, d.event_type, d.body, d.attempt, e.url, e.secret;
+END;
+$$;
+
+-- Mark webhook delivery as completed
+-- Resets backoff of the endpoint
+--
+-- Parameters:
+--   p_id: Delivery identifier
+--   p_worker_id: Worker holding the lock
+--
+-- Returns: BOOLEAN - TRUE when the delivery was completed
+--
+-- Examples:
+--   SELECT bootstrap.mark_webhook_delivery_completed(123, 'worker-1');
+CREATE OR REPLACE FUNCTION bootstrap.mark_webhook_delivery_completed(p_id BIGINT, p_worker_id TEXT)
+    RETURNS BOOLEAN
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_tenant_id   UUID;
+    v_endpoint_id BIGINT;
+BEGIN
+    UPDATE bootstrap.webhook_delivery
+    SET status = 'done',
+        delivered_at = now(),
+        last_error = NULL,
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE id = p_id
+      AND status = 'processing'
+      AND locked_by = p_worker_id
+    RETURNING tenant_id, endpoint_id INTO v_tenant_id, v_endpoint_id;
+
+    IF NOT FOUND THEN
+        RETURN FALSE;
+    END IF;
+
+    UPDATE iam.webhook_endpoint
+    SET failures = 0,
+        retry_at = NULL
+    WHERE tenant_id = v_tenant_id
+      AND id = v_endpoint_id
+      AND failures <> 0;
+
+    RETURN TRUE;
+END;
+$$;
+
+-- Mark webhook delivery as failed
+-- Schedules the delivery for retry or marks it dead after the maximum attempts,
+-- the endpoint is suspended for p_backoff * 2^(failures - 1), but not longer than p_max_backoff
+--
+-- Parameters:
+--   p_id: Delivery identifier
+--   p_worker_id: Worker holding the lock
+--   p_max_attempts: Maximum number of attempts
+--   p_error: Reason of the failure
+--   p_backoff: Suspension of the endpoint after the first failure
+--   p_max_backoff: Maximum suspension of the endpoint
+--
+-- Returns: BOOLEAN - TRUE when the delivery was failed
+--
+-- Examples:
+--   SELECT bootstrap.mark_webhook_delivery_failed(123, 'worker-1', 10, 'status 503', interval '10 seconds', interval '1 hour');
+CREATE OR REPLACE FUNCTION bootstrap.mark_webhook_delivery_failed(
+    p_id BIGINT,
+    p_worker_id TEXT,
+    p_max_attempts INTEGER,
+    p_error TEXT,
+    p_backoff INTERVAL,
+    p_max_backoff INTERVAL
+)
+    RETURNS BOOLEAN
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_tenant_id   UUID;
+    v_endpoint_id BIGINT;
+BEGIN
+    UPDATE bootstrap.webhook_delivery
+    SET status = CASE
+                     WHEN attempt >= p_max_attempts THEN 'dead'
+                     ELSE 'pending'
+        END::bootstrap.outbox_status,
+        last_error = p_error,
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE id = p_id
+      AND status = 'processing'
+      AND locked_by = p_worker_id
+    RETURNING tenant_id, endpoint_id INTO v_tenant_id, v_endpoint_id;
+
+    IF NOT FOUND THEN
+        RETURN FALSE;
+    END IF;
+
+    UPDATE iam.webhook_endpoint
+    SET failures = failures + 1,
+        retry_at = now() + least(p_backoff * power(2, least(failures, 30)), p_max_backoff)
+    WHERE tenant_id = v_tenant_id
+      AND id = v_endpoint_id;
+
+    RETURN TRUE;
+END;
+$$;
+
+-- Release stuck webhook deliveries
+-- Returns deliveries locked longer than the timeout to 'pending', their attempts are kept
+--
+-- Parameters:
+--   p_lock_timeout: Maximum duration of the delivery
+--
+-- Returns: INTEGER - Number of released deliveries
+--
+-- Examples:
+--   SELECT bootstrap.release_stuck_webhook_deliveries(interval '5 minutes');
+CREATE OR REPLACE FUNCTION bootstrap.release_stuck_webhook_deliveries(p_lock_timeout INTERVAL)
+    RETURNS INTEGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_released INTEGER;
+BEGIN
+    UPDATE bootstrap.webhook_delivery
+    SET status = 'pending',
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE status = 'processing'
+      AND locked_at < now() - p_lock_timeout;
+
+    GET DIAGNOSTICS v_released = ROW_COUNT;
+    RETURN v_released;
+END;
+$$;
@@ -0,0 +1,125 @@
+-- ========================================
+-- OUTBOX DEAD LETTERS MIGRATION
+-- ========================================
+-- This migration supports management of the undeliverable outbox events:
+-- - the moment the event became dead is recorded in dead_at
+-- - dead events are requeued for publishing with fresh attempts
+-- - delivered events are purged after the retention period in batches,
+--   so the purge never holds long locks on the outbox
+
+ALTER TABLE bootstrap.outbox
+    ADD COLUMN IF NOT EXISTS dead_at timestamptz;
+
+-- Index for listing of the dead events
+CREATE INDEX IF NOT EXISTS outbox_dead_created_at_idx
+    ON bootstrap.outbox (created_at, id)
+    WHERE status = 'dead';
+
+-- Index for purging of the delivered events
+CREATE INDEX IF NOT EXISTS outbox_done_published_at_idx
+    ON bootstrap.outbox (published_at)
+    WHERE status = 'done';
+
+-- Mark event as failed (with retry logic)
+-- Used by outbox workers after failed processing, the event is dead after the maximum attempts
+--
+-- Parameters:
+--   p_id: Event identifier
+--   p_max_attempts: Maximum number of attempts
+--   p_worker_id: Worker holding the lock, NULL fails the event regardless of the lock
+--
+-- Returns: BOOLEAN - TRUE when the event was failed
+--
+-- Examples:
+--   SELECT bootstrap.mark_event_failed(123, 5, 'worker-1');
+CREATE OR REPLACE FUNCTION bootstrap.mark_event_failed(p_id BIGINT, p_max_attempts INTEGER DEFAULT 5, p_worker_id TEXT DEFAULT NULL)
+    RETURNS BOOLEAN
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_updated_rows INTEGER;
+BEGIN
+    UPDATE bootstrap.outbox
+    SET status = CASE
+                     WHEN attempt >= p_max_attempts THEN 'dead'
+                     ELSE 'pending'
+        END::bootstrap.outbox_status,
+        dead_at = CASE WHEN attempt >= p_max_attempts THEN now() END,
+        next_attempt_at = now() + (attempt * 2 || ' minutes')::interval,
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE id = p_id
+      AND (p_worker_id IS NULL OR (status = 'processing' AND locked_by = p_worker_id));
+
+    GET DIAGNOSTICS v_updated_rows = ROW_COUNT;
+    RETURN v_updated_rows > 0;
+END;
+$$;
+
+-- Requeue dead events
+-- Returns dead events to 'pending' with fresh attempts, they are published as soon as possible.
+-- Later events of the aggregate may be already delivered, so the requeued event arrives out of order.
+--
+-- Parameters:
+--   p_ids: Event identifiers, events which are not dead are skipped
+--
+-- Returns: INTEGER - Number of requeued events
+--
+-- Examples:
+--   SELECT bootstrap.requeue_dead_events(ARRAY[123, 124]);
+CREATE OR REPLACE FUNCTION bootstrap.requeue_dead_events(p_ids BIGINT[])
+    RETURNS INTEGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_requeued INTEGER;
+BEGIN
+    UPDATE bootstrap.outbox
+    SET status = 'pending',
+        attempt = 0,
+        next_attempt_at = now(),
+        dead_at = NULL,
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE id = ANY(p_ids)
+      AND status = 'dead';
+
+    GET DIAGNOSTICS v_requeued = ROW_COUNT;
+    RETURN v_requeued;
+END;
+$$;
+
+-- Purge delivered events
+-- Deletes one batch of events published earlier than the retention period,
+-- the caller repeats it until fewer events than the limit are deleted
+--
+-- Parameters:
+--   p_retention: Period during which delivered events are kept
+--   p_limit: Maximum number of events deleted at once
+--
+-- Returns: INTEGER - Number of deleted events
+--
+-- Examples:
+--   SELECT bootstrap.purge_delivered_events(interval '30 days', 1000);
+CREATE OR REPLACE FUNCTION bootstrap.purge_delivered_events(p_retention INTERVAL, p_limit INTEGER DEFAULT 1000)
+    RETURNS INTEGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_deleted INTEGER;
+BEGIN
+    DELETE FROM bootstrap.outbox o
+    WHERE o.id IN (
+        SELECT d.id
+        FROM bootstrap.outbox d
+        WHERE d.status = 'done'
+          AND d.published_at < now() - p_retention
+        ORDER BY d.published_at
+        LIMIT p_limit
+        FOR UPDATE SKIP LOCKED
+    );
+
+    GET DIAGNOSTICS v_deleted = ROW_COUNT;
+    RETURN v_deleted;
+END;
+$$;
@@ -0,0 +1,192 @@
+-- ========================================
+-- OUTBOX NOTIFICATIONS MIGRATION
+-- ========================================
+-- This migration wakes up the outbox consumers by LISTEN/NOTIFY instead of
+-- leaving them to find new rows by polling:
+-- - new outbox events notify channel 'bootstrap_outbox'
+-- - new webhook deliveries notify channel 'bootstrap_webhook_delivery'
+-- - requeued dead events notify channel 'bootstrap_outbox'
+--
+-- Notifications are delivered on commit and carry no payload, so the
+-- notifications of one transaction are collapsed into one. Consumers read
+-- the tables themselves and keep polling as a safety net against lost
+-- notifications.
+
+-- Generic function to create outbox events
+-- Used by all entity-specific event generation functions
+--
+-- Parameters:
+--   p_aggregate_type: Type of aggregate (e.g., 'user', 'role', 'group')
+--   p_aggregate_id: ID of the aggregate instance
+--   p_event_type: Type of event (e.g., 'user.created', 'role.updated')
+--   p_payload: Event payload as JSONB
+--   p_headers: Additional headers as JSONB
+--
+-- Returns: void
+--
+-- Example:
+--   SELECT bootstrap.create_outbox_event(
+--     'user', 'usr_123', 'user.created',
+--     '{"name": "John Doe", "email": "john@example.com"}',
+--     '{"tenant_id": "uuid", "correlation_id": "req-123"}'
+--   );
+CREATE OR REPLACE FUNCTION bootstrap.create_outbox_event(
+    p_aggregate_type TEXT,
+    p_aggregate_id TEXT,
+    p_event_type TEXT,
+    p_payload JSONB,
+    p_headers JSONB DEFAULT '{}'::jsonb
+)
+    RETURNS void
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_tenant_id UUID;
+    v_principal_id BIGINT;
+BEGIN
+    -- Get current context
+    v_tenant_id := bootstrap.current_tenant_id();
+    v_principal_id := bootstrap.current_principal_id();
+
+    -- Add tenant_id and principal_id to headers if available
+    IF v_tenant_id IS NOT NULL THEN
+        p_headers := p_headers || jsonb_build_object('tenant_id', v_tenant_id::text);
+    END IF;
+
+    IF v_principal_id IS NOT NULL THEN
+        p_headers := p_headers || jsonb_build_object('principal_id', v_principal_id::text);
+    END IF;
+
+    -- Add timestamp and event metadata
+    p_headers := p_headers || jsonb_build_object(
+            'timestamp', extract(epoch from now())::text,
+            'version', '1.0',
+            'event_id', encode(gen_random_bytes(16), 'hex')
+                              );
+
+    -- Insert into outbox
+    INSERT INTO bootstrap.outbox (aggregate_type, aggregate_id, event_type, payload, headers)
+    VALUES (p_aggregate_type, p_aggregate_id, p_event_type, p_payload, p_headers);
+
+    -- Wake up the outbox consumers after commit
+    PERFORM pg_notify('bootstrap_outbox', '');
+END;
+$$;
+
+-- Function to send event to outbox
+CREATE OR REPLACE FUNCTION cache.send_cache_invalidation_event(
+    p_tenant_id UUID,
+    p_aggregate_type TEXT,
+    p_aggregate_id TEXT,
+    p_event_type TEXT
+)
+RETURNS void
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    INSERT INTO bootstrap.outbox (aggregate_type, aggregate_id, event_type, payload, headers)
+    VALUES (
+        p_aggregate_type,
+        p_aggregate_id,
+        p_event_type,
+        '{}'::jsonb,  -- Empty payload - only the fact of change
+        jsonb_build_object('tenant_id', p_tenant_id)
+    );
+
+    -- Wake up the outbox consumers after commit
+    PERFORM pg_notify('bootstrap_outbox', '');
+END;
+$$;
+
+-- Enqueue webhook deliveries
+-- Creates delivery of the event for every active endpoint of the tenant subscribed to the event type
+--
+-- Parameters:
+--   p_tenant_id: Tenant of the event
+--   p_event_id: Outbox event identifier
+--   p_event_type: Type of the event
+--   p_body: Request body
+--
+-- Returns: INTEGER - Number of created deliveries, repeated calls create nothing
+--
+-- Examples:
+--   SELECT bootstrap.enqueue_webhook_deliveries('uuid', 123, 'iam.user.created', '{}');
+CREATE OR REPLACE FUNCTION bootstrap.enqueue_webhook_deliveries(
+    p_tenant_id UUID,
+    p_event_id BIGINT,
+    p_event_type TEXT,
+    p_body JSONB
+)
+    RETURNS INTEGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_created INTEGER;
+BEGIN
+    INSERT INTO bootstrap.webhook_delivery (tenant_id, endpoint_id, event_id, event_type, body)
+    SELECT e.tenant_id, e.id, p_event_id, p_event_type, p_body
+    FROM iam.webhook_endpoint e
+    WHERE e.tenant_id = p_tenant_id
+      AND e.is_active
+      AND e.deleted_at IS NULL
+      AND (
+          cardinality(e.event_types) = 0
+          OR EXISTS (
+              SELECT 1
+              FROM unnest(e.event_types) t(pattern)
+              WHERE t.pattern = p_event_type
+                 OR (right(t.pattern, 1) = '*' AND starts_with(p_event_type, left(t.pattern, -1)))
+          )
+      )
+    ORDER BY e.id
+    ON CONFLICT (tenant_id, endpoint_id, event_id) DO NOTHING;
+
+    GET DIAGNOSTICS v_created = ROW_COUNT;
+
+    -- Wake up the webhook dispatchers after commit
+    IF v_created > 0 THEN
+        PERFORM pg_notify('bootstrap_webhook_delivery', '');
+    END IF;
+
+    RETURN v_created;
+END;
+$$;
+
+-- Requeue dead events
+-- Returns dead events to 'pending' with fresh attempts, they are published as soon as possible.
+-- Later events of the aggregate may be already delivered, so the requeued event arrives out of order.
+--
+-- Parameters:
+--   p_ids: Event identifiers, events which are not dead are skipped
+--
+-- Returns: INTEGER - Number of requeued events
+--
+-- Examples:
+--   SELECT bootstrap.requeue_dead_events(ARRAY[123, 124]);
+CREATE OR REPLACE FUNCTION bootstrap.requeue_dead_events(p_ids BIGINT[])
+    RETURNS INTEGER
+    LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_requeued INTEGER;
+BEGIN
+    UPDATE bootstrap.outbox
+    SET status = 'pending',
+        attempt = 0,
+        next_attempt_at = now(),
+        dead_at = NULL,
+        locked_by = NULL,
+        locked_at = NULL
+    WHERE id = ANY(p_ids)
+      AND status = 'dead';
+
+    GET DIAGNOSTICS v_requeued = ROW_COUNT;
+
+    -- Wake up the outbox consumers after commit
+    IF v_requeued > 0 THEN
+        PERFORM pg_notify('bootstrap_outbox', '');
+    END IF;
+
+    RETURN v_requeued;
+END;
+$$;
@@ -0,0 +1,349 @@
+-- ========================================
+-- CONTRACT EVENTS MIGRATION
+-- ========================================
+-- This migration emits the events declared by contracts/iam-events.yml
+-- that had no producer:
+-- - iam.user.status_changed when the user principal is activated or deactivated
+-- - iam.role.hierarchy_changed when the parent of the role is changed
+-- - iam.role.permissions_changed when grants of the permission sets
+--   assigned to the role groups are changed
+-- - iam.auth.logout when the session is revoked
+--
+-- The remaining iam.auth.* events carry the client address and are emitted
+-- by the authentication service within its transactions.
+
+-- ========================================
+-- USER STATUS EVENT GENERATION FUNCTIONS
+-- ========================================
+
+-- Generate user.status_changed event
+-- Status of the user is the activity of its principal: 'active' or 'inactive'
+CREATE OR REPLACE FUNCTION iam.generate_user_status_changed_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+    v_user_record_id TEXT;
+BEGIN
+    IF NEW.kind = 'user' AND OLD.is_active IS DISTINCT FROM NEW.is_active THEN
+        SELECT record_id INTO v_user_record_id
+        FROM iam."user"
+        WHERE tenant_id = NEW.tenant_id AND id = NEW.subject_id;
+
+        v_payload := jsonb_build_object(
+            'tenant_id', NEW.tenant_id::text,
+            'user_id', v_user_record_id,
+            'old_status', CASE WHEN OLD.is_active THEN 'active' ELSE 'inactive' END,
+            'new_status', CASE WHEN NEW.is_active THEN 'active' ELSE 'inactive' END,
+            'changed_by', CASE WHEN bootstrap.current_principal_id() IS NOT NULL THEN
+                bootstrap.current_principal_id()::text
+            ELSE NULL END,
+            'reason', NULL
+        );
+
+        PERFORM bootstrap.create_outbox_event(
+            'user',
+            v_user_record_id,
+            'iam.user.status_changed',
+            v_payload
+        );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER trg_user_status_changed_event
+    AFTER UPDATE ON iam.principal
+    FOR EACH ROW
+EXECUTE FUNCTION iam.generate_user_status_changed_event();
+
+-- ========================================
+-- ROLE HIERARCHY EVENT GENERATION FUNCTIONS
+-- ========================================
+
+-- Generate role.hierarchy_changed event
+-- Called when the role is moved under another parent
+CREATE OR REPLACE FUNCTION iam.generate_role_hierarchy_changed_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+BEGIN
+    IF OLD.parent_id IS DISTINCT FROM NEW.parent_id THEN
+        v_payload := jsonb_build_object(
+            'tenant_id', NEW.tenant_id::text,
+            'role_id', NEW.id::text,
+            'old_parent_id', CASE WHEN OLD.parent_id IS NOT NULL THEN OLD.parent_id::text ELSE NULL END,
+            'new_parent_id', CASE WHEN NEW.parent_id IS NOT NULL THEN NEW.parent_id::text ELSE NULL END,
+            'changed_by', CASE WHEN NEW.updated_by_principal_id IS NOT NULL THEN
+                NEW.updated_by_principal_id::text
+            ELSE NULL END
+        );
+
+        PERFORM bootstrap.create_outbox_event(
+            'role',
+            NEW.id::text,
+            'iam.role.hierarchy_changed',
+            v_payload
+        );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER trg_role_hierarchy_changed_event
+    AFTER UPDATE ON iam.role
+    FOR EACH ROW
+EXECUTE FUNCTION iam.generate_role_hierarchy_changed_event();
+
+-- ========================================
+-- ROLE PERMISSIONS EVENT GENERATION FUNCTIONS
+-- ========================================
+
+-- Emit role.permissions_changed event
+-- Permissions of the role are the grants of the permission sets assigned
+-- to its 'role' and 'role_and_subordinates' groups
+--
+-- Parameters:
+--   p_tenant_id: Tenant of the permission set
+--   p_group_id: Group the permission set is assigned to, NULL for unassigned sets
+--   p_changes: Array of {permission_type, permission_id, action}
+--
+-- Returns: void, nothing is emitted for other groups or empty changes
+--
+-- Examples:
+--   SELECT security.emit_role_permissions_changed_event('uuid', 123,
+--     '[{"permission_type": "object_permission", "permission_id": "456", "action": "granted"}]');
+CREATE OR REPLACE FUNCTION security.emit_role_permissions_changed_event(
+    p_tenant_id UUID,
+    p_group_id BIGINT,
+    p_changes JSONB
+)
+RETURNS void
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_role_id BIGINT;
+BEGIN
+    IF p_group_id IS NULL OR p_changes IS NULL OR jsonb_array_length(p_changes) = 0 THEN
+        RETURN;
+    END IF;
+
+    SELECT related_role_id INTO v_role_id
+    FROM cluster."group"
+    WHERE tenant_id = p_tenant_id
+      AND id = p_group_id
+      AND type IN ('role', 'role_and_subordinates')
+      AND deleted_at IS NULL;
+
+    IF v_role_id IS NULL THEN
+        RETURN;
+    END IF;
+
+    PERFORM bootstrap.create_outbox_event(
+        'role',
+        v_role_id::text,
+        'iam.role.permissions_changed',
+        jsonb_build_object(
+            'tenant_id', p_tenant_id::text,
+            'role_id', v_role_id::text,
+            'permission_changes', p_changes,
+            'changed_by', CASE WHEN bootstrap.current_principal_id() IS NOT NULL THEN
+                bootstrap.current_principal_id()::text
+            ELSE NULL END
+        )
+    );
+END;
+$$;
+
+-- Get every grant of the permission set as permission changes
+--
+-- Parameters:
+--   p_tenant_id: Tenant of the permission set
+--   p_permission_set_id: Permission set identifier
+--   p_action: Action of the changes, 'granted' or 'revoked'
+--
+-- Returns: JSONB - Array of {permission_type, permission_id, action}
+--
+-- Examples:
+--   SELECT security.get_permission_set_changes('uuid', 123, 'revoked');
+CREATE OR REPLACE FUNCTION security.get_permission_set_changes(
+    p_tenant_id UUID,
+    p_permission_set_id BIGINT,
+    p_action TEXT
+)
+RETURNS JSONB
+LANGUAGE sql
+STABLE
+AS $$
+    SELECT COALESCE(jsonb_agg(c.change ORDER BY c.permission_type, c.id), '[]'::jsonb)
+    FROM (
+        SELECT 'object_permission' AS permission_type, op.id,
+               jsonb_build_object('permission_type', 'object_permission', 'permission_id', op.id::text, 'action', p_action) AS change
+        FROM security.object_permissions op
+        WHERE op.tenant_id = p_tenant_id AND op.permission_set_id = p_permission_set_id
+        UNION ALL
+        SELECT 'field_permission', fp.id,
+               jsonb_build_object('permission_type', 'field_permission', 'permission_id', fp.id::text, 'action', p_action)
+        FROM security.field_permissions fp
+        WHERE fp.tenant_id = p_tenant_id AND fp.permission_set_id = p_permission_set_id
+    ) c;
+$$;
+
+-- Generate role.permissions_changed events on reassignment or deletion of the permission set
+-- New permission sets have no grants yet, their grants are reported when they are added
+CREATE OR REPLACE FUNCTION security.generate_permission_set_role_changed_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_old_group_id BIGINT;
+    v_new_group_id BIGINT;
+BEGIN
+    v_old_group_id := CASE WHEN OLD.deleted_at IS NULL THEN OLD.group_id END;
+    v_new_group_id := CASE WHEN NEW.deleted_at IS NULL THEN NEW.group_id END;
+
+    IF v_old_group_id IS NOT DISTINCT FROM v_new_group_id THEN
+        RETURN NEW;
+    END IF;
+
+    PERFORM security.emit_role_permissions_changed_event(
+        OLD.tenant_id,
+        v_old_group_id,
+        security.get_permission_set_changes(OLD.tenant_id, OLD.id, 'revoked')
+    );
+
+    PERFORM security.emit_role_permissions_changed_event(
+        NEW.tenant_id,
+        v_new_group_id,
+        security.get_permission_set_changes(NEW.tenant_id, NEW.id, 'granted')
+    );
+
+    RETURN NEW;
+END;
+$$;
+
+-- Generate role.permissions_changed event on change of the object or field grant
+-- Used by triggers of security.object_permissions and security.field_permissions,
+-- TG_ARGV[0] is the permission type
+CREATE OR REPLACE FUNCTION security.generate_grant_role_changed_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_tenant_id UUID;
+    v_id BIGINT;
+    v_permission_set_id BIGINT;
+    v_action TEXT;
+    v_group_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        v_tenant_id := OLD.tenant_id;
+        v_id := OLD.id;
+        v_permission_set_id := OLD.permission_set_id;
+        v_action := 'revoked';
+    ELSIF TG_OP = 'INSERT' THEN
+        v_tenant_id := NEW.tenant_id;
+        v_id := NEW.id;
+        v_permission_set_id := NEW.permission_set_id;
+        v_action := 'granted';
+    ELSE
+        IF OLD.permissions IS NOT DISTINCT FROM NEW.permissions THEN
+            RETURN NULL;
+        END IF;
+        v_tenant_id := NEW.tenant_id;
+        v_id := NEW.id;
+        v_permission_set_id := NEW.permission_set_id;
+        v_action := 'updated';
+    END IF;
+
+    -- The permission set is gone when its grants are deleted by cascade
+    SELECT group_id INTO v_group_id
+    FROM security.permission_set
+    WHERE tenant_id = v_tenant_id AND id = v_permission_set_id AND deleted_at IS NULL;
+
+    PERFORM security.emit_role_permissions_changed_event(
+        v_tenant_id,
+        v_group_id,
+        jsonb_build_array(jsonb_build_object(
+            'permission_type', TG_ARGV[0],
+            'permission_id', v_id::text,
+            'action', v_action
+        ))
+    );
+
+    RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER trg_permission_set_role_changed_event
+    AFTER UPDATE ON security.permission_set
+    FOR EACH ROW
+EXECUTE FUNCTION security.generate_permission_set_role_changed_event();
+
+CREATE TRIGGER trg_object_permissions_role_changed_event
+    AFTER INSERT OR UPDATE OR DELETE ON security.object_permissions
+    FOR EACH ROW
+EXECUTE FUNCTION security.generate_grant_role_changed_event('object_permission');
+
+CREATE TRIGGER trg_field_permissions_role_changed_event
+    AFTER INSERT OR UPDATE OR DELETE ON security.field_permissions
+    FOR EACH ROW
+EXECUTE FUNCTION security.generate_grant_role_changed_event('field_permission');
+
+-- ========================================
+-- SESSION EVENT GENERATION FUNCTIONS
+-- ========================================
+
+-- Generate auth.logout event
+-- Every revocation ends the session, the revoke reason is mapped to the logout type:
+--   'logout' -> 'user_initiated'
+--   'identity_revoked' -> 'admin_forced'
+--   'refresh_token_reuse', 'password_reset' -> 'security_policy'
+CREATE OR REPLACE FUNCTION iam.generate_session_revoked_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_payload JSONB;
+    v_user_record_id TEXT;
+BEGIN
+    IF OLD.revoked_at IS NULL AND NEW.revoked_at IS NOT NULL THEN
+        SELECT u.record_id INTO v_user_record_id
+        FROM iam.principal p
+        JOIN iam."user" u ON u.tenant_id = p.tenant_id AND u.id = p.subject_id
+        WHERE p.tenant_id = NEW.tenant_id AND p.id = NEW.principal_id;
+
+        v_payload := jsonb_build_object(
+            'tenant_id', NEW.tenant_id::text,
+            'user_id', v_user_record_id,
+            'principal_id', NEW.principal_id,
+            'session_id', NEW.id::text,
+            'logout_type', CASE NEW.revoke_reason
+                WHEN 'logout' THEN 'user_initiated'
+                WHEN 'identity_revoked' THEN 'admin_forced'
+                ELSE 'security_policy'
+            END,
+            'session_duration', extract(epoch FROM NEW.revoked_at - NEW.created_at)::bigint
+        );
+
+        PERFORM bootstrap.create_outbox_event(
+            'user',
+            COALESCE(v_user_record_id, NEW.principal_id::text),
+            'iam.auth.logout',
+            v_payload
+        );
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER trg_session_revoked_event
+    AFTER UPDATE ON iam.session
+    FOR EACH ROW
+EXECUTE FUNCTION iam.generate_session_revoked_event();
@@ -0,0 +1,157 @@
+-- ========================================
+-- ROW LEVEL SECURITY MIGRATION
+-- ========================================
+-- This migration enforces tenant isolation by the database instead of
+-- leaving it to the tenant_id conditions of the queries:
+-- - every table of iam, cluster, security and cache schemas and every of
+--   its partitions get policy 'tenant_isolation' that shows and accepts only
+--   the rows of bootstrap.current_tenant_id()
+-- - role iam_app is the non-superuser role of the application, it is
+--   subject to the policies and has no access without tenant context
+-- - the webhook and cache maintenance functions run by the workers across
+--   tenants are executed with the rights of their owner
+--
+-- The application must connect as a login role that is a member of iam_app,
+-- for example:
+--   CREATE ROLE iam_service LOGIN PASSWORD '...' IN ROLE iam_app;
+-- The owner of the tables, which runs the migrations, bypasses the policies.
+--
+-- bootstrap schema is shared by the tenants: the outbox has no tenant column
+-- and the webhook deliveries are processed by the workers of all tenants.
+
+-- ========================================
+-- APPLICATION ROLE
+-- ========================================
+
+DO
+$$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'iam_app') THEN
+        CREATE ROLE iam_app NOLOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE NOBYPASSRLS;
+    END IF;
+END
+$$;
+
+GRANT USAGE ON SCHEMA bootstrap, iam, cluster, security, cache TO iam_app;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA bootstrap, iam, cluster, security, cache TO iam_app;
+
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA bootstrap, iam, cluster, security, cache TO iam_app;
+
+GRANT EXECUTE ON ALL FUNCTIONS IN SCHEMA bootstrap, iam, cluster, security, cache TO iam_app;
+
+-- Objects created by the next migrations are available as well
+ALTER DEFAULT PRIVILEGES IN SCHEMA bootstrap, iam, cluster, security, cache
+    GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO iam_app;
+
+ALTER DEFAULT PRIVILEGES IN SCHEMA bootstrap, iam, cluster, security, cache
+    GRANT USAGE, SELECT ON SEQUENCES TO iam_app;
+
+ALTER DEFAULT PRIVILEGES IN SCHEMA bootstrap, iam, cluster, security, cache
+    GRANT EXECUTE ON FUNCTIONS TO iam_app;
+
+-- ========================================
+-- TENANT ISOLATION
+-- ========================================
+
+-- Enable tenant isolation of the table
+-- Enables row level security of the table and its partitions and creates policy 'tenant_isolation'
+-- comparing tenant_id of the rows with bootstrap.current_tenant_id().
+-- Queries through the table are checked by the policy of the table, the policies of the partitions
+-- protect them from the direct access.
+--
+-- Parameters:
+--   p_schema_name: Name of the schema
+--   p_table_name: Name of the table having column tenant_id
+--
+-- Returns: void
+--
+-- Examples:
+--   SELECT bootstrap.enable_tenant_isolation('iam', 'user');
+--
+-- Usage in migrations:
+--   -- Call after bootstrap.make_partitions, partitions created later are not isolated
+--   SELECT bootstrap.make_partitions('iam', 'user', 16);
+--   SELECT bootstrap.enable_tenant_isolation('iam', 'user');
+--
+-- Note: This function is idempotent - can be called multiple times safely
+CREATE OR REPLACE FUNCTION bootstrap.enable_tenant_isolation(p_schema_name TEXT, p_table_name TEXT) RETURNS void
+    LANGUAGE plpgsql AS
+$function$
+DECLARE
+    v_table REGCLASS;
+    v_relation REGCLASS;
+BEGIN
+    v_table := to_regclass(format('%I.%I', p_schema_name, p_table_name));
+    IF v_table IS NULL THEN
+        RAISE EXCEPTION 'Table does not exist: %.%', p_schema_name, p_table_name;
+    END IF;
+
+    IF NOT EXISTS(
+        SELECT 1 FROM pg_attribute
+        WHERE attrelid = v_table
+          AND attname = 'tenant_id'
+          AND NOT attisdropped
+    ) THEN
+        RAISE EXCEPTION 'Table %.% has no tenant_id column', p_schema_name, p_table_name;
+    END IF;
+
+    -- The tree includes the table itself
+    FOR v_relation IN SELECT relid FROM pg_partition_tree(v_table) LOOP
+        EXECUTE format('ALTER TABLE %s ENABLE ROW LEVEL SECURITY', v_relation);
+        EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %s', v_relation);
+        EXECUTE format(
+            'CREATE POLICY tenant_isolation ON %s
+                USING (tenant_id = bootstrap.current_tenant_id())
+                WITH CHECK (tenant_id = bootstrap.current_tenant_id())',
+            v_relation
+        );
+    END LOOP;
+END
+$function$;
+
+SELECT bootstrap.enable_tenant_isolation('iam', 'user');
+SELECT bootstrap.enable_tenant_isolation('iam', 'role');
+SELECT bootstrap.enable_tenant_isolation('iam', 'territory');
+SELECT bootstrap.enable_tenant_isolation('iam', 'principal');
+SELECT bootstrap.enable_tenant_isolation('iam', 'identity');
+SELECT bootstrap.enable_tenant_isolation('iam', 'session');
+SELECT bootstrap.enable_tenant_isolation('iam', 'refresh_token');
+SELECT bootstrap.enable_tenant_isolation('iam', 'password_reset_token');
+SELECT bootstrap.enable_tenant_isolation('iam', 'rights_generation');
+SELECT bootstrap.enable_tenant_isolation('iam', 'webhook_endpoint');
+
+SELECT bootstrap.enable_tenant_isolation('cluster', 'group');
+SELECT bootstrap.enable_tenant_isolation('cluster', 'group_member');
+
+SELECT bootstrap.enable_tenant_isolation('security', 'object');
+SELECT bootstrap.enable_tenant_isolation('security', 'field');
+SELECT bootstrap.enable_tenant_isolation('security', 'permission_set');
+SELECT bootstrap.enable_tenant_isolation('security', 'object_permissions');
+SELECT bootstrap.enable_tenant_isolation('security', 'field_permissions');
+
+SELECT bootstrap.enable_tenant_isolation('cache', 'user_object_permissions');
+SELECT bootstrap.enable_tenant_isolation('cache', 'user_field_restrictions');
+SELECT bootstrap.enable_tenant_isolation('cache', 'user_row_permissions');
+SELECT bootstrap.enable_tenant_isolation('cache', 'group_object_permissions');
+
+-- ========================================
+-- CROSS-TENANT MAINTENANCE FUNCTIONS
+-- ========================================
+-- The webhook dispatcher, the outbox relay and the cache cleanup have no
+-- tenant context, so their functions bypass the policies as the owner.
+
+ALTER FUNCTION bootstrap.enqueue_webhook_deliveries(UUID, BIGINT, TEXT, JSONB)
+    SECURITY DEFINER SET search_path = pg_catalog, public, pg_temp;
+
+ALTER FUNCTION bootstrap.claim_webhook_deliveries(TEXT, INTEGER)
+    SECURITY DEFINER SET search_path = pg_catalog, public, pg_temp;
+
+ALTER FUNCTION bootstrap.mark_webhook_delivery_completed(BIGINT, TEXT)
+    SECURITY DEFINER SET search_path = pg_catalog, public, pg_temp;
+
+ALTER FUNCTION bootstrap.mark_webhook_delivery_failed(BIGINT, TEXT, INTEGER, TEXT, INTERVAL, INTERVAL)
+    SECURITY DEFINER SET search_path = pg_catalog, public, pg_temp;
+
+ALTER FUNCTION cache.cleanup_expired_permissions_cache()
+    SECURITY DEFINER SET search_path = pg_catalog, public, pg_temp;
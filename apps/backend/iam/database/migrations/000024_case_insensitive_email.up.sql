@@ -0,0 +1,21 @@
+-- ========================================
+-- CASE INSENSITIVE EMAIL MIGRATION
+-- ========================================
+-- This migration makes the unique indexes agree with the checks of the
+-- services: emails of the users and subjects of the local password
+-- identities are compared by lower(), so values differing only in case
+-- are duplicates. Previously the indexes compared the raw values and
+-- concurrent inserts differing only in case passed both the checks and
+-- the indexes.
+--
+-- The migration fails when such duplicates already exist, they must be
+-- resolved by hand before it is applied.
+
+-- Email must be unique among active users only (case insensitive)
+DROP INDEX IF EXISTS iam.user_email_alive;
+CREATE UNIQUE INDEX IF NOT EXISTS user_email_alive ON iam."user" (tenant_id, lower(email)) WHERE deleted_at IS NULL;
+
+-- Subject of the local identity is the email of the user (case insensitive),
+-- subjects of the external IdPs are compared as is by ux_identity_idp_subject
+CREATE UNIQUE INDEX IF NOT EXISTS ux_identity_local_subject ON iam.identity (tenant_id, lower(subject))
+    WHERE deleted_at IS NULL AND idp = 'local';
@@ -0,0 +1,107 @@
+-- ========================================
+-- PASSWORD RESET MAIL MIGRATION
+-- ========================================
+-- This migration moves delivery of the password reset tokens out of the
+-- outbox. Events of the outbox are fanned out to the broker, the webhook
+-- endpoints and the dead letter API, so any of their readers holding the
+-- token could take over the account.
+--
+-- The token is written into iam.password_reset_mail instead, which is read
+-- by the mailer only:
+-- - iam.password_reset_mail is isolated by tenant like the other tables
+-- - iam.take_password_reset_mails hands the mails over to the mailer and
+--   deletes them, so the tokens are kept only until they are sent
+-- - iam.auth.password_reset_requested and password_reset_completed events
+--   carry reset_id instead of the token
+
+-- ========================================
+-- PASSWORD RESET MAIL TABLE
+-- ========================================
+
+CREATE TABLE IF NOT EXISTS iam.password_reset_mail
+(
+    -- Tenant identifier for multi-tenant isolation
+    tenant_id   uuid         NOT NULL DEFAULT bootstrap.current_tenant_id(),
+
+    -- Internal sequential ID for database operations
+    id          bigserial    NOT NULL,
+
+    -- Reset token delivered by the mail
+    -- References iam.password_reset_token.id
+    reset_id    bigint       NOT NULL,
+
+    -- Recipient of the mail
+    email       varchar(255) NOT NULL,
+
+    -- Plain reset token, only its digest is kept by iam.password_reset_token
+    reset_token text         NOT NULL,
+
+    -- Expiration of the token, expired mails are not sent
+    expires_at  timestamptz  NOT NULL,
+
+    -- Record creation timestamp
+    created_at  timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (tenant_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS ix_password_reset_mail_reset ON iam.password_reset_mail (tenant_id, reset_id);
+
+SELECT bootstrap.enable_tenant_isolation('iam', 'password_reset_mail');
+
+-- ========================================
+-- PASSWORD RESET MAIL FUNCTIONS
+-- ========================================
+
+-- Take password reset mails
+-- Deletes the mails handed over to the mailer, the oldest first.
+-- Mails of the expired tokens are deleted without being returned.
+-- A mail lost by the mailer is not repeated: the user requests a new token.
+-- Mails of all tenants are taken, so the function runs with the rights of its owner.
+--
+-- Parameters:
+--   p_limit: Maximum number of mails
+--
+-- Returns: Set of the mails
+--
+-- Examples:
+--   SELECT * FROM iam.take_password_reset_mails(100);
+CREATE OR REPLACE FUNCTION iam.take_password_reset_mails(p_limit INTEGER)
+RETURNS SETOF iam.password_reset_mail
+LANGUAGE sql
+SECURITY DEFINER SET search_path = pg_catalog, public, pg_temp
+AS $$
+    DELETE FROM iam.password_reset_mail WHERE expires_at <= now();
+
+    DELETE FROM iam.password_reset_mail
+    WHERE (tenant_id, id) IN (
+        SELECT tenant_id, id
+        FROM iam.password_reset_mail
+        ORDER BY created_at, id
+        LIMIT p_limit
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING *;
+$$;
+
+-- ========================================
+-- PUBLISHED TOKENS
+-- ========================================
+-- Tokens written by the previous version are removed from the events and
+-- from the webhook deliveries that are kept for the dead letter API.
+-- Tokens already delivered to the subscribers can not be recalled,
+-- unused ones are expired.
+
+UPDATE bootstrap.outbox
+SET payload = payload - 'reset_token'
+WHERE event_type IN ('iam.auth.password_reset_requested', 'iam.auth.password_reset_completed')
+  AND payload ? 'reset_token';
+
+UPDATE bootstrap.webhook_delivery
+SET body = body #- '{payload,reset_token}'
+WHERE event_type IN ('iam.auth.password_reset_requested', 'iam.auth.password_reset_completed')
+  AND body -> 'payload' ? 'reset_token';
+
+UPDATE iam.password_reset_token
+SET used_at = now()
+WHERE used_at IS NULL;
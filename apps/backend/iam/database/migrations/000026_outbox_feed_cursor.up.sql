@@ -0,0 +1,20 @@
+-- ========================================
+-- OUTBOX FEED CURSOR MIGRATION
+-- ========================================
+-- This migration lets the permission change feed read the outbox events of
+-- its tenant only. Previously every stream scanned the events of all tenants
+-- to notice the ids of uncommitted transactions, so the cost of one stream
+-- grew with the traffic of every tenant.
+--
+-- - bootstrap.outbox.txid keeps the transaction that wrote the event
+-- - the feed reads events of the transactions finished before its snapshot
+--   (txid below pg_snapshot_xmin) in order of (txid, id), such events can
+--   not appear later, so the position (txid, id) is a gap-free cursor
+-- - ix_outbox_feed serves the reads of one tenant in order of the cursor
+
+-- Transaction that wrote the event, existing events get the transaction of the migration
+ALTER TABLE bootstrap.outbox
+    ADD COLUMN IF NOT EXISTS txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS ix_outbox_feed
+    ON bootstrap.outbox ((coalesce(payload->>'tenant_id', headers->>'tenant_id')), txid, id);
@@ -0,0 +1,105 @@
+-- ========================================
+-- OUTBOX ORDERING TESTS
+-- ========================================
+-- Events of the aggregate are claimed in order of their ids and held back
+-- while an earlier event is processed or waits for retry.
+-- Events of the other tests are marked delivered, so the claims see only
+-- the fixtures; the changes are rolled back.
+
+BEGIN;
+
+SELECT plan(11);
+
+UPDATE bootstrap.outbox SET status = 'done' WHERE status IN ('pending', 'processing');
+
+-- Events a1, a2, a3 of the aggregate 'a' and b1 of the aggregate 'b', named by their event type
+INSERT INTO bootstrap.outbox (aggregate_type, aggregate_id, event_type, payload)
+VALUES ('test', 'a', 'a1', '{}'),
+       ('test', 'b', 'b1', '{}'),
+       ('test', 'a', 'a2', '{}'),
+       ('test', 'a', 'a3', '{}');
+
+-- Returns the id of the fixture event
+CREATE FUNCTION pg_temp.event_id(p_name TEXT) RETURNS BIGINT
+    LANGUAGE sql AS
+$$
+    SELECT id FROM bootstrap.outbox WHERE aggregate_type = 'test' AND event_type = p_name
+$$;
+
+-- ========================================
+-- HOLD-BACK
+-- ========================================
+
+SELECT results_eq(
+    $$ SELECT event_type FROM bootstrap.claim_events_for_processing('worker-1', 1) ORDER BY id $$,
+    ARRAY['a1'],
+    'the earliest event is claimed first'
+);
+
+SELECT results_eq(
+    $$ SELECT event_type FROM bootstrap.claim_events_for_processing('worker-2', 10) ORDER BY id $$,
+    ARRAY['b1'],
+    'events of the aggregate are held back while the earlier event is processed'
+);
+
+SELECT ok(
+    bootstrap.mark_event_failed(pg_temp.event_id('a1'), 5, 'worker-1'),
+    'failed event waits for retry'
+);
+
+SELECT is_empty(
+    $$ SELECT event_type FROM bootstrap.claim_events_for_processing('worker-2', 10) $$,
+    'events of the aggregate are held back while the earlier event waits for retry'
+);
+
+-- ========================================
+-- ORDERING
+-- ========================================
+
+UPDATE bootstrap.outbox SET next_attempt_at = now() WHERE id = pg_temp.event_id('a1');
+
+SELECT results_eq(
+    $$ SELECT event_type FROM bootstrap.claim_events_for_processing('worker-3', 10) ORDER BY id $$,
+    ARRAY['a1', 'a2', 'a3'],
+    'due event is claimed together with the later events of the aggregate'
+);
+
+SELECT ok(
+    bootstrap.release_event(pg_temp.event_id('a3'), 'worker-3'),
+    'event following the undelivered one is released'
+);
+
+SELECT ok(
+    NOT bootstrap.release_event(pg_temp.event_id('a2'), 'worker-2'),
+    'event locked by another worker is not released'
+);
+
+SELECT is(
+    (SELECT attempt FROM bootstrap.outbox WHERE id = pg_temp.event_id('a3')),
+    0,
+    'released event does not spend its attempt'
+);
+
+-- ========================================
+-- DEAD EVENTS
+-- ========================================
+
+SELECT ok(
+    bootstrap.mark_event_failed(pg_temp.event_id('a1'), 1, 'worker-3'),
+    'event is dead after the maximum attempts'
+);
+
+SELECT ok(
+    bootstrap.mark_event_completed(pg_temp.event_id('a2'), 'worker-3'),
+    'event is completed'
+);
+
+SELECT results_eq(
+    $$ SELECT event_type FROM bootstrap.claim_events_for_processing('worker-4', 10) ORDER BY id $$,
+    ARRAY['a3'],
+    'dead event does not hold back the aggregate'
+);
+
+SELECT * FROM finish();
+
+ROLLBACK;
@@ -0,0 +1,211 @@
+-- ========================================
+-- ROW LEVEL SECURITY TESTS
+-- ========================================
+-- Tenant isolation of the tables for the application role iam_app.
+-- The fixtures are created by the owner, who bypasses the policies,
+-- the checked statements are run by pg_temp.as_app as iam_app.
+
+BEGIN;
+
+SELECT plan(18);
+
+-- Runs the statement as iam_app in the context of the tenant, NULL tenant runs it without context
+-- Returns the number of the rows returned or affected by the statement
+CREATE FUNCTION pg_temp.as_app(p_tenant_id UUID, p_statement TEXT) RETURNS BIGINT
+    LANGUAGE plpgsql AS
+$$
+DECLARE
+    v_count BIGINT;
+BEGIN
+    PERFORM set_config('app.tenant_id', coalesce(p_tenant_id::text, ''), true);
+    SET LOCAL ROLE iam_app;
+    EXECUTE p_statement;
+    GET DIAGNOSTICS v_count = ROW_COUNT;
+    RESET ROLE;
+    RETURN v_count;
+END
+$$;
+
+-- ========================================
+-- APPLICATION ROLE
+-- ========================================
+
+SELECT has_role('iam_app');
+
+SELECT isnt_superuser('iam_app');
+
+SELECT ok(
+    NOT (SELECT rolbypassrls FROM pg_roles WHERE rolname = 'iam_app'),
+    'iam_app is subject to row level security'
+);
+
+SELECT is_empty(
+    $$
+        SELECT m.rolname
+        FROM pg_auth_members a
+        JOIN pg_roles g ON g.oid = a.roleid
+        JOIN pg_roles m ON m.oid = a.member
+        WHERE g.rolname = 'iam_app'
+          AND (m.rolsuper OR m.rolbypassrls)
+    $$,
+    'login roles of the application are subject to row level security'
+);
+
+-- ========================================
+-- POLICIES
+-- ========================================
+
+SELECT is_empty(
+    $$
+        SELECT n.nspname || '.' || c.relname
+        FROM pg_class c
+        JOIN pg_namespace n ON n.oid = c.relnamespace
+        JOIN pg_attribute a ON a.attrelid = c.oid AND a.attname = 'tenant_id' AND NOT a.attisdropped
+        WHERE n.nspname IN ('iam', 'cluster', 'security', 'cache')
+          AND c.relkind IN ('r', 'p')
+          AND NOT c.relrowsecurity
+    $$,
+    'row level security is enabled on every tenant table and partition'
+);
+
+SELECT is_empty(
+    $$
+        SELECT n.nspname || '.' || c.relname
+        FROM pg_class c
+        JOIN pg_namespace n ON n.oid = c.relnamespace
+        JOIN pg_attribute a ON a.attrelid = c.oid AND a.attname = 'tenant_id' AND NOT a.attisdropped
+        WHERE n.nspname IN ('iam', 'cluster', 'security', 'cache')
+          AND c.relkind IN ('r', 'p')
+          AND NOT EXISTS (
+              SELECT 1 FROM pg_policy p
+              WHERE p.polrelid = c.oid AND p.polname = 'tenant_isolation'
+          )
+    $$,
+    'every tenant table and partition has policy tenant_isolation'
+);
+
+-- ========================================
+-- FIXTURES
+-- ========================================
+
+INSERT INTO iam."user" (tenant_id, name, email)
+VALUES ('00000000-0000-0000-0000-00000000000a', 'Alice', 'alice@a.example.com'),
+       ('00000000-0000-0000-0000-00000000000b', 'Bob', 'bob@b.example.com');
+
+-- ========================================
+-- READS
+-- ========================================
+
+SELECT is(
+    pg_temp.as_app('00000000-0000-0000-0000-00000000000a', $$SELECT * FROM iam."user"$$),
+    1::BIGINT,
+    'tenant reads its own rows only'
+);
+
+SELECT is(
+    pg_temp.as_app(
+        '00000000-0000-0000-0000-00000000000a',
+        $$SELECT * FROM iam."user" WHERE tenant_id = '00000000-0000-0000-0000-00000000000b'$$
+    ),
+    0::BIGINT,
+    'rows of another tenant are not readable'
+);
+
+SELECT is(
+    pg_temp.as_app(
+        '00000000-0000-0000-0000-00000000000a',
+        format(
+            $$SELECT * FROM %s WHERE tenant_id = '00000000-0000-0000-0000-00000000000b'$$,
+            (SELECT tableoid::regclass FROM iam."user" WHERE tenant_id = '00000000-0000-0000-0000-00000000000b')
+        )
+    ),
+    0::BIGINT,
+    'rows of another tenant are not readable from the partition'
+);
+
+SELECT is(
+    pg_temp.as_app(NULL, $$SELECT * FROM iam."user"$$),
+    0::BIGINT,
+    'rows are not readable without tenant context'
+);
+
+-- ========================================
+-- WRITES
+-- ========================================
+
+SELECT is(
+    pg_temp.as_app(
+        '00000000-0000-0000-0000-00000000000a',
+        $$UPDATE iam."user" SET name = 'Mallory' WHERE tenant_id = '00000000-0000-0000-0000-00000000000b'$$
+    ),
+    0::BIGINT,
+    'rows of another tenant are not updatable'
+);
+
+SELECT is(
+    pg_temp.as_app(
+        '00000000-0000-0000-0000-00000000000a',
+        $$DELETE FROM iam."user" WHERE tenant_id = '00000000-0000-0000-0000-00000000000b'$$
+    ),
+    0::BIGINT,
+    'rows of another tenant are not deletable'
+);
+
+SELECT throws_ok(
+    $$
+        SELECT pg_temp.as_app(
+            '00000000-0000-0000-0000-00000000000a',
+            'INSERT INTO iam."user" (tenant_id, name, email)
+             VALUES (''00000000-0000-0000-0000-00000000000b'', ''Mallory'', ''mallory@b.example.com'')'
+        )
+    $$,
+    '42501',
+    NULL,
+    'rows can not be inserted into another tenant'
+);
+
+SELECT throws_ok(
+    $$
+        SELECT pg_temp.as_app(
+            '00000000-0000-0000-0000-00000000000a',
+            'UPDATE iam."user" SET tenant_id = ''00000000-0000-0000-0000-00000000000b''
+             WHERE email = ''alice@a.example.com'''
+        )
+    $$,
+    '42501',
+    NULL,
+    'rows can not be moved to another tenant'
+);
+
+SELECT is(
+    pg_temp.as_app(
+        '00000000-0000-0000-0000-00000000000a',
+        $$INSERT INTO iam."user" (name, email) VALUES ('Carol', 'carol@a.example.com')$$
+    ),
+    1::BIGINT,
+    'tenant inserts its own rows'
+);
+
+SELECT results_eq(
+    $$SELECT name::text FROM iam."user" WHERE tenant_id = '00000000-0000-0000-0000-00000000000b'$$,
+    ARRAY['Bob'],
+    'rows of another tenant are intact'
+);
+
+-- ========================================
+-- CROSS-TENANT MAINTENANCE FUNCTIONS
+-- ========================================
+
+SELECT is_definer(
+    'bootstrap', 'claim_webhook_deliveries', ARRAY['text', 'integer']::name[],
+    'webhook deliveries are claimed across tenants'
+);
+
+SELECT is_definer(
+    'cache', 'cleanup_expired_permissions_cache', ARRAY[]::name[],
+    'expired cache is removed across tenants'
+);
+
+SELECT * FROM finish();
+
+ROLLBACK;
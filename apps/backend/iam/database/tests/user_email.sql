@@ -0,0 +1,73 @@
+-- ========================================
+-- USER EMAIL TESTS
+-- ========================================
+-- Emails of the active users are unique within the tenant regardless of case,
+-- the same way the services compare them.
+
+BEGIN;
+
+SELECT plan(5);
+
+INSERT INTO iam."user" (tenant_id, name, email)
+VALUES ('00000000-0000-0000-0000-00000000000a', 'Alice', 'alice@example.com');
+
+SELECT throws_ok(
+    $$
+        INSERT INTO iam."user" (tenant_id, name, email)
+        VALUES ('00000000-0000-0000-0000-00000000000a', 'Alice', 'ALICE@example.com')
+    $$,
+    '23505',
+    NULL,
+    'email differing only in case is a duplicate'
+);
+
+SELECT lives_ok(
+    $$
+        INSERT INTO iam."user" (tenant_id, name, email)
+        VALUES ('00000000-0000-0000-0000-00000000000b', 'Alice', 'ALICE@example.com')
+    $$,
+    'email of another tenant is not a duplicate'
+);
+
+UPDATE iam."user" SET deleted_at = now()
+WHERE tenant_id = '00000000-0000-0000-0000-00000000000a' AND email = 'alice@example.com';
+
+SELECT lives_ok(
+    $$
+        INSERT INTO iam."user" (tenant_id, name, email)
+        VALUES ('00000000-0000-0000-0000-00000000000a', 'Alice', 'Alice@example.com')
+    $$,
+    'email of the deleted user may be reused'
+);
+
+INSERT INTO iam.principal (tenant_id, kind, subject_id, login)
+SELECT tenant_id, 'user', id, email FROM iam."user"
+WHERE tenant_id = '00000000-0000-0000-0000-00000000000a' AND deleted_at IS NULL;
+
+INSERT INTO iam.identity (tenant_id, principal_id, kind, idp, subject)
+SELECT tenant_id, id, 'password', 'local', login FROM iam.principal
+WHERE tenant_id = '00000000-0000-0000-0000-00000000000a';
+
+SELECT throws_ok(
+    $$
+        INSERT INTO iam.identity (tenant_id, principal_id, kind, idp, subject)
+        SELECT tenant_id, id, 'password', 'local', upper(login) FROM iam.principal
+        WHERE tenant_id = '00000000-0000-0000-0000-00000000000a'
+    $$,
+    '23505',
+    NULL,
+    'local subject differing only in case is a duplicate'
+);
+
+SELECT lives_ok(
+    $$
+        INSERT INTO iam.identity (tenant_id, principal_id, kind, idp, subject)
+        SELECT tenant_id, id, 'oauth', 'https://idp.example.com', upper(login) FROM iam.principal
+        WHERE tenant_id = '00000000-0000-0000-0000-00000000000a'
+    $$,
+    'subjects of the external IdPs are case sensitive'
+);
+
+SELECT * FROM finish();
+
+ROLLBACK;
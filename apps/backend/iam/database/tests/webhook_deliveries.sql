@@ -0,0 +1,135 @@
+-- ========================================
+-- WEBHOOK DELIVERY TESTS
+-- ========================================
+-- Failed delivery is retried after the backoff of its endpoint, the backoff
+-- doubles on every failure up to the maximum and the delivery is dead after
+-- the maximum attempts. Deliveries of the other tests are marked delivered,
+-- so the claims see only the fixtures; the changes are rolled back.
+
+BEGIN;
+
+SELECT plan(16);
+
+UPDATE bootstrap.webhook_delivery SET status = 'done' WHERE status IN ('pending', 'processing');
+
+-- Endpoint with the deliveries d1 and d2, named by their event type
+INSERT INTO iam.webhook_endpoint (tenant_id, id, url, secret, created_by_principal_id, updated_by_principal_id)
+VALUES ('00000000-0000-0000-0000-00000000000a', -1, 'https://a.example.com/hooks', 'whsec_test', 1, 1);
+
+INSERT INTO bootstrap.webhook_delivery (tenant_id, endpoint_id, event_id, event_type, body)
+VALUES ('00000000-0000-0000-0000-00000000000a', -1, 1, 'd1', '{}'),
+       ('00000000-0000-0000-0000-00000000000a', -1, 2, 'd2', '{}');
+
+-- Returns the id of the fixture delivery
+CREATE FUNCTION pg_temp.delivery_id(p_name TEXT) RETURNS BIGINT
+    LANGUAGE sql AS
+$$
+    SELECT id FROM bootstrap.webhook_delivery WHERE endpoint_id = -1 AND event_type = p_name
+$$;
+
+-- Returns the suspension of the fixture endpoint, now() is fixed within the transaction
+CREATE FUNCTION pg_temp.backoff() RETURNS INTERVAL
+    LANGUAGE sql AS
+$$
+    SELECT retry_at - now() FROM iam.webhook_endpoint WHERE id = -1
+$$;
+
+-- ========================================
+-- RETRY
+-- ========================================
+
+SELECT results_eq(
+    $$ SELECT event_type, attempt FROM bootstrap.claim_webhook_deliveries('worker-1', 10) $$,
+    $$ VALUES ('d1', 1) $$,
+    'the earliest delivery of the endpoint is claimed alone'
+);
+
+SELECT ok(
+    bootstrap.mark_webhook_delivery_failed(pg_temp.delivery_id('d1'), 'worker-1', 3, 'status 503', interval '10 seconds', interval '1 minute'),
+    'delivery is failed'
+);
+
+SELECT results_eq(
+    $$ SELECT status::text, last_error, locked_by FROM bootstrap.webhook_delivery WHERE id = pg_temp.delivery_id('d1') $$,
+    $$ VALUES ('pending', 'status 503', NULL::text) $$,
+    'failed delivery waits for retry with its error'
+);
+
+SELECT is(pg_temp.backoff(), interval '10 seconds', 'endpoint is suspended for the backoff after the first failure');
+
+SELECT is_empty(
+    $$ SELECT id FROM bootstrap.claim_webhook_deliveries('worker-1', 10) $$,
+    'deliveries of the suspended endpoint are not claimed'
+);
+
+UPDATE iam.webhook_endpoint SET retry_at = now() WHERE id = -1;
+
+SELECT results_eq(
+    $$ SELECT event_type, attempt FROM bootstrap.claim_webhook_deliveries('worker-1', 10) $$,
+    $$ VALUES ('d1', 2) $$,
+    'failed delivery is retried before the later deliveries'
+);
+
+SELECT ok(
+    NOT bootstrap.mark_webhook_delivery_failed(pg_temp.delivery_id('d1'), 'worker-2', 3, 'timeout', interval '10 seconds', interval '1 minute'),
+    'delivery locked by another worker is not failed'
+);
+
+SELECT ok(
+    bootstrap.mark_webhook_delivery_failed(pg_temp.delivery_id('d1'), 'worker-1', 3, 'timeout', interval '10 seconds', interval '1 minute'),
+    'retried delivery is failed'
+);
+
+SELECT is(pg_temp.backoff(), interval '20 seconds', 'backoff doubles on the next failure');
+
+-- ========================================
+-- DEAD DELIVERIES
+-- ========================================
+
+UPDATE iam.webhook_endpoint SET retry_at = now(), failures = 10 WHERE id = -1;
+
+SELECT results_eq(
+    $$ SELECT event_type, attempt FROM bootstrap.claim_webhook_deliveries('worker-1', 10) $$,
+    $$ VALUES ('d1', 3) $$,
+    'delivery is claimed for the last attempt'
+);
+
+SELECT ok(
+    bootstrap.mark_webhook_delivery_failed(pg_temp.delivery_id('d1'), 'worker-1', 3, 'timeout', interval '10 seconds', interval '1 minute'),
+    'last attempt is failed'
+);
+
+SELECT is(
+    (SELECT status::text FROM bootstrap.webhook_delivery WHERE id = pg_temp.delivery_id('d1')),
+    'dead',
+    'delivery is dead after the maximum attempts'
+);
+
+SELECT is(pg_temp.backoff(), interval '1 minute', 'backoff is limited by the maximum');
+
+UPDATE iam.webhook_endpoint SET retry_at = now() WHERE id = -1;
+
+SELECT results_eq(
+    $$ SELECT event_type, attempt FROM bootstrap.claim_webhook_deliveries('worker-1', 10) $$,
+    $$ VALUES ('d2', 1) $$,
+    'dead delivery does not hold back the endpoint'
+);
+
+-- ========================================
+-- COMPLETION
+-- ========================================
+
+SELECT ok(
+    bootstrap.mark_webhook_delivery_completed(pg_temp.delivery_id('d2'), 'worker-1'),
+    'delivery is completed'
+);
+
+SELECT results_eq(
+    $$ SELECT failures, retry_at FROM iam.webhook_endpoint WHERE id = -1 $$,
+    $$ VALUES (0, NULL::timestamptz) $$,
+    'completed delivery resets the backoff of the endpoint'
+);
+
+SELECT * FROM finish();
+
+ROLLBACK;
@@ -0,0 +1,289 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+// ObjectGrants - returns effective permissions of the user on the objects selected by api names.
+// Nil names select every object, unknown names are skipped.
+// Cache entries computed on miss live for ttl.
+func (that *Service) ObjectGrants(ctx context.Context, userId string, objectNames []string, ttl time.Duration) (grants []*ObjectGrant, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		id, err := that.resolveUser(ctx, actor, userId)
+		if err != nil {
+			return err
+		}
+
+		// Statement snapshot is taken before the cache functions run,
+		// so the joined cache entry is the one that existed before the call
+		query := `
+			SELECT
+				o.id,
+				o.api_name,
+				p.permissions,
+				c.object_id IS NOT NULL,
+				CASE WHEN p.permissions <> 0 THEN COALESCE(c.cached_at, now()) END,
+				CASE WHEN p.permissions <> 0 THEN COALESCE(c.expires_at, now() + make_interval(secs => $4::int)) END
+			FROM security.object o
+			CROSS JOIN LATERAL (SELECT cache.get_object_permissions($1, $2, o.id, $4::int) AS permissions) p
+			LEFT JOIN cache.user_object_permissions c
+				ON c.tenant_id = $1 AND c.user_id = $2 AND c.object_id = o.id AND c.expires_at > now()
+			WHERE o.tenant_id = $1 AND ($3::text[] IS NULL OR o.api_name = ANY ($3))
+			ORDER BY o.api_name, o.id`
+
+		grants = make([]*ObjectGrant, 0)
+		err = that.db.Fetch(ctx, query, actor.TenantId, id, objectNames, ttlSeconds(ttl))(func(rows sql.Rows) error {
+			var grant ObjectGrant
+			err := rows.Scan(
+				&grant.ObjectId,
+				&grant.ObjectName,
+				&grant.Permissions,
+				&grant.Cached,
+				&grant.CachedAt,
+				&grant.ExpiresAt,
+			)
+			if err != nil {
+				return err
+			}
+			grants = append(grants, &grant)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("get object permissions: %w", err)
+		}
+
+		return that.fillObjectSources(ctx, actor, id, grants)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// FieldGrants - returns effective permissions of the user on the fields of the object selected by api names.
+// Nil names select every field of the object, unknown names are skipped.
+func (that *Service) FieldGrants(ctx context.Context, userId string, objectName string, fieldNames []string, ttl time.Duration) (grants []*FieldGrant, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		id, err := that.resolveUser(ctx, actor, userId)
+		if err != nil {
+			return err
+		}
+
+		var objectId int64
+		err = that.db.QueryRow(
+			ctx,
+			`SELECT id FROM security.object WHERE tenant_id = $1 AND api_name = $2`,
+			actor.TenantId, objectName,
+		).Scan(&objectId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrObjectNotFound
+			}
+			return fmt.Errorf("resolve object: %w", err)
+		}
+
+		// Field permissions are cached as object permissions and field restrictions,
+		// the field is cached when both entries are
+		query := `
+			SELECT
+				f.id,
+				f.api_name,
+				p.permissions,
+				p.restriction,
+				o.object_id IS NOT NULL AND r.field_id IS NOT NULL,
+				CASE WHEN p.permissions <> 0 THEN COALESCE(r.cached_at, now()) END,
+				CASE WHEN p.permissions <> 0 THEN COALESCE(LEAST(o.expires_at, r.expires_at), now() + make_interval(secs => $5::int)) END
+			FROM security.field f
+			CROSS JOIN LATERAL (
+				SELECT
+					cache.get_field_permissions($1, $2, $3, f.id, $5::int) AS permissions,
+					cache.get_field_restriction($1, $2, $3, f.id, $5::int) AS restriction
+			) p
+			LEFT JOIN cache.user_object_permissions o
+				ON o.tenant_id = $1 AND o.user_id = $2 AND o.object_id = $3 AND o.expires_at > now()
+			LEFT JOIN cache.user_field_restrictions r
+				ON r.tenant_id = $1 AND r.user_id = $2 AND r.object_id = $3 AND r.field_id = f.id AND r.expires_at > now()
+			WHERE f.tenant_id = $1 AND f.object_id = $3 AND ($4::text[] IS NULL OR f.api_name = ANY ($4))
+			ORDER BY f.api_name, f.id`
+
+		grants = make([]*FieldGrant, 0)
+		err = that.db.Fetch(ctx, query, actor.TenantId, id, objectId, fieldNames, ttlSeconds(ttl))(func(rows sql.Rows) error {
+			grant := FieldGrant{ObjectId: objectId, ObjectName: objectName}
+			err := rows.Scan(
+				&grant.FieldId,
+				&grant.FieldName,
+				&grant.Permissions,
+				&grant.Restriction,
+				&grant.Cached,
+				&grant.CachedAt,
+				&grant.ExpiresAt,
+			)
+			if err != nil {
+				return err
+			}
+			grants = append(grants, &grant)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("get field permissions: %w", err)
+		}
+
+		return that.fillFieldSources(ctx, actor, id, grants)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// Memberships - returns groups that include the user.
+// Indirect memberships (through nested groups, roles and territories) are returned on demand.
+func (that *Service) Memberships(ctx context.Context, userId string, indirect bool) ([]*Membership, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := that.resolveUser(ctx, actor, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT g.id, g.label, g.api_name, g.type, g.related_role_id, g.related_territory_id, m.created_at
+		FROM cluster."group" g
+		LEFT JOIN cluster.group_member m
+			ON m.tenant_id = g.tenant_id AND m.group_id = g.id AND m.member_user_id = $2 AND m.deleted_at IS NULL
+		WHERE g.tenant_id = $1
+		  AND g.deleted_at IS NULL
+		  AND (
+			  m.id IS NOT NULL
+			  OR $3::bool AND EXISTS (SELECT 1 FROM cluster.get_group_users($1, g.id) u WHERE u.user_id = $2)
+		  )
+		ORDER BY g.api_name, g.id`
+
+	memberships := make([]*Membership, 0)
+	err = that.db.Fetch(ctx, query, actor.TenantId, id, indirect)(func(rows sql.Rows) error {
+		var membership Membership
+		err := rows.Scan(
+			&membership.GroupId,
+			&membership.GroupLabel,
+			&membership.GroupApiName,
+			&membership.GroupType,
+			&membership.RelatedRoleId,
+			&membership.RelatedTerritoryId,
+			&membership.JoinedAt,
+		)
+		if err != nil {
+			return err
+		}
+		membership.Direct = membership.JoinedAt != nil
+		memberships = append(memberships, &membership)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get memberships: %w", err)
+	}
+	return memberships, nil
+}
+
+// fillObjectSources - attaches groups granting object permissions to the grants
+func (that *Service) fillObjectSources(ctx context.Context, actor services.Actor, userId int64, grants []*ObjectGrant) error {
+	if len(grants) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]*ObjectGrant, len(grants))
+	ids := make([]int64, 0, len(grants))
+	for _, grant := range grants {
+		index[grant.ObjectId] = grant
+		ids = append(ids, grant.ObjectId)
+	}
+
+	query := `
+		SELECT op.object_id, g.id, g.label, g.type, bit_or(op.permissions)
+		FROM cluster.get_user_permission_groups($1, $2) ug
+		JOIN cluster."group" g ON g.tenant_id = $1 AND g.id = ug.group_id
+		JOIN security.permission_set ps ON ps.tenant_id = $1 AND ps.group_id = g.id AND ps.deleted_at IS NULL
+		JOIN security.object_permissions op ON op.tenant_id = $1 AND op.permission_set_id = ps.id
+		WHERE op.object_id = ANY ($3)
+		GROUP BY op.object_id, g.id, g.label, g.type
+		HAVING bit_or(op.permissions) <> 0
+		ORDER BY op.object_id, g.label, g.id`
+
+	err := that.db.Fetch(ctx, query, actor.TenantId, userId, ids)(func(rows sql.Rows) error {
+		var objectId int64
+		var source Source[permissions.ObjectAccess]
+		err := rows.Scan(&objectId, &source.GroupId, &source.GroupLabel, &source.GroupType, &source.Permissions)
+		if err != nil {
+			return err
+		}
+		grant := index[objectId]
+		grant.Sources = append(grant.Sources, &source)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("get object permission sources: %w", err)
+	}
+	return nil
+}
+
+// fillFieldSources - attaches groups granting field permissions to the grants
+func (that *Service) fillFieldSources(ctx context.Context, actor services.Actor, userId int64, grants []*FieldGrant) error {
+	if len(grants) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]*FieldGrant, len(grants))
+	ids := make([]int64, 0, len(grants))
+	for _, grant := range grants {
+		index[grant.FieldId] = grant
+		ids = append(ids, grant.FieldId)
+	}
+
+	query := `
+		SELECT fp.field_id, g.id, g.label, g.type, bit_or(fp.permissions)
+		FROM cluster.get_user_permission_groups($1, $2) ug
+		JOIN cluster."group" g ON g.tenant_id = $1 AND g.id = ug.group_id
+		JOIN security.permission_set ps ON ps.tenant_id = $1 AND ps.group_id = g.id AND ps.deleted_at IS NULL
+		JOIN security.field_permissions fp ON fp.tenant_id = $1 AND fp.permission_set_id = ps.id
+		WHERE fp.field_id = ANY ($3)
+		GROUP BY fp.field_id, g.id, g.label, g.type
+		HAVING bit_or(fp.permissions) <> 0
+		ORDER BY fp.field_id, g.label, g.id`
+
+	err := that.db.Fetch(ctx, query, actor.TenantId, userId, ids)(func(rows sql.Rows) error {
+		var fieldId int64
+		var source Source[permissions.FieldAccess]
+		err := rows.Scan(&fieldId, &source.GroupId, &source.GroupLabel, &source.GroupType, &source.Permissions)
+		if err != nil {
+			return err
+		}
+		grant := index[fieldId]
+		grant.Sources = append(grant.Sources, &source)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("get field permission sources: %w", err)
+	}
+	return nil
+}
+
+// ttlSeconds - converts cache ttl for the cache functions, non-positive ttl falls back to the default
+func ttlSeconds(ttl time.Duration) int {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return int(ttl.Seconds())
+}
@@ -0,0 +1,123 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services/groups"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"github.com/adverax/metacrm/pkg/validation"
+)
+
+// Check - question "can the user perform the operation on the object".
+// FieldId narrows the check to the field, RowId narrows it to the row.
+type Check struct {
+	UserId     string
+	ObjectId   int64
+	FieldId    *int64
+	RowId      *int64
+	Permission permissions.ObjectAccess
+}
+
+func (that *Check) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.UserId, validation.Required),
+		validation.Field(&that.ObjectId, validation.Required),
+		validation.Field(&that.FieldId, validation.Nil.When(that.RowId != nil)),
+		validation.Field(
+			&that.Permission,
+			validation.Required,
+			validation.In(
+				permissions.ObjectRead,
+				permissions.ObjectUpdate,
+				permissions.ObjectCreate,
+				permissions.ObjectDelete,
+			),
+		),
+	)
+}
+
+// Decision - answer to the Check
+type Decision struct {
+	Check
+	Allowed bool
+	// Actual - permissions the user has on the checked level.
+	// Field level permissions use field bitmask (1=READ, 2=WRITE).
+	Actual int
+	// Cached - the answer was served from the permission cache without computation
+	Cached bool
+}
+
+// DefaultCacheTTL - lifetime of the computed permission cache entries
+const DefaultCacheTTL = time.Hour
+
+// ObjectGrant - effective object permissions of the user
+type ObjectGrant struct {
+	ObjectId    int64
+	ObjectName  string
+	Permissions permissions.ObjectAccess
+	Cached      bool
+	// CachedAt and ExpiresAt describe the cache entry, both are nil when nothing is granted,
+	// since empty permissions are not cached
+	CachedAt  *time.Time
+	ExpiresAt *time.Time
+	Sources   []*Source[permissions.ObjectAccess]
+}
+
+// FieldGrant - effective field permissions of the user
+type FieldGrant struct {
+	ObjectId    int64
+	ObjectName  string
+	FieldId     int64
+	FieldName   string
+	Permissions permissions.FieldAccess
+	Restriction permissions.FieldAccess
+	Cached      bool
+	CachedAt    *time.Time
+	ExpiresAt   *time.Time
+	Sources     []*Source[permissions.FieldAccess]
+}
+
+// Source - group whose permission sets grant permissions to the user.
+// Permissions of the sources are combined, no source takes precedence.
+type Source[T permissions.ObjectAccess | permissions.FieldAccess] struct {
+	GroupId     int64
+	GroupLabel  string
+	GroupType   groups.GroupType
+	Permissions T
+}
+
+// Membership - group that includes the user
+type Membership struct {
+	GroupId            int64
+	GroupLabel         string
+	GroupApiName       string
+	GroupType          groups.GroupType
+	RelatedRoleId      *int64
+	RelatedTerritoryId *int64
+	// Direct - the user is a member of the group itself, not of its nested group, role or territory
+	Direct   bool
+	JoinedAt *time.Time // nil for indirect memberships
+}
+
+// RowGrant - cached row permissions of the user
+type RowGrant struct {
+	ObjectId    int64
+	ObjectName  string
+	RowId       int64
+	Permissions permissions.ObjectAccess
+	CachedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+type Filter struct {
+	ObjectId *int64
+}
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrObjectNotFound = errors.New("security object not found")
+	ErrFieldNotFound  = errors.New("security field not found")
+)
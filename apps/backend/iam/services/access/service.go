@@ -0,0 +1,304 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+// Service - answers permission questions using the permission cache.
+// Cache functions populate the cache on miss, so every call runs in a transaction.
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// Check - checks permission of the user on the object, field or row.
+// Cache state is inspected before the cache functions are called,
+// so the decision reports whether it was computed or served from cache.
+func (that *Service) Check(ctx context.Context, check Check) (decision *Decision, err error) {
+	if err := check.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		userId, err := that.resolveUser(ctx, actor, check.UserId)
+		if err != nil {
+			return err
+		}
+
+		if err := that.checkTarget(ctx, actor, check); err != nil {
+			return err
+		}
+
+		decision = &Decision{Check: check}
+		err = that.db.QueryRow(
+			ctx,
+			`SELECT CASE
+				WHEN $5::bigint IS NOT NULL THEN EXISTS (
+					SELECT 1 FROM cache.user_row_permissions
+					WHERE tenant_id = $1 AND user_id = $2 AND object_id = $3 AND row_id = $5 AND expires_at > now()
+				)
+				WHEN $4::bigint IS NOT NULL THEN EXISTS (
+					SELECT 1 FROM cache.user_object_permissions
+					WHERE tenant_id = $1 AND user_id = $2 AND object_id = $3 AND expires_at > now()
+				) AND EXISTS (
+					SELECT 1 FROM cache.user_field_restrictions
+					WHERE tenant_id = $1 AND user_id = $2 AND object_id = $3 AND field_id = $4 AND expires_at > now()
+				)
+				ELSE EXISTS (
+					SELECT 1 FROM cache.user_object_permissions
+					WHERE tenant_id = $1 AND user_id = $2 AND object_id = $3 AND expires_at > now()
+				)
+			END`,
+			actor.TenantId, userId, check.ObjectId, check.FieldId, check.RowId,
+		).Scan(&decision.Cached)
+		if err != nil {
+			return fmt.Errorf("inspect permission cache: %w", err)
+		}
+
+		err = that.db.QueryRow(
+			ctx,
+			`SELECT
+				cache.has_permission($1, $2, $3, $4::bigint, $5::bigint, $6),
+				CASE
+					WHEN $5::bigint IS NOT NULL THEN cache.get_row_permissions($1, $2, $3, $5)
+					WHEN $4::bigint IS NOT NULL THEN cache.get_field_permissions($1, $2, $3, $4)
+					ELSE cache.get_object_permissions($1, $2, $3)
+				END`,
+			actor.TenantId, userId, check.ObjectId, check.FieldId, check.RowId, check.Permission,
+		).Scan(&decision.Allowed, &decision.Actual)
+		if err != nil {
+			return fmt.Errorf("check permission: %w", err)
+		}
+
+		return nil
+	})
+	return decision, err
+}
+
+// Objects - returns effective permissions of the user on every object
+func (that *Service) Objects(ctx context.Context, userId string, page services.Page) (list *services.List[*ObjectGrant], err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		id, err := that.resolveUser(ctx, actor, userId)
+		if err != nil {
+			return err
+		}
+
+		// Cache functions are applied to the requested page only
+		query := `
+			WITH page AS (
+				SELECT o.id, o.api_name, count(*) OVER () AS total
+				FROM security.object o
+				WHERE o.tenant_id = $1
+				ORDER BY o.api_name, o.id
+				LIMIT $3 OFFSET $4
+			)
+			SELECT
+				p.id,
+				p.api_name,
+				cache.get_object_permissions($1, $2, p.id),
+				EXISTS (
+					SELECT 1 FROM cache.user_object_permissions c
+					WHERE c.tenant_id = $1 AND c.user_id = $2 AND c.object_id = p.id AND c.expires_at > now()
+				),
+				p.total
+			FROM page p
+			ORDER BY p.api_name, p.id`
+
+		list = &services.List[*ObjectGrant]{Page: page, Items: make([]*ObjectGrant, 0)}
+		return that.db.Fetch(ctx, query, actor.TenantId, id, page.Limit, page.Offset())(func(rows sql.Rows) error {
+			var grant ObjectGrant
+			err := rows.Scan(&grant.ObjectId, &grant.ObjectName, &grant.Permissions, &grant.Cached, &list.Total)
+			if err != nil {
+				return err
+			}
+			list.Items = append(list.Items, &grant)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Fields - returns effective permissions of the user on every field
+func (that *Service) Fields(ctx context.Context, userId string, filter Filter, page services.Page) (list *services.List[*FieldGrant], err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		id, err := that.resolveUser(ctx, actor, userId)
+		if err != nil {
+			return err
+		}
+
+		where := []string{"f.tenant_id = $1"}
+		args := []any{actor.TenantId, id}
+
+		if filter.ObjectId != nil {
+			args = append(args, *filter.ObjectId)
+			where = append(where, fmt.Sprintf("f.object_id = $%d", len(args)))
+		}
+
+		args = append(args, page.Limit, page.Offset())
+		query := fmt.Sprintf(`
+			WITH page AS (
+				SELECT f.object_id, o.api_name AS object_name, f.id, f.api_name, count(*) OVER () AS total
+				FROM security.field f
+				JOIN security.object o ON o.tenant_id = f.tenant_id AND o.id = f.object_id
+				WHERE %s
+				ORDER BY o.api_name, f.api_name, f.id
+				LIMIT $%d OFFSET $%d
+			)
+			SELECT
+				p.object_id,
+				p.object_name,
+				p.id,
+				p.api_name,
+				cache.get_field_permissions($1, $2, p.object_id, p.id),
+				cache.get_field_restriction($1, $2, p.object_id, p.id),
+				EXISTS (
+					SELECT 1 FROM cache.user_object_permissions c
+					WHERE c.tenant_id = $1 AND c.user_id = $2 AND c.object_id = p.object_id AND c.expires_at > now()
+				) AND EXISTS (
+					SELECT 1 FROM cache.user_field_restrictions c
+					WHERE c.tenant_id = $1 AND c.user_id = $2 AND c.object_id = p.object_id AND c.field_id = p.id AND c.expires_at > now()
+				),
+				p.total
+			FROM page p
+			ORDER BY p.object_name, p.api_name, p.id`,
+			strings.Join(where, " AND "), len(args)-1, len(args),
+		)
+
+		list = &services.List[*FieldGrant]{Page: page, Items: make([]*FieldGrant, 0)}
+		return that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+			var grant FieldGrant
+			err := rows.Scan(
+				&grant.ObjectId,
+				&grant.ObjectName,
+				&grant.FieldId,
+				&grant.FieldName,
+				&grant.Permissions,
+				&grant.Restriction,
+				&grant.Cached,
+				&list.Total,
+			)
+			if err != nil {
+				return err
+			}
+			list.Items = append(list.Items, &grant)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Rows - returns row permissions of the user known to the cache.
+// Rows are not enumerable, so only rows checked before are returned.
+func (that *Service) Rows(ctx context.Context, userId string, filter Filter, page services.Page) (*services.List[*RowGrant], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := that.resolveUser(ctx, actor, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"c.tenant_id = $1", "c.user_id = $2", "c.expires_at > now()"}
+	args := []any{actor.TenantId, id}
+
+	if filter.ObjectId != nil {
+		args = append(args, *filter.ObjectId)
+		where = append(where, fmt.Sprintf("c.object_id = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT c.object_id, o.api_name, c.row_id, c.permissions, c.cached_at, c.expires_at, count(*) OVER ()
+		FROM cache.user_row_permissions c
+		JOIN security.object o ON o.tenant_id = c.tenant_id AND o.id = c.object_id
+		WHERE %s
+		ORDER BY o.api_name, c.row_id
+		LIMIT $%d OFFSET $%d`,
+		strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	list := &services.List[*RowGrant]{Page: page, Items: make([]*RowGrant, 0)}
+	err = that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		var grant RowGrant
+		err := rows.Scan(
+			&grant.ObjectId,
+			&grant.ObjectName,
+			&grant.RowId,
+			&grant.Permissions,
+			&grant.CachedAt,
+			&grant.ExpiresAt,
+			&list.Total,
+		)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, &grant)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// resolveUser - returns internal id of the active user by its record id
+func (that *Service) resolveUser(ctx context.Context, actor services.Actor, recordId string) (int64, error) {
+	var id int64
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT id FROM iam."user" WHERE tenant_id = $1 AND record_id = $2 AND deleted_at IS NULL`,
+		actor.TenantId, recordId,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrUserNotFound
+		}
+		return 0, fmt.Errorf("resolve user: %w", err)
+	}
+	return id, nil
+}
+
+// checkTarget - ensures the checked object exists and the field belongs to it
+func (that *Service) checkTarget(ctx context.Context, actor services.Actor, check Check) error {
+	var objectExists, fieldExists bool
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT
+			EXISTS (SELECT 1 FROM security.object WHERE tenant_id = $1 AND id = $2),
+			$3::bigint IS NULL OR EXISTS (SELECT 1 FROM security.field WHERE tenant_id = $1 AND object_id = $2 AND id = $3)`,
+		actor.TenantId, check.ObjectId, check.FieldId,
+	).Scan(&objectExists, &fieldExists)
+	if err != nil {
+		return fmt.Errorf("check target: %w", err)
+	}
+	if !objectExists {
+		return ErrObjectNotFound
+	}
+	if !fieldExists {
+		return ErrFieldNotFound
+	}
+	return nil
+}
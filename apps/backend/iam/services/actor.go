@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/google/uuid"
+)
+
+// Actor - identity on whose behalf a request is executed
+type Actor struct {
+	TenantId    uuid.UUID
+	PrincipalId int64
+}
+
+type actorKeyType int
+
+var actorKey actorKeyType = 0
+
+// WithActor - append actor into context.
+// Actor is the identity of the database transactions started with the context.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	ctx = sql.WithIdentity(ctx, sql.Identity{TenantId: actor.TenantId, PrincipalId: actor.PrincipalId})
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext - extract actor from context
+func ActorFromContext(ctx context.Context) (Actor, error) {
+	actor, ok := ctx.Value(actorKey).(Actor)
+	if !ok || actor.TenantId == uuid.Nil {
+		return Actor{}, ErrTenantRequired
+	}
+	return actor, nil
+}
+
+var (
+	ErrTenantRequired = errors.New("tenant is required")
+)
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Events of the authentication flow declared by contracts/iam-events.yml.
+// iam.auth.logout is emitted by the database on revocation of the session.
+const (
+	EventLoginSuccess           = "iam.auth.login_success"
+	EventLoginFailed            = "iam.auth.login_failed"
+	EventTokenRefreshed         = "iam.auth.token_refreshed"
+	EventPasswordChanged        = "iam.auth.password_changed"
+	EventPasswordResetRequested = "iam.auth.password_reset_requested"
+	EventPasswordResetCompleted = "iam.auth.password_reset_completed"
+)
+
+// Reasons of the failed login
+const (
+	LoginFailedInvalidCredentials = "invalid_credentials"
+	LoginFailedUserNotFound       = "user_not_found"
+)
+
+// emit - writes the event of the aggregate into the outbox within the current transaction
+func (that *Service) emit(ctx context.Context, aggregateType, aggregateId, eventType string, payload map[string]any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+
+	_, err = that.db.Exec(
+		ctx,
+		`SELECT bootstrap.create_outbox_event($1, $2, $3, $4::jsonb)`,
+		aggregateType, aggregateId, eventType, string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("emit %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// emitUser - writes the event of the user into the outbox within the current transaction
+func (that *Service) emitUser(ctx context.Context, userId, eventType string, payload map[string]any) error {
+	return that.emit(ctx, "user", userId, eventType, payload)
+}
+
+// optional - empty value is reported as null
+func optional(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
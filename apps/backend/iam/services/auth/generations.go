@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/adverax/metacrm/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+// Generations - actual rights generations of the principals.
+// Verifier uses them to reject tokens issued before the rights were changed.
+type Generations struct {
+	db sql.DB
+}
+
+func NewGenerations(db sql.DB) *Generations {
+	return &Generations{db: db}
+}
+
+var _ jwt.Generations = (*Generations)(nil)
+
+// Generation - the token is verified before the request has an actor,
+// so the generation is read on behalf of the principal of the token
+func (that *Generations) Generation(ctx context.Context, claims *jwt.Claims) (int64, error) {
+	actor, err := ActorOf(claims)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = services.WithActor(ctx, actor)
+	return rightsGeneration(ctx, that.db, actor.TenantId, actor.PrincipalId)
+}
+
+// ActorOf - returns the principal the verified token is issued to
+func ActorOf(claims *jwt.Claims) (services.Actor, error) {
+	tenantId, err := uuid.Parse(claims.TenantId)
+	if err != nil {
+		return services.Actor{}, jwt.ErrInvalidClaims
+	}
+	principalId, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || principalId <= 0 {
+		return services.Actor{}, jwt.ErrInvalidClaims
+	}
+	return services.Actor{TenantId: tenantId, PrincipalId: principalId}, nil
+}
+
+// rightsGeneration - returns current rights generation of the principal
+func rightsGeneration(ctx context.Context, db sql.DB, tenantId uuid.UUID, principalId int64) (int64, error) {
+	var gen int64
+	err := db.QueryRow(ctx, `SELECT iam.get_rights_generation($1, $2)`, tenantId, principalId).Scan(&gen)
+	if err != nil {
+		return 0, fmt.Errorf("get rights generation: %w", err)
+	}
+	return gen, nil
+}
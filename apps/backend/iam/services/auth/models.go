@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/jwt"
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/adverax/metacrm/pkg/validation/is"
+	"github.com/google/uuid"
+)
+
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 1024
+)
+
+// Options - lifetime of the issued opaque tokens, lifetime of the access token is defined by the issuer
+type Options struct {
+	RefreshTokenTTL time.Duration
+	ResetTokenTTL   time.Duration
+}
+
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (that *Credentials) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Email, validation.Required, validation.Length(1, 255)),
+		validation.Field(&that.Password, validation.Required, validation.Length(1, MaxPasswordLength)),
+	)
+}
+
+type Registration struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (that *Registration) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Name, validation.Required, validation.RuneLength(1, 255)),
+		validation.Field(&that.Email, validation.Required, validation.Length(1, 255), is.EmailFormat),
+		validation.Field(&that.Password, validation.Required, validation.RuneLength(MinPasswordLength, MaxPasswordLength)),
+	)
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+func (that *PasswordResetRequest) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Email, validation.Required, validation.Length(1, 255)),
+	)
+}
+
+type PasswordReset struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (that *PasswordReset) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Token, validation.Required),
+		validation.Field(&that.Password, validation.Required, validation.RuneLength(MinPasswordLength, MaxPasswordLength)),
+	)
+}
+
+// Tokens - credentials issued to the authenticated principal.
+// Access token is a signed JWT, refresh token is opaque, only their digests are stored.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	SessionId    int64
+	PrincipalId  int64
+	UserId       string // record_id of the authenticated user
+}
+
+// Authentication - principal authenticated by the access token
+type Authentication struct {
+	Claims      *jwt.Claims
+	TenantId    uuid.UUID
+	SessionId   int64
+	PrincipalId int64
+	UserId      string // record_id of the authenticated user
+}
+
+var (
+	ErrEmailExists        = errors.New("user with this email already exists")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("token is invalid or expired")
+	ErrTokenReused        = errors.New("refresh token was already used, session is revoked")
+	ErrInvalidResetToken  = errors.New("reset token is invalid or expired")
+)
@@ -0,0 +1,674 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/adverax/metacrm/pkg/jwt"
+)
+
+// localIdp - identity provider of the identities authenticated by IAM itself
+const localIdp = "local"
+
+// Service - authenticates users by password and manages their sessions
+type Service struct {
+	db       sql.DB
+	options  Options
+	issuer   *jwt.Issuer
+	verifier *jwt.Verifier
+}
+
+func NewService(db sql.DB, options Options, issuer *jwt.Issuer, verifier *jwt.Verifier) *Service {
+	return &Service{db: db, options: options, issuer: issuer, verifier: verifier}
+}
+
+// identity - password identity of the active user principal
+type identity struct {
+	Id           int64
+	PrincipalId  int64
+	PasswordHash *string
+	UserId       string
+	Email        string
+}
+
+// Register - creates user together with its principal and password identity.
+// Returns record_id of the created user.
+func (that *Service) Register(ctx context.Context, request Registration) (userId string, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return "", err
+	}
+
+	hash, err := hashPassword(request.Password)
+	if err != nil {
+		return "", err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var exists bool
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT
+				EXISTS (SELECT 1 FROM iam."user" WHERE tenant_id = $1 AND lower(email) = lower($2) AND deleted_at IS NULL)
+				OR EXISTS (SELECT 1 FROM iam.principal WHERE tenant_id = $1 AND lower(login) = lower($2))
+				OR EXISTS (
+					SELECT 1 FROM iam.identity
+					WHERE tenant_id = $1 AND idp = $3 AND lower(subject) = lower($2) AND deleted_at IS NULL
+				)`,
+			actor.TenantId, request.Email, localIdp,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("check email: %w", err)
+		}
+		if exists {
+			return ErrEmailExists
+		}
+
+		var id int64
+		err = that.db.QueryRow(
+			ctx,
+			`INSERT INTO iam."user" (tenant_id, name, email) VALUES ($1, $2, $3) RETURNING id, record_id`,
+			actor.TenantId, request.Name, request.Email,
+		).Scan(&id, &userId)
+		if err != nil {
+			return translateError(err)
+		}
+
+		var principalId int64
+		err = that.db.QueryRow(
+			ctx,
+			`INSERT INTO iam.principal (tenant_id, kind, subject_id, login) VALUES ($1, 'user', $2, $3) RETURNING id`,
+			actor.TenantId, id, request.Email,
+		).Scan(&principalId)
+		if err != nil {
+			return translateError(err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`INSERT INTO iam.identity (tenant_id, principal_id, kind, idp, subject, password_hash, password_changed_at)
+			VALUES ($1, $2, 'password', $3, $4, $5, now())`,
+			actor.TenantId, principalId, localIdp, request.Email, hash,
+		)
+		if err != nil {
+			return translateError(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return userId, nil
+}
+
+// Login - verifies credentials and opens a new session
+func (that *Service) Login(ctx context.Context, request Credentials) (*Tokens, error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := that.findIdentity(ctx, actor, request.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity == nil {
+		_, _ = verifyPassword(dummyHash(), request.Password)
+		return nil, that.loginFailed(ctx, request.Email, nil, LoginFailedUserNotFound)
+	}
+	if identity.PasswordHash == nil {
+		_, _ = verifyPassword(dummyHash(), request.Password)
+		return nil, that.loginFailed(ctx, request.Email, identity, LoginFailedInvalidCredentials)
+	}
+
+	ok, err := verifyPassword(*identity.PasswordHash, request.Password)
+	if err != nil {
+		return nil, fmt.Errorf("verify password: %w", err)
+	}
+	if !ok {
+		return nil, that.loginFailed(ctx, request.Email, identity, LoginFailedInvalidCredentials)
+	}
+
+	var tokens *Tokens
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		var err error
+		tokens, err = that.openSession(ctx, actor, identity)
+		if err != nil {
+			return err
+		}
+
+		client := services.ClientFromContext(ctx)
+		return that.emitUser(ctx, identity.UserId, EventLoginSuccess, map[string]any{
+			"tenant_id":             actor.TenantId.String(),
+			"user_id":               identity.UserId,
+			"principal_id":          identity.PrincipalId,
+			"login":                 request.Email,
+			"ip_address":            optional(client.IpAddress),
+			"user_agent":            optional(client.UserAgent),
+			"session_id":            strconv.FormatInt(tokens.SessionId, 10),
+			"authentication_method": "password",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Refresh - consumes the refresh token and issues new access and refresh tokens.
+// Presenting an already consumed token revokes the whole session,
+// since either the client or an attacker holds a stolen copy.
+func (that *Service) Refresh(ctx context.Context, refreshToken string) (tokens *Tokens, err error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var reused bool
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var tokenId, sessionId int64
+		var used, expired, revoked, accessExpired bool
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT t.id, t.session_id, t.used_at IS NOT NULL, t.expires_at <= now(), s.revoked_at IS NOT NULL,
+				s.access_expires_at <= now()
+			FROM iam.refresh_token t
+			JOIN iam.session s ON s.tenant_id = t.tenant_id AND s.id = t.session_id
+			WHERE t.tenant_id = $1 AND t.token_hash = $2
+			FOR UPDATE OF t, s`,
+			actor.TenantId, digest(refreshToken),
+		).Scan(&tokenId, &sessionId, &used, &expired, &revoked, &accessExpired)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("find refresh token: %w", err)
+		}
+
+		if revoked || expired {
+			return ErrInvalidToken
+		}
+
+		if used {
+			reused = true
+			return that.revokeSession(ctx, actor, sessionId, "refresh_token_reuse")
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.refresh_token SET used_at = now() WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, tokenId,
+		)
+		if err != nil {
+			return fmt.Errorf("consume refresh token: %w", err)
+		}
+
+		var newTokenId int64
+		tokens, newTokenId, err = that.rotateSession(ctx, actor, sessionId, tokenId)
+		if err != nil {
+			return err
+		}
+
+		reason := "manual_refresh"
+		if accessExpired {
+			reason = "expired"
+		}
+		return that.emitUser(ctx, tokens.UserId, EventTokenRefreshed, map[string]any{
+			"tenant_id":      actor.TenantId.String(),
+			"user_id":        tokens.UserId,
+			"principal_id":   tokens.PrincipalId,
+			"old_token_id":   strconv.FormatInt(tokenId, 10),
+			"new_token_id":   strconv.FormatInt(newTokenId, 10),
+			"refresh_reason": reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		// Revocation is committed, the caller still gets an error
+		return nil, ErrTokenReused
+	}
+	return tokens, nil
+}
+
+// Logout - revokes the session of the access token
+func (that *Service) Logout(ctx context.Context, accessToken string) error {
+	if accessToken == "" {
+		return ErrInvalidToken
+	}
+
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var sessionId int64
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT id FROM iam.session
+			WHERE tenant_id = $1 AND access_token_hash = $2 AND revoked_at IS NULL AND access_expires_at > now()
+			FOR UPDATE`,
+			actor.TenantId, digest(accessToken),
+		).Scan(&sessionId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("find session: %w", err)
+		}
+
+		return that.revokeSession(ctx, actor, sessionId, "logout")
+	})
+}
+
+// Authenticate - verifies the access token and returns the authenticated principal.
+// Unlike offline verification, the session of the token is checked, so revoked tokens are rejected.
+// Tenant and principal are taken from the token, the context may have no actor yet.
+func (that *Service) Authenticate(ctx context.Context, accessToken string) (*Authentication, error) {
+	if accessToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := that.verifier.Verify(ctx, accessToken)
+	if err != nil {
+		if errors.Is(err, jwt.ErrGenerationUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	actor, err := ActorOf(claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	result := Authentication{Claims: claims, TenantId: actor.TenantId}
+	err = that.db.QueryRow(
+		services.WithActor(ctx, actor),
+		`SELECT s.id, s.principal_id, u.record_id
+		FROM iam.session s
+		JOIN iam.principal p ON p.tenant_id = s.tenant_id AND p.id = s.principal_id
+		JOIN iam."user" u ON u.tenant_id = p.tenant_id AND u.id = p.subject_id
+		WHERE s.tenant_id = $1 AND s.principal_id = $2 AND s.access_token_hash = $3
+		  AND s.revoked_at IS NULL AND s.access_expires_at > now()`,
+		actor.TenantId, actor.PrincipalId, digest(accessToken),
+	).Scan(&result.SessionId, &result.PrincipalId, &result.UserId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("find session: %w", err)
+	}
+	return &result, nil
+}
+
+// ForgotPassword - issues one-time password reset token.
+// The token is handed over to the mailer by iam.password_reset_mail, it is never published:
+// the iam.auth.password_reset_requested event carries id of the token only.
+// Unknown emails are silently ignored, so the response does not reveal registered emails.
+func (that *Service) ForgotPassword(ctx context.Context, request PasswordResetRequest) error {
+	if err := request.Validate(ctx); err != nil {
+		return err
+	}
+
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		identity, err := that.findIdentity(ctx, actor, request.Email)
+		if err != nil || identity == nil {
+			return err
+		}
+
+		// Only the latest token is valid, mails of the previous tokens are not sent
+		_, err = that.db.Exec(
+			ctx,
+			`WITH consumed AS (
+				UPDATE iam.password_reset_token SET used_at = now()
+				WHERE tenant_id = $1 AND identity_id = $2 AND used_at IS NULL
+				RETURNING tenant_id, id
+			)
+			DELETE FROM iam.password_reset_mail m
+			USING consumed c
+			WHERE m.tenant_id = c.tenant_id AND m.reset_id = c.id`,
+			actor.TenantId, identity.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("consume reset tokens: %w", err)
+		}
+
+		token, hash, err := newToken()
+		if err != nil {
+			return err
+		}
+
+		var resetId int64
+		err = that.db.QueryRow(
+			ctx,
+			`WITH token AS (
+				INSERT INTO iam.password_reset_token (tenant_id, identity_id, token_hash, expires_at)
+				VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+				RETURNING tenant_id, id, expires_at
+			)
+			INSERT INTO iam.password_reset_mail (tenant_id, reset_id, email, reset_token, expires_at)
+			SELECT tenant_id, id, $5, $6, expires_at FROM token
+			RETURNING reset_id`,
+			actor.TenantId, identity.Id, hash, that.options.ResetTokenTTL.Seconds(), identity.Email, token,
+		).Scan(&resetId)
+		if err != nil {
+			return fmt.Errorf("create reset token: %w", err)
+		}
+
+		return that.emitUser(ctx, identity.UserId, EventPasswordResetRequested, map[string]any{
+			"tenant_id":    actor.TenantId.String(),
+			"user_id":      identity.UserId,
+			"email":        identity.Email,
+			"reset_id":     strconv.FormatInt(resetId, 10),
+			"requested_by": "user",
+			"ip_address":   optional(services.ClientFromContext(ctx).IpAddress),
+		})
+	})
+}
+
+// ResetPassword - consumes reset token and sets new password.
+// Every session of the principal is revoked.
+func (that *Service) ResetPassword(ctx context.Context, request PasswordReset) error {
+	if err := request.Validate(ctx); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(request.Password)
+	if err != nil {
+		return err
+	}
+
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var tokenId, identityId, principalId int64
+		var userId string
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT t.id, i.id, i.principal_id, u.record_id
+			FROM iam.password_reset_token t
+			JOIN iam.identity i ON i.tenant_id = t.tenant_id AND i.id = t.identity_id
+			JOIN iam.principal p ON p.tenant_id = i.tenant_id AND p.id = i.principal_id
+			JOIN iam."user" u ON u.tenant_id = p.tenant_id AND u.id = p.subject_id
+			WHERE t.tenant_id = $1 AND t.token_hash = $2
+			  AND t.used_at IS NULL AND t.expires_at > now()
+			  AND i.deleted_at IS NULL
+			FOR UPDATE OF t, i`,
+			actor.TenantId, digest(request.Token),
+		).Scan(&tokenId, &identityId, &principalId, &userId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidResetToken
+			}
+			return fmt.Errorf("find reset token: %w", err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`WITH consumed AS (
+				UPDATE iam.password_reset_token SET used_at = now()
+				WHERE tenant_id = $1 AND id = $2
+				RETURNING tenant_id, id
+			)
+			DELETE FROM iam.password_reset_mail m
+			USING consumed c
+			WHERE m.tenant_id = c.tenant_id AND m.reset_id = c.id`,
+			actor.TenantId, tokenId,
+		)
+		if err != nil {
+			return fmt.Errorf("consume reset token: %w", err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.identity SET password_hash = $3, password_changed_at = now() WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, identityId, hash,
+		)
+		if err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.session SET revoked_at = now(), revoke_reason = 'password_reset'
+			WHERE tenant_id = $1 AND principal_id = $2 AND revoked_at IS NULL`,
+			actor.TenantId, principalId,
+		)
+		if err != nil {
+			return fmt.Errorf("revoke sessions: %w", err)
+		}
+
+		ipAddress := optional(services.ClientFromContext(ctx).IpAddress)
+		err = that.emitUser(ctx, userId, EventPasswordChanged, map[string]any{
+			"tenant_id":    actor.TenantId.String(),
+			"user_id":      userId,
+			"principal_id": principalId,
+			"changed_by":   userId,
+			"change_type":  "reset_completed",
+			"ip_address":   ipAddress,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Id of the token correlates the event with the request
+		return that.emitUser(ctx, userId, EventPasswordResetCompleted, map[string]any{
+			"tenant_id":    actor.TenantId.String(),
+			"user_id":      userId,
+			"principal_id": principalId,
+			"reset_id":     strconv.FormatInt(tokenId, 10),
+			"completed_by": userId,
+			"ip_address":   ipAddress,
+		})
+	})
+}
+
+// loginFailed - reports the failed login and returns ErrInvalidCredentials.
+// The reason is reported by the event only, the caller can not tell unknown users from wrong passwords.
+func (that *Service) loginFailed(ctx context.Context, login string, identity *identity, reason string) error {
+	err := services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+		client := services.ClientFromContext(ctx)
+
+		payload := map[string]any{
+			"tenant_id":  actor.TenantId.String(),
+			"login":      login,
+			"reason":     reason,
+			"ip_address": optional(client.IpAddress),
+			"user_agent": optional(client.UserAgent),
+			"user_id":    nil,
+		}
+		if identity == nil {
+			// Events of the unknown login are ordered by the login itself
+			return that.emit(ctx, "login", strings.ToLower(login), EventLoginFailed, payload)
+		}
+
+		payload["user_id"] = identity.UserId
+		return that.emitUser(ctx, identity.UserId, EventLoginFailed, payload)
+	})
+	if err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// findIdentity - returns local password identity of the active user principal by email
+func (that *Service) findIdentity(ctx context.Context, actor services.Actor, email string) (*identity, error) {
+	var result identity
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT i.id, i.principal_id, i.password_hash, u.record_id, u.email
+		FROM iam.identity i
+		JOIN iam.principal p ON p.tenant_id = i.tenant_id AND p.id = i.principal_id
+		JOIN iam."user" u ON u.tenant_id = p.tenant_id AND u.id = p.subject_id
+		WHERE i.tenant_id = $1 AND i.kind = 'password' AND i.idp = $2 AND lower(i.subject) = lower($3)
+		  AND i.deleted_at IS NULL
+		  AND p.kind = 'user' AND p.is_active
+		  AND u.deleted_at IS NULL`,
+		actor.TenantId, localIdp, email,
+	).Scan(&result.Id, &result.PrincipalId, &result.PasswordHash, &result.UserId, &result.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find identity: %w", err)
+	}
+	return &result, nil
+}
+
+// openSession - creates session with fresh access and refresh tokens
+func (that *Service) openSession(ctx context.Context, actor services.Actor, identity *identity) (*Tokens, error) {
+	accessToken, expiresAt, err := that.issueAccessToken(ctx, actor, identity.PrincipalId)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &Tokens{
+		AccessToken: accessToken,
+		ExpiresIn:   that.issuer.TTL(),
+		PrincipalId: identity.PrincipalId,
+		UserId:      identity.UserId,
+	}
+
+	err = that.db.QueryRow(
+		ctx,
+		`INSERT INTO iam.session (tenant_id, principal_id, identity_id, access_token_hash, access_expires_at)
+		VALUES ($1, $2, $3, $4, to_timestamp($5))
+		RETURNING id`,
+		actor.TenantId, identity.PrincipalId, identity.Id, digest(accessToken), expiresAt,
+	).Scan(&tokens.SessionId)
+	if err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+
+	tokens.RefreshToken, _, err = that.issueRefreshToken(ctx, actor, tokens.SessionId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// rotateSession - replaces access token of the session and issues successor of the refresh token.
+// Returns the tokens together with id of the issued refresh token.
+func (that *Service) rotateSession(ctx context.Context, actor services.Actor, sessionId, parentId int64) (*Tokens, int64, error) {
+	tokens := &Tokens{
+		ExpiresIn: that.issuer.TTL(),
+		SessionId: sessionId,
+	}
+
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT p.id, u.record_id
+		FROM iam.session s
+		JOIN iam.principal p ON p.tenant_id = s.tenant_id AND p.id = s.principal_id
+		JOIN iam."user" u ON u.tenant_id = p.tenant_id AND u.id = p.subject_id
+		WHERE s.tenant_id = $1 AND s.id = $2`,
+		actor.TenantId, sessionId,
+	).Scan(&tokens.PrincipalId, &tokens.UserId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("find session principal: %w", err)
+	}
+
+	var expiresAt int64
+	tokens.AccessToken, expiresAt, err = that.issueAccessToken(ctx, actor, tokens.PrincipalId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, err = that.db.Exec(
+		ctx,
+		`UPDATE iam.session
+		SET access_token_hash = $3, access_expires_at = to_timestamp($4), updated_at = now()
+		WHERE tenant_id = $1 AND id = $2`,
+		actor.TenantId, sessionId, digest(tokens.AccessToken), expiresAt,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("rotate session: %w", err)
+	}
+
+	var tokenId int64
+	tokens.RefreshToken, tokenId, err = that.issueRefreshToken(ctx, actor, sessionId, &parentId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tokens, tokenId, nil
+}
+
+// issueAccessToken - signs access token bound to the current rights generation of the principal.
+// Returns the token together with its expiration in epoch seconds.
+func (that *Service) issueAccessToken(ctx context.Context, actor services.Actor, principalId int64) (string, int64, error) {
+	gen, err := rightsGeneration(ctx, that.db, actor.TenantId, principalId)
+	if err != nil {
+		return "", 0, err
+	}
+
+	claims := &jwt.Claims{
+		Subject:    strconv.FormatInt(principalId, 10),
+		Type:       jwt.PrincipalTypeUser,
+		TenantId:   actor.TenantId.String(),
+		Generation: gen,
+	}
+	token, err := that.issuer.Issue(claims)
+	if err != nil {
+		return "", 0, fmt.Errorf("issue access token: %w", err)
+	}
+	return token, claims.ExpiresAt, nil
+}
+
+// issueRefreshToken - creates refresh token of the session, returns the token together with its id
+func (that *Service) issueRefreshToken(ctx context.Context, actor services.Actor, sessionId int64, parentId *int64) (string, int64, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", 0, err
+	}
+
+	var id int64
+	err = that.db.QueryRow(
+		ctx,
+		`INSERT INTO iam.refresh_token (tenant_id, session_id, parent_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))
+		RETURNING id`,
+		actor.TenantId, sessionId, parentId, hash, that.options.RefreshTokenTTL.Seconds(),
+	).Scan(&id)
+	if err != nil {
+		return "", 0, fmt.Errorf("create refresh token: %w", err)
+	}
+
+	return token, id, nil
+}
+
+func (that *Service) revokeSession(ctx context.Context, actor services.Actor, sessionId int64, reason string) error {
+	_, err := that.db.Exec(
+		ctx,
+		`UPDATE iam.session SET revoked_at = now(), revoke_reason = $3
+		WHERE tenant_id = $1 AND id = $2 AND revoked_at IS NULL`,
+		actor.TenantId, sessionId, reason,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	return nil
+}
+
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrEmailExists
+	}
+	return err
+}
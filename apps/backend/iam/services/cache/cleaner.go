@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/log"
+)
+
+// Cleaner - removes expired cache entries periodically.
+// Expired entries are ignored by cache functions, but never deleted by them.
+type Cleaner struct {
+	service  *Service
+	interval time.Duration
+	logger   log.Logger
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewCleaner - makes cleaner, non-positive interval disables it
+func NewCleaner(service *Service, interval time.Duration, logger log.Logger) *Cleaner {
+	return &Cleaner{
+		service:  service,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start - starts cleanup loop in background
+func (that *Cleaner) Start(ctx context.Context) error {
+	if that.interval <= 0 {
+		return nil
+	}
+
+	ctx, that.cancel = context.WithCancel(ctx)
+	that.wg.Add(1)
+	go that.serve(ctx)
+	return nil
+}
+
+// Stop - stops cleanup loop and waits until the running cleanup completes
+func (that *Cleaner) Stop() {
+	if that.cancel == nil {
+		return
+	}
+
+	that.cancel()
+	that.wg.Wait()
+}
+
+func (that *Cleaner) serve(ctx context.Context) {
+	defer that.wg.Done()
+
+	ticker := time.NewTicker(that.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			that.cleanup(ctx)
+		}
+	}
+}
+
+func (that *Cleaner) cleanup(ctx context.Context) {
+	cleanup, err := that.service.Cleanup(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			that.logger.WithError(err).Error(ctx, "permission cache cleanup failed")
+		}
+		return
+	}
+
+	that.logger.
+		WithFields(log.Fields{
+			"entries_removed": cleanup.EntriesRemoved,
+			"duration_ms":     cleanup.Duration.Milliseconds(),
+		}).
+		Debug(ctx, "permission cache cleaned up")
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// Invalidation - result of the cache invalidation
+type Invalidation struct {
+	// MembersAffected - number of users whose cache was invalidated, known for groups only
+	MembersAffected int
+	InvalidatedAt   time.Time
+}
+
+// Cleanup - result of removing expired cache entries
+type Cleanup struct {
+	EntriesRemoved int64
+	Duration       time.Duration
+	CleanedAt      time.Time
+}
+
+// TableStats - usage of the single cache table
+type TableStats struct {
+	TableName      string
+	TotalRecords   int64
+	ExpiredRecords int64
+	ActiveRecords  int64
+	// HitRatio - percentage of active records
+	HitRatio float64
+}
+
+// Stats - usage of the permission cache
+type Stats struct {
+	Tables       []*TableStats
+	TotalRecords int64
+	TotalExpired int64
+	TotalActive  int64
+	// HitRatio - percentage of active records over all tables
+	HitRatio    float64
+	GeneratedAt time.Time
+}
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrGroupNotFound  = errors.New("group not found")
+	ErrObjectNotFound = errors.New("security object not found")
+	ErrForeignTenant  = errors.New("statistics of another tenant are not available")
+)
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/google/uuid"
+)
+
+// Service - maintains the permission cache
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// InvalidateUser - removes cached permissions of the user
+func (that *Service) InvalidateUser(ctx context.Context, userId string) (invalidation *Invalidation, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT id FROM iam."user" WHERE tenant_id = $1 AND record_id = $2 AND deleted_at IS NULL`,
+			actor.TenantId, userId,
+		).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("resolve user: %w", err)
+		}
+
+		_, err = that.db.Exec(ctx, `SELECT cache.invalidate_user_permissions_cache($1, $2)`, actor.TenantId, id)
+		if err != nil {
+			return fmt.Errorf("invalidate user cache: %w", err)
+		}
+
+		invalidation = &Invalidation{InvalidatedAt: time.Now().UTC()}
+		return nil
+	})
+	return invalidation, err
+}
+
+// InvalidateGroup - removes cached permissions of the group and of every user
+// included into the group directly or transitively
+func (that *Service) InvalidateGroup(ctx context.Context, groupId int64) (invalidation *Invalidation, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var exists bool
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT EXISTS (SELECT 1 FROM cluster."group" WHERE tenant_id = $1 AND id = $2 AND deleted_at IS NULL)`,
+			actor.TenantId, groupId,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("check group: %w", err)
+		}
+		if !exists {
+			return ErrGroupNotFound
+		}
+
+		invalidation = &Invalidation{}
+		err = that.db.QueryRow(
+			ctx,
+			`SELECT count(*) FROM cluster.get_group_users($1, $2)`,
+			actor.TenantId, groupId,
+		).Scan(&invalidation.MembersAffected)
+		if err != nil {
+			return fmt.Errorf("count group users: %w", err)
+		}
+
+		_, err = that.db.Exec(ctx, `SELECT cache.invalidate_group_permissions_cache($1, $2)`, actor.TenantId, groupId)
+		if err != nil {
+			return fmt.Errorf("invalidate group cache: %w", err)
+		}
+
+		invalidation.InvalidatedAt = time.Now().UTC()
+		return nil
+	})
+	return invalidation, err
+}
+
+// InvalidateObject - removes cached permissions on the object of every user
+func (that *Service) InvalidateObject(ctx context.Context, objectId int64) (invalidation *Invalidation, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var exists bool
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT EXISTS (SELECT 1 FROM security.object WHERE tenant_id = $1 AND id = $2)`,
+			actor.TenantId, objectId,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("check object: %w", err)
+		}
+		if !exists {
+			return ErrObjectNotFound
+		}
+
+		_, err = that.db.Exec(ctx, `SELECT cache.invalidate_object_permissions_cache($1, $2)`, actor.TenantId, objectId)
+		if err != nil {
+			return fmt.Errorf("invalidate object cache: %w", err)
+		}
+
+		invalidation = &Invalidation{InvalidatedAt: time.Now().UTC()}
+		return nil
+	})
+	return invalidation, err
+}
+
+// Cleanup - removes expired entries of every tenant.
+// Runs without tenant context, so it can be called by the scheduler.
+func (that *Service) Cleanup(ctx context.Context) (*Cleanup, error) {
+	started := time.Now()
+
+	var removed int64
+	err := that.db.QueryRow(ctx, `SELECT cache.cleanup_expired_permissions_cache()`).Scan(&removed)
+	if err != nil {
+		return nil, fmt.Errorf("cleanup cache: %w", err)
+	}
+
+	return &Cleanup{
+		EntriesRemoved: removed,
+		Duration:       time.Since(started),
+		CleanedAt:      time.Now().UTC(),
+	}, nil
+}
+
+// Stats - returns cache usage of the actor tenant.
+// The tenant may be passed explicitly, but it must match the actor tenant.
+func (that *Service) Stats(ctx context.Context, tenantId *uuid.UUID) (*Stats, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenantId != nil && *tenantId != actor.TenantId {
+		return nil, ErrForeignTenant
+	}
+
+	stats := &Stats{Tables: make([]*TableStats, 0)}
+	err = that.db.Fetch(
+		ctx,
+		`SELECT cache_table, total_records, expired_records, active_records, cache_hit_ratio::float8
+		FROM cache.get_cache_stats($1)`,
+		actor.TenantId,
+	)(func(rows sql.Rows) error {
+		var table TableStats
+		err := rows.Scan(
+			&table.TableName,
+			&table.TotalRecords,
+			&table.ExpiredRecords,
+			&table.ActiveRecords,
+			&table.HitRatio,
+		)
+		if err != nil {
+			return err
+		}
+		stats.Tables = append(stats.Tables, &table)
+		stats.TotalRecords += table.TotalRecords
+		stats.TotalExpired += table.ExpiredRecords
+		stats.TotalActive += table.ActiveRecords
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch cache stats: %w", err)
+	}
+
+	if stats.TotalRecords > 0 {
+		stats.HitRatio = math.Round(float64(stats.TotalActive)/float64(stats.TotalRecords)*10000) / 100
+	}
+	stats.GeneratedAt = time.Now().UTC()
+
+	return stats, nil
+}
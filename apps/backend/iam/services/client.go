@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+)
+
+// Client - remote party of the request, reported by the security events
+type Client struct {
+	IpAddress string
+	UserAgent string
+}
+
+type clientKeyType int
+
+var clientKey clientKeyType = 0
+
+// WithClient - append client into context
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey, client)
+}
+
+// ClientFromContext - extract client from context, unknown client is empty
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey).(Client)
+	return client
+}
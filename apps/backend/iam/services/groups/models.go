@@ -0,0 +1,189 @@
+package groups
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/adverax/metacrm/pkg/validation/is"
+	"github.com/google/uuid"
+)
+
+var apiNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{1,63}$`)
+
+type GroupType string
+
+const (
+	GroupTypeRegular                  GroupType = "regular"
+	GroupTypeQueue                    GroupType = "queue"
+	GroupTypeRole                     GroupType = "role"
+	GroupTypeRoleAndSubordinates      GroupType = "role_and_subordinates"
+	GroupTypeTerritory                GroupType = "territory"
+	GroupTypeTerritoryAndSubordinates GroupType = "territory_and_subordinates"
+)
+
+func (that GroupType) IsRoleBased() bool {
+	return that == GroupTypeRole || that == GroupTypeRoleAndSubordinates
+}
+
+func (that GroupType) IsTerritoryBased() bool {
+	return that == GroupTypeTerritory || that == GroupTypeTerritoryAndSubordinates
+}
+
+type Group struct {
+	TenantId           uuid.UUID
+	Id                 int64
+	Label              string
+	ApiName            string
+	Type               GroupType
+	Email              *string
+	RelatedRoleId      *int64
+	RelatedTerritoryId *int64
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	DeletedAt          *time.Time
+}
+
+type CreateGroup struct {
+	Label              string    `json:"label"`
+	ApiName            string    `json:"api_name"`
+	Type               GroupType `json:"type"`
+	Email              *string   `json:"email"`
+	RelatedRoleId      *int64    `json:"related_role_id"`
+	RelatedTerritoryId *int64    `json:"related_territory_id"`
+}
+
+func (that *CreateGroup) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Label, validation.Required, validation.RuneLength(1, 255)),
+		validation.Field(&that.ApiName, validation.Required, validation.Match(apiNamePattern)),
+		validation.Field(&that.Type, validation.Required, validation.In(
+			GroupTypeRegular,
+			GroupTypeQueue,
+			GroupTypeRole,
+			GroupTypeRoleAndSubordinates,
+			GroupTypeTerritory,
+			GroupTypeTerritoryAndSubordinates,
+		)),
+		validation.Field(&that.Email, validation.NilOrNotEmpty, is.EmailFormat),
+		validation.Field(
+			&that.RelatedRoleId,
+			validation.When(that.Type.IsRoleBased(), validation.Required).Else(validation.Nil),
+		),
+		validation.Field(
+			&that.RelatedTerritoryId,
+			validation.When(that.Type.IsTerritoryBased(), validation.Required).Else(validation.Nil),
+		),
+	)
+}
+
+// UpdateGroup - partial update, nil fields are left unchanged.
+// Email and related ids are tri-state: nil means "keep", pointer to nil means "clear".
+type UpdateGroup struct {
+	Label              *string
+	ApiName            *string
+	Type               *GroupType
+	Email              **string
+	RelatedRoleId      **int64
+	RelatedTerritoryId **int64
+}
+
+// apply - merges update into the current state of the group
+func (that *UpdateGroup) apply(group *Group) CreateGroup {
+	result := CreateGroup{
+		Label:              group.Label,
+		ApiName:            group.ApiName,
+		Type:               group.Type,
+		Email:              group.Email,
+		RelatedRoleId:      group.RelatedRoleId,
+		RelatedTerritoryId: group.RelatedTerritoryId,
+	}
+	if that.Label != nil {
+		result.Label = *that.Label
+	}
+	if that.ApiName != nil {
+		result.ApiName = *that.ApiName
+	}
+	if that.Type != nil {
+		result.Type = *that.Type
+	}
+	if that.Email != nil {
+		result.Email = *that.Email
+	}
+	if that.RelatedRoleId != nil {
+		result.RelatedRoleId = *that.RelatedRoleId
+	}
+	if that.RelatedTerritoryId != nil {
+		result.RelatedTerritoryId = *that.RelatedTerritoryId
+	}
+	return result
+}
+
+type Filter struct {
+	Search string
+	Type   GroupType
+}
+
+// Member - membership of a user or a nested group in the group
+type Member struct {
+	TenantId      uuid.UUID
+	Id            int64
+	RecordId      string
+	GroupId       int64
+	MemberUserId  *string // record_id of the user
+	MemberGroupId *int64
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     *time.Time
+}
+
+type AddMember struct {
+	GroupId       int64   `json:"group_id"`
+	MemberUserId  *string `json:"member_user_id"`
+	MemberGroupId *int64  `json:"member_group_id"`
+}
+
+func (that *AddMember) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.GroupId, validation.Required),
+		validation.Field(&that.MemberUserId, validation.When(that.MemberGroupId == nil, validation.Required).Else(validation.Nil)),
+		validation.Field(&that.MemberGroupId, validation.When(that.MemberUserId == nil, validation.Required).Else(validation.Nil)),
+	)
+}
+
+// UpdateMember - moves membership to another group or replaces the member.
+// Setting one kind of member clears another one.
+type UpdateMember struct {
+	GroupId       *int64  `json:"group_id"`
+	MemberUserId  *string `json:"member_user_id"`
+	MemberGroupId *int64  `json:"member_group_id"`
+}
+
+func (that *UpdateMember) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.MemberUserId, validation.When(that.MemberGroupId != nil, validation.Nil)),
+	)
+}
+
+type MemberFilter struct {
+	GroupId       *int64
+	MemberUserId  string
+	MemberGroupId *int64
+}
+
+var (
+	ErrNotFound            = errors.New("group not found")
+	ErrApiNameExists       = errors.New("group with this api_name and type already exists")
+	ErrRoleNotFound        = errors.New("related role not found")
+	ErrTerritoryNotFound   = errors.New("related territory not found")
+	ErrMemberNotFound      = errors.New("group member not found")
+	ErrMemberUserNotFound  = errors.New("member user not found")
+	ErrMemberGroupNotFound = errors.New("member group not found")
+	ErrMemberExists        = errors.New("member already belongs to the group")
+	ErrMembershipCycle     = errors.New("group membership would create a cycle")
+)
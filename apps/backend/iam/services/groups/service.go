@@ -0,0 +1,595 @@
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+const groupColumns = `
+	g.tenant_id, g.id, g.label, g.api_name, g.type, g.email, g.related_role_id, g.related_territory_id,
+	g.created_at, g.updated_at, g.deleted_at`
+
+const memberColumns = `
+	m.tenant_id, m.id, m.record_id, m.group_id, u.record_id, m.member_group_id, m.created_at, m.updated_at, m.deleted_at`
+
+const memberSource = `
+	cluster.group_member m
+	LEFT JOIN iam."user" u ON u.tenant_id = m.tenant_id AND u.id = m.member_user_id`
+
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func (that *Service) List(ctx context.Context, filter Filter, page services.Page) (*services.List[*Group], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"g.tenant_id = $1", "g.deleted_at IS NULL"}
+	args := []any{actor.TenantId}
+
+	if filter.Search != "" {
+		args = append(args, "%"+services.EscapeLike(filter.Search)+"%")
+		where = append(where, fmt.Sprintf("(g.label ILIKE $%d OR g.api_name ILIKE $%d)", len(args), len(args)))
+	}
+
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		where = append(where, fmt.Sprintf("g.type = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM cluster."group" g WHERE %s ORDER BY g.label, g.id LIMIT $%d OFFSET $%d`,
+		groupColumns, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	list := &services.List[*Group]{Page: page, Items: make([]*Group, 0)}
+	err = that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		group, err := scanGroup(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, group)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (that *Service) Get(ctx context.Context, id int64) (*Group, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.get(ctx, actor, id, false)
+}
+
+func (that *Service) Create(ctx context.Context, request CreateGroup) (group *Group, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := that.checkRelations(ctx, actor, &request); err != nil {
+			return err
+		}
+
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`INSERT INTO cluster."group" (tenant_id, label, api_name, type, email, related_role_id, related_territory_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`,
+			actor.TenantId, request.Label, request.ApiName, request.Type, request.Email,
+			request.RelatedRoleId, request.RelatedTerritoryId,
+		).Scan(&id)
+		if err != nil {
+			return translateError(err)
+		}
+
+		group, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return group, err
+}
+
+func (that *Service) Update(ctx context.Context, id int64, request UpdateGroup) (group *Group, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		next := request.apply(current)
+		if err := next.Validate(ctx); err != nil {
+			return err
+		}
+
+		if err := that.checkRelations(ctx, actor, &next); err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE cluster."group"
+			SET label = $3, api_name = $4, type = $5, email = $6, related_role_id = $7, related_territory_id = $8
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id, next.Label, next.ApiName, next.Type, next.Email,
+			next.RelatedRoleId, next.RelatedTerritoryId,
+		)
+		if err != nil {
+			return translateError(err)
+		}
+
+		group, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return group, err
+}
+
+// Delete - soft deletes the group together with its memberships
+// and memberships of the group in other groups.
+func (that *Service) Delete(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := that.lockMembership(ctx, actor); err != nil {
+			return err
+		}
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE cluster.group_member SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND (group_id = $2 OR member_group_id = $2) AND deleted_at IS NULL`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete group memberships: %w", err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE cluster."group" SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete group: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Members - returns direct memberships of the group
+func (that *Service) Members(ctx context.Context, groupId int64, page services.Page) (*services.List[*Member], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := that.get(ctx, actor, groupId, false); err != nil {
+		return nil, err
+	}
+
+	return that.ListMembers(ctx, MemberFilter{GroupId: &groupId}, page)
+}
+
+func (that *Service) ListMembers(ctx context.Context, filter MemberFilter, page services.Page) (*services.List[*Member], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"m.tenant_id = $1", "m.deleted_at IS NULL"}
+	args := []any{actor.TenantId}
+
+	if filter.GroupId != nil {
+		args = append(args, *filter.GroupId)
+		where = append(where, fmt.Sprintf("m.group_id = $%d", len(args)))
+	}
+
+	if filter.MemberUserId != "" {
+		args = append(args, filter.MemberUserId)
+		where = append(where, fmt.Sprintf("u.record_id = $%d", len(args)))
+	}
+
+	if filter.MemberGroupId != nil {
+		args = append(args, *filter.MemberGroupId)
+		where = append(where, fmt.Sprintf("m.member_group_id = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM %s WHERE %s ORDER BY m.created_at, m.id LIMIT $%d OFFSET $%d`,
+		memberColumns, memberSource, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	list := &services.List[*Member]{Page: page, Items: make([]*Member, 0)}
+	err = that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		member, err := scanMember(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, member)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (that *Service) GetMember(ctx context.Context, recordId string) (*Member, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.getMember(ctx, actor, recordId, false)
+}
+
+// AddMember - adds a user or a nested group into the group.
+// Nested group is rejected if the group is already included into it directly or transitively.
+func (that *Service) AddMember(ctx context.Context, request AddMember) (member *Member, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := that.lockMembership(ctx, actor); err != nil {
+			return err
+		}
+
+		userId, err := that.checkMember(ctx, actor, 0, request.GroupId, request.MemberUserId, request.MemberGroupId)
+		if err != nil {
+			return err
+		}
+
+		var recordId string
+		err = that.db.QueryRow(
+			ctx,
+			`INSERT INTO cluster.group_member (tenant_id, group_id, member_user_id, member_group_id)
+			VALUES ($1, $2, $3, $4)
+			RETURNING record_id`,
+			actor.TenantId, request.GroupId, userId, request.MemberGroupId,
+		).Scan(&recordId)
+		if err != nil {
+			return translateMemberError(err)
+		}
+
+		member, err = that.getMember(ctx, actor, recordId, false)
+		return err
+	})
+	return member, err
+}
+
+// UpdateMember - moves the membership to another group and/or replaces the member
+func (that *Service) UpdateMember(ctx context.Context, recordId string, request UpdateMember) (member *Member, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := that.lockMembership(ctx, actor); err != nil {
+			return err
+		}
+
+		current, err := that.getMember(ctx, actor, recordId, true)
+		if err != nil {
+			return err
+		}
+
+		groupId := current.GroupId
+		if request.GroupId != nil {
+			groupId = *request.GroupId
+		}
+
+		memberUserId, memberGroupId := current.MemberUserId, current.MemberGroupId
+		switch {
+		case request.MemberUserId != nil:
+			memberUserId, memberGroupId = request.MemberUserId, nil
+		case request.MemberGroupId != nil:
+			memberUserId, memberGroupId = nil, request.MemberGroupId
+		}
+
+		userId, err := that.checkMember(ctx, actor, current.Id, groupId, memberUserId, memberGroupId)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE cluster.group_member SET group_id = $3, member_user_id = $4, member_group_id = $5
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id, groupId, userId, memberGroupId,
+		)
+		if err != nil {
+			return translateMemberError(err)
+		}
+
+		member, err = that.getMember(ctx, actor, recordId, false)
+		return err
+	})
+	return member, err
+}
+
+// RemoveMember - soft deletes the membership.
+// If groupId is specified, the membership must belong to that group.
+func (that *Service) RemoveMember(ctx context.Context, recordId string, groupId *int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getMember(ctx, actor, recordId, true)
+		if err != nil {
+			return err
+		}
+		if groupId != nil && current.GroupId != *groupId {
+			return ErrMemberNotFound
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE cluster.group_member SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete group member: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (that *Service) get(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*Group, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM cluster."group" g WHERE g.tenant_id = $1 AND g.id = $2 AND g.deleted_at IS NULL`,
+		groupColumns,
+	)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	group, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readGroup)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrNotFound
+	}
+	return group, nil
+}
+
+func (that *Service) getMember(ctx context.Context, actor services.Actor, recordId string, forUpdate bool) (*Member, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE m.tenant_id = $1 AND m.record_id = $2 AND m.deleted_at IS NULL`,
+		memberColumns, memberSource,
+	)
+	if forUpdate {
+		query += " FOR UPDATE OF m"
+	}
+
+	member, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, recordId), readMember)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrMemberNotFound
+	}
+	return member, nil
+}
+
+// lockMembership - serializes changes of the membership graph within the tenant until the end of transaction.
+// Concurrent nesting of two groups into each other would pass cycle check in both transactions otherwise.
+func (that *Service) lockMembership(ctx context.Context, actor services.Actor) error {
+	_, err := that.db.Exec(
+		ctx,
+		`SELECT pg_advisory_xact_lock(hashtext('cluster.group_member'), hashtext($1))`,
+		actor.TenantId.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("lock group membership: %w", err)
+	}
+	return nil
+}
+
+// checkMember - validates membership and returns internal id of the member user.
+// exceptId excludes the membership being updated from duplicate check.
+func (that *Service) checkMember(
+	ctx context.Context,
+	actor services.Actor,
+	exceptId int64,
+	groupId int64,
+	memberUserId *string,
+	memberGroupId *int64,
+) (*int64, error) {
+	if _, err := that.get(ctx, actor, groupId, false); err != nil {
+		return nil, err
+	}
+
+	var userId *int64
+	if memberUserId != nil {
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT id FROM iam."user" WHERE tenant_id = $1 AND record_id = $2 AND deleted_at IS NULL`,
+			actor.TenantId, *memberUserId,
+		).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrMemberUserNotFound
+			}
+			return nil, err
+		}
+		userId = &id
+	}
+
+	if memberGroupId != nil {
+		if _, err := that.get(ctx, actor, *memberGroupId, false); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil, ErrMemberGroupNotFound
+			}
+			return nil, err
+		}
+
+		var cycle bool
+		err := that.db.QueryRow(
+			ctx,
+			`SELECT cluster.is_membership_cycle($1, $2, $3)`,
+			actor.TenantId, groupId, *memberGroupId,
+		).Scan(&cycle)
+		if err != nil {
+			return nil, fmt.Errorf("check membership cycle: %w", err)
+		}
+		if cycle {
+			return nil, ErrMembershipCycle
+		}
+	}
+
+	var exists bool
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM cluster.group_member
+			WHERE tenant_id = $1 AND group_id = $2 AND id <> $3 AND deleted_at IS NULL
+			  AND (member_user_id = $4 OR member_group_id = $5)
+		)`,
+		actor.TenantId, groupId, exceptId, userId, memberGroupId,
+	).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("check group member: %w", err)
+	}
+	if exists {
+		return nil, ErrMemberExists
+	}
+
+	return userId, nil
+}
+
+// checkRelations - ensures that related role and territory exist
+func (that *Service) checkRelations(ctx context.Context, actor services.Actor, group *CreateGroup) error {
+	if group.RelatedRoleId != nil {
+		exists, err := that.exists(ctx, `iam.role`, actor, *group.RelatedRoleId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRoleNotFound
+		}
+	}
+
+	if group.RelatedTerritoryId != nil {
+		exists, err := that.exists(ctx, `iam.territory`, actor, *group.RelatedTerritoryId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrTerritoryNotFound
+		}
+	}
+
+	return nil
+}
+
+func (that *Service) exists(ctx context.Context, table string, actor services.Actor, id int64) (bool, error) {
+	var exists bool
+	err := that.db.QueryRow(
+		ctx,
+		fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE tenant_id = $1 AND id = $2 AND deleted_at IS NULL)`, table),
+		actor.TenantId, id,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check %s: %w", table, err)
+	}
+	return exists, nil
+}
+
+func readGroup(scanner sql.Scanner) (*Group, error) {
+	return scanGroup(scanner)
+}
+
+func scanGroup(scanner sql.Scanner, extra ...any) (*Group, error) {
+	var group Group
+	dest := []any{
+		&group.TenantId,
+		&group.Id,
+		&group.Label,
+		&group.ApiName,
+		&group.Type,
+		&group.Email,
+		&group.RelatedRoleId,
+		&group.RelatedTerritoryId,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+		&group.DeletedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func readMember(scanner sql.Scanner) (*Member, error) {
+	return scanMember(scanner)
+}
+
+func scanMember(scanner sql.Scanner, extra ...any) (*Member, error) {
+	var member Member
+	dest := []any{
+		&member.TenantId,
+		&member.Id,
+		&member.RecordId,
+		&member.GroupId,
+		&member.MemberUserId,
+		&member.MemberGroupId,
+		&member.CreatedAt,
+		&member.UpdatedAt,
+		&member.DeletedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrApiNameExists
+	}
+	return err
+}
+
+func translateMemberError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrMemberExists
+	}
+	return err
+}
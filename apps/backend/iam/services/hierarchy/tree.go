@@ -0,0 +1,115 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCycle = errors.New("parent assignment would create a cycle")
+)
+
+// Tree - self referencing table with soft delete support, parent is the column referencing the parent node
+type Tree struct {
+	table  string
+	parent string
+}
+
+func NewTree(table, parent string) Tree {
+	return Tree{table: table, parent: parent}
+}
+
+// Lock - serializes structural changes of the tree within the tenant until the end of transaction.
+// Concurrent re-parenting of two nodes under each other would pass cycle check in both
+// transactions otherwise.
+func (that Tree) Lock(ctx context.Context, db sql.Scope, tenantId uuid.UUID) error {
+	_, err := db.Exec(
+		ctx,
+		`SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))`,
+		that.table, tenantId.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("lock %s tree: %w", that.table, err)
+	}
+	return nil
+}
+
+// CheckParent - returns ErrCycle if the parent is the node itself or any of its descendants
+func (that Tree) CheckParent(ctx context.Context, db sql.Scope, tenantId uuid.UUID, id, parentId int64) error {
+	var cycle bool
+	err := db.QueryRow(
+		ctx,
+		fmt.Sprintf(
+			`WITH RECURSIVE chain AS (
+				SELECT id, %[2]s AS parent_id FROM %[1]s WHERE tenant_id = $1 AND id = $2
+				UNION
+				SELECT t.id, t.%[2]s
+				FROM %[1]s t
+				JOIN chain c ON t.id = c.parent_id
+				WHERE t.tenant_id = $1
+			)
+			SELECT EXISTS (SELECT 1 FROM chain WHERE id = $3)`,
+			that.table, that.parent,
+		),
+		tenantId, parentId, id,
+	).Scan(&cycle)
+	if err != nil {
+		return fmt.Errorf("check %s chain: %w", that.table, err)
+	}
+	if cycle {
+		return ErrCycle
+	}
+	return nil
+}
+
+// HasChildren - checks whether the node has active children
+func (that Tree) HasChildren(ctx context.Context, db sql.Scope, tenantId uuid.UUID, id int64) (bool, error) {
+	var exists bool
+	err := db.QueryRow(
+		ctx,
+		fmt.Sprintf(
+			`SELECT EXISTS (SELECT 1 FROM %s WHERE tenant_id = $1 AND %s = $2 AND deleted_at IS NULL)`,
+			that.table, that.parent,
+		),
+		tenantId, id,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check %s children: %w", that.table, err)
+	}
+	return exists, nil
+}
+
+// Descendants - returns common table expression "tree (id, depth, path)" with active descendants of the node.
+// The expression expects tenant id as $1 and node id as $2.
+// If recursive is false, only direct children are included.
+// The path holds the visited nodes, so the expression terminates even if the tree has a cycle.
+func (that Tree) Descendants(recursive bool) string {
+	if !recursive {
+		return fmt.Sprintf(
+			`tree AS (
+				SELECT t.id, 1 AS depth, ARRAY[$2::bigint, t.id] AS path
+				FROM %s t
+				WHERE t.tenant_id = $1 AND t.%s = $2 AND t.deleted_at IS NULL
+			)`,
+			that.table, that.parent,
+		)
+	}
+
+	return fmt.Sprintf(
+		`tree AS (
+			SELECT t.id, 1 AS depth, ARRAY[$2::bigint, t.id] AS path
+			FROM %[1]s t
+			WHERE t.tenant_id = $1 AND t.%[2]s = $2 AND t.deleted_at IS NULL
+			UNION ALL
+			SELECT t.id, p.depth + 1, p.path || t.id
+			FROM %[1]s t
+			JOIN tree p ON t.%[2]s = p.id
+			WHERE t.tenant_id = $1 AND t.deleted_at IS NULL AND t.id <> ALL (p.path)
+		)`,
+		that.table, that.parent,
+	)
+}
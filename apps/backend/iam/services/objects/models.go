@@ -0,0 +1,90 @@
+package objects
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/google/uuid"
+)
+
+// apiNamePattern - mirrors api_name check constraints of security.object and security.field
+var apiNamePattern = regexp.MustCompile(`^[_a-zA-Z][a-zA-Z0-9_]{0,62}$`)
+
+// Object - entity that can have permissions applied to it
+type Object struct {
+	TenantId  uuid.UUID
+	Id        int64
+	ApiName   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type CreateObject struct {
+	ApiName string `json:"api_name"`
+}
+
+func (that *CreateObject) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.ApiName, validation.Required, validation.Match(apiNamePattern)),
+	)
+}
+
+type UpdateObject struct {
+	ApiName *string `json:"api_name"`
+}
+
+func (that *UpdateObject) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.ApiName, validation.NilOrNotEmpty, validation.Match(apiNamePattern)),
+	)
+}
+
+type Filter struct {
+	Search string
+}
+
+// Field - field of the object that can have field level permissions
+type Field struct {
+	TenantId  uuid.UUID
+	Id        int64
+	ObjectId  int64
+	ApiName   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type CreateField struct {
+	ObjectId int64  `json:"object_id"`
+	ApiName  string `json:"api_name"`
+}
+
+func (that *CreateField) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.ObjectId, validation.Required),
+		validation.Field(&that.ApiName, validation.Required, validation.Match(apiNamePattern)),
+	)
+}
+
+type UpdateField struct {
+	ApiName *string `json:"api_name"`
+}
+
+func (that *UpdateField) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.ApiName, validation.NilOrNotEmpty, validation.Match(apiNamePattern)),
+	)
+}
+
+var (
+	ErrNotFound           = errors.New("security object not found")
+	ErrApiNameExists      = errors.New("security object with this api_name already exists")
+	ErrFieldNotFound      = errors.New("security field not found")
+	ErrFieldApiNameExists = errors.New("security field with this api_name already exists in the object")
+)
@@ -0,0 +1,358 @@
+package objects
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+const objectColumns = `
+	o.tenant_id, o.id, o.api_name, o.created_at, o.updated_at`
+
+const fieldColumns = `
+	f.tenant_id, f.id, f.object_id, f.api_name, f.created_at, f.updated_at`
+
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func (that *Service) List(ctx context.Context, filter Filter, page services.Page) (*services.List[*Object], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"o.tenant_id = $1"}
+	args := []any{actor.TenantId}
+
+	if filter.Search != "" {
+		args = append(args, "%"+services.EscapeLike(filter.Search)+"%")
+		where = append(where, fmt.Sprintf("o.api_name ILIKE $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM security.object o WHERE %s ORDER BY o.api_name, o.id LIMIT $%d OFFSET $%d`,
+		objectColumns, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	list := &services.List[*Object]{Page: page, Items: make([]*Object, 0)}
+	err = that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		object, err := scanObject(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (that *Service) Get(ctx context.Context, id int64) (*Object, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.get(ctx, actor, id, false)
+}
+
+func (that *Service) Create(ctx context.Context, request CreateObject) (object *Object, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`INSERT INTO security.object (tenant_id, api_name) VALUES ($1, $2) RETURNING id`,
+			actor.TenantId, request.ApiName,
+		).Scan(&id)
+		if err != nil {
+			return translateError(err)
+		}
+
+		object, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return object, err
+}
+
+func (that *Service) Update(ctx context.Context, id int64, request UpdateObject) (object *Object, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		if request.ApiName != nil {
+			_, err = that.db.Exec(
+				ctx,
+				`UPDATE security.object SET api_name = $3 WHERE tenant_id = $1 AND id = $2`,
+				actor.TenantId, current.Id, *request.ApiName,
+			)
+			if err != nil {
+				return translateError(err)
+			}
+		}
+
+		object, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return object, err
+}
+
+// Delete - deletes the object together with its fields and permission grants
+func (that *Service) Delete(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`DELETE FROM security.object WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete security object: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Fields - returns fields of the object
+func (that *Service) Fields(ctx context.Context, objectId int64, page services.Page) (*services.List[*Field], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := that.get(ctx, actor, objectId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER ()
+		FROM security.field f
+		WHERE f.tenant_id = $1 AND f.object_id = $2
+		ORDER BY f.api_name, f.id
+		LIMIT $3 OFFSET $4`,
+		fieldColumns,
+	)
+
+	list := &services.List[*Field]{Page: page, Items: make([]*Field, 0)}
+	err = that.db.Fetch(ctx, query, actor.TenantId, object.Id, page.Limit, page.Offset())(func(rows sql.Rows) error {
+		field, err := scanField(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, field)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (that *Service) GetField(ctx context.Context, id int64) (*Field, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.getField(ctx, actor, id, false)
+}
+
+func (that *Service) CreateField(ctx context.Context, request CreateField) (field *Field, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		object, err := that.get(ctx, actor, request.ObjectId, false)
+		if err != nil {
+			return err
+		}
+
+		var id int64
+		err = that.db.QueryRow(
+			ctx,
+			`INSERT INTO security.field (tenant_id, object_id, api_name) VALUES ($1, $2, $3) RETURNING id`,
+			actor.TenantId, object.Id, request.ApiName,
+		).Scan(&id)
+		if err != nil {
+			return translateFieldError(err)
+		}
+
+		field, err = that.getField(ctx, actor, id, false)
+		return err
+	})
+	return field, err
+}
+
+func (that *Service) UpdateField(ctx context.Context, id int64, request UpdateField) (field *Field, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getField(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		if request.ApiName != nil {
+			_, err = that.db.Exec(
+				ctx,
+				`UPDATE security.field SET api_name = $3 WHERE tenant_id = $1 AND id = $2`,
+				actor.TenantId, current.Id, *request.ApiName,
+			)
+			if err != nil {
+				return translateFieldError(err)
+			}
+		}
+
+		field, err = that.getField(ctx, actor, id, false)
+		return err
+	})
+	return field, err
+}
+
+// DeleteField - deletes the field together with its permission grants
+func (that *Service) DeleteField(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getField(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`DELETE FROM security.field WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete security field: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (that *Service) get(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*Object, error) {
+	query := fmt.Sprintf(`SELECT %s FROM security.object o WHERE o.tenant_id = $1 AND o.id = $2`, objectColumns)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	object, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readObject)
+	if err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, ErrNotFound
+	}
+	return object, nil
+}
+
+func (that *Service) getField(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*Field, error) {
+	query := fmt.Sprintf(`SELECT %s FROM security.field f WHERE f.tenant_id = $1 AND f.id = $2`, fieldColumns)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	field, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readField)
+	if err != nil {
+		return nil, err
+	}
+	if field == nil {
+		return nil, ErrFieldNotFound
+	}
+	return field, nil
+}
+
+func readObject(scanner sql.Scanner) (*Object, error) {
+	return scanObject(scanner)
+}
+
+func scanObject(scanner sql.Scanner, extra ...any) (*Object, error) {
+	var object Object
+	dest := []any{
+		&object.TenantId,
+		&object.Id,
+		&object.ApiName,
+		&object.CreatedAt,
+		&object.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+func readField(scanner sql.Scanner) (*Field, error) {
+	return scanField(scanner)
+}
+
+func scanField(scanner sql.Scanner, extra ...any) (*Field, error) {
+	var field Field
+	dest := []any{
+		&field.TenantId,
+		&field.Id,
+		&field.ObjectId,
+		&field.ApiName,
+		&field.CreatedAt,
+		&field.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrApiNameExists
+	}
+	return err
+}
+
+func translateFieldError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrFieldApiNameExists
+	}
+	return err
+}
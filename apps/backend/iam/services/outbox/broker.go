@@ -0,0 +1,85 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/adverax/metacrm/pkg/broker"
+)
+
+// BrokerPublisher - publishes events to the message broker.
+// Event type is the channel of contracts/iam-events.yml (e.g. iam.user.created) and is used as the topic.
+// Messages are keyed by the aggregate id, so events of the aggregate stay in one partition in order.
+type BrokerPublisher struct {
+	publisher broker.Publisher
+}
+
+func NewBrokerPublisher(publisher broker.Publisher) *BrokerPublisher {
+	return &BrokerPublisher{publisher: publisher}
+}
+
+func (that *BrokerPublisher) Publish(ctx context.Context, event *Event) error {
+	message, err := NewBrokerMessage(event)
+	if err != nil {
+		return err
+	}
+
+	if err := that.publisher.Publish(ctx, message); err != nil {
+		return fmt.Errorf("publish event %d to %s: %w", event.Id, message.Topic, err)
+	}
+	return nil
+}
+
+// NewBrokerMessage - converts the event to the broker message.
+// Event headers become message headers: strings as is, other values JSON encoded.
+// Aggregate and event type headers of EventHeaders are added when the event has none.
+func NewBrokerMessage(event *Event) (broker.Message, error) {
+	var values map[string]json.RawMessage
+	if len(event.Headers) != 0 {
+		if err := json.Unmarshal(event.Headers, &values); err != nil {
+			return broker.Message{}, fmt.Errorf("decode headers of event %d: %w", event.Id, err)
+		}
+	}
+
+	headers := make([]broker.Header, 0, len(values)+4)
+	for key, value := range values {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			headers = append(headers, broker.Header{Key: key, Value: []byte(s)})
+			continue
+		}
+		if string(value) == "null" {
+			continue
+		}
+		headers = append(headers, broker.Header{Key: key, Value: value})
+	}
+
+	defaults := map[string]string{
+		"event_id":       strconv.FormatInt(event.Id, 10),
+		"event_type":     event.EventType,
+		"aggregate_type": event.AggregateType,
+		"aggregate_id":   event.AggregateId,
+	}
+	for key, value := range defaults {
+		if _, ok := values[key]; !ok {
+			headers = append(headers, broker.Header{Key: key, Value: []byte(value)})
+		}
+	}
+
+	// Stable order of the headers for consumers and tests
+	slices.SortFunc(headers, func(a, b broker.Header) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return broker.Message{
+		Topic:     event.EventType,
+		Key:       []byte(event.AggregateId),
+		Value:     event.Payload,
+		Headers:   headers,
+		Timestamp: event.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Event types are quoted literals: 'iam.user.created' in SQL, "iam.auth.logout" in Go
+var eventTypePattern = regexp.MustCompile(`['"](iam\.[a-z_]+\.[a-z_]+)['"]`)
+
+// TestEventTypesAreContractChannels - every event type emitted by the migrations and the services
+// is the topic of its broker message, so it must be a channel of the events contract.
+func TestEventTypesAreContractChannels(t *testing.T) {
+	data, err := os.ReadFile("../../../../../contracts/iam-events.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contract struct {
+		Channels map[string]any `yaml:"channels"`
+	}
+	if err := yaml.Unmarshal(data, &contract); err != nil {
+		t.Fatalf("decode contract: %v", err)
+	}
+
+	migrations, err := filepath.Glob("../../database/migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	err = filepath.WalkDir("..", func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
+			sources = append(sources, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || len(sources) == 0 {
+		t.Fatal("migrations or sources are not found")
+	}
+
+	emitted := map[string][]string{}
+	for _, path := range append(migrations, sources...) {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range eventTypePattern.FindAllStringSubmatch(string(text), -1) {
+			if !slices.Contains(emitted[match[1]], path) {
+				emitted[match[1]] = append(emitted[match[1]], path)
+			}
+		}
+	}
+
+	for eventType, paths := range emitted {
+		if _, ok := contract.Channels[eventType]; !ok {
+			t.Errorf("event type %s of %v is not a channel of the contract", eventType, paths)
+		}
+	}
+}
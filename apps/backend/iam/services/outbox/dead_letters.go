@@ -0,0 +1,161 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+// purgeBatchSize - number of delivered events deleted by one statement
+const purgeBatchSize = 1000
+
+const deadEventColumns = `
+	o.id, o.aggregate_type, o.aggregate_id, o.event_type, o.payload, o.headers, o.attempt, o.created_at, o.dead_at`
+
+// ListDead - returns dead events matching the filter, the oldest first
+func (that *Service) ListDead(ctx context.Context, filter DeadFilter, page services.Page) (*services.List[*DeadEvent], error) {
+	where, args := deadWhere(filter)
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM bootstrap.outbox o WHERE %s ORDER BY o.created_at, o.id LIMIT $%d OFFSET $%d`,
+		deadEventColumns, where, len(args)-1, len(args),
+	)
+
+	list := &services.List[*DeadEvent]{Page: page, Items: make([]*DeadEvent, 0)}
+	err := that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		event, err := scanDeadEvent(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, event)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list dead outbox events: %w", err)
+	}
+	return list, nil
+}
+
+// GetDead - returns the dead event with its payload and headers
+func (that *Service) GetDead(ctx context.Context, id int64) (*DeadEvent, error) {
+	query := fmt.Sprintf(`SELECT %s FROM bootstrap.outbox o WHERE o.id = $1 AND o.status = 'dead'`, deadEventColumns)
+
+	event, err := sql.FetchModel(that.db.Fetch(ctx, query, id), readDeadEvent)
+	if err != nil {
+		return nil, fmt.Errorf("get dead outbox event: %w", err)
+	}
+	if event == nil {
+		return nil, ErrNotFound
+	}
+	return event, nil
+}
+
+// Requeue - returns the dead event to publishing with fresh attempts
+func (that *Service) Requeue(ctx context.Context, id int64) error {
+	var requeued int
+	err := that.db.QueryRow(ctx, `SELECT bootstrap.requeue_dead_events(ARRAY[$1::bigint])`, id).Scan(&requeued)
+	if err != nil {
+		return fmt.Errorf("requeue dead outbox event: %w", err)
+	}
+	if requeued == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RequeueDead - returns every dead event matching the filter to publishing, reports the number of requeued events
+func (that *Service) RequeueDead(ctx context.Context, filter DeadFilter) (int, error) {
+	where, args := deadWhere(filter)
+
+	var requeued int
+	query := fmt.Sprintf(
+		`SELECT bootstrap.requeue_dead_events(ARRAY(SELECT o.id FROM bootstrap.outbox o WHERE %s))`,
+		where,
+	)
+	if err := that.db.QueryRow(ctx, query, args...).Scan(&requeued); err != nil {
+		return 0, fmt.Errorf("requeue dead outbox events: %w", err)
+	}
+	return requeued, nil
+}
+
+// Purge - deletes events delivered earlier than the retention period, reports the number of deleted events
+func (that *Service) Purge(ctx context.Context, retention time.Duration) (int, error) {
+	if retention <= 0 {
+		return 0, ErrInvalidRetention
+	}
+
+	var total int
+	for {
+		var deleted int
+		err := that.db.QueryRow(ctx, `SELECT bootstrap.purge_delivered_events($1, $2)`, retention, purgeBatchSize).Scan(&deleted)
+		if err != nil {
+			return total, fmt.Errorf("purge delivered outbox events: %w", err)
+		}
+
+		total += deleted
+		if deleted < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// deadWhere - returns condition and arguments selecting dead events of the filter
+func deadWhere(filter DeadFilter) (string, []any) {
+	where := []string{"o.status = 'dead'"}
+	var args []any
+
+	if len(filter.Ids) != 0 {
+		args = append(args, filter.Ids)
+		where = append(where, fmt.Sprintf("o.id = ANY($%d)", len(args)))
+	}
+
+	if filter.EventType != "" {
+		args = append(args, filter.EventType)
+		where = append(where, fmt.Sprintf("o.event_type = $%d", len(args)))
+	}
+
+	if filter.TenantId != nil {
+		args = append(args, filter.TenantId.String())
+		where = append(where, fmt.Sprintf("o.headers->>'tenant_id' = $%d", len(args)))
+	}
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		where = append(where, fmt.Sprintf("o.created_at >= $%d", len(args)))
+	}
+
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		where = append(where, fmt.Sprintf("o.created_at < $%d", len(args)))
+	}
+
+	return strings.Join(where, " AND "), args
+}
+
+func readDeadEvent(scanner sql.Scanner) (*DeadEvent, error) {
+	return scanDeadEvent(scanner)
+}
+
+func scanDeadEvent(scanner sql.Scanner, extra ...any) (*DeadEvent, error) {
+	var event DeadEvent
+	dest := []any{
+		&event.Id,
+		&event.AggregateType,
+		&event.AggregateId,
+		&event.EventType,
+		&event.Payload,
+		&event.Headers,
+		&event.Attempt,
+		&event.CreatedAt,
+		&event.DeadAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event - domain event claimed from the outbox
+type Event struct {
+	Id            int64
+	AggregateType string
+	AggregateId   string
+	EventType     string
+	Payload       json.RawMessage
+	Headers       json.RawMessage
+	Attempt       int // number of the current attempt, starts from 1
+	CreatedAt     time.Time
+}
+
+// Key - events with the same key are delivered in order of their ids
+func (that *Event) Key() Key {
+	return Key{AggregateType: that.AggregateType, AggregateId: that.AggregateId}
+}
+
+// Key - aggregate that generated the event
+type Key struct {
+	AggregateType string
+	AggregateId   string
+}
+
+// Publisher - delivers outbox events to the external system.
+// Error of the publisher schedules the event for retry.
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// PublisherFunc - adapter of the function to Publisher
+type PublisherFunc func(ctx context.Context, event *Event) error
+
+func (that PublisherFunc) Publish(ctx context.Context, event *Event) error {
+	return that(ctx, event)
+}
+
+// DeadEvent - event that was not published within the maximum number of attempts
+type DeadEvent struct {
+	Event
+	DeadAt *time.Time // NULL for events that died before the moment was recorded
+}
+
+// DeadFilter - selection of the dead events, empty filter selects every dead event
+type DeadFilter struct {
+	Ids       []int64
+	EventType string
+	TenantId  *uuid.UUID // tenant_id of the event headers
+	From      *time.Time // events created at or after
+	To        *time.Time // events created before
+}
+
+// IsEmpty - checks whether the filter selects every dead event
+func (that DeadFilter) IsEmpty() bool {
+	return len(that.Ids) == 0 && that.EventType == "" && that.TenantId == nil && that.From == nil && that.To == nil
+}
+
+var (
+	ErrNotFound         = errors.New("dead event not found")
+	ErrInvalidRetention = errors.New("retention must be positive")
+)
@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adverax/metacrm/pkg/log"
+)
+
+// Publishers - publishes the event to every publisher in turn.
+// Publishing stops on the first failure, so the retry delivers the event
+// to the preceding publishers once more.
+type Publishers []Publisher
+
+func (that Publishers) Publish(ctx context.Context, event *Event) error {
+	for i, publisher := range that {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return fmt.Errorf("publisher %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// LogPublisher - writes events to the log, used when no broker is configured
+type LogPublisher struct {
+	logger log.Logger
+}
+
+func NewLogPublisher(logger log.Logger) *LogPublisher {
+	return &LogPublisher{logger: logger}
+}
+
+func (that *LogPublisher) Publish(ctx context.Context, event *Event) error {
+	that.logger.
+		WithFields(log.Fields{
+			"event_id":       event.Id,
+			"event_type":     event.EventType,
+			"aggregate_type": event.AggregateType,
+			"aggregate_id":   event.AggregateId,
+			"payload":        string(event.Payload),
+			"headers":        string(event.Headers),
+		}).
+		Info(ctx, "outbox event")
+	return nil
+}
@@ -0,0 +1,174 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services/worker"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/adverax/metacrm/pkg/log"
+)
+
+// Channel - notified on commit of new outbox events
+const Channel = "bootstrap_outbox"
+
+// RelayOptions - processing of the outbox by the relay
+type RelayOptions struct {
+	WorkerId     string        // Identifier of the relay, unique among the running relays
+	BatchSize    int           // Maximum number of events claimed at once
+	Concurrency  int           // Maximum number of aggregates whose events are published simultaneously
+	PollInterval time.Duration // Period of the outbox polling, 0 disables the relay
+	LockTimeout  time.Duration // Period after which events of the crashed relays are processed again
+	MaxAttempts  int           // Number of attempts before the event is dead
+}
+
+// Relay - publishes outbox events until they are delivered or dead.
+// Events of the same aggregate are published in order of their ids.
+// Notifications of Channel wake up the relay at once, polling remains as a fallback.
+// The claimed batch is completed before the relay stops,
+// so publishing is bounded by the lock timeout instead of the relay context.
+type Relay struct {
+	*worker.Worker[*Event]
+	service   *Service
+	publisher Publisher
+	options   RelayOptions
+	logger    log.Logger
+}
+
+// NewRelay - nil listener leaves the relay to polling only
+func NewRelay(service *Service, publisher Publisher, listener *sql.Listener, options RelayOptions, logger log.Logger) *Relay {
+	relay := &Relay{
+		service:   service,
+		publisher: publisher,
+		options:   options,
+		logger:    logger,
+	}
+	relay.Worker = worker.New(
+		worker.Steps[*Event]{
+			Claim: func(ctx context.Context, limit int) ([]*Event, error) {
+				return service.Claim(ctx, options.WorkerId, limit)
+			},
+			Process: relay.process,
+			Recover: func(ctx context.Context) (int, error) {
+				return service.ReleaseStuck(ctx, options.LockTimeout)
+			},
+		},
+		listener,
+		worker.Options{
+			Subject:      "outbox events",
+			Channel:      Channel,
+			BatchSize:    options.BatchSize,
+			PollInterval: options.PollInterval,
+			LockTimeout:  options.LockTimeout,
+		},
+		logger,
+	)
+	return relay
+}
+
+// process - publishes events of the different aggregates concurrently
+// and events of the same aggregate sequentially
+func (that *Relay) process(ctx context.Context, events []*Event) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(that.options.Concurrency, 1))
+	for _, sequence := range sequencesOf(events) {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			that.deliverSequence(ctx, sequence)
+		}()
+	}
+	wg.Wait()
+}
+
+// deliverSequence - publishes events of the aggregate in order,
+// events following the undelivered one are released to keep the order
+func (that *Relay) deliverSequence(ctx context.Context, sequence []*Event) {
+	for i, event := range sequence {
+		if !that.deliver(ctx, event) {
+			that.release(ctx, sequence[i+1:])
+			return
+		}
+	}
+}
+
+// deliver - publishes the event, reports whether it was delivered
+func (that *Relay) deliver(ctx context.Context, event *Event) bool {
+	logger := that.logger.WithFields(log.Fields{
+		"event_id":   event.Id,
+		"event_type": event.EventType,
+		"attempt":    event.Attempt,
+	})
+
+	err := that.publish(ctx, event)
+	if err != nil {
+		logger.WithError(err).Warning(ctx, "outbox event publishing failed")
+
+		failed, err := that.service.Fail(ctx, event.Id, that.options.WorkerId, that.options.MaxAttempts)
+		if err != nil {
+			logger.WithError(err).Error(ctx, "outbox event failure was not recorded")
+		} else if !failed {
+			logger.Warning(ctx, "outbox event lock was lost before failure")
+		} else if event.Attempt >= that.options.MaxAttempts {
+			logger.Error(ctx, "outbox event is dead")
+		}
+		return false
+	}
+
+	completed, err := that.service.Complete(ctx, event.Id, that.options.WorkerId)
+	if err != nil {
+		logger.WithError(err).Error(ctx, "outbox event completion was not recorded")
+		return false
+	}
+	if !completed {
+		logger.Warning(ctx, "outbox event lock was lost before completion")
+		return false
+	}
+	logger.Debug(ctx, "outbox event published")
+	return true
+}
+
+// release - returns events to the outbox, they are delivered after the preceding event.
+// Events that failed to be released are processed again after the lock timeout.
+func (that *Relay) release(ctx context.Context, events []*Event) {
+	for _, event := range events {
+		if _, err := that.service.Release(ctx, event.Id, that.options.WorkerId); err != nil {
+			that.logger.
+				WithError(err).
+				WithFields(log.Fields{"event_id": event.Id}).
+				Error(ctx, "outbox event release failed")
+		}
+	}
+}
+
+// publish - publishes the event within the lock timeout, so the lock is not recovered while publishing
+func (that *Relay) publish(ctx context.Context, event *Event) error {
+	if that.options.LockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, that.options.LockTimeout)
+		defer cancel()
+	}
+	return that.publisher.Publish(ctx, event)
+}
+
+// sequencesOf - splits events into sequences of the same aggregate preserving order of events
+func sequencesOf(events []*Event) [][]*Event {
+	var sequences [][]*Event
+	index := make(map[Key]int)
+	for _, event := range events {
+		key := event.Key()
+		i, ok := index[key]
+		if !ok {
+			i = len(sequences)
+			index[key] = i
+			sequences = append(sequences, nil)
+		}
+		sequences[i] = append(sequences[i], event)
+	}
+	return sequences
+}
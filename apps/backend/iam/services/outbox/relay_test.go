@@ -0,0 +1,35 @@
+package outbox
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSequencesOf(t *testing.T) {
+	event := func(id int64, aggregateId string) *Event {
+		return &Event{Id: id, AggregateType: "user", AggregateId: aggregateId}
+	}
+	events := []*Event{
+		event(1, "a"),
+		event(2, "b"),
+		event(3, "a"),
+		{Id: 4, AggregateType: "role", AggregateId: "a"},
+		event(5, "b"),
+		event(6, "a"),
+	}
+
+	var got [][]int64
+	for _, sequence := range sequencesOf(events) {
+		var ids []int64
+		for _, e := range sequence {
+			ids = append(ids, e.Id)
+		}
+		got = append(got, ids)
+	}
+
+	// Sequences follow the first event of the aggregate, events keep their order within the aggregate
+	want := [][]int64{{1, 3, 6}, {2, 5}, {4}}
+	if !slices.EqualFunc(got, want, slices.Equal[[]int64]) {
+		t.Errorf("sequences = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+// Service - claims outbox events for processing and records results of their delivery.
+// Outbox is shared by all tenants, so the service works outside of the tenant context.
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// Claim - locks the batch of due events for the worker.
+// Events of the aggregate are claimed together with every earlier undelivered event
+// of the aggregate, the worker must publish them in order of ids.
+func (that *Service) Claim(ctx context.Context, workerId string, limit int) ([]*Event, error) {
+	rows, err := that.db.Query(
+		ctx,
+		`SELECT id, aggregate_type, aggregate_id, event_type, payload, headers, attempt, created_at
+		 FROM bootstrap.claim_events_for_processing($1, $2)`,
+		workerId, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var event Event
+		err := rows.Scan(
+			&event.Id, &event.AggregateType, &event.AggregateId, &event.EventType,
+			&event.Payload, &event.Headers, &event.Attempt, &event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan outbox event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim outbox events: %w", err)
+	}
+
+	slices.SortFunc(events, func(a, b *Event) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return events, nil
+}
+
+// Complete - marks the event as published, reports false when the worker lost the lock
+func (that *Service) Complete(ctx context.Context, id int64, workerId string) (bool, error) {
+	var completed bool
+	err := that.db.QueryRow(ctx, `SELECT bootstrap.mark_event_completed($1, $2)`, id, workerId).Scan(&completed)
+	if err != nil {
+		return false, fmt.Errorf("complete outbox event: %w", err)
+	}
+	return completed, nil
+}
+
+// Fail - schedules the event for retry or marks it dead after maxAttempts,
+// reports false when the worker lost the lock
+func (that *Service) Fail(ctx context.Context, id int64, workerId string, maxAttempts int) (bool, error) {
+	var failed bool
+	err := that.db.QueryRow(ctx, `SELECT bootstrap.mark_event_failed($1, $2, $3)`, id, maxAttempts, workerId).Scan(&failed)
+	if err != nil {
+		return false, fmt.Errorf("fail outbox event: %w", err)
+	}
+	return failed, nil
+}
+
+// Release - returns the claimed event to the outbox without spending its attempt,
+// reports false when the worker lost the lock
+func (that *Service) Release(ctx context.Context, id int64, workerId string) (bool, error) {
+	var released bool
+	err := that.db.QueryRow(ctx, `SELECT bootstrap.release_event($1, $2)`, id, workerId).Scan(&released)
+	if err != nil {
+		return false, fmt.Errorf("release outbox event: %w", err)
+	}
+	return released, nil
+}
+
+// ReleaseStuck - returns events locked longer than lockTimeout to processing by any worker
+func (that *Service) ReleaseStuck(ctx context.Context, lockTimeout time.Duration) (int, error) {
+	var released int
+	err := that.db.QueryRow(ctx, `SELECT bootstrap.release_stuck_events($1)`, lockTimeout).Scan(&released)
+	if err != nil {
+		return 0, fmt.Errorf("release stuck outbox events: %w", err)
+	}
+	return released, nil
+}
@@ -0,0 +1,42 @@
+package services
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Page - requested window of a paginated list
+type Page struct {
+	Number int
+	Limit  int
+}
+
+func (that Page) Offset() int {
+	return (that.Number - 1) * that.Limit
+}
+
+// NewPage - makes page from optional query parameters, applying defaults and bounds
+func NewPage(number, limit *int) Page {
+	page := Page{Number: 1, Limit: DefaultPageLimit}
+	if number != nil && *number > 0 {
+		page.Number = *number
+	}
+	if limit != nil && *limit > 0 {
+		page.Limit = min(*limit, MaxPageLimit)
+	}
+	return page
+}
+
+// List - single page of items together with the total number of items
+type List[T any] struct {
+	Items []T
+	Total int
+	Page  Page
+}
+
+func (that *List[T]) Pages() int {
+	if that.Page.Limit == 0 {
+		return 0
+	}
+	return (that.Total + that.Page.Limit - 1) / that.Page.Limit
+}
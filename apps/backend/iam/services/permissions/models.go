@@ -0,0 +1,321 @@
+package permissions
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/google/uuid"
+)
+
+// apiNamePattern - mirrors api_name check constraint of security.permission_set
+var apiNamePattern = regexp.MustCompile(`^[_a-zA-Z][a-zA-Z0-9_]{0,62}$`)
+
+// ObjectAccess - bitmask of object level permissions
+type ObjectAccess int
+
+const (
+	ObjectRead ObjectAccess = 1 << iota
+	ObjectUpdate
+	ObjectCreate
+	ObjectDelete
+
+	ObjectAll = ObjectRead | ObjectUpdate | ObjectCreate | ObjectDelete
+)
+
+func (that ObjectAccess) Has(flag ObjectAccess) bool {
+	return that&flag == flag
+}
+
+// With - sets or clears the flag
+func (that ObjectAccess) With(flag ObjectAccess, enabled bool) ObjectAccess {
+	if enabled {
+		return that | flag
+	}
+	return that &^ flag
+}
+
+// Names - human-readable names of the granted permissions
+func (that ObjectAccess) Names() []string {
+	names := make([]string, 0, 4)
+	for _, flag := range objectAccessNames {
+		if that.Has(flag.access) {
+			names = append(names, flag.name)
+		}
+	}
+	return names
+}
+
+var objectAccessNames = []struct {
+	access ObjectAccess
+	name   string
+}{
+	{ObjectRead, "READ"},
+	{ObjectUpdate, "UPDATE"},
+	{ObjectCreate, "CREATE"},
+	{ObjectDelete, "DELETE"},
+}
+
+// ObjectFlags - named alternative to the object access bitmask.
+// Nil flags are left unchanged.
+type ObjectFlags struct {
+	Read   *bool
+	Update *bool
+	Create *bool
+	Delete *bool
+}
+
+func (that *ObjectFlags) apply(access ObjectAccess) ObjectAccess {
+	if that == nil {
+		return access
+	}
+	if that.Read != nil {
+		access = access.With(ObjectRead, *that.Read)
+	}
+	if that.Update != nil {
+		access = access.With(ObjectUpdate, *that.Update)
+	}
+	if that.Create != nil {
+		access = access.With(ObjectCreate, *that.Create)
+	}
+	if that.Delete != nil {
+		access = access.With(ObjectDelete, *that.Delete)
+	}
+	return access
+}
+
+// FieldAccess - bitmask of field level permissions
+type FieldAccess int
+
+const (
+	FieldRead FieldAccess = 1 << iota
+	FieldWrite
+
+	FieldAll = FieldRead | FieldWrite
+)
+
+func (that FieldAccess) Has(flag FieldAccess) bool {
+	return that&flag == flag
+}
+
+// With - sets or clears the flag
+func (that FieldAccess) With(flag FieldAccess, enabled bool) FieldAccess {
+	if enabled {
+		return that | flag
+	}
+	return that &^ flag
+}
+
+// Names - human-readable names of the granted permissions
+func (that FieldAccess) Names() []string {
+	names := make([]string, 0, 2)
+	for _, flag := range fieldAccessNames {
+		if that.Has(flag.access) {
+			names = append(names, flag.name)
+		}
+	}
+	return names
+}
+
+var fieldAccessNames = []struct {
+	access FieldAccess
+	name   string
+}{
+	{FieldRead, "READ"},
+	{FieldWrite, "WRITE"},
+}
+
+// FieldFlags - named alternative to the field access bitmask.
+// Nil flags are left unchanged.
+type FieldFlags struct {
+	Read  *bool
+	Write *bool
+}
+
+func (that *FieldFlags) apply(access FieldAccess) FieldAccess {
+	if that == nil {
+		return access
+	}
+	if that.Read != nil {
+		access = access.With(FieldRead, *that.Read)
+	}
+	if that.Write != nil {
+		access = access.With(FieldWrite, *that.Write)
+	}
+	return access
+}
+
+// PermissionSet - named collection of object and field permissions
+type PermissionSet struct {
+	TenantId    uuid.UUID
+	Id          int64
+	GroupId     *int64
+	ApiName     string
+	Label       string
+	Description *string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time
+}
+
+type CreatePermissionSet struct {
+	ApiName     string  `json:"api_name"`
+	Label       string  `json:"label"`
+	Description *string `json:"description"`
+}
+
+func (that *CreatePermissionSet) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.ApiName, validation.Required, validation.Match(apiNamePattern)),
+		validation.Field(&that.Label, validation.Required, validation.RuneLength(1, 255)),
+	)
+}
+
+// UpdatePermissionSet - partial update, nil fields are left unchanged.
+// Description is tri-state: nil means "keep", pointer to nil means "clear".
+type UpdatePermissionSet struct {
+	ApiName     *string  `json:"api_name"`
+	Label       *string  `json:"label"`
+	Description **string `json:"description"`
+}
+
+func (that *UpdatePermissionSet) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.ApiName, validation.NilOrNotEmpty, validation.Match(apiNamePattern)),
+		validation.Field(&that.Label, validation.NilOrNotEmpty, validation.RuneLength(1, 255)),
+	)
+}
+
+type Filter struct {
+	Search  string
+	GroupId *int64
+}
+
+// ObjectPermission - object level permissions granted by the permission set
+type ObjectPermission struct {
+	TenantId        uuid.UUID
+	Id              int64
+	PermissionSetId int64
+	ObjectId        int64
+	Permissions     ObjectAccess
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// GrantObject - either Permissions or Flags must be provided.
+// Flags are applied on top of Permissions.
+type GrantObject struct {
+	ObjectId    int64         `json:"object_id"`
+	Permissions *ObjectAccess `json:"permissions"`
+	Flags       *ObjectFlags  `json:"permissions_detail"`
+}
+
+func (that *GrantObject) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.ObjectId, validation.Required),
+		validation.Field(
+			&that.Permissions,
+			validation.When(that.Flags == nil, validation.NotNil),
+			validation.Min(0),
+			validation.Max(int(ObjectAll)),
+		),
+	)
+}
+
+// UpdateObjectPermission - Permissions replaces the bitmask, Flags change individual permissions
+type UpdateObjectPermission struct {
+	Permissions *ObjectAccess `json:"permissions"`
+	Flags       *ObjectFlags  `json:"permissions_detail"`
+}
+
+func (that *UpdateObjectPermission) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(
+			&that.Permissions,
+			validation.When(that.Flags == nil, validation.NotNil),
+			validation.Min(0),
+			validation.Max(int(ObjectAll)),
+		),
+	)
+}
+
+func (that *UpdateObjectPermission) apply(access ObjectAccess) ObjectAccess {
+	if that.Permissions != nil {
+		access = *that.Permissions
+	}
+	return that.Flags.apply(access)
+}
+
+// FieldPermission - field level permissions granted by the permission set
+type FieldPermission struct {
+	TenantId        uuid.UUID
+	Id              int64
+	PermissionSetId int64
+	FieldId         int64
+	Permissions     FieldAccess
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// GrantField - either Permissions or Flags must be provided.
+// Flags are applied on top of Permissions.
+type GrantField struct {
+	FieldId     int64        `json:"field_id"`
+	Permissions *FieldAccess `json:"permissions"`
+	Flags       *FieldFlags  `json:"permissions_detail"`
+}
+
+func (that *GrantField) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.FieldId, validation.Required),
+		validation.Field(
+			&that.Permissions,
+			validation.When(that.Flags == nil, validation.NotNil),
+			validation.Min(0),
+			validation.Max(int(FieldAll)),
+		),
+	)
+}
+
+// UpdateFieldPermission - Permissions replaces the bitmask, Flags change individual permissions
+type UpdateFieldPermission struct {
+	Permissions *FieldAccess `json:"permissions"`
+	Flags       *FieldFlags  `json:"permissions_detail"`
+}
+
+func (that *UpdateFieldPermission) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(
+			&that.Permissions,
+			validation.When(that.Flags == nil, validation.NotNil),
+			validation.Min(0),
+			validation.Max(int(FieldAll)),
+		),
+	)
+}
+
+func (that *UpdateFieldPermission) apply(access FieldAccess) FieldAccess {
+	if that.Permissions != nil {
+		access = *that.Permissions
+	}
+	return that.Flags.apply(access)
+}
+
+var (
+	ErrNotFound                 = errors.New("permission set not found")
+	ErrApiNameExists            = errors.New("permission set with this api_name already exists")
+	ErrObjectNotFound           = errors.New("security object not found")
+	ErrFieldNotFound            = errors.New("security field not found")
+	ErrObjectPermissionExists   = errors.New("object permission already exists in the permission set")
+	ErrObjectPermissionNotFound = errors.New("object permission not found")
+	ErrFieldPermissionExists    = errors.New("field permission already exists in the permission set")
+	ErrFieldPermissionNotFound  = errors.New("field permission not found")
+)
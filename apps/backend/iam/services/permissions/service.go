@@ -0,0 +1,608 @@
+package permissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+const permissionSetColumns = `
+	ps.tenant_id, ps.id, ps.group_id, ps.api_name, ps.label, ps.description, ps.created_at, ps.updated_at, ps.deleted_at`
+
+const objectPermissionColumns = `
+	op.tenant_id, op.id, op.permission_set_id, op.object_id, op.permissions, op.created_at, op.updated_at`
+
+const fieldPermissionColumns = `
+	fp.tenant_id, fp.id, fp.permission_set_id, fp.field_id, fp.permissions, fp.created_at, fp.updated_at`
+
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func (that *Service) List(ctx context.Context, filter Filter, page services.Page) (*services.List[*PermissionSet], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"ps.tenant_id = $1", "ps.deleted_at IS NULL"}
+	args := []any{actor.TenantId}
+
+	if filter.Search != "" {
+		args = append(args, "%"+services.EscapeLike(filter.Search)+"%")
+		where = append(where, fmt.Sprintf("(ps.label ILIKE $%d OR ps.api_name ILIKE $%d)", len(args), len(args)))
+	}
+
+	if filter.GroupId != nil {
+		args = append(args, *filter.GroupId)
+		where = append(where, fmt.Sprintf("ps.group_id = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM security.permission_set ps WHERE %s ORDER BY ps.label, ps.id LIMIT $%d OFFSET $%d`,
+		permissionSetColumns, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	list := &services.List[*PermissionSet]{Page: page, Items: make([]*PermissionSet, 0)}
+	err = that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		set, err := scanPermissionSet(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, set)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (that *Service) Get(ctx context.Context, id int64) (*PermissionSet, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.get(ctx, actor, id, false)
+}
+
+func (that *Service) Create(ctx context.Context, request CreatePermissionSet) (set *PermissionSet, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`INSERT INTO security.permission_set (tenant_id, api_name, label, description)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`,
+			actor.TenantId, request.ApiName, request.Label, request.Description,
+		).Scan(&id)
+		if err != nil {
+			return translateError(err)
+		}
+
+		set, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return set, err
+}
+
+func (that *Service) Update(ctx context.Context, id int64, request UpdatePermissionSet) (set *PermissionSet, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		sets := make([]string, 0, 3)
+		args := []any{actor.TenantId, current.Id}
+
+		if request.ApiName != nil {
+			args = append(args, *request.ApiName)
+			sets = append(sets, fmt.Sprintf("api_name = $%d", len(args)))
+		}
+
+		if request.Label != nil {
+			args = append(args, *request.Label)
+			sets = append(sets, fmt.Sprintf("label = $%d", len(args)))
+		}
+
+		if request.Description != nil {
+			args = append(args, *request.Description)
+			sets = append(sets, fmt.Sprintf("description = $%d", len(args)))
+		}
+
+		if len(sets) != 0 {
+			_, err = that.db.Exec(
+				ctx,
+				fmt.Sprintf(`UPDATE security.permission_set SET %s WHERE tenant_id = $1 AND id = $2`, strings.Join(sets, ", ")),
+				args...,
+			)
+			if err != nil {
+				return translateError(err)
+			}
+		}
+
+		set, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return set, err
+}
+
+// Delete - soft deletes the permission set.
+// Object and field permissions of the set are removed, so it no longer grants anything.
+func (that *Service) Delete(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`DELETE FROM security.field_permissions WHERE tenant_id = $1 AND permission_set_id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete field permissions: %w", err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`DELETE FROM security.object_permissions WHERE tenant_id = $1 AND permission_set_id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete object permissions: %w", err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE security.permission_set SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete permission set: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ObjectPermissions - returns object permissions granted by the permission set
+func (that *Service) ObjectPermissions(ctx context.Context, setId int64, page services.Page) (*services.List[*ObjectPermission], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := that.get(ctx, actor, setId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER ()
+		FROM security.object_permissions op
+		JOIN security.object o ON o.tenant_id = op.tenant_id AND o.id = op.object_id
+		WHERE op.tenant_id = $1 AND op.permission_set_id = $2
+		ORDER BY o.api_name, op.id
+		LIMIT $3 OFFSET $4`,
+		objectPermissionColumns,
+	)
+
+	list := &services.List[*ObjectPermission]{Page: page, Items: make([]*ObjectPermission, 0)}
+	err = that.db.Fetch(ctx, query, actor.TenantId, set.Id, page.Limit, page.Offset())(func(rows sql.Rows) error {
+		permission, err := scanObjectPermission(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, permission)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GrantObject - grants object permissions by the permission set
+func (that *Service) GrantObject(ctx context.Context, setId int64, request GrantObject) (permission *ObjectPermission, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		set, err := that.get(ctx, actor, setId, true)
+		if err != nil {
+			return err
+		}
+
+		exists, err := that.exists(ctx, "security.object", actor, request.ObjectId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrObjectNotFound
+		}
+
+		var access ObjectAccess
+		if request.Permissions != nil {
+			access = *request.Permissions
+		}
+		access = request.Flags.apply(access)
+
+		var id int64
+		err = that.db.QueryRow(
+			ctx,
+			`INSERT INTO security.object_permissions (tenant_id, permission_set_id, object_id, permissions)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`,
+			actor.TenantId, set.Id, request.ObjectId, access,
+		).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrAlreadyExists) {
+				return ErrObjectPermissionExists
+			}
+			return err
+		}
+
+		permission, err = that.getObjectPermission(ctx, actor, id, false)
+		return err
+	})
+	return permission, err
+}
+
+func (that *Service) UpdateObjectPermission(ctx context.Context, id int64, request UpdateObjectPermission) (permission *ObjectPermission, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getObjectPermission(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE security.object_permissions SET permissions = $3 WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id, request.apply(current.Permissions),
+		)
+		if err != nil {
+			return fmt.Errorf("update object permission: %w", err)
+		}
+
+		permission, err = that.getObjectPermission(ctx, actor, id, false)
+		return err
+	})
+	return permission, err
+}
+
+func (that *Service) RevokeObjectPermission(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getObjectPermission(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`DELETE FROM security.object_permissions WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete object permission: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// FieldPermissions - returns field permissions granted by the permission set
+func (that *Service) FieldPermissions(ctx context.Context, setId int64, page services.Page) (*services.List[*FieldPermission], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := that.get(ctx, actor, setId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER ()
+		FROM security.field_permissions fp
+		JOIN security.field f ON f.tenant_id = fp.tenant_id AND f.id = fp.field_id
+		WHERE fp.tenant_id = $1 AND fp.permission_set_id = $2
+		ORDER BY f.object_id, f.api_name, fp.id
+		LIMIT $3 OFFSET $4`,
+		fieldPermissionColumns,
+	)
+
+	list := &services.List[*FieldPermission]{Page: page, Items: make([]*FieldPermission, 0)}
+	err = that.db.Fetch(ctx, query, actor.TenantId, set.Id, page.Limit, page.Offset())(func(rows sql.Rows) error {
+		permission, err := scanFieldPermission(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, permission)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GrantField - grants field permissions by the permission set
+func (that *Service) GrantField(ctx context.Context, setId int64, request GrantField) (permission *FieldPermission, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		set, err := that.get(ctx, actor, setId, true)
+		if err != nil {
+			return err
+		}
+
+		exists, err := that.exists(ctx, "security.field", actor, request.FieldId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFieldNotFound
+		}
+
+		var access FieldAccess
+		if request.Permissions != nil {
+			access = *request.Permissions
+		}
+		access = request.Flags.apply(access)
+
+		var id int64
+		err = that.db.QueryRow(
+			ctx,
+			`INSERT INTO security.field_permissions (tenant_id, permission_set_id, field_id, permissions)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`,
+			actor.TenantId, set.Id, request.FieldId, access,
+		).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrAlreadyExists) {
+				return ErrFieldPermissionExists
+			}
+			return err
+		}
+
+		permission, err = that.getFieldPermission(ctx, actor, id, false)
+		return err
+	})
+	return permission, err
+}
+
+func (that *Service) UpdateFieldPermission(ctx context.Context, id int64, request UpdateFieldPermission) (permission *FieldPermission, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getFieldPermission(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE security.field_permissions SET permissions = $3 WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id, request.apply(current.Permissions),
+		)
+		if err != nil {
+			return fmt.Errorf("update field permission: %w", err)
+		}
+
+		permission, err = that.getFieldPermission(ctx, actor, id, false)
+		return err
+	})
+	return permission, err
+}
+
+func (that *Service) RevokeFieldPermission(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getFieldPermission(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`DELETE FROM security.field_permissions WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete field permission: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (that *Service) get(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*PermissionSet, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM security.permission_set ps WHERE ps.tenant_id = $1 AND ps.id = $2 AND ps.deleted_at IS NULL`,
+		permissionSetColumns,
+	)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	set, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readPermissionSet)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, ErrNotFound
+	}
+	return set, nil
+}
+
+func (that *Service) getObjectPermission(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*ObjectPermission, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM security.object_permissions op WHERE op.tenant_id = $1 AND op.id = $2`,
+		objectPermissionColumns,
+	)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	permission, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readObjectPermission)
+	if err != nil {
+		return nil, err
+	}
+	if permission == nil {
+		return nil, ErrObjectPermissionNotFound
+	}
+	return permission, nil
+}
+
+func (that *Service) getFieldPermission(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*FieldPermission, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM security.field_permissions fp WHERE fp.tenant_id = $1 AND fp.id = $2`,
+		fieldPermissionColumns,
+	)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	permission, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readFieldPermission)
+	if err != nil {
+		return nil, err
+	}
+	if permission == nil {
+		return nil, ErrFieldPermissionNotFound
+	}
+	return permission, nil
+}
+
+func (that *Service) exists(ctx context.Context, table string, actor services.Actor, id int64) (bool, error) {
+	var exists bool
+	err := that.db.QueryRow(
+		ctx,
+		fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE tenant_id = $1 AND id = $2)`, table),
+		actor.TenantId, id,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check %s: %w", table, err)
+	}
+	return exists, nil
+}
+
+func readPermissionSet(scanner sql.Scanner) (*PermissionSet, error) {
+	return scanPermissionSet(scanner)
+}
+
+func scanPermissionSet(scanner sql.Scanner, extra ...any) (*PermissionSet, error) {
+	var set PermissionSet
+	dest := []any{
+		&set.TenantId,
+		&set.Id,
+		&set.GroupId,
+		&set.ApiName,
+		&set.Label,
+		&set.Description,
+		&set.CreatedAt,
+		&set.UpdatedAt,
+		&set.DeletedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func readObjectPermission(scanner sql.Scanner) (*ObjectPermission, error) {
+	return scanObjectPermission(scanner)
+}
+
+func scanObjectPermission(scanner sql.Scanner, extra ...any) (*ObjectPermission, error) {
+	var permission ObjectPermission
+	dest := []any{
+		&permission.TenantId,
+		&permission.Id,
+		&permission.PermissionSetId,
+		&permission.ObjectId,
+		&permission.Permissions,
+		&permission.CreatedAt,
+		&permission.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func readFieldPermission(scanner sql.Scanner) (*FieldPermission, error) {
+	return scanFieldPermission(scanner)
+}
+
+func scanFieldPermission(scanner sql.Scanner, extra ...any) (*FieldPermission, error) {
+	var permission FieldPermission
+	dest := []any{
+		&permission.TenantId,
+		&permission.Id,
+		&permission.PermissionSetId,
+		&permission.FieldId,
+		&permission.Permissions,
+		&permission.CreatedAt,
+		&permission.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrApiNameExists
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package principals
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/google/uuid"
+)
+
+type Kind string
+
+const (
+	KindUser     Kind = "user"
+	KindService  Kind = "service"
+	KindExternal Kind = "external"
+	KindSystem   Kind = "system"
+)
+
+type Principal struct {
+	TenantId  uuid.UUID
+	Id        int64
+	Kind      Kind
+	Login     string
+	SubjectId *int64 // id of the user for user principals
+	IsActive  bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type CreatePrincipal struct {
+	Kind      Kind   `json:"kind"`
+	Login     string `json:"login"`
+	SubjectId *int64 `json:"subject_id"`
+	IsActive  *bool  `json:"is_active"`
+}
+
+// Validate - user principals require subject_id, other kinds must not have it
+func (that *CreatePrincipal) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Kind, validation.Required, validation.In(KindUser, KindService, KindExternal, KindSystem)),
+		validation.Field(&that.Login, validation.Required, validation.Length(1, 255)),
+		validation.Field(
+			&that.SubjectId,
+			validation.When(that.Kind == KindUser, validation.Required).Else(validation.Nil),
+		),
+	)
+}
+
+// UpdatePrincipal - partial update, nil fields are left unchanged.
+// SubjectId is tri-state: nil means "keep", pointer to nil means "clear".
+type UpdatePrincipal struct {
+	Kind      *Kind
+	Login     *string
+	SubjectId **int64
+	IsActive  *bool
+}
+
+// apply - merges update into the current state of the principal
+func (that *UpdatePrincipal) apply(principal *Principal) CreatePrincipal {
+	result := CreatePrincipal{
+		Kind:      principal.Kind,
+		Login:     principal.Login,
+		SubjectId: principal.SubjectId,
+		IsActive:  that.IsActive,
+	}
+	if that.Kind != nil {
+		result.Kind = *that.Kind
+	}
+	if that.Login != nil {
+		result.Login = *that.Login
+	}
+	if that.SubjectId != nil {
+		result.SubjectId = *that.SubjectId
+	}
+	return result
+}
+
+type Filter struct {
+	Kind      Kind
+	IsActive  *bool
+	SubjectId *int64
+}
+
+type IdentityKind string
+
+const (
+	IdentityKindPassword IdentityKind = "password"
+	IdentityKindApiKey   IdentityKind = "api_key"
+	IdentityKindOauth    IdentityKind = "oauth"
+)
+
+// Identity - authentication method of the principal
+type Identity struct {
+	TenantId    uuid.UUID
+	Id          int64
+	PrincipalId int64
+	Kind        IdentityKind
+	Idp         string
+	Subject     string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type CreateIdentity struct {
+	PrincipalId int64        `json:"principal_id"`
+	Kind        IdentityKind `json:"kind"`
+	Idp         string       `json:"idp"`
+	Subject     string       `json:"subject"`
+}
+
+func (that *CreateIdentity) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.PrincipalId, validation.Required),
+		validation.Field(&that.Kind, validation.Required, validation.In(IdentityKindPassword, IdentityKindApiKey, IdentityKindOauth)),
+		validation.Field(&that.Idp, validation.Required, validation.Length(1, 255)),
+		validation.Field(&that.Subject, validation.Required, validation.Length(1, 255)),
+	)
+}
+
+// UpdateIdentity - partial update, nil fields are left unchanged
+type UpdateIdentity struct {
+	PrincipalId *int64
+	Kind        *IdentityKind
+	Idp         *string
+	Subject     *string
+}
+
+// apply - merges update into the current state of the identity
+func (that *UpdateIdentity) apply(identity *Identity) CreateIdentity {
+	result := CreateIdentity{
+		PrincipalId: identity.PrincipalId,
+		Kind:        identity.Kind,
+		Idp:         identity.Idp,
+		Subject:     identity.Subject,
+	}
+	if that.PrincipalId != nil {
+		result.PrincipalId = *that.PrincipalId
+	}
+	if that.Kind != nil {
+		result.Kind = *that.Kind
+	}
+	if that.Idp != nil {
+		result.Idp = *that.Idp
+	}
+	if that.Subject != nil {
+		result.Subject = *that.Subject
+	}
+	return result
+}
+
+type IdentityFilter struct {
+	PrincipalId *int64
+	Kind        IdentityKind
+	Idp         string
+}
+
+var (
+	ErrNotFound          = errors.New("principal not found")
+	ErrLoginExists       = errors.New("principal with this login already exists")
+	ErrSubjectNotFound   = errors.New("subject user not found")
+	ErrIdentityNotFound  = errors.New("identity not found")
+	ErrIdentityExists    = errors.New("identity with this idp and subject already exists")
+	ErrPrincipalNotFound = errors.New("identity principal not found")
+	ErrPrincipalInactive = errors.New("principal is inactive")
+)
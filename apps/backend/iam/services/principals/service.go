@@ -0,0 +1,497 @@
+package principals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+const principalColumns = `
+	p.tenant_id, p.id, p.kind, p.login, p.subject_id, p.is_active, p.created_at, p.updated_at`
+
+const identityColumns = `
+	i.tenant_id, i.id, i.principal_id, i.kind, i.idp, i.subject, i.created_at, i.updated_at`
+
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func (that *Service) List(ctx context.Context, filter Filter, page services.Page) (*services.List[*Principal], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"p.tenant_id = $1"}
+	args := []any{actor.TenantId}
+
+	if filter.Kind != "" {
+		args = append(args, filter.Kind)
+		where = append(where, fmt.Sprintf("p.kind = $%d", len(args)))
+	}
+
+	if filter.IsActive != nil {
+		args = append(args, *filter.IsActive)
+		where = append(where, fmt.Sprintf("p.is_active = $%d", len(args)))
+	}
+
+	if filter.SubjectId != nil {
+		args = append(args, *filter.SubjectId)
+		where = append(where, fmt.Sprintf("p.subject_id = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM iam.principal p WHERE %s ORDER BY p.login, p.id LIMIT $%d OFFSET $%d`,
+		principalColumns, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	list := &services.List[*Principal]{Page: page, Items: make([]*Principal, 0)}
+	err = that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		principal, err := scanPrincipal(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, principal)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (that *Service) Get(ctx context.Context, id int64) (*Principal, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.get(ctx, actor, id, false)
+}
+
+func (that *Service) Create(ctx context.Context, request CreatePrincipal) (principal *Principal, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := that.checkSubject(ctx, actor, request.SubjectId); err != nil {
+			return err
+		}
+
+		isActive := true
+		if request.IsActive != nil {
+			isActive = *request.IsActive
+		}
+
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`INSERT INTO iam.principal (tenant_id, kind, login, subject_id, is_active)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id`,
+			actor.TenantId, request.Kind, request.Login, request.SubjectId, isActive,
+		).Scan(&id)
+		if err != nil {
+			return translateError(err)
+		}
+
+		principal, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return principal, err
+}
+
+func (that *Service) Update(ctx context.Context, id int64, request UpdatePrincipal) (principal *Principal, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		next := request.apply(current)
+		if err := next.Validate(ctx); err != nil {
+			return err
+		}
+
+		if err := that.checkSubject(ctx, actor, next.SubjectId); err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.principal SET kind = $3, login = $4, subject_id = $5
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id, next.Kind, next.Login, next.SubjectId,
+		)
+		if err != nil {
+			return translateError(err)
+		}
+
+		if next.IsActive != nil {
+			if err := that.setActive(ctx, actor, current, *next.IsActive); err != nil {
+				return err
+			}
+		}
+
+		principal, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return principal, err
+}
+
+// SetActive - activates or deactivates the principal.
+// Deactivation revokes all identities of the principal, so they can not be used
+// for authentication anymore. Revoked identities are not restored on activation.
+func (that *Service) SetActive(ctx context.Context, id int64, active bool) (principal *Principal, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		if err := that.setActive(ctx, actor, current, active); err != nil {
+			return err
+		}
+
+		principal, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return principal, err
+}
+
+// Identities - returns active identities of the principal
+func (that *Service) Identities(ctx context.Context, id int64, page services.Page) (*services.List[*Identity], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	principal, err := that.get(ctx, actor, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.ListIdentities(ctx, IdentityFilter{PrincipalId: &principal.Id}, page)
+}
+
+func (that *Service) ListIdentities(ctx context.Context, filter IdentityFilter, page services.Page) (*services.List[*Identity], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"i.tenant_id = $1", "i.deleted_at IS NULL"}
+	args := []any{actor.TenantId}
+
+	if filter.PrincipalId != nil {
+		args = append(args, *filter.PrincipalId)
+		where = append(where, fmt.Sprintf("i.principal_id = $%d", len(args)))
+	}
+
+	if filter.Kind != "" {
+		args = append(args, filter.Kind)
+		where = append(where, fmt.Sprintf("i.kind = $%d", len(args)))
+	}
+
+	if filter.Idp != "" {
+		args = append(args, filter.Idp)
+		where = append(where, fmt.Sprintf("i.idp = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM iam.identity i WHERE %s ORDER BY i.idp, i.subject, i.id LIMIT $%d OFFSET $%d`,
+		identityColumns, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	list := &services.List[*Identity]{Page: page, Items: make([]*Identity, 0)}
+	err = that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		identity, err := scanIdentity(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, identity)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (that *Service) GetIdentity(ctx context.Context, id int64) (*Identity, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.getIdentity(ctx, actor, id, false)
+}
+
+// CreateIdentity - registers a new authentication method for an active principal
+func (that *Service) CreateIdentity(ctx context.Context, request CreateIdentity) (identity *Identity, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := that.checkPrincipal(ctx, actor, request.PrincipalId); err != nil {
+			return err
+		}
+
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`INSERT INTO iam.identity (tenant_id, principal_id, kind, idp, subject)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id`,
+			actor.TenantId, request.PrincipalId, request.Kind, request.Idp, request.Subject,
+		).Scan(&id)
+		if err != nil {
+			return translateIdentityError(err)
+		}
+
+		identity, err = that.getIdentity(ctx, actor, id, false)
+		return err
+	})
+	return identity, err
+}
+
+func (that *Service) UpdateIdentity(ctx context.Context, id int64, request UpdateIdentity) (identity *Identity, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getIdentity(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		next := request.apply(current)
+		if err := next.Validate(ctx); err != nil {
+			return err
+		}
+
+		if next.PrincipalId != current.PrincipalId {
+			if err := that.checkPrincipal(ctx, actor, next.PrincipalId); err != nil {
+				return err
+			}
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.identity SET principal_id = $3, kind = $4, idp = $5, subject = $6
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id, next.PrincipalId, next.Kind, next.Idp, next.Subject,
+		)
+		if err != nil {
+			return translateIdentityError(err)
+		}
+
+		identity, err = that.getIdentity(ctx, actor, id, false)
+		return err
+	})
+	return identity, err
+}
+
+// DeleteIdentity - revokes the identity
+func (that *Service) DeleteIdentity(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.getIdentity(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.identity SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete identity: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (that *Service) get(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*Principal, error) {
+	query := fmt.Sprintf(`SELECT %s FROM iam.principal p WHERE p.tenant_id = $1 AND p.id = $2`, principalColumns)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	principal, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readPrincipal)
+	if err != nil {
+		return nil, err
+	}
+	if principal == nil {
+		return nil, ErrNotFound
+	}
+	return principal, nil
+}
+
+func (that *Service) getIdentity(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*Identity, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM iam.identity i WHERE i.tenant_id = $1 AND i.id = $2 AND i.deleted_at IS NULL`,
+		identityColumns,
+	)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	identity, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readIdentity)
+	if err != nil {
+		return nil, err
+	}
+	if identity == nil {
+		return nil, ErrIdentityNotFound
+	}
+	return identity, nil
+}
+
+// setActive - changes status of the locked principal.
+// The outbox event is emitted by the status trigger of iam.principal.
+func (that *Service) setActive(ctx context.Context, actor services.Actor, principal *Principal, active bool) error {
+	if principal.IsActive == active {
+		return nil
+	}
+
+	if !active {
+		_, err := that.db.Exec(
+			ctx,
+			`UPDATE iam.identity SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND principal_id = $2 AND deleted_at IS NULL`,
+			actor.TenantId, principal.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("revoke identities: %w", err)
+		}
+	}
+
+	_, err := that.db.Exec(
+		ctx,
+		`UPDATE iam.principal SET is_active = $3 WHERE tenant_id = $1 AND id = $2`,
+		actor.TenantId, principal.Id, active,
+	)
+	if err != nil {
+		return fmt.Errorf("update principal status: %w", err)
+	}
+
+	return nil
+}
+
+// checkSubject - subject of the user principal must be an existing user
+func (that *Service) checkSubject(ctx context.Context, actor services.Actor, subjectId *int64) error {
+	if subjectId == nil {
+		return nil
+	}
+
+	var exists bool
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM iam."user" WHERE tenant_id = $1 AND id = $2 AND deleted_at IS NULL)`,
+		actor.TenantId, *subjectId,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check subject: %w", err)
+	}
+	if !exists {
+		return ErrSubjectNotFound
+	}
+	return nil
+}
+
+// checkPrincipal - identities can be attached to active principals only.
+// The principal row is locked to serialize with concurrent deactivation.
+func (that *Service) checkPrincipal(ctx context.Context, actor services.Actor, principalId int64) error {
+	principal, err := that.get(ctx, actor, principalId, true)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrPrincipalNotFound
+		}
+		return err
+	}
+	if !principal.IsActive {
+		return ErrPrincipalInactive
+	}
+	return nil
+}
+
+func readPrincipal(scanner sql.Scanner) (*Principal, error) {
+	return scanPrincipal(scanner)
+}
+
+func scanPrincipal(scanner sql.Scanner, extra ...any) (*Principal, error) {
+	var principal Principal
+	dest := []any{
+		&principal.TenantId,
+		&principal.Id,
+		&principal.Kind,
+		&principal.Login,
+		&principal.SubjectId,
+		&principal.IsActive,
+		&principal.CreatedAt,
+		&principal.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &principal, nil
+}
+
+func readIdentity(scanner sql.Scanner) (*Identity, error) {
+	return scanIdentity(scanner)
+}
+
+func scanIdentity(scanner sql.Scanner, extra ...any) (*Identity, error) {
+	var identity Identity
+	dest := []any{
+		&identity.TenantId,
+		&identity.Id,
+		&identity.PrincipalId,
+		&identity.Kind,
+		&identity.Idp,
+		&identity.Subject,
+		&identity.CreatedAt,
+		&identity.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrLoginExists
+	}
+	return err
+}
+
+func translateIdentityError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrIdentityExists
+	}
+	return err
+}
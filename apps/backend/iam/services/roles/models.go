@@ -0,0 +1,71 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/google/uuid"
+)
+
+var apiNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,59}$`)
+
+type Role struct {
+	TenantId   uuid.UUID
+	Id         int64
+	ExternalId *string
+	Label      string
+	ApiName    string
+	ParentId   *int64
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  *time.Time
+}
+
+type CreateRole struct {
+	Label      string  `json:"label"`
+	ApiName    string  `json:"api_name"`
+	ExternalId *string `json:"external_id"`
+	ParentId   *int64  `json:"parent_id"`
+}
+
+func (that *CreateRole) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Label, validation.Required, validation.RuneLength(1, 255)),
+		validation.Field(&that.ApiName, validation.Required, validation.Match(apiNamePattern)),
+		validation.Field(&that.ExternalId, validation.NilOrNotEmpty, validation.Length(1, 255)),
+	)
+}
+
+// UpdateRole - partial update, nil fields are left unchanged.
+// ExternalId and ParentId are tri-state: nil means "keep", pointer to nil means "clear".
+type UpdateRole struct {
+	Label      *string  `json:"label"`
+	ApiName    *string  `json:"api_name"`
+	ExternalId **string `json:"external_id"`
+	ParentId   **int64  `json:"parent_id"`
+}
+
+func (that *UpdateRole) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Label, validation.NilOrNotEmpty, validation.RuneLength(1, 255)),
+		validation.Field(&that.ApiName, validation.NilOrNotEmpty, validation.Match(apiNamePattern)),
+	)
+}
+
+type Filter struct {
+	Search   string
+	ParentId *int64
+}
+
+var (
+	ErrNotFound       = errors.New("role not found")
+	ErrApiNameExists  = errors.New("role with this api_name already exists")
+	ErrParentNotFound = errors.New("parent role not found")
+	ErrHasChildren    = errors.New("role has child roles")
+	ErrInUse          = errors.New("role is referenced by active groups")
+)
@@ -0,0 +1,328 @@
+package roles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/hierarchy"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+const roleColumns = `
+	r.tenant_id, r.id, r.external_id, r.label, r.api_name, r.parent_id, r.created_at, r.updated_at, r.deleted_at`
+
+var tree = hierarchy.NewTree("iam.role", "parent_id")
+
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func (that *Service) List(ctx context.Context, filter Filter, page services.Page) (*services.List[*Role], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"r.tenant_id = $1", "r.deleted_at IS NULL"}
+	args := []any{actor.TenantId}
+
+	if filter.Search != "" {
+		args = append(args, "%"+services.EscapeLike(filter.Search)+"%")
+		where = append(where, fmt.Sprintf("(r.label ILIKE $%d OR r.api_name ILIKE $%d)", len(args), len(args)))
+	}
+
+	if filter.ParentId != nil {
+		args = append(args, *filter.ParentId)
+		where = append(where, fmt.Sprintf("r.parent_id = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM iam.role r WHERE %s ORDER BY r.label, r.id LIMIT $%d OFFSET $%d`,
+		roleColumns, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	return that.fetchList(ctx, page, query, args...)
+}
+
+func (that *Service) Get(ctx context.Context, id int64) (*Role, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.get(ctx, actor, id, false)
+}
+
+func (that *Service) Create(ctx context.Context, request CreateRole) (role *Role, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if request.ParentId != nil {
+			if _, err := that.getParent(ctx, actor, *request.ParentId); err != nil {
+				return err
+			}
+		}
+
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`INSERT INTO iam.role (tenant_id, label, api_name, external_id, parent_id)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id`,
+			actor.TenantId, request.Label, request.ApiName, request.ExternalId, request.ParentId,
+		).Scan(&id)
+		if err != nil {
+			return translateError(err)
+		}
+
+		role, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return role, err
+}
+
+func (that *Service) Update(ctx context.Context, id int64, request UpdateRole) (role *Role, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if request.ParentId != nil {
+			if err := tree.Lock(ctx, that.db, actor.TenantId); err != nil {
+				return err
+			}
+		}
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		sets := make([]string, 0, 4)
+		args := []any{actor.TenantId, current.Id}
+
+		if request.Label != nil {
+			args = append(args, *request.Label)
+			sets = append(sets, fmt.Sprintf("label = $%d", len(args)))
+		}
+
+		if request.ApiName != nil {
+			args = append(args, *request.ApiName)
+			sets = append(sets, fmt.Sprintf("api_name = $%d", len(args)))
+		}
+
+		if request.ExternalId != nil {
+			args = append(args, *request.ExternalId)
+			sets = append(sets, fmt.Sprintf("external_id = $%d", len(args)))
+		}
+
+		if request.ParentId != nil {
+			if err := that.checkParent(ctx, actor, current, *request.ParentId); err != nil {
+				return err
+			}
+			args = append(args, *request.ParentId)
+			sets = append(sets, fmt.Sprintf("parent_id = $%d", len(args)))
+		}
+
+		if len(sets) != 0 {
+			_, err = that.db.Exec(
+				ctx,
+				fmt.Sprintf(`UPDATE iam.role SET %s WHERE tenant_id = $1 AND id = $2`, strings.Join(sets, ", ")),
+				args...,
+			)
+			if err != nil {
+				return translateError(err)
+			}
+		}
+
+		role, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return role, err
+}
+
+// SetParent - moves the role under another parent, nil makes the role top-level
+func (that *Service) SetParent(ctx context.Context, id int64, parentId *int64) (*Role, error) {
+	return that.Update(ctx, id, UpdateRole{ParentId: &parentId})
+}
+
+// Delete - soft deletes the role.
+// Roles with active children or roles referenced by active groups can not be deleted.
+func (that *Service) Delete(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := tree.Lock(ctx, that.db, actor.TenantId); err != nil {
+			return err
+		}
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		hasChildren, err := tree.HasChildren(ctx, that.db, actor.TenantId, current.Id)
+		if err != nil {
+			return err
+		}
+		if hasChildren {
+			return ErrHasChildren
+		}
+
+		var inUse bool
+		err = that.db.QueryRow(
+			ctx,
+			`SELECT EXISTS (
+				SELECT 1 FROM cluster."group"
+				WHERE tenant_id = $1 AND related_role_id = $2 AND deleted_at IS NULL
+			)`,
+			actor.TenantId, current.Id,
+		).Scan(&inUse)
+		if err != nil {
+			return fmt.Errorf("check role groups: %w", err)
+		}
+		if inUse {
+			return ErrInUse
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.role SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete role: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Children - returns child roles of the role.
+// If recursive is true, the whole subtree is returned ordered by depth.
+func (that *Service) Children(ctx context.Context, id int64, recursive bool, page services.Page) (*services.List[*Role], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := that.get(ctx, actor, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`WITH RECURSIVE %s
+		SELECT %s, count(*) OVER ()
+		FROM tree t
+		JOIN iam.role r ON r.tenant_id = $1 AND r.id = t.id
+		ORDER BY t.depth, r.label, r.id
+		LIMIT $3 OFFSET $4`,
+		tree.Descendants(recursive), roleColumns,
+	)
+
+	return that.fetchList(ctx, page, query, actor.TenantId, role.Id, page.Limit, page.Offset())
+}
+
+func (that *Service) get(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*Role, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM iam.role r WHERE r.tenant_id = $1 AND r.id = $2 AND r.deleted_at IS NULL`,
+		roleColumns,
+	)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	role, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readRole)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrNotFound
+	}
+	return role, nil
+}
+
+func (that *Service) getParent(ctx context.Context, actor services.Actor, parentId int64) (*Role, error) {
+	parent, err := that.get(ctx, actor, parentId, false)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrParentNotFound
+		}
+		return nil, err
+	}
+	return parent, nil
+}
+
+func (that *Service) checkParent(ctx context.Context, actor services.Actor, role *Role, parentId *int64) error {
+	if parentId == nil {
+		return nil
+	}
+
+	if _, err := that.getParent(ctx, actor, *parentId); err != nil {
+		return err
+	}
+
+	return tree.CheckParent(ctx, that.db, actor.TenantId, role.Id, *parentId)
+}
+
+func (that *Service) fetchList(ctx context.Context, page services.Page, query string, args ...any) (*services.List[*Role], error) {
+	list := &services.List[*Role]{Page: page, Items: make([]*Role, 0)}
+	err := that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		role, err := scanRole(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, role)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func readRole(scanner sql.Scanner) (*Role, error) {
+	return scanRole(scanner)
+}
+
+func scanRole(scanner sql.Scanner, extra ...any) (*Role, error) {
+	var role Role
+	dest := []any{
+		&role.TenantId,
+		&role.Id,
+		&role.ExternalId,
+		&role.Label,
+		&role.ApiName,
+		&role.ParentId,
+		&role.CreatedAt,
+		&role.UpdatedAt,
+		&role.DeletedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrApiNameExists
+	}
+	return err
+}
@@ -0,0 +1,10 @@
+package services
+
+import "strings"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike - escapes wildcards of the LIKE pattern, so the value is matched literally
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,211 @@
+package snapshots
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/outbox"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/google/uuid"
+)
+
+// feedEvents - outbox events streamed by the change feed
+var feedEvents = map[string]ChangeType{
+	"iam.group_member.added":                   ChangeTypeGroupJoined,
+	"iam.group_member.removed":                 ChangeTypeGroupLeft,
+	"iam.permission_set.assigned_to_group":     ChangeTypePermissionGranted,
+	"iam.permission_set.unassigned_from_group": ChangeTypePermissionRevoked,
+	"iam.object_permissions_changed":           ChangeTypePermissionChanged,
+	"iam.field_permissions_changed":            ChangeTypePermissionChanged,
+}
+
+// FeedOptions - polling of the outbox by the change feed
+type FeedOptions struct {
+	PollInterval time.Duration // Period of the outbox polling
+	BatchSize    int           // Maximum number of the outbox events read at once
+}
+
+// Feed - streams changes affecting permissions of the tenant users from the outbox.
+// Outbox ids are allocated before commit, so the feed reads only events of the transactions
+// finished before its snapshot, in order of the transaction and the id. Such events can not
+// appear behind the cursor later, and the feed reads the events of its tenant only.
+// The start is approximate, so the changes are delivered at least once.
+// Notifications of the outbox channel wake up the feed at once, polling remains as a fallback.
+type Feed struct {
+	db       sql.DB
+	listener *sql.Listener
+	options  FeedOptions
+}
+
+// NewFeed - nil listener leaves the feed to polling only
+func NewFeed(db sql.DB, listener *sql.Listener, options FeedOptions) *Feed {
+	return &Feed{db: db, listener: listener, options: options}
+}
+
+// Subscribe - delivers changes of the tenant until the context is done or delivery fails
+func (that *Feed) Subscribe(ctx context.Context, query FeedQuery, deliver func(event *FeedEvent) error) error {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := that.start(ctx, actor.TenantId, query)
+	if err != nil {
+		return err
+	}
+
+	var signal *sql.Signal
+	if that.listener != nil {
+		if signal, err = that.listener.Signal(outbox.Channel); err != nil {
+			return err
+		}
+		defer signal.Close()
+	}
+
+	eventTypes := feedEventTypesOf(query.ChangeTypes)
+
+	ticker := time.NewTicker(that.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		events, next, full, err := that.poll(ctx, actor.TenantId, eventTypes, cursor)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := deliver(event); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if full {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-signal.C():
+		case <-ticker.C:
+		}
+	}
+}
+
+// start - returns position of the feed start.
+// Transactions are not ordered by time, so the start from the time is approximate:
+// it precedes the events written after Since and the transactions in progress.
+func (that *Feed) start(ctx context.Context, tenantId uuid.UUID, query FeedQuery) (Cursor, error) {
+	if query.Cursor != nil {
+		return *query.Cursor, nil
+	}
+
+	var start Cursor
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT least(
+		            (SELECT min(o.txid)
+		             FROM bootstrap.outbox o
+		             WHERE coalesce(o.payload->>'tenant_id', o.headers->>'tenant_id') = $1::text
+		               AND o.created_at > $2::timestamptz),
+		            pg_snapshot_xmin(pg_current_snapshot())
+		        )::text::bigint`,
+		tenantId, query.Since,
+	).Scan(&start.TxId)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("get change feed start: %w", err)
+	}
+	return start, nil
+}
+
+// poll - reads the next batch of changes following the cursor.
+// Returns the changes, the cursor of the batch and whether the batch was full.
+// Rows are read completely before the changes are delivered, so a slow subscriber
+// does not hold the connection.
+func (that *Feed) poll(
+	ctx context.Context,
+	tenantId uuid.UUID,
+	eventTypes []string,
+	cursor Cursor,
+) ([]*FeedEvent, Cursor, bool, error) {
+	rows, err := that.db.Query(
+		ctx,
+		`SELECT o.txid::text::bigint,
+		        o.id,
+		        o.event_type = ANY ($4::text[]),
+		        o.event_type,
+		        o.payload->>'member_type',
+		        o.payload->>'member_id',
+		        o.payload->>'group_id',
+		        ob.api_name,
+		        o.payload->>'reason',
+		        o.headers->>'event_id',
+		        o.created_at
+		 FROM bootstrap.outbox o
+		 LEFT JOIN security.object ob
+		        ON o.aggregate_type = 'object' AND ob.tenant_id = $3::uuid AND ob.id::text = o.aggregate_id
+		 WHERE coalesce(o.payload->>'tenant_id', o.headers->>'tenant_id') = $3::text
+		   AND (o.txid, o.id) > ($1::text::xid8, $2::bigint)
+		   AND o.txid < pg_snapshot_xmin(pg_current_snapshot())
+		 ORDER BY o.txid, o.id
+		 LIMIT $5`,
+		strconv.FormatUint(cursor.TxId, 10), cursor.Id, tenantId, eventTypes, that.options.BatchSize,
+	)
+	if err != nil {
+		return nil, cursor, false, fmt.Errorf("read change feed: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	var events []*FeedEvent
+	for rows.Next() {
+		var (
+			matched    bool
+			eventType  string
+			memberType *string
+			memberId   *string
+			change     Change
+		)
+		err := rows.Scan(
+			&cursor.TxId, &cursor.Id, &matched, &eventType, &memberType, &memberId,
+			&change.GroupId, &change.ObjectName, &change.ChangeReason, &change.EventId, &change.ChangedAt,
+		)
+		if err != nil {
+			return nil, cursor, false, fmt.Errorf("scan change feed: %w", err)
+		}
+
+		count++
+		if !matched {
+			continue
+		}
+
+		change.ChangeId = strconv.FormatInt(cursor.Id, 10)
+		change.ChangeType = feedEvents[eventType]
+		if memberType != nil && *memberType == "user" && memberId != nil {
+			change.UserId = *memberId
+		}
+		events = append(events, &FeedEvent{Cursor: cursor, Change: &change})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, cursor, false, fmt.Errorf("read change feed: %w", err)
+	}
+
+	return events, cursor, count == that.options.BatchSize, nil
+}
+
+// feedEventTypesOf - outbox event types of the change types, nil change types select every event
+func feedEventTypesOf(changeTypes []ChangeType) []string {
+	eventTypes := make([]string, 0, len(feedEvents))
+	for eventType, changeType := range feedEvents {
+		if changeTypes == nil || slices.Contains(changeTypes, changeType) {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	return eventTypes
+}
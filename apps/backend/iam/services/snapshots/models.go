@@ -0,0 +1,268 @@
+package snapshots
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services/groups"
+	"github.com/adverax/metacrm/apps/backend/iam/services/permissions"
+	"github.com/adverax/metacrm/pkg/validation"
+)
+
+// MaxBulkUsers - maximum number of users in the single bulk request
+const MaxBulkUsers = 100
+
+// Query - selection of the user permissions included into the snapshot.
+// Nil ObjectNames select every object.
+type Query struct {
+	ObjectNames        []string
+	IncludeMemberships bool
+	IncludeSources     bool
+	TTL                time.Duration
+}
+
+// BulkQuery - snapshots of several users
+type BulkQuery struct {
+	Query
+	UserIds []string
+}
+
+func (that *BulkQuery) Validate(ctx context.Context) error {
+	err := validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.UserIds, validation.Required),
+	)
+	if err != nil {
+		return err
+	}
+	if len(that.UserIds) > MaxBulkUsers {
+		return ErrTooManyUsers
+	}
+	return nil
+}
+
+// GroupQuery - snapshots of the users included into the group
+type GroupQuery struct {
+	Query
+	GroupId        int64
+	IncludeMembers bool
+}
+
+// SyncQuery - recomputation of the cached permissions of the group users.
+// Empty UserIds select every user of the group.
+type SyncQuery struct {
+	GroupId      int64
+	UserIds      []string
+	ObjectNames  []string
+	ForceRefresh bool
+}
+
+// Snapshot - complete permissions of the user for the local cache of the external service
+type Snapshot struct {
+	UserId           string         `json:"user_id"`
+	TenantId         string         `json:"tenant_id"`
+	Permissions      []*ObjectGrant `json:"permissions"`
+	FieldPermissions []*FieldGrant  `json:"field_permissions"`
+	Memberships      []*Membership  `json:"group_memberships"`
+	Sources          []*Source      `json:"permission_sources"`
+	CacheInfo        *CacheInfo     `json:"cache_info"`
+	SnapshotAt       time.Time      `json:"snapshot_at"`
+	SnapshotVersion  string         `json:"snapshot_version"`
+}
+
+type ObjectGrant struct {
+	ObjectName  string                   `json:"object_api_name"`
+	ObjectId    int64                    `json:"object_id"`
+	Permissions permissions.ObjectAccess `json:"permissions"`
+	ComputedAt  time.Time                `json:"computed_at"`
+}
+
+type FieldGrant struct {
+	ObjectName  string                  `json:"object_api_name"`
+	FieldName   string                  `json:"field_api_name"`
+	ObjectId    int64                   `json:"object_id"`
+	FieldId     int64                   `json:"field_id"`
+	Permissions permissions.FieldAccess `json:"permissions"`
+	ComputedAt  time.Time               `json:"computed_at"`
+}
+
+// MembershipType - how the user became a member of the group
+type MembershipType string
+
+const (
+	MembershipTypeDirect    MembershipType = "direct"
+	MembershipTypeInherited MembershipType = "inherited"
+)
+
+// Membership - group that includes the user, groups are identified by their numeric id
+type Membership struct {
+	GroupId         string           `json:"group_record_id"`
+	GroupLabel      string           `json:"group_label"`
+	GroupApiName    string           `json:"group_api_name"`
+	GroupType       groups.GroupType `json:"group_type"`
+	MembershipType  MembershipType   `json:"membership_type"`
+	RelatedEntityId *string          `json:"related_entity_id"` // id of the role or territory
+	JoinedAt        *time.Time       `json:"joined_at"`         // nil for inherited memberships
+}
+
+// SourceType - kind of the group granting permissions
+type SourceType string
+
+const (
+	SourceTypeGroup     SourceType = "group"
+	SourceTypeRole      SourceType = "role"
+	SourceTypeTerritory SourceType = "territory"
+)
+
+// Source - group whose permission sets grant object permissions to the user.
+// Permissions are combined over the objects of the snapshot.
+type Source struct {
+	SourceType  SourceType               `json:"source_type"`
+	SourceId    string                   `json:"source_id"`
+	SourceName  string                   `json:"source_name"`
+	Permissions permissions.ObjectAccess `json:"permissions"`
+	Priority    int                      `json:"priority"`
+	GrantedAt   *time.Time               `json:"granted_at"`
+}
+
+// CacheInfo - state of the permission cache the snapshot was built from.
+// FromCache is set when every granted object permission was already cached.
+type CacheInfo struct {
+	FromCache  bool       `json:"from_cache"`
+	CachedAt   *time.Time `json:"cached_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	TtlSeconds int        `json:"ttl_seconds"`
+}
+
+type BulkSnapshots struct {
+	Snapshots     []*Snapshot `json:"snapshots"`
+	TotalUsers    int         `json:"total_users"`
+	FailedUserIds []string    `json:"failed_user_ids"`
+	GeneratedAt   time.Time   `json:"generated_at"`
+}
+
+type GroupInfo struct {
+	GroupId      string           `json:"group_record_id"`
+	GroupLabel   string           `json:"group_label"`
+	GroupApiName string           `json:"group_api_name"`
+	GroupType    groups.GroupType `json:"group_type"`
+	MemberCount  int              `json:"member_count"`
+	LastModified time.Time        `json:"last_modified"`
+}
+
+// GroupSnapshots - permissions of the users included into the group directly or transitively
+type GroupSnapshots struct {
+	Group        *GroupInfo  `json:"group_info"`
+	Members      []*Snapshot `json:"member_permissions"`
+	TotalMembers int         `json:"total_members"`
+	GeneratedAt  time.Time   `json:"generated_at"`
+}
+
+// ChangeType - kind of the change affecting permissions of the user
+type ChangeType string
+
+const (
+	ChangeTypeGroupJoined       ChangeType = "group_joined"
+	ChangeTypeGroupLeft         ChangeType = "group_left"
+	ChangeTypePermissionGranted ChangeType = "permission_granted"
+	ChangeTypePermissionRevoked ChangeType = "permission_revoked"
+	ChangeTypePermissionChanged ChangeType = "permission_changed" // object or field permissions of the object changed
+)
+
+// Change - event affecting permissions of the user
+type Change struct {
+	ChangeId     string     `json:"change_id"`
+	UserId       string     `json:"user_id"`
+	ChangeType   ChangeType `json:"change_type"`
+	GroupId      *string    `json:"group_id"`
+	ObjectName   *string    `json:"object_api_name"`
+	ChangedAt    time.Time  `json:"changed_at"`
+	ChangeReason *string    `json:"change_reason"`
+	EventId      *string    `json:"event_id"`
+}
+
+// Cursor - position of the change feed in the outbox:
+// the transaction that wrote the event and the event id
+type Cursor struct {
+	TxId uint64
+	Id   int64
+}
+
+func ParseCursor(s string) (Cursor, error) {
+	txId, id, ok := strings.Cut(s, ":")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var cursor Cursor
+	var err error
+	if cursor.TxId, err = strconv.ParseUint(txId, 10, 64); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if cursor.Id, err = strconv.ParseInt(id, 10, 64); err != nil || cursor.Id < 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+func (that Cursor) String() string {
+	return strconv.FormatUint(that.TxId, 10) + ":" + strconv.FormatInt(that.Id, 10)
+}
+
+// FeedQuery - selection of the streamed changes of the tenant.
+// Nil Cursor starts the feed from Since, nil Since starts it from now.
+// Nil ChangeTypes select every change type.
+type FeedQuery struct {
+	Cursor      *Cursor
+	Since       *time.Time
+	ChangeTypes []ChangeType
+}
+
+// FeedEvent - streamed change, the feed resumes after the Cursor
+type FeedEvent struct {
+	Cursor Cursor
+	Change *Change
+}
+
+// Changes - changes of the user permissions since the given time
+type Changes struct {
+	Changes      []*Change `json:"changes"`
+	TotalChanges int       `json:"total_changes"`
+	LastChange   time.Time `json:"last_change"`
+}
+
+// ChangeSummary - whether permissions of the user changed since the given time.
+// ChangedObjects hold ids of the groups the changes relate to.
+type ChangeSummary struct {
+	HasChanges     bool       `json:"has_changes"`
+	LastChange     *time.Time `json:"last_change"`
+	ChangedObjects []string   `json:"changed_objects"`
+	TotalChanges   int        `json:"total_changes"`
+}
+
+// SyncResult - result of the group permissions sync
+type SyncResult struct {
+	UsersSynced        int       `json:"users_synced"`
+	PermissionsUpdated int       `json:"permissions_updated"`
+	FailedUserIds      []string  `json:"failed_user_ids"`
+	SyncedAt           time.Time `json:"synced_at"`
+}
+
+// Stats - outbox statistics of the permission sync
+type Stats struct {
+	TotalEvents       int       `json:"total_events"`
+	SyncEvents        int       `json:"sync_events"`
+	FailedEvents      int       `json:"failed_events"`
+	AvgProcessingTime float64   `json:"avg_processing_time"` // seconds
+	PeriodStart       time.Time `json:"period_start"`
+	PeriodEnd         time.Time `json:"period_end"`
+}
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrGroupNotFound = errors.New("group not found")
+	ErrTooManyUsers  = errors.New("too many users in the bulk request")
+	ErrInvalidCursor = errors.New("invalid change feed cursor")
+)
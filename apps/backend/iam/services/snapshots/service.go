@@ -0,0 +1,203 @@
+package snapshots
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/access"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/google/uuid"
+)
+
+// Service - provides permission snapshots for external services that cache permissions locally.
+// Snapshot functions populate the permission cache, so every call runs in a transaction.
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// Snapshot - returns complete permissions of the user
+func (that *Service) Snapshot(ctx context.Context, userId string, query Query) (snapshot *Snapshot, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		found, err := that.fetch(
+			ctx, &snapshot,
+			`SELECT iam.get_user_permissions_snapshot($1, $2, $3::text[], $4, $5, $6::int)`,
+			actor.TenantId, userId, query.ObjectNames, query.IncludeMemberships, query.IncludeSources, ttlSeconds(query.TTL),
+		)
+		if err != nil {
+			return fmt.Errorf("get permissions snapshot: %w", err)
+		}
+		if !found {
+			return ErrUserNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// BulkSnapshots - returns complete permissions of several users, unknown users are reported as failed
+func (that *Service) BulkSnapshots(ctx context.Context, query BulkQuery) (snapshots *BulkSnapshots, err error) {
+	if err := query.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		_, err := that.fetch(
+			ctx, &snapshots,
+			`SELECT iam.get_bulk_user_permissions($1, $2, $3::text[], $4, $5, $6::int)`,
+			actor.TenantId, query.UserIds, query.ObjectNames, query.IncludeMemberships, query.IncludeSources, ttlSeconds(query.TTL),
+		)
+		if err != nil {
+			return fmt.Errorf("get bulk permissions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// GroupSnapshots - returns the group and, on demand, permissions of its users
+func (that *Service) GroupSnapshots(ctx context.Context, query GroupQuery) (snapshots *GroupSnapshots, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		found, err := that.fetch(
+			ctx, &snapshots,
+			`SELECT iam.get_group_membership_permissions($1, $2, $3::text[], $4, $5, $6::int)`,
+			actor.TenantId, strconv.FormatInt(query.GroupId, 10), query.ObjectNames, query.IncludeMembers, query.IncludeSources, ttlSeconds(query.TTL),
+		)
+		if err != nil {
+			return fmt.Errorf("get group permissions: %w", err)
+		}
+		if !found {
+			return ErrGroupNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// Changes - returns changes affecting permissions of the user since the given time
+func (that *Service) Changes(ctx context.Context, userId string, since time.Time, objectNames []string, includeMemberships bool) (changes *Changes, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		found, err := that.fetch(
+			ctx, &changes,
+			`SELECT iam.get_user_permissions_changes($1, $2, $3, $4::text[], $5)`,
+			actor.TenantId, userId, since, objectNames, includeMemberships,
+		)
+		if err != nil {
+			return fmt.Errorf("get permissions changes: %w", err)
+		}
+		if !found {
+			return ErrUserNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Changed - checks whether permissions of the user changed since the given time
+func (that *Service) Changed(ctx context.Context, userId string, since time.Time, objectNames []string) (summary *ChangeSummary, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		found, err := that.fetch(
+			ctx, &summary,
+			`SELECT iam.check_user_permissions_changed($1, $2, $3, $4::text[])`,
+			actor.TenantId, userId, since, objectNames,
+		)
+		if err != nil {
+			return fmt.Errorf("check permissions changes: %w", err)
+		}
+		if !found {
+			return ErrUserNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// SyncGroup - recomputes cached permissions of the group users
+func (that *Service) SyncGroup(ctx context.Context, query SyncQuery) (result *SyncResult, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		found, err := that.fetch(
+			ctx, &result,
+			`SELECT iam.sync_group_permissions($1, $2, $3::text[], $4::text[], $5)`,
+			actor.TenantId, strconv.FormatInt(query.GroupId, 10), query.UserIds, query.ObjectNames, query.ForceRefresh,
+		)
+		if err != nil {
+			return fmt.Errorf("sync group permissions: %w", err)
+		}
+		if !found {
+			return ErrGroupNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Stats - returns outbox statistics since the given time.
+// Nil tenant selects statistics of all tenants.
+func (that *Service) Stats(ctx context.Context, tenantId *uuid.UUID, since time.Time) (*Stats, error) {
+	var stats *Stats
+	_, err := that.fetch(ctx, &stats, `SELECT iam.get_permission_sync_stats($1::uuid, $2)`, tenantId, since)
+	if err != nil {
+		return nil, fmt.Errorf("get permission sync stats: %w", err)
+	}
+	return stats, nil
+}
+
+// fetch - decodes JSONB result of the function, reports false when the function returned NULL
+func (that *Service) fetch(ctx context.Context, dst any, query string, args ...any) (bool, error) {
+	var data []byte
+	if err := that.db.QueryRow(ctx, query, args...).Scan(&data); err != nil {
+		return false, err
+	}
+	if data == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return false, fmt.Errorf("decode result: %w", err)
+	}
+	return true, nil
+}
+
+// ttlSeconds - converts cache ttl for the snapshot functions, non-positive ttl falls back to the default
+func ttlSeconds(ttl time.Duration) int {
+	if ttl <= 0 {
+		ttl = access.DefaultCacheTTL
+	}
+	return int(ttl.Seconds())
+}
@@ -0,0 +1,67 @@
+package territories
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/google/uuid"
+)
+
+var apiNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,59}$`)
+
+type Territory struct {
+	TenantId  uuid.UUID
+	Id        int64
+	Label     string
+	ApiName   string
+	ParentId  *int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+type CreateTerritory struct {
+	Label    string `json:"label"`
+	ApiName  string `json:"api_name"`
+	ParentId *int64 `json:"parent_id"`
+}
+
+func (that *CreateTerritory) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Label, validation.Required, validation.RuneLength(1, 255)),
+		validation.Field(&that.ApiName, validation.Required, validation.Match(apiNamePattern)),
+	)
+}
+
+// UpdateTerritory - partial update, nil fields are left unchanged.
+// ParentId is tri-state: nil means "keep", pointer to nil means "clear".
+type UpdateTerritory struct {
+	Label    *string `json:"label"`
+	ApiName  *string `json:"api_name"`
+	ParentId **int64 `json:"parent_id"`
+}
+
+func (that *UpdateTerritory) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Label, validation.NilOrNotEmpty, validation.RuneLength(1, 255)),
+		validation.Field(&that.ApiName, validation.NilOrNotEmpty, validation.Match(apiNamePattern)),
+	)
+}
+
+type Filter struct {
+	Search   string
+	ParentId *int64
+}
+
+var (
+	ErrNotFound       = errors.New("territory not found")
+	ErrApiNameExists  = errors.New("territory with this api_name already exists")
+	ErrParentNotFound = errors.New("parent territory not found")
+	ErrHasChildren    = errors.New("territory has child territories")
+	ErrInUse          = errors.New("territory is referenced by active groups")
+)
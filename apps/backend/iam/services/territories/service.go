@@ -0,0 +1,322 @@
+package territories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/hierarchy"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+const territoryColumns = `
+	tr.tenant_id, tr.id, tr.label, tr.api_name, tr.parent_id, tr.created_at, tr.updated_at, tr.deleted_at`
+
+var tree = hierarchy.NewTree("iam.territory", "parent_id")
+
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func (that *Service) List(ctx context.Context, filter Filter, page services.Page) (*services.List[*Territory], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"tr.tenant_id = $1", "tr.deleted_at IS NULL"}
+	args := []any{actor.TenantId}
+
+	if filter.Search != "" {
+		args = append(args, "%"+services.EscapeLike(filter.Search)+"%")
+		where = append(where, fmt.Sprintf("(tr.label ILIKE $%d OR tr.api_name ILIKE $%d)", len(args), len(args)))
+	}
+
+	if filter.ParentId != nil {
+		args = append(args, *filter.ParentId)
+		where = append(where, fmt.Sprintf("tr.parent_id = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM iam.territory tr WHERE %s ORDER BY tr.label, tr.id LIMIT $%d OFFSET $%d`,
+		territoryColumns, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	return that.fetchList(ctx, page, query, args...)
+}
+
+func (that *Service) Get(ctx context.Context, id int64) (*Territory, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.get(ctx, actor, id, false)
+}
+
+func (that *Service) Create(ctx context.Context, request CreateTerritory) (territory *Territory, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if request.ParentId != nil {
+			if _, err := that.getParent(ctx, actor, *request.ParentId); err != nil {
+				return err
+			}
+		}
+
+		var id int64
+		err := that.db.QueryRow(
+			ctx,
+			`INSERT INTO iam.territory (tenant_id, label, api_name, parent_id)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`,
+			actor.TenantId, request.Label, request.ApiName, request.ParentId,
+		).Scan(&id)
+		if err != nil {
+			return translateError(err)
+		}
+
+		territory, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return territory, err
+}
+
+func (that *Service) Update(ctx context.Context, id int64, request UpdateTerritory) (territory *Territory, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if request.ParentId != nil {
+			if err := tree.Lock(ctx, that.db, actor.TenantId); err != nil {
+				return err
+			}
+		}
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		sets := make([]string, 0, 4)
+		args := []any{actor.TenantId, current.Id}
+
+		if request.Label != nil {
+			args = append(args, *request.Label)
+			sets = append(sets, fmt.Sprintf("label = $%d", len(args)))
+		}
+
+		if request.ApiName != nil {
+			args = append(args, *request.ApiName)
+			sets = append(sets, fmt.Sprintf("api_name = $%d", len(args)))
+		}
+
+		if request.ParentId != nil {
+			if err := that.checkParent(ctx, actor, current, *request.ParentId); err != nil {
+				return err
+			}
+			args = append(args, *request.ParentId)
+			sets = append(sets, fmt.Sprintf("parent_id = $%d", len(args)))
+		}
+
+		if len(sets) != 0 {
+			_, err = that.db.Exec(
+				ctx,
+				fmt.Sprintf(`UPDATE iam.territory SET %s WHERE tenant_id = $1 AND id = $2`, strings.Join(sets, ", ")),
+				args...,
+			)
+			if err != nil {
+				return translateError(err)
+			}
+		}
+
+		territory, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return territory, err
+}
+
+// SetParent - moves the territory under another parent, nil makes the territory top-level
+func (that *Service) SetParent(ctx context.Context, id int64, parentId *int64) (*Territory, error) {
+	return that.Update(ctx, id, UpdateTerritory{ParentId: &parentId})
+}
+
+// Delete - soft deletes the territory.
+// Territories with active children or territorys referenced by active groups can not be deleted.
+func (that *Service) Delete(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := tree.Lock(ctx, that.db, actor.TenantId); err != nil {
+			return err
+		}
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		hasChildren, err := tree.HasChildren(ctx, that.db, actor.TenantId, current.Id)
+		if err != nil {
+			return err
+		}
+		if hasChildren {
+			return ErrHasChildren
+		}
+
+		var inUse bool
+		err = that.db.QueryRow(
+			ctx,
+			`SELECT EXISTS (
+				SELECT 1 FROM cluster."group"
+				WHERE tenant_id = $1 AND related_territory_id = $2 AND deleted_at IS NULL
+			)`,
+			actor.TenantId, current.Id,
+		).Scan(&inUse)
+		if err != nil {
+			return fmt.Errorf("check territory groups: %w", err)
+		}
+		if inUse {
+			return ErrInUse
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.territory SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete territory: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Children - returns child territories of the territory.
+// If recursive is true, the whole subtree is returned ordered by depth.
+func (that *Service) Children(ctx context.Context, id int64, recursive bool, page services.Page) (*services.List[*Territory], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	territory, err := that.get(ctx, actor, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`WITH RECURSIVE %s
+		SELECT %s, count(*) OVER ()
+		FROM tree t
+		JOIN iam.territory tr ON tr.tenant_id = $1 AND tr.id = t.id
+		ORDER BY t.depth, tr.label, tr.id
+		LIMIT $3 OFFSET $4`,
+		tree.Descendants(recursive), territoryColumns,
+	)
+
+	return that.fetchList(ctx, page, query, actor.TenantId, territory.Id, page.Limit, page.Offset())
+}
+
+func (that *Service) get(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*Territory, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM iam.territory tr WHERE tr.tenant_id = $1 AND tr.id = $2 AND tr.deleted_at IS NULL`,
+		territoryColumns,
+	)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	territory, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readTerritory)
+	if err != nil {
+		return nil, err
+	}
+	if territory == nil {
+		return nil, ErrNotFound
+	}
+	return territory, nil
+}
+
+func (that *Service) getParent(ctx context.Context, actor services.Actor, parentId int64) (*Territory, error) {
+	parent, err := that.get(ctx, actor, parentId, false)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrParentNotFound
+		}
+		return nil, err
+	}
+	return parent, nil
+}
+
+func (that *Service) checkParent(ctx context.Context, actor services.Actor, territory *Territory, parentId *int64) error {
+	if parentId == nil {
+		return nil
+	}
+
+	if _, err := that.getParent(ctx, actor, *parentId); err != nil {
+		return err
+	}
+
+	return tree.CheckParent(ctx, that.db, actor.TenantId, territory.Id, *parentId)
+}
+
+func (that *Service) fetchList(ctx context.Context, page services.Page, query string, args ...any) (*services.List[*Territory], error) {
+	list := &services.List[*Territory]{Page: page, Items: make([]*Territory, 0)}
+	err := that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		territory, err := scanTerritory(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, territory)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func readTerritory(scanner sql.Scanner) (*Territory, error) {
+	return scanTerritory(scanner)
+}
+
+func scanTerritory(scanner sql.Scanner, extra ...any) (*Territory, error) {
+	var territory Territory
+	dest := []any{
+		&territory.TenantId,
+		&territory.Id,
+		&territory.Label,
+		&territory.ApiName,
+		&territory.ParentId,
+		&territory.CreatedAt,
+		&territory.UpdatedAt,
+		&territory.DeletedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &territory, nil
+}
+
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrApiNameExists
+	}
+	return err
+}
@@ -0,0 +1,18 @@
+package services
+
+import (
+	"context"
+
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+// Transact - runs action in a transaction bound to the tenant and principal of the actor.
+// The database binds the transaction to the identity set by WithActor,
+// the actor is checked beforehand to report missing tenant as ErrTenantRequired.
+func Transact(ctx context.Context, db sql.DB, action sql.Act) error {
+	if _, err := ActorFromContext(ctx); err != nil {
+		return err
+	}
+
+	return db.Transact(ctx, action)
+}
@@ -0,0 +1,68 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/adverax/metacrm/pkg/validation/is"
+	"github.com/google/uuid"
+)
+
+type User struct {
+	TenantId   uuid.UUID
+	Id         int64
+	RecordId   string
+	ExternalId *string
+	Name       string
+	Email      string
+	ManagerId  *string // record_id of the manager
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type CreateUser struct {
+	Name       string  `json:"name"`
+	Email      string  `json:"email"`
+	ExternalId *string `json:"external_id"`
+	ManagerId  *string `json:"manager_id"`
+}
+
+func (that *CreateUser) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Name, validation.Required, validation.RuneLength(1, 255)),
+		validation.Field(&that.Email, validation.Required, validation.Length(1, 255), is.EmailFormat),
+		validation.Field(&that.ExternalId, validation.NilOrNotEmpty, validation.Length(1, 255)),
+	)
+}
+
+// UpdateUser - partial update, nil fields are left unchanged.
+// ExternalId and ManagerId are tri-state: nil means "keep", pointer to nil means "clear".
+type UpdateUser struct {
+	Name       *string  `json:"name"`
+	Email      *string  `json:"email"`
+	ExternalId **string `json:"external_id"`
+	ManagerId  **string `json:"manager_id"`
+}
+
+func (that *UpdateUser) Validate(ctx context.Context) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Name, validation.NilOrNotEmpty, validation.RuneLength(1, 255)),
+		validation.Field(&that.Email, validation.NilOrNotEmpty, validation.Length(1, 255), is.EmailFormat),
+	)
+}
+
+type Filter struct {
+	Search    string
+	ManagerId string
+}
+
+var (
+	ErrNotFound        = errors.New("user not found")
+	ErrEmailExists     = errors.New("user with this email already exists")
+	ErrManagerNotFound = errors.New("manager not found")
+	ErrManagerCycle    = errors.New("manager assignment would create a cycle")
+)
@@ -0,0 +1,408 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/hierarchy"
+	"github.com/adverax/metacrm/pkg/database/sql"
+)
+
+var tree = hierarchy.NewTree(`iam."user"`, "manager_id")
+
+const userColumns = `
+	u.tenant_id, u.id, u.record_id, u.external_id, u.name, u.email, m.record_id, u.created_at, u.updated_at`
+
+const userSource = `
+	iam."user" u
+	LEFT JOIN iam."user" m ON m.tenant_id = u.tenant_id AND m.id = u.manager_id`
+
+type Service struct {
+	db sql.DB
+}
+
+func NewService(db sql.DB) *Service {
+	return &Service{db: db}
+}
+
+func (that *Service) List(ctx context.Context, filter Filter, page services.Page) (*services.List[*User], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"u.tenant_id = $1", "u.deleted_at IS NULL"}
+	args := []any{actor.TenantId}
+
+	if filter.Search != "" {
+		args = append(args, "%"+services.EscapeLike(filter.Search)+"%")
+		where = append(where, fmt.Sprintf("(u.name ILIKE $%d OR u.email ILIKE $%d)", len(args), len(args)))
+	}
+
+	if filter.ManagerId != "" {
+		args = append(args, filter.ManagerId)
+		where = append(where, fmt.Sprintf("m.record_id = $%d", len(args)))
+	}
+
+	args = append(args, page.Limit, page.Offset())
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM %s WHERE %s ORDER BY u.name, u.id LIMIT $%d OFFSET $%d`,
+		userColumns, userSource, strings.Join(where, " AND "), len(args)-1, len(args),
+	)
+
+	return that.fetchList(ctx, page, query, args...)
+}
+
+func (that *Service) Get(ctx context.Context, recordId string) (*User, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.get(ctx, actor, recordId)
+}
+
+func (that *Service) Create(ctx context.Context, request CreateUser) (user *User, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if err := that.checkEmail(ctx, actor, request.Email, 0); err != nil {
+			return err
+		}
+
+		var managerId *int64
+		if request.ManagerId != nil {
+			manager, err := that.get(ctx, actor, *request.ManagerId)
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
+					return ErrManagerNotFound
+				}
+				return err
+			}
+			managerId = &manager.Id
+		}
+
+		var recordId string
+		err := that.db.QueryRow(
+			ctx,
+			`INSERT INTO iam."user" (tenant_id, name, email, external_id, manager_id)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING record_id`,
+			actor.TenantId, request.Name, request.Email, request.ExternalId, managerId,
+		).Scan(&recordId)
+		if err != nil {
+			return translateError(err)
+		}
+
+		user, err = that.get(ctx, actor, recordId)
+		return err
+	})
+	return user, err
+}
+
+func (that *Service) Update(ctx context.Context, recordId string, request UpdateUser) (user *User, err error) {
+	if err := request.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		if request.ManagerId != nil {
+			if err := tree.Lock(ctx, that.db, actor.TenantId); err != nil {
+				return err
+			}
+		}
+
+		current, err := that.lock(ctx, actor, recordId)
+		if err != nil {
+			return err
+		}
+
+		sets := make([]string, 0, 4)
+		args := []any{actor.TenantId, current.Id}
+
+		if request.Name != nil {
+			args = append(args, *request.Name)
+			sets = append(sets, fmt.Sprintf("name = $%d", len(args)))
+		}
+
+		if request.Email != nil && *request.Email != current.Email {
+			if err := that.checkEmail(ctx, actor, *request.Email, current.Id); err != nil {
+				return err
+			}
+			args = append(args, *request.Email)
+			sets = append(sets, fmt.Sprintf("email = $%d", len(args)))
+		}
+
+		if request.ExternalId != nil {
+			args = append(args, *request.ExternalId)
+			sets = append(sets, fmt.Sprintf("external_id = $%d", len(args)))
+		}
+
+		if request.ManagerId != nil {
+			managerId, err := that.resolveManager(ctx, actor, current, *request.ManagerId)
+			if err != nil {
+				return err
+			}
+			args = append(args, managerId)
+			sets = append(sets, fmt.Sprintf("manager_id = $%d", len(args)))
+		}
+
+		if len(sets) != 0 {
+			_, err = that.db.Exec(
+				ctx,
+				fmt.Sprintf(`UPDATE iam."user" SET %s WHERE tenant_id = $1 AND id = $2`, strings.Join(sets, ", ")),
+				args...,
+			)
+			if err != nil {
+				return translateError(err)
+			}
+		}
+
+		user, err = that.get(ctx, actor, recordId)
+		return err
+	})
+	return user, err
+}
+
+// Delete - soft deletes the user and detaches its direct subordinates
+func (that *Service) Delete(ctx context.Context, recordId string) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.lock(ctx, actor, recordId)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam."user" SET manager_id = NULL
+			WHERE tenant_id = $1 AND manager_id = $2 AND deleted_at IS NULL`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("detach subordinates: %w", err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam."user" SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete user: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetManager - returns manager of the user or nil if the user has no manager
+func (that *Service) GetManager(ctx context.Context, recordId string) (*User, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := that.get(ctx, actor, recordId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.ManagerId == nil {
+		return nil, nil
+	}
+
+	return that.get(ctx, actor, *user.ManagerId)
+}
+
+// SetManager - assigns the manager of the user, nil removes the manager
+func (that *Service) SetManager(ctx context.Context, recordId string, managerId *string) (*User, error) {
+	return that.Update(ctx, recordId, UpdateUser{ManagerId: &managerId})
+}
+
+// Subordinates - returns direct and indirect subordinates of the user
+func (that *Service) Subordinates(ctx context.Context, recordId string, page services.Page) (*services.List[*User], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := that.get(ctx, actor, recordId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`WITH RECURSIVE %s
+		SELECT %s, count(*) OVER ()
+		FROM tree t
+		JOIN %s ON u.tenant_id = $1 AND u.id = t.id
+		ORDER BY t.depth, u.name, u.id
+		LIMIT $3 OFFSET $4`,
+		tree.Descendants(true), userColumns, userSource,
+	)
+
+	return that.fetchList(ctx, page, query, actor.TenantId, user.Id, page.Limit, page.Offset())
+}
+
+// InGroup - returns users belonging to the group directly or through nested groups
+func (that *Service) InGroup(ctx context.Context, groupId int64, page services.Page) (*services.List[*User], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER ()
+		FROM cluster.get_group_users($1, $2) gu
+		JOIN %s ON u.tenant_id = $1 AND u.id = gu.user_id
+		ORDER BY u.name, u.id
+		LIMIT $3 OFFSET $4`,
+		userColumns, userSource,
+	)
+
+	return that.fetchList(ctx, page, query, actor.TenantId, groupId, page.Limit, page.Offset())
+}
+
+func (that *Service) get(ctx context.Context, actor services.Actor, recordId string) (*User, error) {
+	user, err := sql.FetchModel(
+		that.db.Fetch(
+			ctx,
+			fmt.Sprintf(
+				`SELECT %s FROM %s WHERE u.tenant_id = $1 AND u.record_id = $2 AND u.deleted_at IS NULL`,
+				userColumns, userSource,
+			),
+			actor.TenantId, recordId,
+		),
+		readUser,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+func (that *Service) lock(ctx context.Context, actor services.Actor, recordId string) (*User, error) {
+	var id int64
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT id FROM iam."user"
+		WHERE tenant_id = $1 AND record_id = $2 AND deleted_at IS NULL
+		FOR UPDATE`,
+		actor.TenantId, recordId,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return that.get(ctx, actor, recordId)
+}
+
+func (that *Service) checkEmail(ctx context.Context, actor services.Actor, email string, exceptId int64) error {
+	var exists bool
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM iam."user"
+			WHERE tenant_id = $1 AND lower(email) = lower($2) AND id <> $3 AND deleted_at IS NULL
+		)`,
+		actor.TenantId, email, exceptId,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check email: %w", err)
+	}
+	if exists {
+		return ErrEmailExists
+	}
+	return nil
+}
+
+// resolveManager - validates new manager of the user and returns its internal id.
+// A manager must exist and must not be the user itself or any of its subordinates.
+// The caller holds the tree lock, so concurrent assignments can not create a cycle.
+func (that *Service) resolveManager(ctx context.Context, actor services.Actor, user *User, managerId *string) (*int64, error) {
+	if managerId == nil {
+		return nil, nil
+	}
+
+	manager, err := that.get(ctx, actor, *managerId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrManagerNotFound
+		}
+		return nil, err
+	}
+
+	err = tree.CheckParent(ctx, that.db, actor.TenantId, user.Id, manager.Id)
+	if err != nil {
+		if errors.Is(err, hierarchy.ErrCycle) {
+			return nil, ErrManagerCycle
+		}
+		return nil, err
+	}
+
+	return &manager.Id, nil
+}
+
+func (that *Service) fetchList(ctx context.Context, page services.Page, query string, args ...any) (*services.List[*User], error) {
+	list := &services.List[*User]{Page: page, Items: make([]*User, 0)}
+	err := that.db.Fetch(ctx, query, args...)(func(rows sql.Rows) error {
+		user, err := scanUser(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func readUser(scanner sql.Scanner) (*User, error) {
+	return scanUser(scanner)
+}
+
+func scanUser(scanner sql.Scanner, extra ...any) (*User, error) {
+	var user User
+	dest := []any{
+		&user.TenantId,
+		&user.Id,
+		&user.RecordId,
+		&user.ExternalId,
+		&user.Name,
+		&user.Email,
+		&user.ManagerId,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrAlreadyExists) {
+		return ErrEmailExists
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services/worker"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/adverax/metacrm/pkg/log"
+	"github.com/adverax/metacrm/pkg/webhook"
+)
+
+// Channel - notified on commit of new webhook deliveries
+const Channel = "bootstrap_webhook_delivery"
+
+// maxErrorBodyLen - part of the failed response body kept as the delivery error
+const maxErrorBodyLen = 512
+
+// DispatcherOptions - delivery of the webhooks by the dispatcher
+type DispatcherOptions struct {
+	WorkerId     string        // Identifier of the dispatcher, unique among the running dispatchers
+	BatchSize    int           // Maximum number of deliveries claimed at once
+	Concurrency  int           // Maximum number of endpoints called simultaneously
+	PollInterval time.Duration // Period of the deliveries polling, 0 disables the dispatcher
+	Timeout      time.Duration // Timeout of the endpoint call
+	LockTimeout  time.Duration // Period after which deliveries of the crashed dispatchers are processed again
+	MaxAttempts  int           // Number of attempts before the delivery is dead
+	Backoff      time.Duration // Suspension of the endpoint after the first failure, doubled on every next failure
+	MaxBackoff   time.Duration // Maximum suspension of the endpoint
+}
+
+// Dispatcher - sends claimed deliveries to the endpoints as signed POST requests.
+// Endpoint receives its deliveries one at a time in order of events,
+// any response except 2xx fails the delivery and suspends the endpoint.
+// Notifications of Channel wake up the dispatcher at once, polling remains as a fallback.
+type Dispatcher struct {
+	*worker.Worker[*Delivery]
+	service *Service
+	client  *http.Client
+	options DispatcherOptions
+	logger  log.Logger
+}
+
+// NewDispatcher - nil listener leaves the dispatcher to polling only
+func NewDispatcher(service *Service, client *http.Client, listener *sql.Listener, options DispatcherOptions, logger log.Logger) *Dispatcher {
+	dispatcher := &Dispatcher{
+		service: service,
+		client:  client,
+		options: options,
+		logger:  logger,
+	}
+	dispatcher.Worker = worker.New(
+		worker.Steps[*Delivery]{
+			Claim: func(ctx context.Context, limit int) ([]*Delivery, error) {
+				return service.Claim(ctx, options.WorkerId, limit)
+			},
+			Process: dispatcher.process,
+			Recover: func(ctx context.Context) (int, error) {
+				return service.ReleaseStuck(ctx, options.LockTimeout)
+			},
+		},
+		listener,
+		worker.Options{
+			Subject:      "webhook deliveries",
+			Channel:      Channel,
+			BatchSize:    options.BatchSize,
+			PollInterval: options.PollInterval,
+			LockTimeout:  options.LockTimeout,
+		},
+		logger,
+	)
+	return dispatcher
+}
+
+// process - sends deliveries concurrently, the batch holds at most one delivery of the endpoint
+func (that *Dispatcher) process(ctx context.Context, deliveries []*Delivery) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(that.options.Concurrency, 1))
+	for _, delivery := range deliveries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			that.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+// deliver - sends the delivery and records the result
+func (that *Dispatcher) deliver(ctx context.Context, delivery *Delivery) {
+	logger := that.logger.WithFields(log.Fields{
+		"delivery_id": delivery.Id,
+		"endpoint_id": delivery.EndpointId,
+		"event_id":    delivery.EventId,
+		"event_type":  delivery.EventType,
+		"attempt":     delivery.Attempt,
+	})
+
+	err := that.send(ctx, delivery)
+	if err != nil {
+		logger.WithError(err).Warning(ctx, "webhook delivery failed")
+
+		failed, err := that.service.Fail(
+			ctx, delivery.Id, that.options.WorkerId, that.options.MaxAttempts,
+			err.Error(), that.options.Backoff, that.options.MaxBackoff,
+		)
+		if err != nil {
+			logger.WithError(err).Error(ctx, "webhook delivery failure was not recorded")
+		} else if !failed {
+			logger.Warning(ctx, "webhook delivery lock was lost before failure")
+		} else if delivery.Attempt >= that.options.MaxAttempts {
+			logger.Error(ctx, "webhook delivery is dead")
+		}
+		return
+	}
+
+	completed, err := that.service.Complete(ctx, delivery.Id, that.options.WorkerId)
+	if err != nil {
+		logger.WithError(err).Error(ctx, "webhook delivery completion was not recorded")
+		return
+	}
+	if !completed {
+		logger.Warning(ctx, "webhook delivery lock was lost before completion")
+		return
+	}
+	logger.Debug(ctx, "webhook delivered")
+}
+
+// send - calls the endpoint, the request is signed at the moment of sending,
+// so the timestamp of the retried delivery is fresh
+func (that *Dispatcher) send(ctx context.Context, delivery *Delivery) error {
+	if that.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, that.options.Timeout)
+		defer cancel()
+	}
+
+	secret, err := that.service.options.Secrets.Open(delivery.TenantId, delivery.Secret)
+	if err != nil {
+		return err
+	}
+
+	request, err := webhook.NewRequest(
+		ctx,
+		delivery.Url,
+		[]byte(secret),
+		webhook.Message{
+			Id:    strconv.FormatInt(delivery.EventId, 10),
+			Event: delivery.EventType,
+			Body:  delivery.Body,
+		},
+		time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+
+	response, err := that.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyLen))
+		return fmt.Errorf("endpoint responded with status %d: %s", response.StatusCode, body)
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	return nil
+}
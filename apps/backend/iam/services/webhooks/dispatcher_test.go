@@ -0,0 +1,127 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/webhook"
+	"github.com/google/uuid"
+)
+
+func TestDispatcherSend(t *testing.T) {
+	box, err := NewSecretBox(bytes.Repeat([]byte{7}, SecretKeyLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantId := uuid.New()
+	secret := "whsec_test"
+	sealed, err := box.Seal(tenantId, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The endpoint verifies every request and fails the first one
+	var (
+		mu       sync.Mutex
+		received []*http.Request
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		err := webhook.Verify(
+			[]byte(secret),
+			r.Header.Get(webhook.HeaderSignature),
+			r.Header.Get(webhook.HeaderTimestamp),
+			body,
+			time.Now(),
+			webhook.DefaultTolerance,
+		)
+		if err != nil {
+			t.Errorf("signature of the attempt %d: %v", len(received)+1, err)
+		}
+		if string(body) != `{"id":1}` {
+			t.Errorf("body = %s", body)
+		}
+		received = append(received, r)
+		if len(received) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	dispatcher := &Dispatcher{
+		service: NewService(nil, Options{Secrets: box}),
+		client:  server.Client(),
+		options: DispatcherOptions{Timeout: time.Second},
+	}
+	delivery := &Delivery{
+		TenantId:  tenantId,
+		EventId:   42,
+		EventType: "iam.user.created",
+		Body:      []byte(`{"id":1}`),
+		Attempt:   1,
+		Url:       server.URL,
+		Secret:    sealed,
+	}
+
+	err = dispatcher.send(context.Background(), delivery)
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "unavailable") {
+		t.Fatalf("send() error = %v, want failure with status and body", err)
+	}
+
+	delivery.Attempt++
+	if err := dispatcher.send(context.Background(), delivery); err != nil {
+		t.Fatalf("retried send() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Fatalf("endpoint received %d requests, want 2", len(received))
+	}
+	for _, r := range received {
+		if r.Header.Get(webhook.HeaderId) != "42" || r.Header.Get(webhook.HeaderEvent) != "iam.user.created" {
+			t.Errorf("headers = %v, want id and type of the event", r.Header)
+		}
+	}
+}
+
+func TestDispatcherSendSealedWithoutKey(t *testing.T) {
+	box, err := NewSecretBox(bytes.Repeat([]byte{7}, SecretKeyLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantId := uuid.New()
+	sealed, err := box.Seal(tenantId, "whsec_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var called atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}))
+	defer server.Close()
+
+	dispatcher := &Dispatcher{
+		service: NewService(nil, Options{}),
+		client:  server.Client(),
+	}
+	err = dispatcher.send(context.Background(), &Delivery{TenantId: tenantId, Body: []byte(`{}`), Url: server.URL, Secret: sealed})
+	if err == nil {
+		t.Fatal("send() succeeded without the key of the sealed secret")
+	}
+	if called.Load() {
+		t.Error("endpoint is called without signature")
+	}
+}
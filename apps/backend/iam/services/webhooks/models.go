@@ -0,0 +1,138 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/validation"
+	"github.com/adverax/metacrm/pkg/validation/is"
+	"github.com/adverax/metacrm/pkg/webhook"
+	"github.com/google/uuid"
+)
+
+// eventTypePattern - exact event type or prefix ending with '*', e.g. "iam.user.created" or "iam.user.*"
+var eventTypePattern = regexp.MustCompile(`^([a-z][a-z0-9_]*)(\.[a-z][a-z0-9_]*)*(\.\*)?$|^\*$`)
+
+var urlPattern = regexp.MustCompile(`^https?://`)
+
+// Endpoint - subscriber of the tenant events.
+// Secret is filled only when the endpoint is created or its secret is rotated.
+type Endpoint struct {
+	TenantId    uuid.UUID
+	Id          int64
+	Url         string
+	Description *string
+	EventTypes  []string // empty list subscribes to every event
+	Secret      string
+	IsActive    bool
+	Failures    int        // number of consecutive failed deliveries
+	RetryAt     *time.Time // deliveries are suspended until this time after failure
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type CreateEndpoint struct {
+	Url         string
+	Description *string
+	EventTypes  []string
+	IsActive    *bool
+}
+
+// Validate - the url must be allowed by the targets policy
+func (that *CreateEndpoint) Validate(ctx context.Context, targets webhook.TargetPolicy) error {
+	return validation.ValidateStruct(
+		ctx, that,
+		validation.Field(&that.Url, validation.Required, validation.Length(1, 2048), is.URL, validation.Match(urlPattern), targetRule(targets)),
+		validation.Field(&that.Description, validation.NilOrNotEmpty, validation.RuneLength(1, 1000)),
+		validation.Field(&that.EventTypes, validation.Each(validation.Required, validation.Length(1, 255), validation.Match(eventTypePattern))),
+	)
+}
+
+// targetRule - checks the url and the addresses of its host against the policy
+func targetRule(targets webhook.TargetPolicy) validation.Rule {
+	return validation.By(func(ctx context.Context, value interface{}) error {
+		url, _ := value.(string)
+		err := targets.CheckURL(ctx, nil, url)
+		var dnsErr *net.DNSError
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, webhook.ErrInsecureTarget):
+			return ErrUrlInsecure
+		case errors.Is(err, webhook.ErrForbiddenTarget):
+			return ErrUrlForbidden
+		case errors.As(err, &dnsErr):
+			return ErrUrlUnresolved
+		default:
+			return err
+		}
+	})
+}
+
+// UpdateEndpoint - partial update, nil fields are left unchanged.
+// Description is tri-state: nil means "keep", pointer to nil means "clear".
+type UpdateEndpoint struct {
+	Url         *string
+	Description **string
+	EventTypes  *[]string
+	IsActive    *bool
+}
+
+// apply - merges update into the current state of the endpoint
+func (that *UpdateEndpoint) apply(endpoint *Endpoint) CreateEndpoint {
+	result := CreateEndpoint{
+		Url:         endpoint.Url,
+		Description: endpoint.Description,
+		EventTypes:  endpoint.EventTypes,
+		IsActive:    &endpoint.IsActive,
+	}
+	if that.Url != nil {
+		result.Url = *that.Url
+	}
+	if that.Description != nil {
+		result.Description = *that.Description
+	}
+	if that.EventTypes != nil {
+		result.EventTypes = *that.EventTypes
+	}
+	if that.IsActive != nil {
+		result.IsActive = that.IsActive
+	}
+	return result
+}
+
+// Delivery - event claimed for delivery to the endpoint
+type Delivery struct {
+	Id         int64
+	TenantId   uuid.UUID
+	EndpointId int64
+	EventId    int64
+	EventType  string
+	Body       json.RawMessage
+	Attempt    int // number of the current attempt, starts from 1
+	Url        string
+	Secret     string // sealed by SecretBox when the key is configured
+}
+
+// Message - body of the webhook request, payload and headers are defined by contracts/iam-events.yml
+type Message struct {
+	Id            int64           `json:"id"`
+	EventType     string          `json:"event_type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateId   string          `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Headers       json.RawMessage `json:"headers"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+var (
+	ErrNotFound = errors.New("webhook endpoint not found")
+
+	ErrUrlInsecure   = validation.NewError("validation_webhook_url_insecure", "must use https")
+	ErrUrlForbidden  = validation.NewError("validation_webhook_url_forbidden", "must not point to a loopback, link-local or private address")
+	ErrUrlUnresolved = validation.NewError("validation_webhook_url_unresolved", "host can not be resolved")
+)
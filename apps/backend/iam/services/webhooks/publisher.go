@@ -0,0 +1,62 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services/outbox"
+	"github.com/google/uuid"
+)
+
+// Publisher - outbox publisher queueing the event for delivery to the webhook endpoints of its tenant.
+// Endpoints are called later by the dispatcher, so a slow endpoint does not hold the outbox.
+// Events without tenant are not delivered.
+type Publisher struct {
+	service *Service
+}
+
+func NewPublisher(service *Service) *Publisher {
+	return &Publisher{service: service}
+}
+
+func (that *Publisher) Publish(ctx context.Context, event *outbox.Event) error {
+	var headers struct {
+		TenantId string `json:"tenant_id"`
+	}
+	if len(event.Headers) != 0 {
+		if err := json.Unmarshal(event.Headers, &headers); err != nil {
+			return fmt.Errorf("decode event headers: %w", err)
+		}
+	}
+	if headers.TenantId == "" {
+		return nil
+	}
+	tenantId, err := uuid.Parse(headers.TenantId)
+	if err != nil {
+		return fmt.Errorf("decode event tenant: %w", err)
+	}
+
+	body, err := json.Marshal(Message{
+		Id:            event.Id,
+		EventType:     event.EventType,
+		AggregateType: event.AggregateType,
+		AggregateId:   event.AggregateId,
+		OccurredAt:    event.CreatedAt,
+		Headers:       jsonOrEmpty(event.Headers),
+		Payload:       jsonOrEmpty(event.Payload),
+	})
+	if err != nil {
+		return fmt.Errorf("encode webhook message: %w", err)
+	}
+
+	_, err = that.service.Enqueue(ctx, tenantId, event.Id, event.EventType, body)
+	return err
+}
+
+func jsonOrEmpty(data json.RawMessage) json.RawMessage {
+	if len(data) == 0 {
+		return json.RawMessage(`{}`)
+	}
+	return data
+}
@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// sealedPrefix - marks the encrypted secrets, secrets without it are kept in plain text
+const sealedPrefix = "sealed:v1:"
+
+// SecretKeyLen - length of the key encrypting the endpoint secrets (AES-256)
+const SecretKeyLen = 32
+
+var ErrInvalidSealedSecret = errors.New("invalid sealed webhook secret")
+
+// SecretBox - encrypts the endpoint secrets at rest with AES-GCM.
+// The tenant is bound as additional data, so a secret can not be moved to another tenant.
+// Secrets stored in plain text before the key was configured are opened as is
+// and encrypted on the next rotation. Nil box keeps the secrets in plain text.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != SecretKeyLen {
+		return nil, fmt.Errorf("webhook secret key must be %d bytes long", SecretKeyLen)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal - returns the stored form of the secret
+func (that *SecretBox) Seal(tenantId uuid.UUID, secret string) (string, error) {
+	if that == nil {
+		return secret, nil
+	}
+
+	nonce := make([]byte, that.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate webhook secret nonce: %w", err)
+	}
+	sealed := that.aead.Seal(nonce, nonce, []byte(secret), tenantId[:])
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open - returns the secret of the stored form
+func (that *SecretBox) Open(tenantId uuid.UUID, stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, nil
+	}
+	if that == nil {
+		return "", fmt.Errorf("%w: secret key is not configured", ErrInvalidSealedSecret)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < that.aead.NonceSize() {
+		return "", ErrInvalidSealedSecret
+	}
+	nonce, ciphertext := sealed[:that.aead.NonceSize()], sealed[that.aead.NonceSize():]
+	secret, err := that.aead.Open(nil, nonce, ciphertext, tenantId[:])
+	if err != nil {
+		return "", ErrInvalidSealedSecret
+	}
+	return string(secret), nil
+}
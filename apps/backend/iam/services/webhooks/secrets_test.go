@@ -0,0 +1,75 @@
+package webhooks
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox(bytes.Repeat([]byte{7}, SecretKeyLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenantId := uuid.New()
+	secret := "whsec_test"
+
+	sealed, err := box.Seal(tenantId, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, sealedPrefix) || strings.Contains(sealed, secret) {
+		t.Fatalf("secret is not sealed: %q", sealed)
+	}
+
+	t.Run("open", func(t *testing.T) {
+		opened, err := box.Open(tenantId, sealed)
+		if err != nil || opened != secret {
+			t.Fatalf("Open() = %q, %v, want %q", opened, err, secret)
+		}
+	})
+
+	t.Run("other tenant", func(t *testing.T) {
+		if _, err := box.Open(uuid.New(), sealed); !errors.Is(err, ErrInvalidSealedSecret) {
+			t.Fatalf("Open() error = %v, want %v", err, ErrInvalidSealedSecret)
+		}
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		other, err := NewSecretBox(bytes.Repeat([]byte{8}, SecretKeyLen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := other.Open(tenantId, sealed); !errors.Is(err, ErrInvalidSealedSecret) {
+			t.Fatalf("Open() error = %v, want %v", err, ErrInvalidSealedSecret)
+		}
+	})
+
+	t.Run("plain text", func(t *testing.T) {
+		opened, err := box.Open(tenantId, secret)
+		if err != nil || opened != secret {
+			t.Fatalf("Open() = %q, %v, want %q", opened, err, secret)
+		}
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		var none *SecretBox
+		stored, err := none.Seal(tenantId, secret)
+		if err != nil || stored != secret {
+			t.Fatalf("Seal() = %q, %v, want %q", stored, err, secret)
+		}
+		if _, err := none.Open(tenantId, sealed); !errors.Is(err, ErrInvalidSealedSecret) {
+			t.Fatalf("Open() error = %v, want %v", err, ErrInvalidSealedSecret)
+		}
+	})
+
+	t.Run("key length", func(t *testing.T) {
+		if _, err := NewSecretBox([]byte("short")); err == nil {
+			t.Fatal("NewSecretBox() accepted short key")
+		}
+	})
+}
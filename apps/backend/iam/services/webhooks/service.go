@@ -0,0 +1,371 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/adverax/metacrm/pkg/webhook"
+	"github.com/google/uuid"
+)
+
+const endpointColumns = `
+	e.tenant_id, e.id, e.url, e.description, e.event_types, e.is_active, e.failures, e.retry_at, e.created_at, e.updated_at`
+
+// secretPrefix - makes secrets recognizable by secret scanners
+const secretPrefix = "whsec_"
+
+const secretLen = 32
+
+// Options - protection of the endpoints
+type Options struct {
+	Targets webhook.TargetPolicy // Endpoints the webhooks may be sent to
+	Secrets *SecretBox           // Encryption of the endpoint secrets at rest, nil keeps them in plain text
+}
+
+// Service - manages webhook endpoints of the tenant and deliveries of events to them.
+// Deliveries are shared by all tenants, so their processing works outside of the tenant context.
+type Service struct {
+	db      sql.DB
+	options Options
+}
+
+func NewService(db sql.DB, options Options) *Service {
+	return &Service{db: db, options: options}
+}
+
+func (that *Service) List(ctx context.Context, page services.Page) (*services.List[*Endpoint], error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		`SELECT %s, count(*) OVER () FROM iam.webhook_endpoint e
+		WHERE e.tenant_id = $1 AND e.deleted_at IS NULL
+		ORDER BY e.id LIMIT $2 OFFSET $3`,
+		endpointColumns,
+	)
+
+	list := &services.List[*Endpoint]{Page: page, Items: make([]*Endpoint, 0)}
+	err = that.db.Fetch(ctx, query, actor.TenantId, page.Limit, page.Offset())(func(rows sql.Rows) error {
+		endpoint, err := scanEndpoint(rows, &list.Total)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, endpoint)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (that *Service) Get(ctx context.Context, id int64) (*Endpoint, error) {
+	actor, err := services.ActorFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return that.get(ctx, actor, id, false)
+}
+
+// Create - registers the endpoint, the returned endpoint holds the generated secret
+func (that *Service) Create(ctx context.Context, request CreateEndpoint) (endpoint *Endpoint, err error) {
+	if err := request.Validate(ctx, that.options.Targets); err != nil {
+		return nil, err
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		sealed, err := that.options.Secrets.Seal(actor.TenantId, secret)
+		if err != nil {
+			return err
+		}
+
+		isActive := true
+		if request.IsActive != nil {
+			isActive = *request.IsActive
+		}
+
+		var id int64
+		err = that.db.QueryRow(
+			ctx,
+			`INSERT INTO iam.webhook_endpoint (tenant_id, url, description, event_types, secret, is_active)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`,
+			actor.TenantId, request.Url, request.Description, eventTypesOf(request.EventTypes), sealed, isActive,
+		).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("create webhook endpoint: %w", err)
+		}
+
+		endpoint, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint.Secret = secret
+	return endpoint, nil
+}
+
+func (that *Service) Update(ctx context.Context, id int64, request UpdateEndpoint) (endpoint *Endpoint, err error) {
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		next := request.apply(current)
+		if err := next.Validate(ctx, that.options.Targets); err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.webhook_endpoint SET url = $3, description = $4, event_types = $5, is_active = $6
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id, next.Url, next.Description, eventTypesOf(next.EventTypes), *next.IsActive,
+		)
+		if err != nil {
+			return fmt.Errorf("update webhook endpoint: %w", err)
+		}
+
+		endpoint, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	return endpoint, err
+}
+
+// RotateSecret - replaces the secret of the endpoint, the returned endpoint holds the new secret.
+// Deliveries in flight may still be signed with the previous secret.
+func (that *Service) RotateSecret(ctx context.Context, id int64) (endpoint *Endpoint, err error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		sealed, err := that.options.Secrets.Seal(actor.TenantId, secret)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.webhook_endpoint SET secret = $3 WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id, sealed,
+		)
+		if err != nil {
+			return fmt.Errorf("rotate webhook endpoint secret: %w", err)
+		}
+
+		endpoint, err = that.get(ctx, actor, id, false)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint.Secret = secret
+	return endpoint, nil
+}
+
+// Delete - soft deletes the endpoint, its undelivered deliveries are dropped
+func (that *Service) Delete(ctx context.Context, id int64) error {
+	return services.Transact(ctx, that.db, func(ctx context.Context) error {
+		actor, _ := services.ActorFromContext(ctx)
+
+		current, err := that.get(ctx, actor, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`UPDATE iam.webhook_endpoint SET deleted_at = now(), deleted_by_principal_id = bootstrap.current_principal_id()
+			WHERE tenant_id = $1 AND id = $2`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete webhook endpoint: %w", err)
+		}
+
+		_, err = that.db.Exec(
+			ctx,
+			`DELETE FROM bootstrap.webhook_delivery
+			WHERE tenant_id = $1 AND endpoint_id = $2 AND status = 'pending'`,
+			actor.TenantId, current.Id,
+		)
+		if err != nil {
+			return fmt.Errorf("delete webhook deliveries: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Enqueue - creates deliveries of the event to the subscribed endpoints of the tenant.
+// Repeated calls for the same event create nothing.
+func (that *Service) Enqueue(ctx context.Context, tenantId uuid.UUID, eventId int64, eventType string, body []byte) (int, error) {
+	var created int
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT bootstrap.enqueue_webhook_deliveries($1, $2, $3, $4)`,
+		tenantId, eventId, eventType, body,
+	).Scan(&created)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue webhook deliveries: %w", err)
+	}
+	return created, nil
+}
+
+// Claim - locks the oldest pending delivery of the endpoints that are not in backoff.
+// Secrets of the deliveries are left sealed until they are sent.
+func (that *Service) Claim(ctx context.Context, workerId string, limit int) ([]*Delivery, error) {
+	rows, err := that.db.Query(
+		ctx,
+		`SELECT id, tenant_id, endpoint_id, event_id, event_type, body, attempt, url, secret
+		 FROM bootstrap.claim_webhook_deliveries($1, $2)`,
+		workerId, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*Delivery
+	for rows.Next() {
+		var delivery Delivery
+		err := rows.Scan(
+			&delivery.Id, &delivery.TenantId, &delivery.EndpointId, &delivery.EventId, &delivery.EventType,
+			&delivery.Body, &delivery.Attempt, &delivery.Url, &delivery.Secret,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Complete - marks the delivery as delivered and resets backoff of the endpoint,
+// reports false when the worker lost the lock
+func (that *Service) Complete(ctx context.Context, id int64, workerId string) (bool, error) {
+	var completed bool
+	err := that.db.QueryRow(ctx, `SELECT bootstrap.mark_webhook_delivery_completed($1, $2)`, id, workerId).Scan(&completed)
+	if err != nil {
+		return false, fmt.Errorf("complete webhook delivery: %w", err)
+	}
+	return completed, nil
+}
+
+// Fail - schedules the delivery for retry or marks it dead after maxAttempts
+// and suspends the endpoint, reports false when the worker lost the lock
+func (that *Service) Fail(ctx context.Context, id int64, workerId string, maxAttempts int, reason string, backoff, maxBackoff time.Duration) (bool, error) {
+	var failed bool
+	err := that.db.QueryRow(
+		ctx,
+		`SELECT bootstrap.mark_webhook_delivery_failed($1, $2, $3, $4, $5, $6)`,
+		id, workerId, maxAttempts, reason, backoff, maxBackoff,
+	).Scan(&failed)
+	if err != nil {
+		return false, fmt.Errorf("fail webhook delivery: %w", err)
+	}
+	return failed, nil
+}
+
+// ReleaseStuck - returns deliveries locked longer than lockTimeout to processing by any worker
+func (that *Service) ReleaseStuck(ctx context.Context, lockTimeout time.Duration) (int, error) {
+	var released int
+	err := that.db.QueryRow(ctx, `SELECT bootstrap.release_stuck_webhook_deliveries($1)`, lockTimeout).Scan(&released)
+	if err != nil {
+		return 0, fmt.Errorf("release stuck webhook deliveries: %w", err)
+	}
+	return released, nil
+}
+
+func (that *Service) get(ctx context.Context, actor services.Actor, id int64, forUpdate bool) (*Endpoint, error) {
+	query := fmt.Sprintf(
+		`SELECT %s FROM iam.webhook_endpoint e WHERE e.tenant_id = $1 AND e.id = $2 AND e.deleted_at IS NULL`,
+		endpointColumns,
+	)
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	endpoint, err := sql.FetchModel(that.db.Fetch(ctx, query, actor.TenantId, id), readEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil {
+		return nil, ErrNotFound
+	}
+	return endpoint, nil
+}
+
+func readEndpoint(scanner sql.Scanner) (*Endpoint, error) {
+	return scanEndpoint(scanner)
+}
+
+func scanEndpoint(scanner sql.Scanner, extra ...any) (*Endpoint, error) {
+	var endpoint Endpoint
+	dest := []any{
+		&endpoint.TenantId,
+		&endpoint.Id,
+		&endpoint.Url,
+		&endpoint.Description,
+		&endpoint.EventTypes,
+		&endpoint.IsActive,
+		&endpoint.Failures,
+		&endpoint.RetryAt,
+		&endpoint.CreatedAt,
+		&endpoint.UpdatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// eventTypesOf - empty list is stored as empty array, NULL is not allowed
+func eventTypesOf(eventTypes []string) []string {
+	if eventTypes == nil {
+		return []string{}
+	}
+	return eventTypes
+}
+
+// newSecret - returns random secret signing the deliveries of the endpoint
+func newSecret() (string, error) {
+	buf := make([]byte, secretLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
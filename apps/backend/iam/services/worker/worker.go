@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/adverax/metacrm/pkg/log"
+)
+
+// Options - polling of the claimed items by the worker
+type Options struct {
+	Subject      string        // Items named in the log messages, e.g. "outbox events"
+	Channel      string        // Channel notified on commit of new items
+	BatchSize    int           // Maximum number of items claimed at once
+	PollInterval time.Duration // Period of the polling, 0 disables the worker
+	LockTimeout  time.Duration // Period of the recovery of the items locked by crashed workers, 0 disables it
+}
+
+// Steps - processing of the items
+type Steps[T any] struct {
+	Claim   func(ctx context.Context, limit int) ([]T, error) // locks the due items
+	Process func(ctx context.Context, batch []T)              // processes the claimed items, records their results
+	Recover func(ctx context.Context) (int, error)            // releases the items of crashed workers
+}
+
+// Worker - claims and processes batches of items until none is due.
+// Notifications of the channel wake up the worker at once, polling remains as a fallback.
+// The claimed batch is processed before the worker stops,
+// so processing is bounded by the lock timeout instead of the worker context.
+type Worker[T any] struct {
+	steps    Steps[T]
+	listener *sql.Listener
+	options  Options
+	logger   log.Logger
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// New - nil listener leaves the worker to polling only
+func New[T any](steps Steps[T], listener *sql.Listener, options Options, logger log.Logger) *Worker[T] {
+	return &Worker[T]{
+		steps:    steps,
+		listener: listener,
+		options:  options,
+		logger:   logger,
+	}
+}
+
+// Start - starts worker loop in background
+func (that *Worker[T]) Start(ctx context.Context) error {
+	if that.options.PollInterval <= 0 {
+		return nil
+	}
+
+	var signal *sql.Signal
+	if that.listener != nil {
+		var err error
+		if signal, err = that.listener.Signal(that.options.Channel); err != nil {
+			return err
+		}
+	}
+
+	ctx, that.cancel = context.WithCancel(ctx)
+	that.wg.Add(1)
+	go that.serve(ctx, signal)
+	return nil
+}
+
+// Stop - stops worker loop and waits until the claimed items are processed
+func (that *Worker[T]) Stop() {
+	if that.cancel == nil {
+		return
+	}
+
+	that.cancel()
+	that.wg.Wait()
+}
+
+func (that *Worker[T]) serve(ctx context.Context, signal *sql.Signal) {
+	defer that.wg.Done()
+	defer signal.Close()
+
+	ticker := time.NewTicker(that.options.PollInterval)
+	defer ticker.Stop()
+
+	var recovery <-chan time.Time
+	if that.options.LockTimeout > 0 {
+		recoveryTicker := time.NewTicker(that.options.LockTimeout)
+		defer recoveryTicker.Stop()
+		recovery = recoveryTicker.C
+		that.recover(ctx)
+	}
+
+	for {
+		that.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-recovery:
+			that.recover(ctx)
+		case <-signal.C():
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain - processes batches until no item is due
+func (that *Worker[T]) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := that.steps.Claim(ctx, that.options.BatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				that.logger.WithError(err).Error(ctx, that.options.Subject+" claim failed")
+			}
+			return
+		}
+
+		that.steps.Process(context.WithoutCancel(ctx), batch)
+
+		if len(batch) < that.options.BatchSize {
+			return
+		}
+	}
+}
+
+// recover - returns items of the crashed workers to processing
+func (that *Worker[T]) recover(ctx context.Context) {
+	released, err := that.steps.Recover(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			that.logger.WithError(err).Error(ctx, "stuck "+that.options.Subject+" release failed")
+		}
+		return
+	}
+	if released > 0 {
+		that.logger.
+			WithFields(log.Fields{"released": released}).
+			Warning(ctx, "stuck "+that.options.Subject+" released")
+	}
+}
@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/log"
+)
+
+type recordingExporter struct {
+	mx       sync.Mutex
+	messages []string
+}
+
+func (that *recordingExporter) Export(ctx context.Context, entry *log.Entry) {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+	that.messages = append(that.messages, entry.Message)
+}
+
+func (that *recordingExporter) contains(message string) bool {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+	for _, m := range that.messages {
+		if m == message {
+			return true
+		}
+	}
+	return false
+}
+
+func newLogger(t *testing.T) (log.Logger, *recordingExporter) {
+	exporter := &recordingExporter{}
+	logger, err := log.NewBuilder().WithExporter(exporter).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger, exporter
+}
+
+func TestWorkerDrainsFullBatches(t *testing.T) {
+	logger, _ := newLogger(t)
+
+	var mx sync.Mutex
+	pending := []int{1, 2, 3, 4, 5}
+	var processed []int
+	drained := make(chan struct{})
+
+	w := New(
+		Steps[int]{
+			Claim: func(ctx context.Context, limit int) ([]int, error) {
+				mx.Lock()
+				defer mx.Unlock()
+				batch := pending[:min(limit, len(pending))]
+				pending = pending[len(batch):]
+				return batch, nil
+			},
+			Process: func(ctx context.Context, batch []int) {
+				mx.Lock()
+				defer mx.Unlock()
+				processed = append(processed, batch...)
+				if len(batch) < 2 {
+					close(drained)
+				}
+			},
+			Recover: func(ctx context.Context) (int, error) { return 0, nil },
+		},
+		nil,
+		Options{Subject: "items", BatchSize: 2, PollInterval: time.Hour},
+		logger,
+	)
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not drain the items")
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+	if len(processed) != 5 {
+		t.Fatalf("processed %v, want every item", processed)
+	}
+}
+
+func TestWorkerCompletesBatchOnStop(t *testing.T) {
+	logger, _ := newLogger(t)
+
+	claimed := make(chan struct{})
+	var completed bool
+
+	w := New(
+		Steps[int]{
+			Claim: func(ctx context.Context, limit int) ([]int, error) {
+				return []int{1}, nil
+			},
+			Process: func(ctx context.Context, batch []int) {
+				close(claimed)
+				time.Sleep(50 * time.Millisecond)
+				if ctx.Err() == nil {
+					completed = true
+				}
+			},
+			Recover: func(ctx context.Context) (int, error) { return 0, nil },
+		},
+		nil,
+		Options{Subject: "items", BatchSize: 10, PollInterval: time.Hour},
+		logger,
+	)
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	<-claimed
+	w.Stop()
+	if !completed {
+		t.Fatal("claimed batch was cancelled by stop")
+	}
+}
+
+func TestWorkerRecoversAndLogsFailures(t *testing.T) {
+	logger, exporter := newLogger(t)
+
+	w := New(
+		Steps[int]{
+			Claim: func(ctx context.Context, limit int) ([]int, error) {
+				return nil, errors.New("connection refused")
+			},
+			Process: func(ctx context.Context, batch []int) {},
+			Recover: func(ctx context.Context) (int, error) { return 3, nil },
+		},
+		nil,
+		Options{Subject: "items", BatchSize: 10, PollInterval: time.Hour, LockTimeout: time.Hour},
+		logger,
+	)
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, message := range []string{"stuck items released", "items claim failed"} {
+		for !exporter.contains(message) {
+			if time.Now().After(deadline) {
+				t.Fatalf("message %q is not logged", message)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestWorkerDisabled(t *testing.T) {
+	logger, _ := newLogger(t)
+
+	w := New(
+		Steps[int]{
+			Claim: func(ctx context.Context, limit int) ([]int, error) {
+				t.Error("disabled worker claimed items")
+				return nil, nil
+			},
+		},
+		nil,
+		Options{Subject: "items", BatchSize: 10},
+		logger,
+	)
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	w.Stop()
+}
@@ -0,0 +1,145 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/auth"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/adverax/metacrm/pkg/jwt"
+)
+
+const (
+	testEmail    = "alice@example.com"
+	testPassword = "correct horse battery"
+)
+
+func newAuthService(t *testing.T, db sql.DB) *auth.Service {
+	t.Helper()
+	return newAuthServiceAt(t, db, time.Now)
+}
+
+// newAuthServiceAt - the access tokens are issued at the time of the clock
+func newAuthServiceAt(t *testing.T, db sql.DB, clock func() time.Time) *auth.Service {
+	t.Helper()
+
+	key, err := jwt.GenerateKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := jwt.NewKeySet(key)
+
+	issuer, err := jwt.NewIssuerBuilder().WithKeys(keys).WithTTL(time.Hour).WithClock(clock).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := jwt.NewVerifierBuilder().WithKeys(keys).WithGenerations(auth.NewGenerations(db)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return auth.NewService(db, auth.Options{RefreshTokenTTL: time.Hour, ResetTokenTTL: time.Hour}, issuer, verifier)
+}
+
+// register - registers the test user in the tenant of the context
+func register(t *testing.T, ctx context.Context, service *auth.Service) {
+	t.Helper()
+	_, err := service.Register(ctx, auth.Registration{Name: "Alice", Email: testEmail, Password: testPassword})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	service := newAuthService(t, testDB(t))
+	ctx := newTenant()
+	register(t, ctx, service)
+
+	first, err := service.Login(ctx, auth.Credentials{Email: testEmail, Password: testPassword})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	second, err := service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token is not rotated")
+	}
+	if _, err := service.Authenticate(ctx, second.AccessToken); err != nil {
+		t.Fatalf("authenticate by the refreshed access token: %v", err)
+	}
+
+	if _, err := service.Refresh(ctx, first.RefreshToken); !errors.Is(err, auth.ErrTokenReused) {
+		t.Fatalf("reused refresh token: got %v, want ErrTokenReused", err)
+	}
+
+	// The session is revoked, so the tokens of the legitimate client are rejected as well
+	if _, err := service.Refresh(ctx, second.RefreshToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("refresh of the revoked session: got %v, want ErrInvalidToken", err)
+	}
+	if _, err := service.Authenticate(ctx, second.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("access token of the revoked session: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestResetTokenExpiry(t *testing.T) {
+	db := testDB(t)
+	service := newAuthService(t, db)
+	ctx := newTenant()
+	actor, _ := services.ActorFromContext(ctx)
+	register(t, ctx, service)
+
+	// resetToken - requests the reset and returns the token handed over to the mailer
+	resetToken := func() string {
+		t.Helper()
+		if err := service.ForgotPassword(ctx, auth.PasswordResetRequest{Email: testEmail}); err != nil {
+			t.Fatalf("forgot password: %v", err)
+		}
+		var token string
+		err := db.QueryRow(
+			ctx,
+			`SELECT reset_token FROM iam.password_reset_mail WHERE tenant_id = $1 ORDER BY id DESC LIMIT 1`,
+			actor.TenantId,
+		).Scan(&token)
+		if err != nil {
+			t.Fatalf("read reset mail: %v", err)
+		}
+		return token
+	}
+
+	expired := resetToken()
+	_, err := db.Exec(
+		ctx,
+		`UPDATE iam.password_reset_token SET expires_at = now() - interval '1 second' WHERE tenant_id = $1`,
+		actor.TenantId,
+	)
+	if err != nil {
+		t.Fatalf("expire reset token: %v", err)
+	}
+
+	const newPassword = "new correct horse battery"
+	err = service.ResetPassword(ctx, auth.PasswordReset{Token: expired, Password: newPassword})
+	if !errors.Is(err, auth.ErrInvalidResetToken) {
+		t.Fatalf("expired reset token: got %v, want ErrInvalidResetToken", err)
+	}
+
+	token := resetToken()
+	if err := service.ResetPassword(ctx, auth.PasswordReset{Token: token, Password: newPassword}); err != nil {
+		t.Fatalf("reset password: %v", err)
+	}
+	err = service.ResetPassword(ctx, auth.PasswordReset{Token: token, Password: testPassword})
+	if !errors.Is(err, auth.ErrInvalidResetToken) {
+		t.Errorf("used reset token: got %v, want ErrInvalidResetToken", err)
+	}
+
+	if _, err := service.Login(ctx, auth.Credentials{Email: testEmail, Password: newPassword}); err != nil {
+		t.Errorf("login by the new password: %v", err)
+	}
+}
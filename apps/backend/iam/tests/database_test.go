@@ -0,0 +1,80 @@
+//go:build integration
+
+// Package tests - service level tests against the database of DB_URL_TEST,
+// e.g. the postgres-test container of docker-compose.dev.yaml (make test_integration_local).
+// The migrations are applied before the first test, every test works in its own tenant,
+// so the tests do not see the data of each other.
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/pkg/database/sql"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// testDB - connects to the migrated test database, the test is skipped without DB_URL_TEST
+func testDB(t *testing.T) sql.DB {
+	t.Helper()
+
+	url := os.Getenv("DB_URL_TEST")
+	if url == "" {
+		t.Skip("DB_URL_TEST is not set")
+	}
+
+	db, err := sql.NewBuilder().
+		WithDSN(url).
+		WithIdentityRequired(true).
+		Build()
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	migrateOnce.Do(func() {
+		migrateErr = migrateUp(db)
+	})
+	if migrateErr != nil {
+		t.Fatal(migrateErr)
+	}
+	return db
+}
+
+func migrateUp(db sql.DB) error {
+	driver, err := pgx.WithInstance(stdlib.OpenDBFromPool(db.Pool()), &pgx.Config{})
+	if err != nil {
+		return fmt.Errorf("create migration driver: %w", err)
+	}
+	defer func() {
+		_ = driver.Close()
+	}()
+
+	m, err := migrate.NewWithDatabaseInstance("file://../database/migrations", "postgres", driver)
+	if err != nil {
+		return fmt.Errorf("create migration instance: %w", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("apply migrations: %w", err)
+	}
+	return nil
+}
+
+// newTenant - context of the new tenant, the requests are anonymous
+func newTenant() context.Context {
+	return services.WithActor(context.Background(), services.Actor{TenantId: uuid.New()})
+}
@@ -0,0 +1,161 @@
+//go:build integration
+
+package tests
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/hierarchy"
+	"github.com/adverax/metacrm/apps/backend/iam/services/roles"
+	"github.com/adverax/metacrm/apps/backend/iam/services/territories"
+	"github.com/adverax/metacrm/apps/backend/iam/services/users"
+)
+
+func TestRoleHierarchyCycles(t *testing.T) {
+	service := roles.NewService(testDB(t))
+	ctx := newTenant()
+
+	create := func(apiName string, parentId *int64) *roles.Role {
+		t.Helper()
+		role, err := service.Create(ctx, roles.CreateRole{Label: apiName, ApiName: apiName, ParentId: parentId})
+		if err != nil {
+			t.Fatalf("create role %s: %v", apiName, err)
+		}
+		return role
+	}
+
+	// root <- middle <- leaf
+	root := create("root", nil)
+	middle := create("middle", &root.Id)
+	leaf := create("leaf", &middle.Id)
+
+	if _, err := service.SetParent(ctx, root.Id, &root.Id); !errors.Is(err, hierarchy.ErrCycle) {
+		t.Errorf("role under itself: got %v, want ErrCycle", err)
+	}
+	if _, err := service.SetParent(ctx, root.Id, &middle.Id); !errors.Is(err, hierarchy.ErrCycle) {
+		t.Errorf("role under its child: got %v, want ErrCycle", err)
+	}
+	if _, err := service.SetParent(ctx, root.Id, &leaf.Id); !errors.Is(err, hierarchy.ErrCycle) {
+		t.Errorf("role under its descendant: got %v, want ErrCycle", err)
+	}
+
+	moved, err := service.SetParent(ctx, leaf.Id, &root.Id)
+	if err != nil {
+		t.Fatalf("move leaf under root: %v", err)
+	}
+	if moved.ParentId == nil || *moved.ParentId != root.Id {
+		t.Errorf("leaf parent = %v, want %d", moved.ParentId, root.Id)
+	}
+	if _, err := service.SetParent(ctx, middle.Id, &leaf.Id); err != nil {
+		t.Errorf("move middle under former descendant: %v", err)
+	}
+}
+
+func TestTerritoryHierarchyCycles(t *testing.T) {
+	service := territories.NewService(testDB(t))
+	ctx := newTenant()
+
+	create := func(apiName string, parentId *int64) *territories.Territory {
+		t.Helper()
+		territory, err := service.Create(ctx, territories.CreateTerritory{Label: apiName, ApiName: apiName, ParentId: parentId})
+		if err != nil {
+			t.Fatalf("create territory %s: %v", apiName, err)
+		}
+		return territory
+	}
+
+	world := create("world", nil)
+	europe := create("europe", &world.Id)
+	france := create("france", &europe.Id)
+
+	if _, err := service.SetParent(ctx, world.Id, &france.Id); !errors.Is(err, hierarchy.ErrCycle) {
+		t.Errorf("territory under its descendant: got %v, want ErrCycle", err)
+	}
+	if _, err := service.SetParent(ctx, europe.Id, &europe.Id); !errors.Is(err, hierarchy.ErrCycle) {
+		t.Errorf("territory under itself: got %v, want ErrCycle", err)
+	}
+	if _, err := service.SetParent(ctx, france.Id, nil); err != nil {
+		t.Errorf("make territory top-level: %v", err)
+	}
+}
+
+func TestManagerHierarchyCycles(t *testing.T) {
+	db := testDB(t)
+	service := users.NewService(db)
+	ctx := newTenant()
+
+	create := func(name string, managerId *string) *users.User {
+		t.Helper()
+		user, err := service.Create(ctx, users.CreateUser{Name: name, Email: name + "@example.com", ManagerId: managerId})
+		if err != nil {
+			t.Fatalf("create user %s: %v", name, err)
+		}
+		return user
+	}
+
+	boss := create("boss", nil)
+	lead := create("lead", &boss.RecordId)
+	dev := create("dev", &lead.RecordId)
+
+	if _, err := service.SetManager(ctx, boss.RecordId, &dev.RecordId); !errors.Is(err, users.ErrManagerCycle) {
+		t.Errorf("manager under the subordinate: got %v, want ErrManagerCycle", err)
+	}
+	if _, err := service.SetManager(ctx, boss.RecordId, &boss.RecordId); !errors.Is(err, users.ErrManagerCycle) {
+		t.Errorf("manager of itself: got %v, want ErrManagerCycle", err)
+	}
+
+	t.Run("concurrent", func(t *testing.T) {
+		a := create("a", nil)
+		b := create("b", nil)
+
+		// a under b and b under a at once, the tree lock lets only one of them pass
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, pair := range [][2]*users.User{{a, b}, {b, a}} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = service.SetManager(ctx, pair[0].RecordId, &pair[1].RecordId)
+			}()
+		}
+		wg.Wait()
+
+		failed := 0
+		for _, err := range errs {
+			switch {
+			case errors.Is(err, users.ErrManagerCycle):
+				failed++
+			case err != nil:
+				t.Fatalf("set manager: %v", err)
+			}
+		}
+		if failed != 1 {
+			t.Errorf("%d of the concurrent assignments failed, want 1", failed)
+		}
+	})
+
+	t.Run("subordinates of the cycle", func(t *testing.T) {
+		// The cycle is created behind the service, the query of the subordinates must terminate anyway
+		actor, _ := services.ActorFromContext(ctx)
+		_, err := db.Exec(
+			ctx,
+			`UPDATE iam."user" SET manager_id = (SELECT id FROM iam."user" WHERE tenant_id = $1 AND record_id = $2)
+			WHERE tenant_id = $1 AND record_id = $3`,
+			actor.TenantId, dev.RecordId, boss.RecordId,
+		)
+		if err != nil {
+			t.Fatalf("create cycle: %v", err)
+		}
+
+		list, err := service.Subordinates(ctx, boss.RecordId, services.NewPage(nil, nil))
+		if err != nil {
+			t.Fatalf("subordinates: %v", err)
+		}
+		if list.Total != 2 {
+			t.Errorf("subordinates = %d, want 2", list.Total)
+		}
+	})
+}
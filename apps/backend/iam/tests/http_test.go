@@ -0,0 +1,71 @@
+//go:build integration
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	httpApi "github.com/adverax/metacrm/apps/backend/iam/cmd/iam/api/http"
+	"github.com/adverax/metacrm/apps/backend/iam/services"
+	"github.com/adverax/metacrm/apps/backend/iam/services/auth"
+	"github.com/adverax/metacrm/apps/backend/iam/services/users"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefreshWithExpiredAccessToken(t *testing.T) {
+	db := testDB(t)
+	// Access tokens are issued two hours ago for an hour, so they have expired at once
+	service := newAuthServiceAt(t, db, func() time.Time { return time.Now().Add(-2 * time.Hour) })
+	ctx := newTenant()
+	actor, _ := services.ActorFromContext(ctx)
+	register(t, ctx, service)
+
+	tokens, err := service.Login(ctx, auth.Credentials{Email: testEmail, Password: testPassword})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	server := httpApi.NewServer(nil, nil, nil, nil, nil, nil, nil, nil, nil, httpApi.NewAuthHandler(service, users.NewService(db)), nil, nil)
+	server.Register(router, httpApi.ActorMiddleware(service), httpApi.ClientMiddleware())
+
+	// request - sends the request of the tenant authorized by the expired access token
+	request := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		var payload bytes.Buffer
+		if body != nil {
+			if err := json.NewEncoder(&payload).Encode(body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r := httptest.NewRequest(method, httpApi.BaseURL+path, &payload)
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", httpApi.TokenTypeBearer+" "+tokens.AccessToken)
+		r.Header.Set(httpApi.TenantIdHeader, actor.TenantId.String())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request(http.MethodGet, "/auth/me", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("secured operation with the expired access token: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w := request(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": tokens.RefreshToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var refreshed httpApi.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &refreshed); err != nil {
+		t.Fatalf("decode refresh response: %v", err)
+	}
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Error("refresh token is not rotated")
+	}
+}
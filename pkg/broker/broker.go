@@ -0,0 +1,31 @@
+package broker
+
+import (
+	"context"
+	"time"
+)
+
+// Header - metadata of the message, delivered together with the message
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message - unit of the publishing.
+// Messages with the same key are delivered to the same partition of the topic,
+// so consumers receive them in the order of publishing.
+type Message struct {
+	Topic     string
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	Timestamp time.Time // zero means the time of publishing
+}
+
+// Publisher - broker-agnostic producer of messages.
+// Publish returns after the broker acknowledged every message or fails as a whole,
+// so failed messages must be published again.
+type Publisher interface {
+	Publish(ctx context.Context, messages ...Message) error
+	Close() error
+}
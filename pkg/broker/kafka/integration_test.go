@@ -0,0 +1,49 @@
+//go:build integration
+
+package kafkaBroker
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/broker"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProducer_Integration publishes to the broker from KAFKA_BROKERS,
+// e.g. the kafka-test container of apps/backend/iam/docker-compose.dev.yaml (localhost:19092).
+// The broker must create topics automatically.
+func TestProducer_Integration(t *testing.T) {
+	brokers := os.Getenv("KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_BROKERS is not set")
+	}
+
+	producer, err := NewBuilder().
+		WithBrokers(strings.Split(brokers, ",")...).
+		WithClientId("metacrm-test").
+		WithRetries(10, 500*time.Millisecond).
+		Build()
+	require.NoError(t, err)
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err = producer.Publish(ctx,
+		broker.Message{
+			Topic:   "iam.test.created",
+			Key:     []byte("tst_1"),
+			Value:   []byte(`{"id":"tst_1"}`),
+			Headers: []broker.Header{{Key: "tenant_id", Value: []byte("00000000-0000-0000-0000-000000000001")}},
+		},
+		broker.Message{
+			Topic: "iam.test.created",
+			Value: []byte(`{"id":"tst_2"}`),
+		},
+	)
+	require.NoError(t, err)
+}
@@ -0,0 +1,236 @@
+package kafkaBroker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/broker"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Acknowledgements required from the broker before the message is considered published
+const (
+	AcksAll    int16 = -1 // every in-sync replica stored the message
+	AcksLeader int16 = 1  // the partition leader stored the message
+)
+
+// SASL mechanisms of the authentication on the brokers
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSha256 = "SCRAM-SHA-256"
+	SASLScramSha512 = "SCRAM-SHA-512"
+)
+
+// Producer - publishes messages to Kafka compatible brokers by the franz-go client.
+// Keyed messages go to the partition chosen by the key like in the Java client,
+// messages without key are distributed among the partitions.
+// With AcksAll the producer is idempotent, so retries do not store the message twice
+// within the session of the producer.
+type Producer struct {
+	client *kgo.Client
+}
+
+var _ broker.Publisher = (*Producer)(nil)
+
+func (that *Producer) Publish(ctx context.Context, messages ...broker.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	records := make([]*kgo.Record, len(messages))
+	for i, message := range messages {
+		records[i] = recordOf(message)
+	}
+	return that.client.ProduceSync(ctx, records...).FirstErr()
+}
+
+func (that *Producer) Close() error {
+	that.client.Close()
+	return nil
+}
+
+// recordOf - zero timestamp is replaced by the time of producing
+func recordOf(message broker.Message) *kgo.Record {
+	record := &kgo.Record{
+		Topic:     message.Topic,
+		Key:       message.Key,
+		Value:     message.Value,
+		Timestamp: message.Timestamp,
+	}
+	if len(message.Headers) != 0 {
+		record.Headers = make([]kgo.RecordHeader, len(message.Headers))
+		for i, header := range message.Headers {
+			record.Headers[i] = kgo.RecordHeader{Key: header.Key, Value: header.Value}
+		}
+	}
+	return record
+}
+
+type Builder struct {
+	brokers        []string
+	clientId       string
+	acks           int16
+	idempotent     bool
+	timeout        time.Duration
+	retries        int
+	retryBackoff   time.Duration
+	metadataMaxAge time.Duration
+	tls            *tls.Config
+	saslMechanism  string
+	saslUser       string
+	saslPassword   string
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		clientId:       "metacrm",
+		acks:           AcksAll,
+		idempotent:     true,
+		timeout:        10 * time.Second,
+		retries:        3,
+		retryBackoff:   100 * time.Millisecond,
+		metadataMaxAge: 5 * time.Minute,
+	}
+}
+
+// WithBrokers - bootstrap brokers in host:port form, the rest of the cluster is discovered from them
+func (that *Builder) WithBrokers(brokers ...string) *Builder {
+	that.brokers = brokers
+	return that
+}
+
+func (that *Builder) WithClientId(clientId string) *Builder {
+	that.clientId = clientId
+	return that
+}
+
+// WithRequiredAcks - AcksLeader disables idempotence, it requires acknowledgements of every replica
+func (that *Builder) WithRequiredAcks(acks int16) *Builder {
+	that.acks = acks
+	return that
+}
+
+// WithIdempotence - idempotent producer requires IDEMPOTENT_WRITE permission on the cluster
+func (that *Builder) WithIdempotence(idempotent bool) *Builder {
+	that.idempotent = idempotent
+	return that
+}
+
+// WithTimeout - time the broker waits for the acknowledgements
+func (that *Builder) WithTimeout(timeout time.Duration) *Builder {
+	that.timeout = timeout
+	return that
+}
+
+// WithRetries - number of retries of the failed message and the pause between them
+func (that *Builder) WithRetries(retries int, backoff time.Duration) *Builder {
+	that.retries = retries
+	that.retryBackoff = backoff
+	return that
+}
+
+func (that *Builder) WithMetadataMaxAge(maxAge time.Duration) *Builder {
+	that.metadataMaxAge = maxAge
+	return that
+}
+
+// WithTLS - connects to the brokers by TLS, nil config connects in plain text
+func (that *Builder) WithTLS(config *tls.Config) *Builder {
+	that.tls = config
+	return that
+}
+
+// WithSASL - authenticates on the brokers by the mechanism, empty mechanism disables authentication
+func (that *Builder) WithSASL(mechanism, user, password string) *Builder {
+	that.saslMechanism = mechanism
+	that.saslUser = user
+	that.saslPassword = password
+	return that
+}
+
+func (that *Builder) Build() (*Producer, error) {
+	if err := that.checkRequiredFields(); err != nil {
+		return nil, err
+	}
+
+	backoff := that.retryBackoff
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(that.brokers...),
+		kgo.ClientID(that.clientId),
+		kgo.ProduceRequestTimeout(that.timeout),
+		kgo.RecordRetries(that.retries),
+		kgo.RetryBackoffFn(func(int) time.Duration { return backoff }),
+		kgo.MetadataMaxAge(that.metadataMaxAge),
+	}
+
+	if that.acks == AcksAll {
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	} else {
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()))
+	}
+	if that.acks != AcksAll || !that.idempotent {
+		opts = append(opts, kgo.DisableIdempotentWrite())
+	}
+
+	if that.tls != nil {
+		opts = append(opts, kgo.DialTLSConfig(that.tls.Clone()))
+	}
+
+	if that.saslMechanism != "" {
+		mechanism, err := that.mechanism()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create kafka client: %w", err)
+	}
+	return &Producer{client: client}, nil
+}
+
+func (that *Builder) mechanism() (sasl.Mechanism, error) {
+	switch that.saslMechanism {
+	case SASLPlain:
+		return plain.Auth{User: that.saslUser, Pass: that.saslPassword}.AsMechanism(), nil
+	case SASLScramSha256:
+		return scram.Auth{User: that.saslUser, Pass: that.saslPassword}.AsSha256Mechanism(), nil
+	case SASLScramSha512:
+		return scram.Auth{User: that.saslUser, Pass: that.saslPassword}.AsSha512Mechanism(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSASLMechanism, that.saslMechanism)
+	}
+}
+
+func (that *Builder) checkRequiredFields() error {
+	if len(that.brokers) == 0 {
+		return ErrBrokersRequired
+	}
+	if that.acks != AcksAll && that.acks != AcksLeader {
+		return ErrInvalidAcks
+	}
+	if that.timeout <= 0 {
+		return ErrInvalidTimeout
+	}
+	if that.retries < 0 {
+		return ErrInvalidRetries
+	}
+	return nil
+}
+
+var (
+	ErrClosed               = kgo.ErrClientClosed
+	ErrBrokersRequired      = errors.New("brokers are required")
+	ErrInvalidAcks          = errors.New("required acks must be AcksAll or AcksLeader")
+	ErrInvalidTimeout       = errors.New("timeout must be positive")
+	ErrInvalidRetries       = errors.New("retries must not be negative")
+	ErrInvalidSASLMechanism = errors.New("unknown SASL mechanism")
+)
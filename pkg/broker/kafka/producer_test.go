@@ -0,0 +1,87 @@
+package kafkaBroker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestBuilder(t *testing.T) {
+	t.Run("brokers are required", func(t *testing.T) {
+		_, err := NewBuilder().Build()
+		assert.ErrorIs(t, err, ErrBrokersRequired)
+	})
+
+	t.Run("invalid acks", func(t *testing.T) {
+		_, err := NewBuilder().WithBrokers("localhost:9092").WithRequiredAcks(0).Build()
+		assert.ErrorIs(t, err, ErrInvalidAcks)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		_, err := NewBuilder().WithBrokers("localhost:9092").WithTimeout(0).Build()
+		assert.ErrorIs(t, err, ErrInvalidTimeout)
+	})
+
+	t.Run("invalid retries", func(t *testing.T) {
+		_, err := NewBuilder().WithBrokers("localhost:9092").WithRetries(-1, time.Second).Build()
+		assert.ErrorIs(t, err, ErrInvalidRetries)
+	})
+
+	t.Run("unknown SASL mechanism", func(t *testing.T) {
+		_, err := NewBuilder().WithBrokers("localhost:9092").WithSASL("GSSAPI", "user", "password").Build()
+		assert.ErrorIs(t, err, ErrInvalidSASLMechanism)
+	})
+
+	for _, mechanism := range []string{SASLPlain, SASLScramSha256, SASLScramSha512} {
+		t.Run(mechanism, func(t *testing.T) {
+			producer, err := NewBuilder().WithBrokers("localhost:9092").WithSASL(mechanism, "user", "password").Build()
+			require.NoError(t, err)
+			require.NoError(t, producer.Close())
+		})
+	}
+
+	t.Run("leader acks", func(t *testing.T) {
+		producer, err := NewBuilder().WithBrokers("localhost:9092").WithRequiredAcks(AcksLeader).Build()
+		require.NoError(t, err)
+		require.NoError(t, producer.Close())
+	})
+}
+
+func TestRecordOf(t *testing.T) {
+	timestamp := time.Unix(1737650000, 0)
+	record := recordOf(broker.Message{
+		Topic:     "iam.user.created",
+		Key:       []byte("usr_1"),
+		Value:     []byte(`{"id":"usr_1"}`),
+		Headers:   []broker.Header{{Key: "tenant_id", Value: []byte("t1")}},
+		Timestamp: timestamp,
+	})
+
+	assert.Equal(t, "iam.user.created", record.Topic)
+	assert.Equal(t, []byte("usr_1"), record.Key)
+	assert.Equal(t, []byte(`{"id":"usr_1"}`), record.Value)
+	assert.Equal(t, []kgo.RecordHeader{{Key: "tenant_id", Value: []byte("t1")}}, record.Headers)
+	assert.Equal(t, timestamp, record.Timestamp)
+}
+
+func TestProducer_PublishAfterClose(t *testing.T) {
+	producer, err := NewBuilder().WithBrokers("localhost:9092").Build()
+	require.NoError(t, err)
+	require.NoError(t, producer.Close())
+
+	err = producer.Publish(context.Background(), broker.Message{Topic: "iam.test", Value: []byte("{}")})
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestProducer_PublishNothing(t *testing.T) {
+	producer, err := NewBuilder().WithBrokers("localhost:9092").Build()
+	require.NoError(t, err)
+	defer producer.Close()
+
+	require.NoError(t, producer.Publish(context.Background()))
+}
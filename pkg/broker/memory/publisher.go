@@ -0,0 +1,96 @@
+package memoryBroker
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/broker"
+)
+
+// Publisher - keeps published messages in memory, used in tests instead of the real broker
+type Publisher struct {
+	mx       sync.Mutex
+	messages []broker.Message
+	err      error
+	closed   bool
+	clock    func() time.Time
+}
+
+var _ broker.Publisher = (*Publisher)(nil)
+
+func New() *Publisher {
+	return &Publisher{clock: time.Now}
+}
+
+func (that *Publisher) Publish(ctx context.Context, messages ...broker.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	if that.closed {
+		return ErrClosed
+	}
+	if that.err != nil {
+		return that.err
+	}
+
+	for _, message := range messages {
+		if message.Timestamp.IsZero() {
+			message.Timestamp = that.clock()
+		}
+		that.messages = append(that.messages, message)
+	}
+	return nil
+}
+
+func (that *Publisher) Close() error {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	that.closed = true
+	return nil
+}
+
+// Fail - makes every next publishing fail with err, nil restores publishing
+func (that *Publisher) Fail(err error) {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	that.err = err
+}
+
+// Messages - returns published messages of the topic in order of publishing, empty topic returns all messages
+func (that *Publisher) Messages(topic string) []broker.Message {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	if topic == "" {
+		return slices.Clone(that.messages)
+	}
+
+	var messages []broker.Message
+	for _, message := range that.messages {
+		if message.Topic == topic {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Reset - forgets published messages
+func (that *Publisher) Reset() {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	that.messages = nil
+}
+
+var (
+	ErrClosed = errors.New("publisher is closed")
+)
@@ -0,0 +1,44 @@
+package memoryBroker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher(t *testing.T) {
+	now := time.UnixMilli(1737650000123)
+	publisher := New()
+	publisher.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx,
+		broker.Message{Topic: "iam.user.created", Key: []byte("usr_1")},
+		broker.Message{Topic: "iam.role.created", Key: []byte("rol_1"), Timestamp: now.Add(time.Second)},
+		broker.Message{Topic: "iam.user.created", Key: []byte("usr_2")},
+	))
+
+	users := publisher.Messages("iam.user.created")
+	require.Len(t, users, 2)
+	assert.Equal(t, []byte("usr_1"), users[0].Key)
+	assert.Equal(t, []byte("usr_2"), users[1].Key)
+	assert.Equal(t, now, users[0].Timestamp)
+	assert.Equal(t, now.Add(time.Second), publisher.Messages("iam.role.created")[0].Timestamp)
+	assert.Len(t, publisher.Messages(""), 3)
+
+	failure := errors.New("broker is down")
+	publisher.Fail(failure)
+	assert.ErrorIs(t, publisher.Publish(ctx, broker.Message{Topic: "iam.user.created"}), failure)
+	publisher.Fail(nil)
+
+	publisher.Reset()
+	assert.Empty(t, publisher.Messages(""))
+
+	require.NoError(t, publisher.Close())
+	assert.ErrorIs(t, publisher.Publish(ctx, broker.Message{Topic: "iam.user.created"}), ErrClosed)
+}
@@ -0,0 +1,173 @@
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+type autocommitKeyType int
+
+var autocommitKey autocommitKeyType = 0
+
+// withAutocommit - marks the statement executed by the pool outside of the transactions
+func withAutocommit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, autocommitKey, true)
+}
+
+// isAutocommit - reports whether the statement is executed outside of the transactions,
+// failed statements of the transaction can not be repeated, because the transaction is aborted
+func isAutocommit(ctx context.Context) bool {
+	autocommit, _ := ctx.Value(autocommitKey).(bool)
+	return autocommit
+}
+
+// NewMiddleware - middleware applying the behavior to every statement and transaction
+func NewMiddleware(behavior Behavior) Middleware {
+	return func(next Handler) Handler {
+		return NewCustomHandler(behavior, next)
+	}
+}
+
+// NewTimeoutMiddleware - limits duration of every statement, BEGIN, COMMIT and ROLLBACK.
+// Rows of the query are read within the timeout too, the row is scanned within it.
+func NewTimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return &timeoutHandler{timeout: timeout, next: next}
+	}
+}
+
+type timeoutHandler struct {
+	timeout time.Duration
+	next    Handler
+}
+
+func (that *timeoutHandler) Query(ctx context.Context, action QueryAction, query string, args ...interface{}) (Rows, error) {
+	ctx, cancel := context.WithTimeout(ctx, that.timeout)
+	rs, err := that.next.Query(ctx, action, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timeoutRows{Rows: rs, cancel: cancel}, nil
+}
+
+func (that *timeoutHandler) QueryRow(ctx context.Context, action QueryRowAction, query string, args ...interface{}) Row {
+	ctx, cancel := context.WithTimeout(ctx, that.timeout)
+	return &timeoutRow{Row: that.next.QueryRow(ctx, action, query, args...), cancel: cancel}
+}
+
+func (that *timeoutHandler) Exec(ctx context.Context, action ExecAction, query string, args ...interface{}) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, that.timeout)
+	defer cancel()
+	return that.next.Exec(ctx, action, query, args...)
+}
+
+func (that *timeoutHandler) BeginTx(ctx context.Context, opts *TxOptions, action BeginAction) (Tx, error) {
+	ctx, cancel := context.WithTimeout(ctx, that.timeout)
+	defer cancel()
+	return that.next.BeginTx(ctx, opts, action)
+}
+
+func (that *timeoutHandler) Commit(ctx context.Context, action CommitAction) error {
+	ctx, cancel := context.WithTimeout(ctx, that.timeout)
+	defer cancel()
+	return that.next.Commit(ctx, action)
+}
+
+func (that *timeoutHandler) Rollback(ctx context.Context, action RollbackAction) error {
+	ctx, cancel := context.WithTimeout(ctx, that.timeout)
+	defer cancel()
+	return that.next.Rollback(ctx, action)
+}
+
+type timeoutRows struct {
+	Rows
+	cancel context.CancelFunc
+}
+
+func (that *timeoutRows) Close() error {
+	err := that.Rows.Close()
+	that.cancel()
+	return err
+}
+
+type timeoutRow struct {
+	Row
+	cancel context.CancelFunc
+}
+
+func (that *timeoutRow) Scan(dest ...interface{}) error {
+	defer that.cancel()
+	return that.Row.Scan(dest...)
+}
+
+// NewRetryMiddleware - repeats Exec and Query failed with ErrRetryable outside of the transactions.
+// Statements of the transactions are not repeated, the whole transaction is repeated
+// by TransactionWithRetry. Errors of QueryRow are reported by Scan, so it is not repeated as well.
+// Statements of the context with identity never reach the middleware outside of the transactions:
+// they run in their own transactions, which are repeated by the database with the options of WithRetry.
+func NewRetryMiddleware(options RetryOptions) Middleware {
+	return func(next Handler) Handler {
+		return &retryHandler{options: options, Handler: next}
+	}
+}
+
+type retryHandler struct {
+	Handler
+	options RetryOptions
+}
+
+func (that *retryHandler) Query(ctx context.Context, action QueryAction, query string, args ...interface{}) (rs Rows, err error) {
+	if !isAutocommit(ctx) {
+		return that.Handler.Query(ctx, action, query, args...)
+	}
+
+	err = retry(ctx, that.options, func() error {
+		rs, err = that.Handler.Query(ctx, action, query, args...)
+		return err
+	})
+	return rs, err
+}
+
+func (that *retryHandler) Exec(ctx context.Context, action ExecAction, query string, args ...interface{}) (res Result, err error) {
+	if !isAutocommit(ctx) {
+		return that.Handler.Exec(ctx, action, query, args...)
+	}
+
+	err = retry(ctx, that.options, func() error {
+		res, err = that.Handler.Exec(ctx, action, query, args...)
+		return err
+	})
+	return res, err
+}
+
+// SlowQueryHook - receives the statement executed longer than the threshold
+type SlowQueryHook func(ctx context.Context, query string, args []interface{}, duration time.Duration, err error)
+
+// SlowQueryBehavior - calls the hook for the statements executed longer than the threshold.
+// Duration of the query does not include reading of its rows.
+type SlowQueryBehavior struct {
+	threshold time.Duration
+	hook      SlowQueryHook
+}
+
+func NewSlowQueryBehavior(threshold time.Duration, hook SlowQueryHook) *SlowQueryBehavior {
+	return &SlowQueryBehavior{
+		threshold: threshold,
+		hook:      hook,
+	}
+}
+
+func (that *SlowQueryBehavior) Apply(
+	ctx context.Context,
+	action func(ctx context.Context) error,
+	query string,
+	args ...interface{},
+) error {
+	started := time.Now()
+	err := action(ctx)
+	if duration := time.Since(started); duration > that.threshold {
+		that.hook(ctx, query, args, duration, err)
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingBehavior struct {
+	name  string
+	calls *[]string
+}
+
+func (that *recordingBehavior) Apply(ctx context.Context, action func(ctx context.Context) error, query string, args ...interface{}) error {
+	*that.calls = append(*that.calls, that.name)
+	return action(ctx)
+}
+
+func TestBuilder_WithMiddleware(t *testing.T) {
+	var calls []string
+	builder := NewBuilder().
+		WithBehavior(&recordingBehavior{name: "outer", calls: &calls}).
+		WithMiddleware(NewMiddleware(&recordingBehavior{name: "inner", calls: &calls}))
+
+	_, err := builder.newSniffer().Exec(context.Background(), func(ctx context.Context, query string, args ...interface{}) (Result, error) {
+		calls = append(calls, "action")
+		return nil, nil
+	}, "SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner", "action"}, calls)
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := NewTimeoutMiddleware(time.Minute)(NewDummyHandler())
+
+	var queryCtx context.Context
+	rs, err := handler.Query(context.Background(), func(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+		queryCtx = ctx
+		return &fakeRows{}, nil
+	}, "SELECT 1")
+	require.NoError(t, err)
+
+	_, ok := queryCtx.Deadline()
+	assert.True(t, ok)
+	assert.NoError(t, queryCtx.Err(), "rows are read within the timeout")
+	require.NoError(t, rs.Close())
+	assert.Error(t, queryCtx.Err(), "timeout is released by close")
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	handler := NewRetryMiddleware(RetryOptions{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})(NewDummyHandler())
+
+	attempts := 0
+	action := func(ctx context.Context, query string, args ...interface{}) (Result, error) {
+		attempts++
+		return nil, fmt.Errorf("Tx.ExecContext: %w", ErrRetryable)
+	}
+
+	_, err := handler.Exec(withAutocommit(context.Background()), action, "UPDATE t SET v = 1")
+	assert.ErrorIs(t, err, ErrRetryable)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	_, err = handler.Exec(context.Background(), action, "UPDATE t SET v = 1")
+	assert.ErrorIs(t, err, ErrRetryable)
+	assert.Equal(t, 1, attempts, "statements of the transactions are not repeated")
+}
+
+func TestSlowQueryBehavior(t *testing.T) {
+	var slow []string
+	behavior := NewSlowQueryBehavior(5*time.Millisecond, func(ctx context.Context, query string, args []interface{}, duration time.Duration, err error) {
+		slow = append(slow, query)
+	})
+
+	_ = behavior.Apply(context.Background(), func(ctx context.Context) error { return nil }, "SELECT 1")
+	_ = behavior.Apply(context.Background(), func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, "SELECT pg_sleep(1)")
+	assert.Equal(t, []string{"SELECT pg_sleep(1)"}, slow)
+}
+
+func TestMetricsBehavior(t *testing.T) {
+	metrics := NewMetricsBehavior("db_statement_duration_seconds", time.Hour, time.Nanosecond)
+	ok := func(ctx context.Context) error { return nil }
+
+	_ = metrics.Apply(context.Background(), ok, "SELECT * FROM t WHERE id = 1")
+	_ = metrics.Apply(context.Background(), ok, "SELECT * FROM t WHERE id = 2")
+	_ = metrics.Apply(context.Background(), func(ctx context.Context) error { return ErrInvalid }, "DELETE FROM t")
+
+	snapshot := metrics.Snapshot()
+	require.Len(t, snapshot, 2)
+	for _, series := range snapshot {
+		assert.Equal(t, []time.Duration{time.Nanosecond, time.Hour}, series.Buckets)
+		switch series.Fingerprint {
+		case SQLFingerprint("SELECT * FROM t WHERE id = 1"):
+			assert.Equal(t, uint64(2), series.Count)
+			assert.Equal(t, uint64(2), series.Counts[1])
+			assert.Zero(t, series.Errors)
+		case SQLFingerprint("DELETE FROM t"):
+			assert.Equal(t, uint64(1), series.Count)
+			assert.Equal(t, uint64(1), series.Errors)
+		default:
+			t.Fatalf("unexpected series %q", series.Query)
+		}
+	}
+
+	var out bytes.Buffer
+	n, err := metrics.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Equal(t, int64(out.Len()), n)
+	assert.True(t, strings.HasPrefix(out.String(), "# HELP db_statement_duration_seconds "))
+	assert.Contains(t, out.String(), fmt.Sprintf(
+		"db_statement_duration_seconds_bucket{fingerprint=%q,le=\"3600\"} 2\n",
+		SQLFingerprint("SELECT * FROM t WHERE id = 1"),
+	))
+	assert.Contains(t, out.String(), fmt.Sprintf(
+		"db_statement_duration_seconds_count{fingerprint=%q} 1\n",
+		SQLFingerprint("DELETE FROM t"),
+	))
+}
@@ -29,7 +29,9 @@ type Builder struct {
 func NewBuilder() *Builder {
 	return &Builder{
 		db: &db{
-			dbId: "platform",
+			dbId:       "platform",
+			savepoints: true,
+			retry:      DefaultRetryOptions,
 		},
 		dsn:             DefaultDSN(),
 		maxOpenConns:    5,
@@ -116,6 +118,43 @@ func (that *Builder) WithBeforeAcquire(fn func(ctx context.Context, conn *pgx.Co
 	return that
 }
 
+// WithIdentityApplier - replaces ApplyIdentity binding identity of the context to the started transactions
+func (that *Builder) WithIdentityApplier(applier IdentityApplier) *Builder {
+	that.db.identity = applier
+	return that
+}
+
+// WithIdentityRequired - read-write transactions without identity in the context fail with ErrIdentityRequired
+func (that *Builder) WithIdentityRequired(required bool) *Builder {
+	that.db.identityRequired = required
+	return that
+}
+
+// WithSavepoints - nested transactions are savepoints of the outer transaction, enabled by default.
+// Disabled nested transactions are committed and rolled back only with the outer transaction.
+func (that *Builder) WithSavepoints(enabled bool) *Builder {
+	that.db.savepoints = enabled
+	return that
+}
+
+// WithRetry - re-running of the transactions started by TransactionWithRetry
+// and of the own transactions of the statements with identity, MaxAttempts 1 disables re-running
+func (that *Builder) WithRetry(options RetryOptions) *Builder {
+	that.db.retry = options
+	return that
+}
+
+// WithMiddleware - appends middlewares of the statements and transactions, the first one is the outermost
+func (that *Builder) WithMiddleware(middlewares ...Middleware) *Builder {
+	that.middlewares = append(that.middlewares, middlewares...)
+	return that
+}
+
+// WithBehavior - appends middleware applying the behavior
+func (that *Builder) WithBehavior(behavior Behavior) *Builder {
+	return that.WithMiddleware(NewMiddleware(behavior))
+}
+
 func (that *Builder) WithQueryTracer(tracer pgx.QueryTracer) *Builder {
 	that.tracer = tracer
 	return that
@@ -177,6 +216,9 @@ func (that *Builder) checkRequiredParams() error {
 	if that.dsn.Database == "" {
 		return ErrDatabaseIsRequired
 	}
+	if retry := that.db.retry; retry.MaxAttempts < 1 || retry.Backoff < 0 || retry.MaxBackoff < retry.Backoff {
+		return ErrInvalidRetry
+	}
 	return nil
 }
 
@@ -184,6 +226,9 @@ func (that *Builder) updateDefaultParams() error {
 	if that.db.errors == nil {
 		that.db.errors = new(dummyErrorBuilder)
 	}
+	if that.db.identity == nil {
+		that.db.identity = ApplyIdentity
+	}
 	return nil
 }
 
@@ -224,4 +269,5 @@ var (
 	ErrHostIsRequired     = fmt.Errorf("Host is required")
 	ErrUserIsRequired     = fmt.Errorf("User is required")
 	ErrDatabaseIsRequired = fmt.Errorf("Database is required")
+	ErrInvalidRetry       = fmt.Errorf("Retry must have positive attempts and backoff not exceeding max backoff")
 )
@@ -98,11 +98,15 @@ func (that *cancelableExecutorEx) BeginTx(ctx context.Context, opts *TxOptions)
 }
 
 type db struct {
-	pool    *pgxpool.Pool
-	dbId    DbId
-	errors  ErrorBuilder
-	source  string
-	handler Handler
+	pool             *pgxpool.Pool
+	dbId             DbId
+	errors           ErrorBuilder
+	source           string
+	handler          Handler
+	identity         IdentityApplier
+	identityRequired bool
+	savepoints       bool
+	retry            RetryOptions
 }
 
 func (that *db) Pool() *pgxpool.Pool {
@@ -130,7 +134,7 @@ func (that *db) Exec(ctx context.Context, query string, args ...interface{}) (Re
 }
 
 func (that *db) DoExec(ctx context.Context, query string, args ...interface{}) (Result, error) {
-	return that.handler.Exec(ctx, func(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	return that.handler.Exec(withAutocommit(ctx), func(ctx context.Context, query string, args ...interface{}) (Result, error) {
 		res, err := that.pool.Exec(ctx, query, args...)
 		if err != nil {
 			return nil, that.errors.Build(ctx, err, "DB.ExecContext")
@@ -149,7 +153,7 @@ func (that *db) Query(ctx context.Context, query string, args ...interface{}) (R
 }
 
 func (that *db) DoQuery(ctx context.Context, query string, args ...interface{}) (Rows, error) {
-	return that.handler.Query(ctx, func(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	return that.handler.Query(withAutocommit(ctx), func(ctx context.Context, query string, args ...interface{}) (Rows, error) {
 		rs, err := that.pool.Query(ctx, query, args...)
 		if err != nil {
 			return nil, that.errors.Build(ctx, err, "DB.QueryContext")
@@ -178,8 +182,8 @@ func (that *db) DoBegin(ctx context.Context) (Tx, error) {
 	return that.DoBeginTx(ctx, nil)
 }
 
-func (that *db) BeginTx(ctx context.Context, _ *TxOptions) (Tx, error) {
-	return that.DoBeginTx(NewContextWithoutCancel(ctx), nil)
+func (that *db) BeginTx(ctx context.Context, opts *TxOptions) (Tx, error) {
+	return that.DoBeginTx(NewContextWithoutCancel(ctx), opts)
 }
 
 func (that *db) DoBeginTx(ctx context.Context, opts *TxOptions) (Tx, error) {
@@ -215,16 +219,16 @@ func (that *tx) Begin(ctx context.Context) (Tx, error) {
 	return that.DoBegin(NewContextWithoutCancel(ctx))
 }
 
-func (that *tx) DoBegin(_ context.Context) (Tx, error) {
-	return &tx2{tx: that.tx, db: that.db, level: 1}, nil
+func (that *tx) DoBegin(ctx context.Context) (Tx, error) {
+	return that.db.nested(ctx, that.tx, 1)
 }
 
 func (that *tx) BeginTx(ctx context.Context, opts *TxOptions) (Tx, error) {
 	return that.DoBeginTx(NewContextWithoutCancel(ctx), opts)
 }
 
-func (that *tx) DoBeginTx(_ context.Context, _ *TxOptions) (Tx, error) {
-	return &tx2{tx: that.tx, db: that.db, level: 1}, nil
+func (that *tx) DoBeginTx(ctx context.Context, _ *TxOptions) (Tx, error) {
+	return that.db.nested(ctx, that.tx, 1)
 }
 
 func (that *tx) Commit(ctx context.Context) error {
@@ -295,10 +299,27 @@ func (that *tx) DoQueryRow(ctx context.Context, query string, args ...interface{
 	}, query, args...)
 }
 
+// tx2 - nested transaction, it is a savepoint of the outer transaction
+// unless savepoints are disabled, then Commit and Rollback do nothing
+// and the work is committed or rolled back with the outer transaction
 type tx2 struct {
-	db    *db
-	tx    pgx.Tx
-	level int
+	db        *db
+	tx        pgx.Tx
+	level     int
+	savepoint bool
+}
+
+// nested - starts nested transaction of the parent
+func (that *db) nested(ctx context.Context, parent pgx.Tx, level int) (Tx, error) {
+	if !that.savepoints {
+		return &tx2{tx: parent, db: that, level: level}, nil
+	}
+
+	sp, err := parent.Begin(ctx)
+	if err != nil {
+		return nil, that.errors.Build(ctx, err, "Tx.Savepoint")
+	}
+	return &tx2{tx: sp, db: that, level: level, savepoint: true}, nil
 }
 
 func (that *tx2) DbId(_ context.Context) DbId {
@@ -313,24 +334,50 @@ func (that *tx2) Begin(ctx context.Context) (Tx, error) {
 	return that.DoBegin(ctx)
 }
 
-func (that *tx2) DoBegin(_ context.Context) (Tx, error) {
-	return &tx2{tx: that.tx, db: that.db, level: that.level + 1}, nil
+func (that *tx2) DoBegin(ctx context.Context) (Tx, error) {
+	return that.db.nested(ctx, that.tx, that.level+1)
 }
 
 func (that *tx2) BeginTx(ctx context.Context, opts *TxOptions) (Tx, error) {
 	return that.DoBeginTx(ctx, opts)
 }
 
-func (that *tx2) DoBeginTx(_ context.Context, _ *TxOptions) (Tx, error) {
-	return &tx2{tx: that.tx, db: that.db, level: that.level + 1}, nil
+func (that *tx2) DoBeginTx(ctx context.Context, _ *TxOptions) (Tx, error) {
+	return that.db.nested(ctx, that.tx, that.level+1)
 }
 
-func (that *tx2) Commit(_ context.Context) error {
-	return nil
+// Commit - releases the savepoint
+func (that *tx2) Commit(ctx context.Context) error {
+	if !that.savepoint {
+		return nil
+	}
+
+	return that.db.handler.Commit(ctx, func(ctx context.Context) error {
+		err := that.tx.Commit(ctx)
+		if err != nil {
+			return that.db.errors.Build(context.Background(), err, "Tx2.Commit")
+		}
+		return nil
+	})
 }
 
-func (that *tx2) Rollback(_ context.Context) error {
-	return nil
+// Rollback - rolls back to the savepoint, the outer transaction remains usable
+func (that *tx2) Rollback(ctx context.Context) error {
+	if !that.savepoint {
+		return nil
+	}
+
+	return that.db.handler.Rollback(ctx, func(ctx context.Context) error {
+		err := that.tx.Rollback(ctx)
+		if err != nil {
+			if errors.Is(err, ErrTxDone) {
+				return err
+			}
+			return that.db.errors.Build(context.Background(), err, "Tx2.Rollback")
+		}
+
+		return nil
+	})
 }
 
 func (that *tx2) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
@@ -481,9 +528,9 @@ func (that *database) Scope(ctx context.Context) Scope {
 }
 
 func (that *database) Transact(ctx context.Context, action Act) error {
-	tx, err := that.Scope(ctx).BeginTx(ctx, &TxOptions{AccessMode: pgx.ReadWrite})
+	tx, err := that.beginTx(ctx, &TxOptions{AccessMode: pgx.ReadWrite})
 	if err != nil {
-		return fmt.Errorf("BeginTx: %w", err)
+		return err
 	}
 	ctx2 := ToContext(ctx, tx)
 	defer tx.Rollback(ctx2)
@@ -508,9 +555,9 @@ func (that *database) TransactionTx(
 	action Action,
 	options *TxOptions,
 ) error {
-	tx, err := that.Scope(ctx).BeginTx(ctx, options)
+	tx, err := that.beginTx(ctx, options)
 	if err != nil {
-		return fmt.Errorf("BeginTx: %w", err)
+		return err
 	}
 	ctx2 := ToContext(ctx, tx)
 	defer tx.Rollback(ctx2)
@@ -535,14 +582,27 @@ func (that *database) WithCancel(ctx context.Context) Scope {
 }
 
 func (that *database) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	if that.bound(ctx) {
+		return that.execBound(ctx, query, args...)
+	}
 	return that.Scope(ctx).Exec(ctx, query, args...)
 }
 
+func (that *database) Fetch(ctx context.Context, query string, args ...any) Fetcher {
+	return newFetcher(ctx, that, query, args...)
+}
+
 func (that *database) Query(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	if that.bound(ctx) {
+		return that.queryBound(ctx, query, args...)
+	}
 	return that.Scope(ctx).Query(ctx, query, args...)
 }
 
 func (that *database) QueryRow(ctx context.Context, query string, args ...interface{}) Row {
+	if that.bound(ctx) {
+		return that.queryRowBound(ctx, query, args...)
+	}
 	return that.Scope(ctx).QueryRow(ctx, query, args...)
 }
 
@@ -0,0 +1,75 @@
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePgxTx - records savepoints started, released and rolled back
+type fakePgxTx struct {
+	pgx.Tx
+	savepoints []*fakePgxTx
+	committed  bool
+	rolledBack bool
+}
+
+func (that *fakePgxTx) Begin(context.Context) (pgx.Tx, error) {
+	sp := &fakePgxTx{}
+	that.savepoints = append(that.savepoints, sp)
+	return sp, nil
+}
+
+func (that *fakePgxTx) Commit(context.Context) error {
+	that.committed = true
+	return nil
+}
+
+func (that *fakePgxTx) Rollback(context.Context) error {
+	that.rolledBack = true
+	return nil
+}
+
+func TestTx_NestedSavepoints(t *testing.T) {
+	ctx := context.Background()
+	outer := &fakePgxTx{}
+	db := &db{dbId: "test", handler: NewDummyHandler(), errors: new(dummyErrorBuilder), savepoints: true}
+	root := &tx{tx: outer, db: db}
+
+	nested, err := root.Begin(ctx)
+	require.NoError(t, err)
+	require.Len(t, outer.savepoints, 1)
+	require.NoError(t, nested.Rollback(ctx))
+	assert.True(t, outer.savepoints[0].rolledBack)
+	assert.False(t, outer.rolledBack, "outer transaction is kept")
+
+	nested, err = root.Begin(ctx)
+	require.NoError(t, err)
+	deeper, err := nested.Begin(ctx)
+	require.NoError(t, err)
+	require.Len(t, outer.savepoints, 2)
+	require.Len(t, outer.savepoints[1].savepoints, 1)
+	require.NoError(t, deeper.Commit(ctx))
+	require.NoError(t, nested.Commit(ctx))
+	assert.True(t, outer.savepoints[1].savepoints[0].committed)
+	assert.True(t, outer.savepoints[1].committed)
+	assert.False(t, outer.committed)
+}
+
+func TestTx_NestedWithoutSavepoints(t *testing.T) {
+	ctx := context.Background()
+	outer := &fakePgxTx{}
+	db := &db{dbId: "test", handler: NewDummyHandler(), errors: new(dummyErrorBuilder)}
+	root := &tx{tx: outer, db: db}
+
+	nested, err := root.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, nested.Rollback(ctx))
+	require.NoError(t, nested.Commit(ctx))
+	assert.Empty(t, outer.savepoints)
+	assert.False(t, outer.rolledBack)
+	assert.False(t, outer.committed)
+}
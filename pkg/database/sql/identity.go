@@ -0,0 +1,243 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Identity - tenant and principal on whose behalf the transaction is executed.
+// Column defaults, audit columns and tenant isolation of the schema read them
+// by bootstrap.current_tenant_id() and bootstrap.current_principal_id().
+type Identity struct {
+	TenantId    uuid.UUID
+	PrincipalId int64 // 0 for anonymous requests of the tenant
+}
+
+// IdentityApplier - binds identity to the transaction just started
+type IdentityApplier func(ctx context.Context, tx Tx, identity Identity) error
+
+type identityKeyType int
+
+var identityKey identityKeyType = 0
+
+// WithIdentity - append identity into context, transactions started with the context are bound to it
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+// IdentityFromContext - extract identity from context
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey).(Identity)
+	if !ok || identity.TenantId == uuid.Nil {
+		return Identity{}, false
+	}
+	return identity, true
+}
+
+// ApplyIdentity - sets context of the transaction by bootstrap.set_ctx,
+// anonymous requests set the tenant only
+func ApplyIdentity(ctx context.Context, tx Tx, identity Identity) error {
+	var err error
+	if identity.PrincipalId > 0 {
+		_, err = tx.Exec(ctx, `SELECT bootstrap.set_ctx($1, $2)`, identity.TenantId, identity.PrincipalId)
+	} else {
+		_, err = tx.Exec(ctx, `SELECT set_config('app.tenant_id', $1, true)`, identity.TenantId.String())
+	}
+	if err != nil {
+		return fmt.Errorf("set identity: %w", err)
+	}
+	return nil
+}
+
+// beginTx - starts transaction of the scope bound to the identity of the context.
+// Statements of the context with identity executed outside of transactions run in the own transaction.
+// Nested transactions inherit the identity of the outer one.
+func (that *database) beginTx(ctx context.Context, options *TxOptions) (Tx, error) {
+	nested := that.InTransaction(ctx)
+
+	identity, ok := IdentityFromContext(ctx)
+	if !ok && !nested && that.identityRequired && (options == nil || options.AccessMode != pgx.ReadOnly) {
+		return nil, ErrIdentityRequired
+	}
+
+	tx, err := that.Scope(ctx).BeginTx(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx: %w", err)
+	}
+	if !ok || nested {
+		return tx, nil
+	}
+
+	if err := that.identity(ctx, tx, identity); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// bound - reports whether the statement must run in the own transaction bound to the identity.
+// Tenant isolation of the schema hides every row from the statements outside of the transactions,
+// so the statements of the context with identity are not executed by the pool directly.
+func (that *database) bound(ctx context.Context) bool {
+	if that.InTransaction(ctx) {
+		return false
+	}
+	_, ok := IdentityFromContext(ctx)
+	return ok
+}
+
+// execBound - the own transaction of the statement is repeated as a whole while it fails with ErrRetryable,
+// like the statement outside of the transactions is repeated by the retry middleware
+func (that *database) execBound(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	var res Result
+	err := retry(ctx, that.retry, func() (err error) {
+		res, err = that.execBoundOnce(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+func (that *database) execBoundOnce(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	tx, err := that.beginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// queryBound - the query is repeated in the new transaction while it fails with ErrRetryable.
+// Failures after the rows are returned, including the commit, are reported to the caller.
+func (that *database) queryBound(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	var rs Rows
+	err := retry(ctx, that.retry, func() (err error) {
+		rs, err = that.queryBoundOnce(ctx, query, args...)
+		return err
+	})
+	return rs, err
+}
+
+func (that *database) queryBoundOnce(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	tx, err := that.beginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return &boundRows{Rows: rs, tx: tx, ctx: ctx}, nil
+}
+
+func (that *database) queryRowBound(ctx context.Context, query string, args ...interface{}) Row {
+	begin := func() (Tx, error) {
+		return that.beginTx(ctx, nil)
+	}
+	return &boundRow{begin: begin, retry: that.retry, query: query, args: args, ctx: ctx}
+}
+
+// boundRows - rows of the own transaction.
+// The transaction is finished when the rows are exhausted or closed,
+// so the failed commit is reported by Err after the iteration as well as by Close.
+type boundRows struct {
+	Rows
+	tx  Tx
+	ctx context.Context
+	err error
+}
+
+func (that *boundRows) Next() bool {
+	if that.Rows.Next() {
+		return true
+	}
+	that.finish()
+	return false
+}
+
+func (that *boundRows) Err() error {
+	if that.err != nil {
+		return that.err
+	}
+	return that.Rows.Err()
+}
+
+func (that *boundRows) Close() error {
+	that.finish()
+	return that.err
+}
+
+// finish - closes the rows and finishes the transaction once,
+// keeps the first error of the rows and the commit
+func (that *boundRows) finish() {
+	if that.tx == nil {
+		return
+	}
+
+	tx := that.tx
+	that.tx = nil
+	err := that.Rows.Close()
+	if err == nil {
+		err = that.Rows.Err()
+	}
+	if err != nil {
+		// Failure of the rows is the cause, the failed rollback only follows it
+		_ = tx.Rollback(that.ctx)
+		that.err = err
+		return
+	}
+	that.err = tx.Commit(that.ctx)
+}
+
+// boundRow - row of the own transaction.
+// The statement is executed by Scan within the transaction, so the row never scanned
+// does not keep the transaction and its connection open.
+// The transaction failed with ErrRetryable is repeated by Scan.
+type boundRow struct {
+	begin func() (Tx, error)
+	retry RetryOptions
+	query string
+	args  []interface{}
+	ctx   context.Context
+}
+
+func (that *boundRow) Scan(dest ...interface{}) error {
+	return retry(that.ctx, that.retry, func() error {
+		return that.scan(dest...)
+	})
+}
+
+func (that *boundRow) scan(dest ...interface{}) error {
+	tx, err := that.begin()
+	if err != nil {
+		return err
+	}
+
+	if err := tx.QueryRow(that.ctx, that.query, that.args...).Scan(dest...); err != nil {
+		_ = tx.Rollback(that.ctx)
+		return err
+	}
+	return tx.Commit(that.ctx)
+}
+
+// Err - errors of the statement are reported by Scan
+func (that *boundRow) Err() error {
+	return nil
+}
+
+var (
+	ErrIdentityRequired = errors.New("identity is required for read-write transaction")
+)
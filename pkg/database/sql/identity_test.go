@@ -0,0 +1,244 @@
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentityFromContext(t *testing.T) {
+	_, ok := IdentityFromContext(context.Background())
+	assert.False(t, ok)
+
+	_, ok = IdentityFromContext(WithIdentity(context.Background(), Identity{PrincipalId: 1}))
+	assert.False(t, ok, "identity without tenant")
+
+	expected := Identity{TenantId: uuid.New(), PrincipalId: 42}
+	identity, ok := IdentityFromContext(WithIdentity(context.Background(), expected))
+	require.True(t, ok)
+	assert.Equal(t, expected, identity)
+}
+
+func TestDatabase_IdentityRequired(t *testing.T) {
+	database := &database{db: &db{dbId: "test", identityRequired: true}}
+
+	called := false
+	err := database.Transact(context.Background(), func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrIdentityRequired)
+
+	err = database.Transaction(context.Background(), func(ctx context.Context, tx Tx) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrIdentityRequired)
+	assert.False(t, called)
+}
+
+type fakeTx struct {
+	Tx
+	row        Row
+	stmtErr    error
+	commitErr  error
+	committed  bool
+	rolledBack bool
+}
+
+func (that *fakeTx) DbId(context.Context) DbId {
+	return "test"
+}
+
+func (that *fakeTx) Exec(context.Context, string, ...interface{}) (Result, error) {
+	return nil, that.stmtErr
+}
+
+func (that *fakeTx) Query(context.Context, string, ...interface{}) (Rows, error) {
+	if that.stmtErr != nil {
+		return nil, that.stmtErr
+	}
+	return &fakeRows{}, nil
+}
+
+func (that *fakeTx) QueryRow(context.Context, string, ...interface{}) Row {
+	return that.row
+}
+
+func (that *fakeTx) Commit(context.Context) error {
+	that.committed = true
+	return that.commitErr
+}
+
+func (that *fakeTx) Rollback(context.Context) error {
+	that.rolledBack = true
+	return nil
+}
+
+type fakeRows struct {
+	Rows
+	count int
+	err   error
+}
+
+func (that *fakeRows) Next() bool {
+	if that.count == 0 {
+		return false
+	}
+	that.count--
+	return true
+}
+
+func (that *fakeRows) Close() error {
+	return nil
+}
+
+func (that *fakeRows) Err() error {
+	return that.err
+}
+
+type fakeRow struct {
+	err error
+}
+
+func (that *fakeRow) Scan(...interface{}) error {
+	return that.err
+}
+
+func (that *fakeRow) Err() error {
+	return that.err
+}
+
+func TestBoundRows_Close(t *testing.T) {
+	tx := &fakeTx{}
+	rows := &boundRows{Rows: &fakeRows{}, tx: tx, ctx: context.Background()}
+	require.NoError(t, rows.Close())
+	require.NoError(t, rows.Close(), "repeated close")
+	assert.True(t, tx.committed)
+	assert.False(t, tx.rolledBack)
+
+	tx = &fakeTx{}
+	rows = &boundRows{Rows: &fakeRows{err: ErrNoRows}, tx: tx, ctx: context.Background()}
+	assert.ErrorIs(t, rows.Close(), ErrNoRows)
+	assert.ErrorIs(t, rows.Close(), ErrNoRows, "repeated close")
+	assert.False(t, tx.committed)
+	assert.True(t, tx.rolledBack)
+
+	tx = &fakeTx{commitErr: ErrRetryable}
+	rows = &boundRows{Rows: &fakeRows{}, tx: tx, ctx: context.Background()}
+	assert.ErrorIs(t, rows.Close(), ErrRetryable)
+	assert.False(t, tx.rolledBack)
+}
+
+func TestBoundRows_Next(t *testing.T) {
+	tx := &fakeTx{commitErr: ErrRetryable}
+	rows := &boundRows{Rows: &fakeRows{count: 2}, tx: tx, ctx: context.Background()}
+
+	assert.True(t, rows.Next())
+	assert.True(t, rows.Next())
+	assert.False(t, tx.committed, "committed before the rows are exhausted")
+	assert.False(t, rows.Next())
+	assert.True(t, tx.committed)
+	assert.ErrorIs(t, rows.Err(), ErrRetryable, "failed commit is reported after the iteration")
+	assert.ErrorIs(t, rows.Close(), ErrRetryable)
+}
+
+func TestBoundRow_Scan(t *testing.T) {
+	tx := &fakeTx{row: &fakeRow{}}
+	row := &boundRow{begin: func() (Tx, error) { return tx, nil }, ctx: context.Background()}
+	assert.False(t, tx.committed, "transaction is started by Scan")
+	require.NoError(t, row.Scan())
+	assert.True(t, tx.committed)
+	assert.False(t, tx.rolledBack)
+
+	tx = &fakeTx{row: &fakeRow{err: ErrNoRows}}
+	row = &boundRow{begin: func() (Tx, error) { return tx, nil }, ctx: context.Background()}
+	assert.ErrorIs(t, row.Scan(), ErrNoRows)
+	assert.False(t, tx.committed)
+	assert.True(t, tx.rolledBack)
+
+	tx = &fakeTx{row: &fakeRow{}, commitErr: ErrRetryable}
+	row = &boundRow{begin: func() (Tx, error) { return tx, nil }, ctx: context.Background()}
+	assert.ErrorIs(t, row.Scan(), ErrRetryable)
+
+	row = &boundRow{begin: func() (Tx, error) { return nil, ErrIdentityRequired }, ctx: context.Background()}
+	assert.ErrorIs(t, row.Scan(), ErrIdentityRequired)
+}
+
+func TestDatabase_Bound(t *testing.T) {
+	database := &database{db: &db{dbId: "test"}}
+	assert.False(t, database.bound(context.Background()), "without identity")
+
+	ctx := WithIdentity(context.Background(), Identity{TenantId: uuid.New()})
+	assert.True(t, database.bound(ctx))
+	assert.False(t, database.bound(ToContext(ctx, &fakeTx{})), "in transaction")
+}
+
+// flakyScope - starts transactions whose statements fail with ErrRetryable until the failures are exhausted
+type flakyScope struct {
+	Tx
+	failures int
+	started  []*fakeTx
+}
+
+func (that *flakyScope) DbId(context.Context) DbId {
+	return "test"
+}
+
+func (that *flakyScope) BeginTx(context.Context, *TxOptions) (Tx, error) {
+	tx := &fakeTx{row: &fakeRow{}}
+	if len(that.started) < that.failures {
+		tx.stmtErr = ErrRetryable
+		tx.row = &fakeRow{err: ErrRetryable}
+	}
+	that.started = append(that.started, tx)
+	return tx, nil
+}
+
+func TestDatabase_BoundRetry(t *testing.T) {
+	database := &database{db: &db{
+		dbId:  "test",
+		retry: RetryOptions{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	}}
+
+	// The scope of the context stands for the pool starting the own transactions
+	run := func(failures int, statement func(ctx context.Context) error) (*flakyScope, error) {
+		scope := &flakyScope{failures: failures}
+		return scope, statement(ToContext(context.Background(), scope))
+	}
+	statements := map[string]func(ctx context.Context) error{
+		"exec": func(ctx context.Context) error {
+			_, err := database.execBound(ctx, "UPDATE")
+			return err
+		},
+		"query": func(ctx context.Context) error {
+			rs, err := database.queryBound(ctx, "SELECT")
+			if err != nil {
+				return err
+			}
+			return rs.Close()
+		},
+		"query row": func(ctx context.Context) error {
+			return database.queryRowBound(ctx, "SELECT").Scan()
+		},
+	}
+
+	for name, statement := range statements {
+		t.Run(name, func(t *testing.T) {
+			scope, err := run(2, statement)
+			require.NoError(t, err)
+			require.Len(t, scope.started, 3, "transaction is repeated as a whole")
+			assert.True(t, scope.started[0].rolledBack)
+			assert.True(t, scope.started[1].rolledBack)
+			assert.True(t, scope.started[2].committed)
+
+			scope, err = run(3, statement)
+			assert.ErrorIs(t, err, ErrRetryable)
+			assert.Len(t, scope.started, 3, "attempts are limited")
+		})
+	}
+}
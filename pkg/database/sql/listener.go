@@ -0,0 +1,272 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/adverax/metacrm/pkg/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Notification - message sent by NOTIFY to the listened channel
+type Notification struct {
+	Channel string
+	Payload string
+	// Reconnect - listening of the channel was (re)established rather than notified.
+	// Notifications sent while the listener was disconnected are lost,
+	// so handlers must check the state they watch.
+	Reconnect bool
+}
+
+// NotificationHandler - receives notifications in the listener goroutine, so it must not block
+type NotificationHandler func(notification Notification)
+
+// Listener - receives notifications of the channels on the dedicated connection.
+// The connection is taken from the pool and detached from it, so it does not occupy the pool.
+// Broken connection is established again after the backoff and the channels are listened again.
+type Listener struct {
+	pool       *pgxpool.Pool
+	channels   []string
+	backoff    time.Duration
+	maxBackoff time.Duration
+	logger     log.Logger
+
+	mx       sync.Mutex
+	handlers map[string]map[int]NotificationHandler
+	nextId   int
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// Start - starts listening in background
+func (that *Listener) Start(ctx context.Context) error {
+	ctx, that.cancel = context.WithCancel(ctx)
+	that.wg.Add(1)
+	go that.serve(ctx)
+	return nil
+}
+
+// Stop - stops listening and closes the connection
+func (that *Listener) Stop() {
+	if that.cancel == nil {
+		return
+	}
+
+	that.cancel()
+	that.wg.Wait()
+}
+
+// Handle - registers handler of the channel notifications, returns function removing the handler
+func (that *Listener) Handle(channel string, handler NotificationHandler) (func(), error) {
+	if !slices.Contains(that.channels, channel) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownChannel, channel)
+	}
+
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	id := that.nextId
+	that.nextId++
+	that.handlers[channel][id] = handler
+
+	return func() {
+		that.mx.Lock()
+		defer that.mx.Unlock()
+		delete(that.handlers[channel], id)
+	}, nil
+}
+
+// Signal - returns signal raised by notifications and reconnections of the channel
+func (that *Listener) Signal(channel string) (*Signal, error) {
+	signal := &Signal{c: make(chan struct{}, 1)}
+	stop, err := that.Handle(channel, func(Notification) {
+		signal.raise()
+	})
+	if err != nil {
+		return nil, err
+	}
+	signal.stop = stop
+	return signal, nil
+}
+
+func (that *Listener) serve(ctx context.Context) {
+	defer that.wg.Done()
+
+	backoff := that.backoff
+	for {
+		connected, err := that.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = that.backoff
+		}
+
+		that.logger.
+			WithError(err).
+			WithFields(log.Fields{"channels": that.channels, "backoff": backoff.String()}).
+			Warning(ctx, "database listener disconnected")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, that.maxBackoff)
+	}
+}
+
+// listen - listens the channels until the connection fails, reports whether the connection was established
+func (that *Listener) listen(ctx context.Context) (bool, error) {
+	conn, err := that.connect(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	for _, channel := range that.channels {
+		that.dispatch(Notification{Channel: channel, Reconnect: true})
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		that.dispatch(Notification{Channel: notification.Channel, Payload: notification.Payload})
+	}
+}
+
+// connect - detaches connection from the pool and listens the channels on it
+func (that *Listener) connect(ctx context.Context) (*pgx.Conn, error) {
+	pooled, err := that.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire listener connection: %w", err)
+	}
+	conn := pooled.Hijack()
+
+	for _, channel := range that.channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			_ = conn.Close(context.WithoutCancel(ctx))
+			return nil, fmt.Errorf("listen %s: %w", channel, err)
+		}
+	}
+	return conn, nil
+}
+
+func (that *Listener) dispatch(notification Notification) {
+	that.mx.Lock()
+	handlers := make([]NotificationHandler, 0, len(that.handlers[notification.Channel]))
+	for _, handler := range that.handlers[notification.Channel] {
+		handlers = append(handlers, handler)
+	}
+	that.mx.Unlock()
+
+	for _, handler := range handlers {
+		handler(notification)
+	}
+}
+
+// Signal - coalescing wakeup of the consumer, pending wakeup absorbs the following ones
+type Signal struct {
+	c    chan struct{}
+	stop func()
+}
+
+// C - receives wakeups, nil signal never wakes up, so polling consumers may use it unconditionally
+func (that *Signal) C() <-chan struct{} {
+	if that == nil {
+		return nil
+	}
+	return that.c
+}
+
+// Close - stops wakeups of the signal
+func (that *Signal) Close() {
+	if that != nil && that.stop != nil {
+		that.stop()
+	}
+}
+
+func (that *Signal) raise() {
+	select {
+	case that.c <- struct{}{}:
+	default:
+	}
+}
+
+type ListenerBuilder struct {
+	listener *Listener
+}
+
+func NewListenerBuilder() *ListenerBuilder {
+	return &ListenerBuilder{
+		listener: &Listener{
+			backoff:    time.Second,
+			maxBackoff: time.Minute,
+			handlers:   make(map[string]map[int]NotificationHandler),
+		},
+	}
+}
+
+func (that *ListenerBuilder) WithPool(pool *pgxpool.Pool) *ListenerBuilder {
+	that.listener.pool = pool
+	return that
+}
+
+func (that *ListenerBuilder) WithChannels(channels ...string) *ListenerBuilder {
+	that.listener.channels = append(that.listener.channels, channels...)
+	return that
+}
+
+// WithBackoff - pause before reconnection, doubled after every failed connection up to maxBackoff
+func (that *ListenerBuilder) WithBackoff(backoff, maxBackoff time.Duration) *ListenerBuilder {
+	that.listener.backoff = backoff
+	that.listener.maxBackoff = maxBackoff
+	return that
+}
+
+func (that *ListenerBuilder) WithLogger(logger log.Logger) *ListenerBuilder {
+	that.listener.logger = logger
+	return that
+}
+
+func (that *ListenerBuilder) Build() (*Listener, error) {
+	if err := that.checkRequiredFields(); err != nil {
+		return nil, err
+	}
+
+	for _, channel := range that.listener.channels {
+		that.listener.handlers[channel] = make(map[int]NotificationHandler)
+	}
+	return that.listener, nil
+}
+
+func (that *ListenerBuilder) checkRequiredFields() error {
+	if that.listener.pool == nil {
+		return ErrPoolRequired
+	}
+	if len(that.listener.channels) == 0 {
+		return ErrChannelsRequired
+	}
+	if that.listener.backoff <= 0 || that.listener.maxBackoff < that.listener.backoff {
+		return ErrInvalidBackoff
+	}
+	if that.listener.logger == nil {
+		return ErrLoggerRequired
+	}
+	return nil
+}
+
+var (
+	ErrPoolRequired     = errors.New("pool is required")
+	ErrChannelsRequired = errors.New("channels are required")
+	ErrInvalidBackoff   = errors.New("backoff must be positive and not exceed max backoff")
+	ErrLoggerRequired   = errors.New("logger is required")
+	ErrUnknownChannel   = errors.New("channel is not listened")
+)
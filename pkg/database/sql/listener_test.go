@@ -0,0 +1,66 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestListener(channels ...string) *Listener {
+	listener := &Listener{channels: channels, handlers: make(map[string]map[int]NotificationHandler)}
+	for _, channel := range channels {
+		listener.handlers[channel] = make(map[int]NotificationHandler)
+	}
+	return listener
+}
+
+func TestListener_Handle(t *testing.T) {
+	listener := newTestListener("outbox", "webhooks")
+
+	var received []Notification
+	stop, err := listener.Handle("outbox", func(notification Notification) {
+		received = append(received, notification)
+	})
+	require.NoError(t, err)
+
+	listener.dispatch(Notification{Channel: "outbox", Payload: "1"})
+	listener.dispatch(Notification{Channel: "webhooks", Payload: "2"})
+	listener.dispatch(Notification{Channel: "outbox", Reconnect: true})
+	stop()
+	listener.dispatch(Notification{Channel: "outbox", Payload: "3"})
+
+	assert.Equal(t, []Notification{
+		{Channel: "outbox", Payload: "1"},
+		{Channel: "outbox", Reconnect: true},
+	}, received)
+
+	_, err = listener.Handle("unknown", func(Notification) {})
+	assert.ErrorIs(t, err, ErrUnknownChannel)
+}
+
+func TestListener_Signal(t *testing.T) {
+	listener := newTestListener("outbox")
+
+	signal, err := listener.Signal("outbox")
+	require.NoError(t, err)
+
+	listener.dispatch(Notification{Channel: "outbox"})
+	listener.dispatch(Notification{Channel: "outbox"})
+
+	assert.Len(t, signal.C(), 1, "wakeups are coalesced")
+	<-signal.C()
+
+	signal.Close()
+	listener.dispatch(Notification{Channel: "outbox"})
+	assert.Len(t, signal.C(), 0)
+
+	var none *Signal
+	assert.Nil(t, none.C())
+	none.Close()
+}
+
+func TestListenerBuilder_Build(t *testing.T) {
+	_, err := NewListenerBuilder().WithChannels("outbox").Build()
+	assert.ErrorIs(t, err, ErrPoolRequired)
+}
@@ -0,0 +1,168 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets - upper bounds of the latency histogram buckets
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// MetricsBehavior - latency histograms of the statements keyed by SQLFingerprint.
+// Duration of the query does not include reading of its rows.
+type MetricsBehavior struct {
+	name    string
+	buckets []time.Duration
+
+	mx     sync.Mutex
+	series map[string]*latencySeries
+}
+
+// LatencySeries - latency histogram of the statements having the same fingerprint
+type LatencySeries struct {
+	Fingerprint string
+	Query       string          // normalized statement
+	Buckets     []time.Duration // upper bounds of the buckets
+	Counts      []uint64        // cumulative number of the statements within the buckets
+	Count       uint64
+	Errors      uint64
+	Sum         time.Duration
+}
+
+type latencySeries struct {
+	query  string
+	counts []uint64
+	count  uint64
+	errors uint64
+	sum    time.Duration
+}
+
+// NewMetricsBehavior - creates histograms exported as the metric name,
+// DefaultLatencyBuckets are used without buckets
+func NewMetricsBehavior(name string, buckets ...time.Duration) *MetricsBehavior {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+
+	return &MetricsBehavior{
+		name:    name,
+		buckets: buckets,
+		series:  make(map[string]*latencySeries),
+	}
+}
+
+func (that *MetricsBehavior) Apply(
+	ctx context.Context,
+	action func(ctx context.Context) error,
+	query string,
+	args ...interface{},
+) error {
+	started := time.Now()
+	err := action(ctx)
+	that.observe(query, time.Since(started), err)
+	return err
+}
+
+func (that *MetricsBehavior) observe(query string, duration time.Duration, err error) {
+	fingerprint := SQLFingerprint(query)
+
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	series, ok := that.series[fingerprint]
+	if !ok {
+		series = &latencySeries{query: NormalizeSQL(query), counts: make([]uint64, len(that.buckets))}
+		that.series[fingerprint] = series
+	}
+
+	for i, bound := range that.buckets {
+		if duration <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += duration
+	if err != nil {
+		series.errors++
+	}
+}
+
+// Snapshot - returns copy of the histograms ordered by fingerprint
+func (that *MetricsBehavior) Snapshot() []LatencySeries {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	result := make([]LatencySeries, 0, len(that.series))
+	for fingerprint, series := range that.series {
+		result = append(result, LatencySeries{
+			Fingerprint: fingerprint,
+			Query:       series.query,
+			Buckets:     that.buckets,
+			Counts:      slices.Clone(series.counts),
+			Count:       series.count,
+			Errors:      series.errors,
+			Sum:         series.sum,
+		})
+	}
+	slices.SortFunc(result, func(a, b LatencySeries) int {
+		return strings.Compare(a.Fingerprint, b.Fingerprint)
+	})
+	return result
+}
+
+// WriteTo - writes the histograms in the Prometheus text exposition format,
+// latency is exported in seconds with label fingerprint
+func (that *MetricsBehavior) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	write := func(format string, args ...any) error {
+		n, err := fmt.Fprintf(w, format, args...)
+		written += int64(n)
+		return err
+	}
+
+	if err := write("# HELP %s Latency of the database statements by fingerprint.\n# TYPE %s histogram\n", that.name, that.name); err != nil {
+		return written, err
+	}
+	for _, series := range that.Snapshot() {
+		for i, bound := range series.Buckets {
+			err := write("%s_bucket{fingerprint=%q,le=%q} %d\n", that.name, series.Fingerprint, seconds(bound), series.Counts[i])
+			if err != nil {
+				return written, err
+			}
+		}
+		err := write(
+			"%s_bucket{fingerprint=%q,le=\"+Inf\"} %d\n%s_sum{fingerprint=%q} %s\n%s_count{fingerprint=%q} %d\n",
+			that.name, series.Fingerprint, series.Count,
+			that.name, series.Fingerprint, seconds(series.Sum),
+			that.name, series.Fingerprint, series.Count,
+		)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
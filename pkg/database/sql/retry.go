@@ -0,0 +1,57 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryOptions - re-running of the transactions failed with ErrRetryable
+// (serialization failures and deadlocks)
+type RetryOptions struct {
+	MaxAttempts int           // Number of attempts including the first one
+	Backoff     time.Duration // Pause before the second attempt, doubled after every attempt up to MaxBackoff
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetryOptions - retries of the short transactions
+var DefaultRetryOptions = RetryOptions{
+	MaxAttempts: 5,
+	Backoff:     10 * time.Millisecond,
+	MaxBackoff:  500 * time.Millisecond,
+}
+
+// TransactionWithRetry - runs the action in the transaction and runs it again in the new transaction
+// while it fails with ErrRetryable, so the action must not have effects outside of the transaction.
+// Nested transaction is not retried: the outer transaction is aborted by the failure and is retried as a whole.
+func (that *database) TransactionWithRetry(ctx context.Context, action Action, options *TxOptions) error {
+	if that.InTransaction(ctx) {
+		return that.TransactionTx(ctx, action, options)
+	}
+
+	return retry(ctx, that.retry, func() error {
+		return that.TransactionTx(ctx, action, options)
+	})
+}
+
+// retry - calls fn until it succeeds, fails with not retryable error or the attempts are exhausted.
+// Pauses between the attempts are jittered, so the conflicting transactions do not collide again.
+func retry(ctx context.Context, options RetryOptions, fn func() error) error {
+	backoff := options.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !errors.Is(err, ErrRetryable) || attempt >= options.MaxAttempts {
+			return err
+		}
+
+		// Equal jitter: half of the backoff is kept, the other half is random
+		pause := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(pause):
+		}
+		backoff = min(backoff*2, options.MaxBackoff)
+	}
+}
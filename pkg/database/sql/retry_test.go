@@ -0,0 +1,62 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	options := RetryOptions{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	retryable := fmt.Errorf("action: %w", ErrRetryable)
+
+	t.Run("retries retryable errors", func(t *testing.T) {
+		attempts := 0
+		err := retry(context.Background(), options, func() error {
+			attempts++
+			if attempts < 3 {
+				return retryable
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("stops after max attempts", func(t *testing.T) {
+		attempts := 0
+		err := retry(context.Background(), options, func() error {
+			attempts++
+			return retryable
+		})
+		assert.ErrorIs(t, err, ErrRetryable)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		attempts := 0
+		err := retry(context.Background(), options, func() error {
+			attempts++
+			return ErrInvalid
+		})
+		assert.ErrorIs(t, err, ErrInvalid)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("stops when context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		attempts := 0
+		err := retry(ctx, RetryOptions{MaxAttempts: 3, Backoff: time.Hour, MaxBackoff: time.Hour}, func() error {
+			attempts++
+			cancel()
+			return retryable
+		})
+		assert.ErrorIs(t, err, ErrRetryable)
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, 1, attempts)
+	})
+}
@@ -86,6 +86,7 @@ type DB interface {
 	Transact(ctx context.Context, action Act) error
 	Transaction(ctx context.Context, action Action) error
 	TransactionTx(ctx context.Context, action Action, options *TxOptions) error
+	TransactionWithRetry(ctx context.Context, action Action, options *TxOptions) error
 	InTransaction(ctx context.Context) bool
 	WithCancel(ctx context.Context) Scope
 	Close()
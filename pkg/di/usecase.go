@@ -11,11 +11,14 @@ type Action func(ctx context.Context) error
 
 type usecase[T any] struct {
 	*App
-	config T
-	action Action
+	config  T
+	action  Action
+	daemons []Daemon
 }
 
-func NewUsecase[T any](config T, action Action) Constructor[Application] {
+// NewUsecase - makes application that runs the action.
+// Daemons are constructed before the action, so components they touch are initialized before it runs.
+func NewUsecase[T any](config T, action Action, daemons ...Daemon) Constructor[Application] {
 	return func(ctx context.Context) Application {
 		app := GetAppFromContext(ctx)
 		err := SetVariable(ctx, ConfigKey, config)
@@ -23,13 +26,18 @@ func NewUsecase[T any](config T, action Action) Constructor[Application] {
 			panic("failed to set config variable: " + err.Error())
 		}
 		return &usecase[T]{
-			App:    app,
-			config: config,
-			action: action,
+			App:     app,
+			config:  config,
+			action:  action,
+			daemons: daemons,
 		}
 	}
 }
 
+func (that *usecase[T]) Daemons(_ context.Context) []Daemon {
+	return that.daemons
+}
+
 func (that *usecase[T]) Run(ctx context.Context) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
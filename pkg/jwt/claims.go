@@ -0,0 +1,48 @@
+package jwt
+
+import "slices"
+
+// PrincipalType - kind of the principal the token is issued to
+type PrincipalType string
+
+const (
+	PrincipalTypeUser     PrincipalType = "user"
+	PrincipalTypeService  PrincipalType = "service"
+	PrincipalTypeExternal PrincipalType = "external"
+	PrincipalTypeSystem   PrincipalType = "system"
+)
+
+func (that PrincipalType) IsValid() bool {
+	switch that {
+	case PrincipalTypeUser, PrincipalTypeService, PrincipalTypeExternal, PrincipalTypeSystem:
+		return true
+	default:
+		return false
+	}
+}
+
+// Claims - payload of the token, see PrincipalJWT in contracts/api.yml
+type Claims struct {
+	Subject    string        `json:"sub"`             // principal id
+	Type       PrincipalType `json:"typ"`             // type of principal
+	TenantId   string        `json:"tid"`             // tenant id
+	Actor      *string       `json:"act"`             // actor principal id
+	OnBehalfOf *string       `json:"obo"`             // on-behalf-of principal id
+	Scope      []string      `json:"scope,omitempty"` // list of rights
+	Generation int64         `json:"gen"`             // version of rights
+	IssuedAt   int64         `json:"iat"`             // epoch seconds
+	ExpiresAt  int64         `json:"exp"`             // epoch seconds
+	Id         string        `json:"jti"`
+}
+
+// HasScope - checks whether the token grants the right
+func (that *Claims) HasScope(scope string) bool {
+	return slices.Contains(that.Scope, scope)
+}
+
+func (that *Claims) validate() error {
+	if that.Subject == "" || that.TenantId == "" || !that.Type.IsValid() {
+		return ErrInvalidClaims
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"errors"
+	"time"
+)
+
+// Issuer - signs tokens with the current key of the key set
+type Issuer struct {
+	keys  *KeySet
+	ttl   time.Duration
+	clock func() time.Time
+}
+
+// Issue - signs the claims. Missing iat, exp and jti are filled in place.
+func (that *Issuer) Issue(claims *Claims) (string, error) {
+	if err := claims.validate(); err != nil {
+		return "", err
+	}
+
+	now := that.clock()
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = now.Unix()
+	}
+	if claims.ExpiresAt == 0 {
+		claims.ExpiresAt = now.Add(that.ttl).Unix()
+	}
+	if claims.Id == "" {
+		id, err := newId()
+		if err != nil {
+			return "", err
+		}
+		claims.Id = id
+	}
+
+	return sign(that.keys.Current(), claims)
+}
+
+// TTL - lifetime of the issued tokens
+func (that *Issuer) TTL() time.Duration {
+	return that.ttl
+}
+
+type IssuerBuilder struct {
+	issuer *Issuer
+}
+
+func NewIssuerBuilder() *IssuerBuilder {
+	return &IssuerBuilder{
+		issuer: &Issuer{
+			ttl:   time.Hour,
+			clock: time.Now,
+		},
+	}
+}
+
+func (that *IssuerBuilder) WithKeys(keys *KeySet) *IssuerBuilder {
+	that.issuer.keys = keys
+	return that
+}
+
+func (that *IssuerBuilder) WithTTL(ttl time.Duration) *IssuerBuilder {
+	that.issuer.ttl = ttl
+	return that
+}
+
+func (that *IssuerBuilder) WithClock(clock func() time.Time) *IssuerBuilder {
+	that.issuer.clock = clock
+	return that
+}
+
+func (that *IssuerBuilder) Build() (*Issuer, error) {
+	if err := that.checkRequiredFields(); err != nil {
+		return nil, err
+	}
+
+	return that.issuer, nil
+}
+
+func (that *IssuerBuilder) checkRequiredFields() error {
+	if that.issuer.keys == nil {
+		return ErrKeysRequired
+	}
+	if that.issuer.ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	if that.issuer.clock == nil {
+		return ErrClockRequired
+	}
+	return nil
+}
+
+var (
+	ErrKeysRequired  = errors.New("keys are required")
+	ErrClockRequired = errors.New("clock is required")
+	ErrInvalidTTL    = errors.New("ttl must be positive")
+)
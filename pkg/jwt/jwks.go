@@ -0,0 +1,112 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+)
+
+// JWK - public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func NewJWK(id string, key *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: AlgRS256,
+		Kid: id,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func (that *JWK) PublicKey() (*rsa.PublicKey, error) {
+	if that.Kty != "RSA" {
+		return nil, fmt.Errorf("%w: unsupported key type %q", ErrInvalidJWK, that.Kty)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(that.N)
+	if err != nil {
+		return nil, fmt.Errorf("%w: modulus: %w", ErrInvalidJWK, err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(that.E)
+	if err != nil {
+		return nil, fmt.Errorf("%w: exponent: %w", ErrInvalidJWK, err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 2 || exponent.Int64() > 1<<31-1 {
+		return nil, ErrInvalidJWK
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// JWKS - JSON Web Key Set, published by the issuer
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// StaticKeys - key source built from a fetched key set
+type StaticKeys map[string]*rsa.PublicKey
+
+// NewStaticKeys - converts key set into key source, keys not intended for signature are skipped
+func NewStaticKeys(jwks JWKS) (StaticKeys, error) {
+	res := make(StaticKeys, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+		res[jwk.Kid] = key
+	}
+	return res, nil
+}
+
+func (that StaticKeys) PublicKey(id string) (*rsa.PublicKey, bool) {
+	key, ok := that[id]
+	return key, ok
+}
+
+// FetchJWKS - loads key set published by the issuer
+func FetchJWKS(ctx context.Context, client *http.Client, url string) (JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return JWKS{}, fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return JWKS{}, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return JWKS{}, fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return JWKS{}, fmt.Errorf("fetch jwks: %w", err)
+	}
+	return jwks, nil
+}
+
+var (
+	ErrInvalidJWK = errors.New("invalid JSON web key")
+)
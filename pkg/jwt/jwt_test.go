@@ -0,0 +1,254 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Unix(1737650000, 0)
+
+func clock() time.Time {
+	return now
+}
+
+func newClaims() *Claims {
+	actor := "12345"
+	return &Claims{
+		Subject:    "12345",
+		Type:       PrincipalTypeService,
+		TenantId:   "11111111-1111-1111-1111-111111111111",
+		Actor:      &actor,
+		Scope:      []string{"crm.read", "crm.orders.write"},
+		Generation: 42,
+	}
+}
+
+func newKeySet(t *testing.T, id string) *KeySet {
+	key, err := GenerateKey(id)
+	require.NoError(t, err)
+	return NewKeySet(key)
+}
+
+func issue(t *testing.T, keys *KeySet, claims *Claims) string {
+	issuer, err := NewIssuerBuilder().WithKeys(keys).WithClock(clock).Build()
+	require.NoError(t, err)
+	token, err := issuer.Issue(claims)
+	require.NoError(t, err)
+	return token
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	keys := newKeySet(t, "k1")
+	claims := newClaims()
+	token := issue(t, keys, claims)
+
+	assert.Equal(t, now.Unix(), claims.IssuedAt)
+	assert.Equal(t, now.Add(time.Hour).Unix(), claims.ExpiresAt)
+	assert.Len(t, claims.Id, 36)
+
+	verifier, err := NewVerifierBuilder().WithKeys(keys).WithClock(clock).Build()
+	require.NoError(t, err)
+
+	actual, err := verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, claims, actual)
+	assert.True(t, actual.HasScope("crm.read"))
+	assert.Nil(t, actual.OnBehalfOf)
+}
+
+func TestVerify(t *testing.T) {
+	keys := newKeySet(t, "k1")
+	token := issue(t, keys, newClaims())
+
+	type Test struct {
+		name     string
+		token    string
+		keys     KeySource
+		at       time.Time
+		expected error
+	}
+
+	tests := []Test{
+		{
+			name:     "within leeway after expiration",
+			token:    token,
+			keys:     keys,
+			at:       now.Add(time.Hour + 10*time.Second),
+			expected: nil,
+		},
+		{
+			name:     "expired",
+			token:    token,
+			keys:     keys,
+			at:       now.Add(time.Hour + time.Minute),
+			expected: ErrExpired,
+		},
+		{
+			name:     "within leeway before issue",
+			token:    token,
+			keys:     keys,
+			at:       now.Add(-10 * time.Second),
+			expected: nil,
+		},
+		{
+			name:     "not yet valid",
+			token:    token,
+			keys:     keys,
+			at:       now.Add(-time.Minute),
+			expected: ErrNotYetValid,
+		},
+		{
+			name:     "unknown key",
+			token:    token,
+			keys:     newKeySet(t, "k2"),
+			at:       now,
+			expected: ErrUnknownKey,
+		},
+		{
+			name:     "foreign key with the same id",
+			token:    token,
+			keys:     newKeySet(t, "k1"),
+			at:       now,
+			expected: ErrInvalidSignature,
+		},
+		{
+			name:     "tampered claims",
+			token:    tamper(t, token),
+			keys:     keys,
+			at:       now,
+			expected: ErrInvalidSignature,
+		},
+		{
+			name:     "unsigned",
+			token:    "eyJhbGciOiJub25lIiwia2lkIjoiazEifQ.e30.",
+			keys:     keys,
+			at:       now,
+			expected: ErrUnsupportedAlgorithm,
+		},
+		{
+			name:     "malformed",
+			token:    "token",
+			keys:     keys,
+			at:       now,
+			expected: ErrMalformed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifier, err := NewVerifierBuilder().
+				WithKeys(test.keys).
+				WithClock(func() time.Time { return test.at }).
+				Build()
+			require.NoError(t, err)
+
+			_, err = verifier.Verify(context.Background(), test.token)
+			assert.ErrorIs(t, err, test.expected)
+		})
+	}
+}
+
+func TestVerifyGeneration(t *testing.T) {
+	keys := newKeySet(t, "k1")
+	token := issue(t, keys, newClaims())
+
+	for gen, expected := range map[int64]error{41: nil, 42: nil, 43: ErrStaleGeneration} {
+		verifier, err := NewVerifierBuilder().
+			WithKeys(keys).
+			WithClock(clock).
+			WithGenerations(GenerationsFunc(func(ctx context.Context, claims *Claims) (int64, error) {
+				return gen, nil
+			})).
+			Build()
+		require.NoError(t, err)
+
+		_, err = verifier.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, expected)
+	}
+
+	verifier, err := NewVerifierBuilder().
+		WithKeys(keys).
+		WithClock(clock).
+		WithGenerations(GenerationsFunc(func(ctx context.Context, claims *Claims) (int64, error) {
+			return 0, context.DeadlineExceeded
+		})).
+		Build()
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, ErrGenerationUnavailable)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRotation(t *testing.T) {
+	keys := newKeySet(t, "k1")
+	old := issue(t, keys, newClaims())
+
+	next, err := GenerateKey("k2")
+	require.NoError(t, err)
+	keys.Rotate(next)
+	fresh := issue(t, keys, newClaims())
+
+	verifier, err := NewVerifierBuilder().WithKeys(keys).WithClock(clock).Build()
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(context.Background(), old)
+	assert.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), fresh)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, keys.Retire("k2"), ErrRetireCurrentKey)
+	require.NoError(t, keys.Retire("k1"))
+
+	_, err = verifier.Verify(context.Background(), old)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	_, err = verifier.Verify(context.Background(), fresh)
+	assert.NoError(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	keys := newKeySet(t, "k1")
+	next, err := GenerateKey("k2")
+	require.NoError(t, err)
+	keys.Rotate(next)
+	token := issue(t, keys, newClaims())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(keys.JWKS())
+	}))
+	defer server.Close()
+
+	jwks, err := FetchJWKS(context.Background(), server.Client(), server.URL)
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "k2", jwks.Keys[0].Kid)
+	assert.Equal(t, "AQAB", jwks.Keys[0].E)
+
+	static, err := NewStaticKeys(jwks)
+	require.NoError(t, err)
+
+	verifier, err := NewVerifierBuilder().WithKeys(static).WithClock(clock).Build()
+	require.NoError(t, err)
+
+	claims, err := verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "12345", claims.Subject)
+}
+
+func tamper(t *testing.T, token string) string {
+	parts := strings.Split(token, ".")
+	claims := newClaims()
+	claims.Generation = 100
+	data, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(data) + "." + parts[2]
+}
@@ -0,0 +1,135 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+const keyBits = 2048
+
+// Key - RSA key used to sign tokens
+type Key struct {
+	Id      string
+	private *rsa.PrivateKey
+}
+
+// GenerateKey - generates a new signing key
+func GenerateKey(id string) (*Key, error) {
+	private, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	return &Key{Id: id, private: private}, nil
+}
+
+// ParseKey - parses signing key from PEM (PKCS#1 or PKCS#8)
+func ParseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	if private, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return &Key{Id: id, private: private}, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return &Key{Id: id, private: private}, nil
+}
+
+func (that *Key) PublicKey() *rsa.PublicKey {
+	return &that.private.PublicKey
+}
+
+// KeySource - provides public keys for token verification
+type KeySource interface {
+	PublicKey(id string) (*rsa.PublicKey, bool)
+}
+
+// KeySet - signing key together with the keys that are still accepted for verification.
+// On rotation the previous signing key remains published until it is retired,
+// so tokens signed before the rotation stay valid until they expire.
+type KeySet struct {
+	mx      sync.RWMutex
+	current *Key
+	keys    []*Key // published keys, the newest first
+}
+
+func NewKeySet(current *Key, previous ...*Key) *KeySet {
+	keys := make([]*Key, 0, len(previous)+1)
+	keys = append(keys, current)
+	keys = append(keys, previous...)
+	return &KeySet{current: current, keys: keys}
+}
+
+// Rotate - makes the key current, the previous keys remain available for verification
+func (that *KeySet) Rotate(key *Key) {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	that.keys = slices.DeleteFunc(that.keys, func(k *Key) bool { return k.Id == key.Id })
+	that.keys = slices.Insert(that.keys, 0, key)
+	that.current = key
+}
+
+// Retire - removes the key from verification, the current key can not be retired
+func (that *KeySet) Retire(id string) error {
+	that.mx.Lock()
+	defer that.mx.Unlock()
+
+	if that.current.Id == id {
+		return ErrRetireCurrentKey
+	}
+	that.keys = slices.DeleteFunc(that.keys, func(k *Key) bool { return k.Id == id })
+	return nil
+}
+
+// Current - returns the key used for signing
+func (that *KeySet) Current() *Key {
+	that.mx.RLock()
+	defer that.mx.RUnlock()
+
+	return that.current
+}
+
+func (that *KeySet) PublicKey(id string) (*rsa.PublicKey, bool) {
+	that.mx.RLock()
+	defer that.mx.RUnlock()
+
+	for _, key := range that.keys {
+		if key.Id == id {
+			return key.PublicKey(), true
+		}
+	}
+	return nil, false
+}
+
+// JWKS - returns published keys in JSON Web Key Set format
+func (that *KeySet) JWKS() JWKS {
+	that.mx.RLock()
+	defer that.mx.RUnlock()
+
+	res := JWKS{Keys: make([]JWK, 0, len(that.keys))}
+	for _, key := range that.keys {
+		res.Keys = append(res.Keys, NewJWK(key.Id, key.PublicKey()))
+	}
+	return res
+}
+
+var (
+	ErrInvalidKey       = errors.New("invalid RSA private key")
+	ErrRetireCurrentKey = errors.New("current signing key can not be retired")
+)
@@ -0,0 +1,132 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const DefaultLeeway = 30 * time.Second
+
+// Generations - provides the actual version of the principal rights.
+// Tokens issued before the rights were changed are rejected.
+type Generations interface {
+	Generation(ctx context.Context, claims *Claims) (int64, error)
+}
+
+type GenerationsFunc func(ctx context.Context, claims *Claims) (int64, error)
+
+func (that GenerationsFunc) Generation(ctx context.Context, claims *Claims) (int64, error) {
+	return that(ctx, claims)
+}
+
+// Verifier - checks signature, lifetime and rights version of the tokens
+type Verifier struct {
+	keys        KeySource
+	leeway      time.Duration
+	clock       func() time.Time
+	generations Generations
+}
+
+// Verify - returns claims of the valid token
+func (that *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	claims, err := parse(token, that.keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.validate(); err != nil {
+		return nil, err
+	}
+
+	now := that.clock()
+	if now.Add(-that.leeway).Unix() >= claims.ExpiresAt {
+		return nil, ErrExpired
+	}
+	if now.Add(that.leeway).Unix() < claims.IssuedAt {
+		return nil, ErrNotYetValid
+	}
+
+	if that.generations != nil {
+		gen, err := that.generations.Generation(ctx, claims)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrGenerationUnavailable, err)
+		}
+		if claims.Generation < gen {
+			return nil, ErrStaleGeneration
+		}
+	}
+
+	return claims, nil
+}
+
+type VerifierBuilder struct {
+	verifier *Verifier
+}
+
+func NewVerifierBuilder() *VerifierBuilder {
+	return &VerifierBuilder{
+		verifier: &Verifier{
+			leeway: DefaultLeeway,
+			clock:  time.Now,
+		},
+	}
+}
+
+func (that *VerifierBuilder) WithKeys(keys KeySource) *VerifierBuilder {
+	that.verifier.keys = keys
+	return that
+}
+
+// WithLeeway - allowed clock skew between the issuer and the verifier
+func (that *VerifierBuilder) WithLeeway(leeway time.Duration) *VerifierBuilder {
+	that.verifier.leeway = leeway
+	return that
+}
+
+func (that *VerifierBuilder) WithClock(clock func() time.Time) *VerifierBuilder {
+	that.verifier.clock = clock
+	return that
+}
+
+// WithGenerations - enables rights version check, it is skipped by default
+func (that *VerifierBuilder) WithGenerations(generations Generations) *VerifierBuilder {
+	that.verifier.generations = generations
+	return that
+}
+
+func (that *VerifierBuilder) Build() (*Verifier, error) {
+	if err := that.checkRequiredFields(); err != nil {
+		return nil, err
+	}
+
+	return that.verifier, nil
+}
+
+func (that *VerifierBuilder) checkRequiredFields() error {
+	if that.verifier.keys == nil {
+		return ErrKeysRequired
+	}
+	if that.verifier.leeway < 0 {
+		return ErrInvalidLeeway
+	}
+	if that.verifier.clock == nil {
+		return ErrClockRequired
+	}
+	return nil
+}
+
+var (
+	ErrInvalidLeeway        = errors.New("leeway must not be negative")
+	ErrMalformed            = errors.New("token is malformed")
+	ErrUnsupportedAlgorithm = errors.New("token signing algorithm is not supported")
+	ErrUnknownKey           = errors.New("token is signed with unknown key")
+	ErrInvalidSignature     = errors.New("token signature is invalid")
+	ErrInvalidClaims        = errors.New("token claims are invalid")
+	ErrExpired              = errors.New("token is expired")
+	ErrNotYetValid          = errors.New("token is not valid yet")
+	ErrStaleGeneration      = errors.New("token was issued for outdated rights")
+
+	// ErrGenerationUnavailable - verification failed, not the token itself
+	ErrGenerationUnavailable = errors.New("rights generation is unavailable")
+)
@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Message - webhook to be delivered to the subscriber endpoint
+type Message struct {
+	Id    string // Identifier of the message, the same for every attempt, so subscribers can deduplicate
+	Event string // Type of the event
+	Body  []byte // JSON encoded body
+}
+
+// NewRequest - returns POST request of the message signed with the secret at the timestamp
+func NewRequest(ctx context.Context, url string, secret []byte, message Message, timestamp time.Time) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(message.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderId, message.Id)
+	request.Header.Set(HeaderEvent, message.Event)
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	request.Header.Set(HeaderSignature, Sign(secret, timestamp, message.Body))
+	return request, nil
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of the webhook request
+const (
+	HeaderId        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signatureVersion - prefix of the signature, changes together with the signing scheme
+const signatureVersion = "v1="
+
+// DefaultTolerance - maximum age of the webhook accepted by Verify
+const DefaultTolerance = 5 * time.Minute
+
+// Sign - returns signature of the webhook body sent at the timestamp.
+// Signature is HMAC-SHA256 over "<unix timestamp>.<body>", so the timestamp
+// can not be replaced without invalidating the signature.
+func Sign(secret []byte, timestamp time.Time, body []byte) string {
+	return signatureVersion + hex.EncodeToString(digest(secret, strconv.FormatInt(timestamp.Unix(), 10), body))
+}
+
+// Verify - checks signature and timestamp headers of the received webhook.
+// Webhooks older or newer than tolerance are rejected to prevent replays.
+func Verify(secret []byte, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrExpired
+	}
+
+	encoded, ok := strings.CutPrefix(signature, signatureVersion)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	mac, err := hex.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal(mac, digest(secret, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func digest(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+var (
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+	ErrExpired          = errors.New("webhook timestamp is outside of the tolerance")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	secret = []byte("whsec_test")
+	body   = []byte(`{"event_type":"iam.user.created"}`)
+	now    = time.Unix(1737650000, 0)
+)
+
+func TestSign(t *testing.T) {
+	signature := Sign(secret, now, body)
+
+	assert.Regexp(t, `^v1=[0-9a-f]{64}$`, signature)
+	assert.Equal(t, signature, Sign(secret, now, body))
+	assert.NotEqual(t, signature, Sign(secret, now.Add(time.Second), body))
+	assert.NotEqual(t, signature, Sign([]byte("other"), now, body))
+}
+
+func TestVerify(t *testing.T) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign(secret, now, body)
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, Verify(secret, signature, timestamp, body, now.Add(time.Minute), DefaultTolerance))
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		err := Verify(secret, signature, timestamp, []byte(`{}`), now, DefaultTolerance)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("replaced timestamp", func(t *testing.T) {
+		replaced := strconv.FormatInt(now.Unix()+1, 10)
+		err := Verify(secret, signature, replaced, body, now, DefaultTolerance)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		err := Verify([]byte("other"), signature, timestamp, body, now, DefaultTolerance)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("malformed signature", func(t *testing.T) {
+		err := Verify(secret, "v1=zz", timestamp, body, now, DefaultTolerance)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+
+		err = Verify(secret, signature[3:], timestamp, body, now, DefaultTolerance)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		err := Verify(secret, signature, timestamp, body, now.Add(DefaultTolerance+time.Second), DefaultTolerance)
+		assert.ErrorIs(t, err, ErrExpired)
+	})
+
+	t.Run("from future", func(t *testing.T) {
+		err := Verify(secret, signature, timestamp, body, now.Add(-DefaultTolerance-time.Second), DefaultTolerance)
+		assert.ErrorIs(t, err, ErrExpired)
+	})
+
+	t.Run("invalid timestamp", func(t *testing.T) {
+		err := Verify(secret, signature, "yesterday", body, now, DefaultTolerance)
+		assert.ErrorIs(t, err, ErrInvalidTimestamp)
+	})
+}
+
+func TestNewRequest(t *testing.T) {
+	message := Message{Id: "42", Event: "iam.user.created", Body: body}
+
+	request, err := NewRequest(context.Background(), "https://example.com/hooks", secret, message, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "POST", request.Method)
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Equal(t, "42", request.Header.Get(HeaderId))
+	assert.Equal(t, "iam.user.created", request.Header.Get(HeaderEvent))
+
+	sent, err := io.ReadAll(request.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, sent)
+
+	err = Verify(secret, request.Header.Get(HeaderSignature), request.Header.Get(HeaderTimestamp), sent, now, DefaultTolerance)
+	assert.NoError(t, err)
+}
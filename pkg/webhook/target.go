@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInsecureTarget  = errors.New("webhook target must use https")
+	ErrForbiddenTarget = errors.New("webhook target address is forbidden")
+)
+
+// reservedPrefixes - special purpose networks not covered by the netip.Addr predicates
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64 translation of IPv4 addresses
+}
+
+// TargetPolicy - endpoints the webhooks may be sent to.
+// Zero policy allows only https endpoints at public addresses, so subscribers
+// can not make the sender call the services of its own network.
+type TargetPolicy struct {
+	AllowInsecure bool // allows http endpoints
+	AllowPrivate  bool // allows loopback, link-local, private and reserved addresses
+}
+
+// CheckURL - verifies the scheme of the url and every address its host resolves to.
+// Resolution may change later, so the client of the policy checks addresses again on dial.
+func (that TargetPolicy) CheckURL(ctx context.Context, resolver *net.Resolver, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	switch target.Scheme {
+	case "https":
+	case "http":
+		if !that.AllowInsecure {
+			return ErrInsecureTarget
+		}
+	default:
+		return fmt.Errorf("unsupported webhook target scheme %q", target.Scheme)
+	}
+
+	host := target.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return that.CheckAddr(addr)
+	}
+
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve webhook target: %w", err)
+	}
+	for _, addr := range addrs {
+		if err := that.CheckAddr(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckAddr - verifies the address is allowed by the policy
+func (that TargetPolicy) CheckAddr(addr netip.Addr) error {
+	if that.AllowPrivate {
+		return nil
+	}
+
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsUnspecified() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, addr)
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s", ErrForbiddenTarget, addr)
+		}
+	}
+	return nil
+}
+
+// control - checks the address right before the connection, after every resolution
+func (that TargetPolicy) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	return that.CheckAddr(addrPort.Addr())
+}
+
+// NewClient - returns client sending the webhooks within the policy.
+// Addresses are checked on dial, so DNS rebinding can not bypass the check of the url.
+// Proxies are not used and redirects are not followed, the redirect response fails the delivery.
+func NewClient(policy TargetPolicy, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: policy.control,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
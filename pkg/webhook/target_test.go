@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetPolicyCheckAddr(t *testing.T) {
+	forbidden := []string{
+		"127.0.0.1",
+		"::1",
+		"0.0.0.0",
+		"10.1.2.3",
+		"172.16.0.1",
+		"192.168.1.1",
+		"169.254.169.254",
+		"fe80::1",
+		"fd00::1",
+		"100.64.0.1",
+		"224.0.0.1",
+		"::ffff:127.0.0.1",
+		"::ffff:10.0.0.1",
+	}
+	for _, addr := range forbidden {
+		t.Run(addr, func(t *testing.T) {
+			err := TargetPolicy{}.CheckAddr(netip.MustParseAddr(addr))
+			assert.ErrorIs(t, err, ErrForbiddenTarget)
+			assert.NoError(t, TargetPolicy{AllowPrivate: true}.CheckAddr(netip.MustParseAddr(addr)))
+		})
+	}
+
+	for _, addr := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		t.Run(addr, func(t *testing.T) {
+			assert.NoError(t, TargetPolicy{}.CheckAddr(netip.MustParseAddr(addr)))
+		})
+	}
+}
+
+func TestTargetPolicyCheckURL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("public https", func(t *testing.T) {
+		require.NoError(t, TargetPolicy{}.CheckURL(ctx, nil, "https://93.184.216.34/hooks"))
+	})
+
+	t.Run("http", func(t *testing.T) {
+		err := TargetPolicy{}.CheckURL(ctx, nil, "http://93.184.216.34/hooks")
+		assert.ErrorIs(t, err, ErrInsecureTarget)
+		assert.NoError(t, TargetPolicy{AllowInsecure: true}.CheckURL(ctx, nil, "http://93.184.216.34/hooks"))
+	})
+
+	t.Run("private literal", func(t *testing.T) {
+		err := TargetPolicy{}.CheckURL(ctx, nil, "https://[::1]:8443/hooks")
+		assert.ErrorIs(t, err, ErrForbiddenTarget)
+	})
+
+	t.Run("resolved to loopback", func(t *testing.T) {
+		err := TargetPolicy{}.CheckURL(ctx, nil, "https://localhost/hooks")
+		assert.ErrorIs(t, err, ErrForbiddenTarget)
+	})
+
+	t.Run("scheme", func(t *testing.T) {
+		assert.Error(t, TargetPolicy{AllowInsecure: true}.CheckURL(ctx, nil, "ftp://93.184.216.34/hooks"))
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	t.Run("forbidden on dial", func(t *testing.T) {
+		client := NewClient(TargetPolicy{AllowInsecure: true}, time.Second)
+		_, err := client.Get(server.URL)
+		assert.ErrorIs(t, err, ErrForbiddenTarget)
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		client := NewClient(TargetPolicy{AllowInsecure: true, AllowPrivate: true}, time.Second)
+		response, err := client.Get(server.URL)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	})
+
+	t.Run("redirect is not followed", func(t *testing.T) {
+		client := NewClient(TargetPolicy{AllowInsecure: true, AllowPrivate: true}, time.Second)
+		response, err := client.Get(server.URL + "/redirect")
+		require.NoError(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusFound, response.StatusCode)
+	})
+}